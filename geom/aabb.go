@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+)
+
+// An AABB is an axis-aligned bounding box.  A box whose Min coordinates are
+// greater than its Max ones is empty.
+type AABB struct {
+	Min, Max Point
+}
+
+// EmptyAABB returns a box containing no point.  It is the identity element of
+// Union and Expand.
+func EmptyAABB() AABB {
+	inf := math.Inf(1)
+	return AABB{Point{inf, inf, inf}, Point{-inf, -inf, -inf}}
+}
+
+func (b *AABB) Empty() bool {
+	return b.Min.X > b.Max.X || b.Min.Y > b.Max.Y || b.Min.Z > b.Max.Z
+}
+
+// Union returns the smallest box containing both b and other.
+func (b *AABB) Union(other AABB) AABB {
+	return AABB{
+		Point{math.Min(b.Min.X, other.Min.X), math.Min(b.Min.Y, other.Min.Y), math.Min(b.Min.Z, other.Min.Z)},
+		Point{math.Max(b.Max.X, other.Max.X), math.Max(b.Max.Y, other.Max.Y), math.Max(b.Max.Z, other.Max.Z)},
+	}
+}
+
+// Expand returns the smallest box containing both b and p.
+func (b *AABB) Expand(p Point) AABB {
+	return b.Union(AABB{p, p})
+}
+
+// Contains returns whether p lies inside b or on its boundary.
+func (b *AABB) Contains(p *Point) bool {
+	return b.Min.X <= p.X && p.X <= b.Max.X &&
+		b.Min.Y <= p.Y && p.Y <= b.Max.Y &&
+		b.Min.Z <= p.Z && p.Z <= b.Max.Z
+}
+
+// Return the parameters along r of the points where r enters and exits b,
+// clipped to [r.TMin..r.TMax].  Set ok to false if r misses b.
+//
+// Uses the slab method: r is clipped successively against the pair of planes
+// bounding b along each axis.
+func RayAABBIntersection(b AABB, r Ray) (tmin, tmax float64, ok bool) {
+	tmin, tmax = r.TMin, r.TMax
+	slabs := [3]struct{ o, d, min, max float64 }{
+		{r.Origin.X, r.Dir.X, b.Min.X, b.Max.X},
+		{r.Origin.Y, r.Dir.Y, b.Min.Y, b.Max.Y},
+		{r.Origin.Z, r.Dir.Z, b.Min.Z, b.Max.Z},
+	}
+	for _, s := range slabs {
+		if s.d == 0 {
+			// r parallel to slab
+			if s.o < s.min || s.o > s.max {
+				return 0, 0, false
+			}
+			continue
+		}
+		inv := 1 / s.d
+		t0 := (s.min - s.o) * inv
+		t1 := (s.max - s.o) * inv
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		tmin = math.Max(tmin, t0)
+		tmax = math.Min(tmax, t1)
+		if tmin > tmax {
+			return 0, 0, false
+		}
+	}
+	return tmin, tmax, true
+}
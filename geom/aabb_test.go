@@ -0,0 +1,89 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+func TestAABBUnionExpand(t *testing.T) {
+	b := EmptyAABB()
+	if !b.Empty() {
+		t.Fatalf("empty box not empty: %v", b)
+	}
+	b = b.Expand(Point{1, 2, 3})
+	b = b.Expand(Point{-1, 4, 0})
+	exp := AABB{Point{-1, 2, 0}, Point{1, 4, 3}}
+	if b != exp {
+		t.Fatalf("exp: %v act: %v", exp, b)
+	}
+
+	b = b.Union(AABB{Point{0, 0, 0}, Point{5, 1, 1}})
+	exp = AABB{Point{-1, 0, 0}, Point{5, 4, 3}}
+	if b != exp {
+		t.Fatalf("exp: %v act: %v", exp, b)
+	}
+}
+
+func TestAABBContains(t *testing.T) {
+	b := AABB{Point{0, 0, 0}, Point{1, 2, 3}}
+	for _, td := range []struct {
+		p      Point
+		inside bool
+	}{
+		{Point{0.5, 1, 1}, true},
+		{Point{1, 2, 3}, true},
+		{Point{1.1, 1, 1}, false},
+		{Point{0.5, -1, 1}, false},
+	} {
+		if act := b.Contains(&td.p); act != td.inside {
+			t.Fatalf("%v: exp: %v act: %v", td.p, td.inside, act)
+		}
+	}
+}
+
+func TestRayAABBIntersection(t *testing.T) {
+	b := AABB{Point{-1, -1, 4}, Point{1, 1, 6}}
+	for _, td := range []struct {
+		r          Ray
+		intersect  bool
+		tmin, tmax float64
+	}{
+		{MakeRay(Origin, Vector{0, 0, 1}), true, 4, 6},
+		{MakeRay(Origin, Vector{0, 0, 2}), true, 2, 3},
+		{MakeRay(Point{0, 0, 5}, Vector{0, 0, 1}), true, 0, 1},
+		{MakeRay(Point{0, 0, 10}, Vector{0, 0, -1}), true, 4, 6},
+		{MakeRay(Origin, Vector{0, 1, 0}), false, 0, 0},
+		{MakeRay(Point{2, 0, 0}, Vector{0, 0, 1}), false, 0, 0},
+		{MakeRay(Point{0, 0, 10}, Vector{0, 0, 1}), false, 0, 0},
+		{MakeRay(Point{-3, 0, 5}, Vector{1, 0, 0}), true, 2, 4},
+	} {
+		tmin, tmax, ok := RayAABBIntersection(b, td.r)
+		if ok != td.intersect {
+			t.Fatalf("%v: bad ok: exp: %v act: %v", td.r, td.intersect, ok)
+		}
+		if ok && (!FloatsEqual(tmin, td.tmin, epsilon) || !FloatsEqual(tmax, td.tmax, epsilon)) {
+			t.Fatalf("%v: exp: [%v, %v] act: [%v, %v]", td.r, td.tmin, td.tmax, tmin, tmax)
+		}
+	}
+}
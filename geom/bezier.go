@@ -0,0 +1,80 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+// A BezierPatch is a bicubic Bézier patch defined by a 4x4 grid of control
+// points stored row by row.  The parameter u runs along rows and v across
+// them.
+type BezierPatch [16]Point
+
+// bernstein returns the cubic Bernstein polynomials and their derivatives at
+// t.
+func bernstein(t float64) (b, db [4]float64) {
+	s := 1 - t
+	b = [4]float64{s * s * s, 3 * t * s * s, 3 * t * t * s, t * t * t}
+	db = [4]float64{-3 * s * s, 3*s*s - 6*t*s, 6*t*s - 3*t*t, 3 * t * t}
+	return b, db
+}
+
+// Evaluate returns the point of bp of parameters (u, v) in [0..1] and the
+// partial derivatives of bp at this point.
+func (bp *BezierPatch) Evaluate(u, v float64) (p Point, du, dv Vector) {
+	bu, dbu := bernstein(u)
+	bv, dbv := bernstein(v)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			c := Vector(bp[4*i+j])
+			p = p.Translate(c.Scale(bv[i] * bu[j]))
+			du = du.Add(c.Scale(bv[i] * dbu[j]))
+			dv = dv.Add(c.Scale(dbv[i] * bu[j]))
+		}
+	}
+	return p, du, dv
+}
+
+// NormalAt returns the unit normal vector of bp at parameters (u, v), oriented
+// along the cross product of the u and v derivatives.  Where bp is degenerate,
+// as at the pole of a patch whose control points collapse, the normal is taken
+// at a nearby point instead.
+func (bp *BezierPatch) NormalAt(u, v float64) Vector {
+	const nudge = 1e-4
+	for k := 0; k < 2; k++ {
+		_, du, dv := bp.Evaluate(u, v)
+		if n := CrossProduct(&du, &dv); n.Module() > 1e-12 {
+			return n.UnitVector()
+		}
+		u += nudge * (0.5 - u)
+		v += nudge * (0.5 - v)
+	}
+	return Vector{}
+}
+
+// Bounds returns a box enclosing bp, which lies inside the convex hull of its
+// control points.
+func (bp *BezierPatch) Bounds() AABB {
+	b := EmptyAABB()
+	for _, c := range bp {
+		b = b.Expand(c)
+	}
+	return b
+}
@@ -0,0 +1,61 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+func TestBezierPatchEvaluate(t *testing.T) {
+	// Flat patch covering [0..3]x[0..3] in the z = 0 plane.
+	var bp BezierPatch
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			bp[4*i+j] = Point{float64(j), float64(i), 0}
+		}
+	}
+	p, du, dv := bp.Evaluate(0.5, 0.5)
+	if exp := (Point{1.5, 1.5, 0}); !PointsEqual(p, exp, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, p)
+	}
+	if exp := (Vector{3, 0, 0}); !VectorsEqual(du, exp, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, du)
+	}
+	if exp := (Vector{0, 3, 0}); !VectorsEqual(dv, exp, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, dv)
+	}
+	if n, exp := bp.NormalAt(0.2, 0.7), (Vector{0, 0, 1}); !VectorsEqual(n, exp, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, n)
+	}
+	if b, exp := bp.Bounds(), (AABB{Point{0, 0, 0}, Point{3, 3, 0}}); b != exp {
+		t.Errorf("exp: %v act: %v", exp, b)
+	}
+
+	// Collapse the first row to a pole: the normal there is still defined.
+	for j := 0; j < 4; j++ {
+		bp[j] = Point{1.5, 0, 0}
+	}
+	if n := bp.NormalAt(0.5, 0); !VectorsEqual(n, Vector{0, 0, 1}, 1e-6) {
+		t.Errorf("exp: %v act: %v", Vector{0, 0, 1}, n)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Cone is a truncated cone closed by two disk caps.  It is a true cone when
+// one of the radii is null, in which case the corresponding cap vanishes.
+type Cone struct {
+	Base       Point  // center of the bottom cap
+	Axis       Vector // from Base to the center of the top cap
+	BaseRadius float64
+	TopRadius  float64
+}
+
+func (c *Cone) Validate() error {
+	if c.BaseRadius < 0 || c.TopRadius < 0 {
+		return fmt.Errorf("invalid cone: negative radius")
+	}
+	if c.BaseRadius == 0 && c.TopRadius == 0 {
+		return fmt.Errorf("invalid cone: null radii")
+	}
+	if c.Axis.Module() == 0 {
+		return fmt.Errorf("invalid cone: null axis")
+	}
+	return nil
+}
+
+// Contains returns whether p lies inside c or on its surface.
+func (c *Cone) Contains(p *Point) bool {
+	v := MakeVector(*p, c.Base)
+	baba := DotProduct(&c.Axis, &c.Axis)
+	bav := DotProduct(&c.Axis, &v)
+	if bav < 0 || bav > baba {
+		return false
+	}
+	// Squared distance between p and the axis.
+	d2 := DotProduct(&v, &v) - bav*bav/baba
+	r := c.radiusAt(bav / baba)
+	return d2 <= r*r
+}
+
+// radiusAt returns the radius of the section of c at s*c.Axis from c.Base.
+func (c *Cone) radiusAt(s float64) float64 {
+	return c.BaseRadius + (c.TopRadius-c.BaseRadius)*s
+}
+
+// sideHits adds to h the intersections between r and the lateral surface of c.
+func (c *Cone) sideHits(h *hit, r *Ray) {
+	oc := MakeVector(r.Origin, c.Base)
+	baba := DotProduct(&c.Axis, &c.Axis)
+	bard := DotProduct(&c.Axis, &r.Dir)
+	baoc := DotProduct(&c.Axis, &oc)
+	dr := c.TopRadius - c.BaseRadius
+
+	// The radius of the section containing r.At(t) is g + k*t.  The quadratic
+	// in t equates it with the distance between r.At(t) and the axis.
+	g := c.BaseRadius + dr*baoc/baba
+	k := dr * bard / baba
+	qa := baba*DotProduct(&r.Dir, &r.Dir) - bard*bard - baba*k*k
+	qb := 2 * (baba*DotProduct(&oc, &r.Dir) - baoc*bard - baba*g*k)
+	qc := baba*DotProduct(&oc, &oc) - baoc*baoc - baba*g*g
+	t0, t1, ok := SolveQuadratic(qa, qb, qc)
+	if !ok {
+		return
+	}
+	for _, t := range [...]float64{t0, t1} {
+		s := (baoc + t*bard) / baba
+		if s < 0 || s > 1 {
+			continue
+		}
+		p := r.At(t)
+		h.add(r, t, c.SideNormalAt(&p))
+	}
+}
+
+// SideNormalAt returns the outward unit normal vector of the lateral surface of
+// c at p.  The normal at the apex of a true cone is the axis.
+func (c *Cone) SideNormalAt(p *Point) Vector {
+	up := c.Axis.UnitVector()
+	v := MakeVector(*p, c.Base)
+	radial := v.Sub(up.Scale(DotProduct(&v, &up)))
+	dr := c.TopRadius - c.BaseRadius
+	if radial.Module() == 0 {
+		return up.Scale(-math.Copysign(1, dr))
+	}
+	radial = radial.UnitVector()
+	// Tilt the radial direction against the axis where the cone widens.
+	n := radial.Sub(up.Scale(dr / c.Axis.Module()))
+	return n.UnitVector()
+}
+
+// Return the point of r nearest from r.Origin intersecting c, in
+// [r.TMin..r.TMax].  Set ok to false if there is no intersection.  t is the
+// parameter of the intersection point along r and n the outward unit normal
+// vector of c at this point.
+func RayConeIntersection(c Cone, r Ray) (p Point, t float64, n Vector, ok bool) {
+	var h hit
+	c.sideHits(&h, &r)
+	up := c.Axis.UnitVector()
+	if c.BaseRadius > 0 {
+		diskHit(&h, &r, c.Base, up.Neg(), c.BaseRadius)
+	}
+	if c.TopRadius > 0 {
+		diskHit(&h, &r, c.Base.Translate(c.Axis), up, c.TopRadius)
+	}
+	if !h.ok {
+		return Origin, math.MaxFloat64, Vector{}, false
+	}
+	return r.At(h.t), h.t, h.normal, true
+}
+
+// RayConeSideIntersection is like RayConeIntersection for the lateral surface
+// of c only.  n points outside c even when the inner side of the surface is
+// hit.
+func RayConeSideIntersection(c Cone, r Ray) (p Point, t float64, n Vector, ok bool) {
+	var h hit
+	c.sideHits(&h, &r)
+	if !h.ok {
+		return Origin, math.MaxFloat64, Vector{}, false
+	}
+	return r.At(h.t), h.t, h.normal, true
+}
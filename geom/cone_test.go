@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRayConeIntersection(t *testing.T) {
+	// Vertical cone of base radius 2 on y == 0 and apex (0, 2, 0).
+	c := Cone{Point{0, 0, 0}, Vector{0, 2, 0}, 2, 0}
+	n := Vector{1, 1, 0}
+	n = n.UnitVector()
+	for _, td := range []solidTestCase{
+		{MakeRay(Point{5, 1, 0}, Vector{-1, 0, 0}), true, Point{1, 1, 0}, n},
+		{MakeRay(Point{1, -5, 0}, Vector{0, 1, 0}), true, Point{1, 0, 0}, Vector{0, -1, 0}},
+		{MakeRay(Point{0, 10, 0}, Vector{0, -1, 0}), true, Point{0, 2, 0}, Vector{0, 1, 0}},
+		{MakeRay(Point{5, 1.5, 1}, Vector{-1, 0, 0}), false, Origin, Vector{}},
+		// Would hit the mirror cone beyond the apex.
+		{MakeRay(Point{5, 3, 0}, Vector{-1, 0, 0}), false, Origin, Vector{}},
+	} {
+		checkSolidHit(t, td, func(r Ray) (Point, float64, Vector, bool) {
+			return RayConeIntersection(c, r)
+		})
+	}
+}
+
+func TestRayConeFrustumIntersection(t *testing.T) {
+	// Truncated cone of radii 2 at y == 0 and 1 at y == 2.
+	c := Cone{Point{0, 0, 0}, Vector{0, 2, 0}, 2, 1}
+	n := Vector{2, 1, 0}
+	n = n.UnitVector()
+	for _, td := range []solidTestCase{
+		{MakeRay(Point{5, 1, 0}, Vector{-1, 0, 0}), true, Point{1.5, 1, 0}, n},
+		{MakeRay(Point{0.5, 10, 0}, Vector{0, -1, 0}), true, Point{0.5, 2, 0}, Vector{0, 1, 0}},
+		{MakeRay(Point{1.5, 10, 0}, Vector{0, -1, 0}), true, Point{1.5, 1, 0}, n},
+	} {
+		checkSolidHit(t, td, func(r Ray) (Point, float64, Vector, bool) {
+			return RayConeIntersection(c, r)
+		})
+	}
+
+	// Inner side seen through the missing caps.
+	td := solidTestCase{MakeRay(Point{0, 10, 0}, Vector{0, -1, 0}), false, Origin, Vector{}}
+	checkSolidHit(t, td, func(r Ray) (Point, float64, Vector, bool) {
+		return RayConeSideIntersection(c, r)
+	})
+	td = solidTestCase{MakeRay(Point{0, 1, 0}, Vector{1, 0, 0}), true, Point{1.5, 1, 0}, n}
+	checkSolidHit(t, td, func(r Ray) (Point, float64, Vector, bool) {
+		return RayConeSideIntersection(c, r)
+	})
+}
+
+func TestConeContains(t *testing.T) {
+	c := Cone{Point{0, 0, 0}, Vector{0, 2, 0}, 2, 0}
+	for _, td := range []struct {
+		p   Point
+		exp bool
+	}{
+		{Point{0, 1, 0}, true},
+		{Point{0.9, 1, 0}, true},
+		{Point{1.1, 1, 0}, false},
+		{Point{0, 2.1, 0}, false},
+		{Point{0, -0.1, 0}, false},
+		{Point{0, 1, -math.Sqrt(0.5)}, true},
+	} {
+		if act := c.Contains(&td.p); act != td.exp {
+			t.Errorf("%v: exp: %v act: %v", td.p, td.exp, act)
+		}
+	}
+}
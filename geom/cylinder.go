@@ -0,0 +1,198 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Cylinder is a finite cylinder closed by two disk caps.
+type Cylinder struct {
+	Base   Point  // center of the bottom cap
+	Axis   Vector // from Base to the center of the top cap
+	Radius float64
+}
+
+func (c *Cylinder) Validate() error {
+	if c.Radius <= 0 {
+		return fmt.Errorf("invalid cylinder: negative or null radius")
+	}
+	if c.Axis.Module() == 0 {
+		return fmt.Errorf("invalid cylinder: null axis")
+	}
+	return nil
+}
+
+// Contains returns whether p lies inside c or on its surface.
+func (c *Cylinder) Contains(p *Point) bool {
+	v := MakeVector(*p, c.Base)
+	baba := DotProduct(&c.Axis, &c.Axis)
+	bav := DotProduct(&c.Axis, &v)
+	if bav < 0 || bav > baba {
+		return false
+	}
+	// Squared distance between p and the axis.
+	d2 := DotProduct(&v, &v) - bav*bav/baba
+	return d2 <= c.Radius*c.Radius
+}
+
+// A Capsule is a cylinder capped by two hemispheres, that is the set of points
+// within Radius of segment [A, B].
+type Capsule struct {
+	A, B   Point
+	Radius float64
+}
+
+func (c *Capsule) Validate() error {
+	if c.Radius <= 0 {
+		return fmt.Errorf("invalid capsule: negative or null radius")
+	}
+	return nil
+}
+
+// hit accumulates the nearest of a set of candidate intersections.
+type hit struct {
+	t      float64
+	normal Vector
+	ok     bool
+}
+
+func (h *hit) add(r *Ray, t float64, normal Vector) {
+	if t < r.TMin || t > r.TMax || (h.ok && t >= h.t) {
+		return
+	}
+	h.t = t
+	h.normal = normal
+	h.ok = true
+}
+
+// tubeHits adds to h the intersections between r and the infinite tube of the
+// given radius around the line a + s*axis that satisfy 0 <= s <= 1.
+func tubeHits(h *hit, r *Ray, a Point, axis Vector, radius float64) {
+	oc := MakeVector(r.Origin, a)
+	baba := DotProduct(&axis, &axis)
+	bard := DotProduct(&axis, &r.Dir)
+	baoc := DotProduct(&axis, &oc)
+
+	// Quadratic in t obtained by projecting oc + t*r.Dir orthogonally to axis.
+	qa := baba*DotProduct(&r.Dir, &r.Dir) - bard*bard
+	qb := 2 * (baba*DotProduct(&oc, &r.Dir) - baoc*bard)
+	qc := baba*DotProduct(&oc, &oc) - baoc*baoc - radius*radius*baba
+	if qa == 0 {
+		// r parallel to axis
+		return
+	}
+	t0, t1, ok := SolveQuadratic(qa, qb, qc)
+	if !ok {
+		return
+	}
+	for _, t := range [...]float64{t0, t1} {
+		s := (baoc + t*bard) / baba
+		if s < 0 || s > 1 {
+			continue
+		}
+		p := r.At(t)
+		onAxis := a.Translate(axis.Scale(s))
+		n := MakeVector(p, onAxis)
+		h.add(r, t, n.Scale(1/radius))
+	}
+}
+
+// diskHit adds to h the intersection between r and the disk of the given
+// center, outward normal and radius.
+func diskHit(h *hit, r *Ray, center Point, normal Vector, radius float64) {
+	p, t, ok := RayPlaneIntersection(center, normal, *r)
+	if !ok {
+		return
+	}
+	d := MakeVector(p, center)
+	if DotProduct(&d, &d) <= radius*radius {
+		h.add(r, t, normal)
+	}
+}
+
+// Return the point of r nearest from r.Origin intersecting c, in
+// [r.TMin..r.TMax].  Set ok to false if there is no intersection.  t is the
+// parameter of the intersection point along r and n the outward unit normal
+// vector of c at this point.
+func RayCylinderIntersection(c Cylinder, r Ray) (p Point, t float64, n Vector, ok bool) {
+	var h hit
+	tubeHits(&h, &r, c.Base, c.Axis, c.Radius)
+	up := c.Axis.UnitVector()
+	diskHit(&h, &r, c.Base, up.Neg(), c.Radius)
+	diskHit(&h, &r, c.Base.Translate(c.Axis), up, c.Radius)
+	if !h.ok {
+		return Origin, math.MaxFloat64, Vector{}, false
+	}
+	return r.At(h.t), h.t, h.normal, true
+}
+
+// RayTubeIntersection is like RayCylinderIntersection for the open cylinder
+// obtained by removing the caps of c.  n points outside c even when the inner
+// side of the tube is hit.
+func RayTubeIntersection(c Cylinder, r Ray) (p Point, t float64, n Vector, ok bool) {
+	var h hit
+	tubeHits(&h, &r, c.Base, c.Axis, c.Radius)
+	if !h.ok {
+		return Origin, math.MaxFloat64, Vector{}, false
+	}
+	return r.At(h.t), h.t, h.normal, true
+}
+
+// Return the point of r nearest from r.Origin intersecting c, in
+// [r.TMin..r.TMax].  Set ok to false if there is no intersection.  t is the
+// parameter of the intersection point along r and n the outward unit normal
+// vector of c at this point.
+func RayCapsuleIntersection(c Capsule, r Ray) (p Point, t float64, n Vector, ok bool) {
+	var h hit
+	axis := MakeVector(c.B, c.A)
+	if axis.Module() > 0 {
+		tubeHits(&h, &r, c.A, axis, c.Radius)
+	}
+
+	// Only the outer hemisphere of each end sphere is part of the surface.
+	for _, end := range [...]struct {
+		center Point
+		sign   float64
+	}{{c.A, -1}, {c.B, 1}} {
+		s := Sphere{end.center, c.Radius}
+		t0, t1, hitSphere := RaySphereRoots(s, r)
+		if !hitSphere {
+			continue
+		}
+		for _, t := range [...]float64{t0, t1} {
+			p := r.At(t)
+			v := MakeVector(p, end.center)
+			if end.sign*DotProduct(&v, &axis) < 0 {
+				continue
+			}
+			h.add(&r, t, v.Scale(1/c.Radius))
+		}
+	}
+
+	if !h.ok {
+		return Origin, math.MaxFloat64, Vector{}, false
+	}
+	return r.At(h.t), h.t, h.normal, true
+}
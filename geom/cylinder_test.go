@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+type solidTestCase struct {
+	r         Ray
+	intersect bool
+	p         Point
+	n         Vector
+}
+
+func TestRayCylinderIntersection(t *testing.T) {
+	// Vertical cylinder of radius 1 from y == 0 to y == 4 centered on x == 5.
+	c := Cylinder{Point{5, 0, 0}, Vector{0, 4, 0}, 1}
+	for _, td := range []solidTestCase{
+		{MakeRay(Point{0, 2, 0}, Vector{1, 0, 0}), true, Point{4, 2, 0}, Vector{-1, 0, 0}},
+		{MakeRay(Point{5, 10, 0}, Vector{0, -1, 0}), true, Point{5, 4, 0}, Vector{0, 1, 0}},
+		{MakeRay(Point{5.5, -3, 0}, Vector{0, 1, 0}), true, Point{5.5, 0, 0}, Vector{0, -1, 0}},
+		{MakeRay(Point{5, 2, 0}, Vector{0, 0, 1}), true, Point{5, 2, 1}, Vector{0, 0, 1}},
+		{MakeRay(Point{0, 5, 0}, Vector{1, 0, 0}), false, Origin, Vector{}},
+		{MakeRay(Point{0, 2, 3}, Vector{1, 0, 0}), false, Origin, Vector{}},
+		{MakeRay(Point{7, 10, 0}, Vector{0, -1, 0}), false, Origin, Vector{}},
+		{MakeRay(Point{0, 2, 0}, Vector{-1, 0, 0}), false, Origin, Vector{}},
+	} {
+		checkSolidHit(t, td, func(r Ray) (Point, float64, Vector, bool) {
+			return RayCylinderIntersection(c, r)
+		})
+	}
+}
+
+func TestRayTubeIntersection(t *testing.T) {
+	c := Cylinder{Point{5, 0, 0}, Vector{0, 4, 0}, 1}
+	for _, td := range []solidTestCase{
+		{MakeRay(Point{0, 2, 0}, Vector{1, 0, 0}), true, Point{4, 2, 0}, Vector{-1, 0, 0}},
+		{MakeRay(Point{5, 2, 0}, Vector{1, 0, 0}), true, Point{6, 2, 0}, Vector{1, 0, 0}},
+		{MakeRay(Point{5.5, 10, 0}, Vector{0, -1, 0}), false, Origin, Vector{}},
+		{MakeRay(Point{5, 7, 0}, Vector{0.5, -2, 0}), true, Point{6, 3, 0}, Vector{1, 0, 0}},
+	} {
+		checkSolidHit(t, td, func(r Ray) (Point, float64, Vector, bool) {
+			return RayTubeIntersection(c, r)
+		})
+	}
+}
+
+func TestCylinderContains(t *testing.T) {
+	c := Cylinder{Point{5, 0, 0}, Vector{0, 4, 0}, 1}
+	for _, td := range []struct {
+		p   Point
+		exp bool
+	}{
+		{Point{5, 2, 0}, true},
+		{Point{5.5, 0, 0.5}, true},
+		{Point{5, 4, 0}, true},
+		{Point{5, 4.1, 0}, false},
+		{Point{5, -0.1, 0}, false},
+		{Point{6, 2, 0.5}, false},
+	} {
+		if act := c.Contains(&td.p); act != td.exp {
+			t.Errorf("%v: exp: %v act: %v", td.p, td.exp, act)
+		}
+	}
+}
+
+func TestRayCapsuleIntersection(t *testing.T) {
+	c := Capsule{Point{0, 0, 0}, Point{0, 4, 0}, 1}
+	for _, td := range []solidTestCase{
+		{MakeRay(Point{-5, 2, 0}, Vector{1, 0, 0}), true, Point{-1, 2, 0}, Vector{-1, 0, 0}},
+		{MakeRay(Point{0, 10, 0}, Vector{0, -1, 0}), true, Point{0, 5, 0}, Vector{0, 1, 0}},
+		{MakeRay(Point{0, -10, 0}, Vector{0, 1, 0}), true, Point{0, -1, 0}, Vector{0, -1, 0}},
+		{MakeRay(Point{0, 2, 0}, Vector{0, 1, 0}), true, Point{0, 5, 0}, Vector{0, 1, 0}},
+		{MakeRay(Point{-5, 5.5, 0}, Vector{1, 0, 0}), false, Origin, Vector{}},
+		{MakeRay(Point{-5, 2, 2}, Vector{1, 0, 0}), false, Origin, Vector{}},
+	} {
+		checkSolidHit(t, td, func(r Ray) (Point, float64, Vector, bool) {
+			return RayCapsuleIntersection(c, r)
+		})
+	}
+}
+
+func checkSolidHit(t *testing.T, td solidTestCase, intersect func(Ray) (Point, float64, Vector, bool)) {
+	p, tt, n, ok := intersect(td.r)
+	if ok != td.intersect {
+		t.Fatalf("%v: bad ok: exp: %v act: %v", td.r, td.intersect, ok)
+	}
+	if !ok {
+		return
+	}
+	if !PointsEqual(p, td.p, epsilon) || !PointsEqual(td.r.At(tt), td.p, epsilon) {
+		t.Fatalf("%v: bad intersection: exp: %v act: %v", td.r, td.p, p)
+	}
+	if !VectorsEqual(n, td.n, epsilon) {
+		t.Fatalf("%v: bad normal: exp: %v act: %v", td.r, td.n, n)
+	}
+}
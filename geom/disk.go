@@ -0,0 +1,71 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Disk is a flat disk whose front face is the one Normal points to.
+type Disk struct {
+	Center Point
+	Normal Vector
+	Radius float64
+}
+
+func (d *Disk) Validate() error {
+	if d.Radius <= 0 {
+		return fmt.Errorf("invalid disk: negative or null radius")
+	}
+	if d.Normal.Module() == 0 {
+		return fmt.Errorf("invalid disk: null normal")
+	}
+	return nil
+}
+
+// Bounds returns the smallest box enclosing d.
+func (d *Disk) Bounds() AABB {
+	n := d.Normal.UnitVector()
+	e := Vector{
+		d.Radius * math.Sqrt(math.Max(0, 1-n.X*n.X)),
+		d.Radius * math.Sqrt(math.Max(0, 1-n.Y*n.Y)),
+		d.Radius * math.Sqrt(math.Max(0, 1-n.Z*n.Z)),
+	}
+	return AABB{d.Center.Translate(e.Neg()), d.Center.Translate(e)}
+}
+
+// Return the point where r intersects d, in [r.TMin..r.TMax].  Set ok to
+// false if there is no intersection.  t is the parameter of the intersection
+// point along r.
+func RayDiskIntersection(d Disk, r Ray) (p Point, t float64, ok bool) {
+	p, t, ok = RayPlaneIntersection(d.Center, d.Normal, r)
+	if !ok {
+		return
+	}
+	v := MakeVector(p, d.Center)
+	if DotProduct(&v, &v) > d.Radius*d.Radius {
+		return Origin, math.MaxFloat64, false
+	}
+	return
+}
@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+func TestRayDiskIntersection(t *testing.T) {
+	d := Disk{Point{0, 0, 10}, Vector{0, 0, -2}, 3}
+	for i, td := range []struct {
+		r  Ray
+		ok bool
+		p  Point
+	}{
+		{MakeRay(Point{0, 0, 0}, Vector{0, 0, 1}), true, Point{0, 0, 10}},
+		{MakeRay(Point{2, 2, 0}, Vector{0, 0, 1}), true, Point{2, 2, 10}},
+		{MakeRay(Point{3, 3, 0}, Vector{0, 0, 1}), false, Origin},
+		{MakeRay(Point{0, 0, 20}, Vector{0, 0, 1}), false, Origin},
+		{MakeRay(Point{0, 0, 0}, Vector{1, 0, 0}), false, Origin},
+	} {
+		p, _, ok := RayDiskIntersection(d, td.r)
+		if ok != td.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, td.ok, ok)
+			continue
+		}
+		if ok && !PointsEqual(p, td.p, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, td.p, p)
+		}
+	}
+}
+
+func TestDiskBounds(t *testing.T) {
+	d := Disk{Point{1, 2, 3}, Vector{0, 5, 0}, 2}
+	exp := AABB{Point{-1, 2, 1}, Point{3, 2, 5}}
+	if act := d.Bounds(); !PointsEqual(act.Min, exp.Min, 1e-9) || !PointsEqual(act.Max, exp.Max, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+}
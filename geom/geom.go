@@ -59,6 +59,11 @@ type Point struct {
 	X, Y, Z float64
 }
 
+// Translate returns p moved by v.
+func (p *Point) Translate(v Vector) Point {
+	return Point{p.X + v.X, p.Y + v.Y, p.Z + v.Z}
+}
+
 func PointsEqual(lhs, rhs Point, epsilon float64) bool {
 	return FloatsEqual(lhs.X, rhs.X, epsilon) &&
 		FloatsEqual(lhs.Y, rhs.Y, epsilon) &&
@@ -67,9 +72,71 @@ func PointsEqual(lhs, rhs Point, epsilon float64) bool {
 
 var Origin = Point{0, 0, 0}
 
+func DistanceSquared(lhs, rhs Point) float64 {
+	v := MakeVector(lhs, rhs)
+	return DotProduct(&v, &v)
+}
+
+func Distance(lhs, rhs Point) float64 {
+	return math.Sqrt(DistanceSquared(lhs, rhs))
+}
+
+// LerpPoint interpolates linearly between a (t == 0) and b (t == 1).
+func LerpPoint(a, b Point, t float64) Point {
+	v := MakeVector(b, a)
+	return a.Translate(v.Scale(t))
+}
+
+// LerpVector interpolates linearly between a (t == 0) and b (t == 1).
+func LerpVector(a, b Vector, t float64) Vector {
+	d := b.Sub(a)
+	return a.Add(d.Scale(t))
+}
+
 // A Line is a line segment
 type Line [2]Point
 
+// PointLineDistance returns the distance between p and the infinite line
+// passing through l[0] and l[1].
+func PointLineDistance(p Point, l Line) float64 {
+	d := MakeVector(l[1], l[0])
+	v := MakeVector(p, l[0])
+	c := CrossProduct(&d, &v)
+	return c.Module() / d.Module()
+}
+
+// PointPlaneDistance returns the signed distance between p and the infinite
+// plane supporting pl.  It is positive on the side pl.Normal points to.
+func PointPlaneDistance(p Point, pl Plane) float64 {
+	n := pl.Normal.UnitVector()
+	v := MakeVector(p, pl.Point)
+	return DotProduct(&v, &n)
+}
+
+// Ray returns the ray starting at l[0] and passing through l[1] at t == 1.
+func (l *Line) Ray() Ray {
+	return MakeRay(l[0], MakeVector(l[1], l[0]))
+}
+
+// A Ray is a half-line made of points Origin + t*Dir with t in [TMin..TMax].
+// Dir is not necessarily a unit vector.
+type Ray struct {
+	Origin     Point
+	Dir        Vector
+	TMin, TMax float64 // parametric bounds
+}
+
+// MakeRay returns the ray starting at origin and extending infinitely in
+// direction dir.
+func MakeRay(origin Point, dir Vector) Ray {
+	return Ray{origin, dir, 0, math.Inf(1)}
+}
+
+// At returns the point of parameter t along r.
+func (r *Ray) At(t float64) Point {
+	return r.Origin.Translate(r.Dir.Scale(t))
+}
+
 type Vector struct {
 	X, Y, Z float64
 }
@@ -83,6 +150,22 @@ func (v *Vector) UnitVector() Vector {
 	return Vector{v.X / m, v.Y / m, v.Z / m}
 }
 
+func (v *Vector) Add(w Vector) Vector {
+	return Vector{v.X + w.X, v.Y + w.Y, v.Z + w.Z}
+}
+
+func (v *Vector) Sub(w Vector) Vector {
+	return Vector{v.X - w.X, v.Y - w.Y, v.Z - w.Z}
+}
+
+func (v *Vector) Scale(k float64) Vector {
+	return Vector{k * v.X, k * v.Y, k * v.Z}
+}
+
+func (v *Vector) Neg() Vector {
+	return Vector{-v.X, -v.Y, -v.Z}
+}
+
 func (v *Vector) Module() float64 {
 	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
 }
@@ -91,12 +174,79 @@ func DotProduct(lhs, rhs *Vector) float64 {
 	return lhs.X*rhs.X + lhs.Y*rhs.Y + lhs.Z*rhs.Z
 }
 
+func CrossProduct(lhs, rhs *Vector) Vector {
+	return Vector{
+		lhs.Y*rhs.Z - lhs.Z*rhs.Y,
+		lhs.Z*rhs.X - lhs.X*rhs.Z,
+		lhs.X*rhs.Y - lhs.Y*rhs.X,
+	}
+}
+
+// Reflect returns the reflection of incident off a surface of unit normal
+// vector normal.  incident points toward the surface.
+func Reflect(incident, normal Vector) Vector {
+	dot := DotProduct(&incident, &normal)
+	return incident.Sub(normal.Scale(2 * dot))
+}
+
+// Refract returns the direction of a ray of unit direction incident refracted
+// by a surface of unit normal vector normal, following Snell's law.  normal
+// points against incident.  eta is the ratio of the refraction index of the
+// medium incident comes from to the one of the medium it enters.  ok is false
+// in case of total internal reflection.
+func Refract(incident, normal Vector, eta float64) (v Vector, ok bool) {
+	cosi := -DotProduct(&incident, &normal)
+	k := 1 - eta*eta*(1-cosi*cosi)
+	if k < 0 {
+		return Vector{}, false
+	}
+	v = incident.Scale(eta)
+	return v.Add(normal.Scale(eta*cosi - math.Sqrt(k))), true
+}
+
 func VectorsEqual(lhs, rhs Vector, epsilon float64) bool {
 	return FloatsEqual(lhs.X, rhs.X, epsilon) &&
 		FloatsEqual(lhs.Y, rhs.Y, epsilon) &&
 		FloatsEqual(lhs.Z, rhs.Z, epsilon)
 }
 
+// An ONB is a right-handed orthonormal basis.
+type ONB struct {
+	U, V, W Vector
+}
+
+// MakeONB returns an orthonormal basis whose W axis is the direction of w.  U
+// and V are tangent directions chosen without branching on w so that they
+// vary continuously except across the w.Z == 0 plane.
+//
+// Algorithm taken from:
+// 	Duff et al., "Building an Orthonormal Basis, Revisited", JCGT 2017.
+func MakeONB(w Vector) ONB {
+	n := w.UnitVector()
+	sign := math.Copysign(1, n.Z)
+	a := -1 / (sign + n.Z)
+	b := n.X * n.Y * a
+	return ONB{
+		Vector{1 + sign*n.X*n.X*a, sign * b, -sign * n.X},
+		Vector{b, sign + n.Y*n.Y*a, -n.Y},
+		n,
+	}
+}
+
+// ToWorld converts v from coordinates in basis o to world coordinates.
+func (o *ONB) ToWorld(v Vector) Vector {
+	return Vector{
+		v.X*o.U.X + v.Y*o.V.X + v.Z*o.W.X,
+		v.X*o.U.Y + v.Y*o.V.Y + v.Z*o.W.Y,
+		v.X*o.U.Z + v.Y*o.V.Z + v.Z*o.W.Z,
+	}
+}
+
+// ToLocal converts v from world coordinates to coordinates in basis o.
+func (o *ONB) ToLocal(v Vector) Vector {
+	return Vector{DotProduct(&v, &o.U), DotProduct(&v, &o.V), DotProduct(&v, &o.W)}
+}
+
 type Sphere struct {
 	Center Point
 	Radius float64
@@ -109,6 +259,12 @@ func (s *Sphere) Validate() error {
 	return nil
 }
 
+// Contains returns whether p lies strictly inside s.
+func (s *Sphere) Contains(p *Point) bool {
+	v := MakeVector(*p, s.Center)
+	return DotProduct(&v, &v) < s.Radius*s.Radius
+}
+
 func (s *Sphere) NormalVectorAt(p *Point) Vector {
 	v := MakeVector(*p, s.Center)
 	return v.UnitVector()
@@ -116,43 +272,249 @@ func (s *Sphere) NormalVectorAt(p *Point) Vector {
 
 // Return the point nearest from l[0] intersecting s and l.  Set ok to false if
 // there is no intersection.  t is proportional to the distance between the
-// intersection point and l[0].
+// intersection point and l[0].  When l[0] lies inside s, the returned point is
+// where the line exits s in the l[0] to l[1] direction.  Intersections behind
+// l[0] are ignored.
+//
+// This is equivalent to RaySphereIntersection with l.Ray().
+func SphereLineIntersection(s Sphere, l Line) (p Point, t float64, ok bool) {
+	return RaySphereIntersection(s, l.Ray())
+}
+
+// UVAt maps point p of the surface of s to spherical texture coordinates in
+// [0..1].  u is the longitude, increasing counterclockwise around the y-axis
+// as seen from above, with u == 0.5 at the point facing -z.  v is the
+// latitude, 0 at the north (+y) pole and 1 at the south one, so that v grows
+// downward like image rows.
+func (s *Sphere) UVAt(p *Point) Point2d {
+	n := s.NormalVectorAt(p)
+	u := 0.5 + math.Atan2(n.X, -n.Z)/(2*math.Pi)
+	v := 0.5 - math.Asin(math.Max(-1, math.Min(1, n.Y)))/math.Pi
+	return Point2d{u, v}
+}
+
+// TangentAt returns the unit vector tangent to s at point p of its surface in
+// the direction in which the u texture coordinate grows (see UVAt).  It is
+// null at the poles.
+func (s *Sphere) TangentAt(p *Point) Vector {
+	n := s.NormalVectorAt(p)
+	t := Vector{-n.Z, 0, n.X}
+	if t.Module() == 0 {
+		return t
+	}
+	return t.UnitVector()
+}
+
+// Return the parameters t0 <= t1 of the points where the line supporting r
+// enters and exits s, regardless of r.TMin and r.TMax.  Set ok to false if the
+// line misses s.  t0 is negative when r.Origin lies inside s or past it.
 //
 // Formulas taken from:
 // 	http://www.ccs.neu.edu/home/fell/CSU540/programs/RayTracingFormulas.htm
-func SphereLineIntersection(s Sphere, l Line) (p Point, t float64, ok bool) {
+func RaySphereRoots(s Sphere, r Ray) (t0, t1 float64, ok bool) {
+	oc := MakeVector(r.Origin, s.Center)
+	a := DotProduct(&r.Dir, &r.Dir)
+	b := 2 * DotProduct(&r.Dir, &oc)
+	c := DotProduct(&oc, &oc) - s.Radius*s.Radius
+
+	t0, t1, ok = SolveQuadratic(a, b, c)
+	if !ok {
+		return math.MaxFloat64, math.MaxFloat64, false
+	}
+	return t0, t1, true
+}
+
+// Return the point of r nearest from r.Origin intersecting s.  Set ok to false
+// if there is no intersection.  t is the parameter of the intersection point
+// along r.  Only intersections with parameters in [r.TMin..r.TMax] are
+// considered so when r.Origin lies inside s, the returned point is where r
+// exits s.
+func RaySphereIntersection(s Sphere, r Ray) (p Point, t float64, ok bool) {
 	p = Origin
 	t = math.MaxFloat64
 	ok = false
 
-	dx := l[1].X - l[0].X
-	dy := l[1].Y - l[0].Y
-	dz := l[1].Z - l[0].Z
+	t0, t1, hit := RaySphereRoots(s, r)
+	if !hit {
+		return
+	}
 
-	a := dx*dx + dy*dy + dz*dz
+	// Use exit root if entry one is out of range, which happens in particular
+	// when r.Origin is inside s.
+	switch {
+	case r.TMin <= t0 && t0 <= r.TMax:
+		t = t0
+	case r.TMin <= t1 && t1 <= r.TMax:
+		t = t1
+	default:
+		return
+	}
+	ok = true
+	p = r.At(t)
 
-	b := 2*dx*(l[0].X-s.Center.X) +
-		2*dy*(l[0].Y-s.Center.Y) +
-		2*dz*(l[0].Z-s.Center.Z)
+	return
+}
 
-	c := s.Center.X*s.Center.X + s.Center.Y*s.Center.Y + s.Center.Z*s.Center.Z +
-		l[0].X*l[0].X + l[0].Y*l[0].Y + l[0].Z*l[0].Z -
-		2*(s.Center.X*l[0].X+s.Center.Y*l[0].Y+s.Center.Z*l[0].Z) -
-		s.Radius*s.Radius
+// Return the point where r intersects the infinite plane containing point
+// origin and orthogonal to normal.  Set ok to false if there is no
+// intersection, which includes the case where r is parallel to the plane.  t
+// is the parameter of the intersection point along r.
+func RayPlaneIntersection(origin Point, normal Vector, r Ray) (p Point, t float64, ok bool) {
+	denom := DotProduct(&normal, &r.Dir)
+	if denom == 0 {
+		return Origin, math.MaxFloat64, false
+	}
+	v := MakeVector(origin, r.Origin)
+	t = DotProduct(&normal, &v) / denom
+	if t < r.TMin || t > r.TMax {
+		return Origin, math.MaxFloat64, false
+	}
+	return r.At(t), t, true
+}
 
-	d := b*b - 4*a*c
+// A Triangle is defined by its 3 vertices.  Its front face is the one from
+// which vertices are seen in counterclockwise order.
+type Triangle [3]Point
 
-	if d < 0 {
+// Normal returns the unit normal vector of the front face of tr.
+func (tr *Triangle) Normal() Vector {
+	e1 := MakeVector(tr[1], tr[0])
+	e2 := MakeVector(tr[2], tr[0])
+	n := CrossProduct(&e1, &e2)
+	return n.UnitVector()
+}
+
+// Barycentric returns the barycentric coordinates (u, v) of p relative to
+// tr[1] and tr[2], with the same convention as RayTriangleIntersection: p ==
+// (1-u-v)*tr[0] + u*tr[1] + v*tr[2].  p is projected onto the plane of tr
+// first.  p lies inside tr if u >= 0, v >= 0 and u+v <= 1.  The result is
+// undefined if tr is degenerate.
+//
+// Algorithm taken from:
+// 	Ericson, "Real-Time Collision Detection", section 3.4.
+func (tr *Triangle) Barycentric(p Point) (u, v float64) {
+	v0 := MakeVector(tr[1], tr[0])
+	v1 := MakeVector(tr[2], tr[0])
+	v2 := MakeVector(p, tr[0])
+	d00 := DotProduct(&v0, &v0)
+	d01 := DotProduct(&v0, &v1)
+	d11 := DotProduct(&v1, &v1)
+	d20 := DotProduct(&v2, &v0)
+	d21 := DotProduct(&v2, &v1)
+	denom := d00*d11 - d01*d01
+	u = (d11*d20 - d01*d21) / denom
+	v = (d00*d21 - d01*d20) / denom
+	return u, v
+}
+
+// InterpolateFloat interpolates values a, b and c attached to the vertices of
+// a triangle at the point of barycentric coordinates (u, v).
+func InterpolateFloat(a, b, c, u, v float64) float64 {
+	return (1-u-v)*a + u*b + v*c
+}
+
+// InterpolateVector is like InterpolateFloat for vectors such as vertex
+// normals.  The result is not normalized.
+func InterpolateVector(a, b, c Vector, u, v float64) Vector {
+	return Vector{
+		InterpolateFloat(a.X, b.X, c.X, u, v),
+		InterpolateFloat(a.Y, b.Y, c.Y, u, v),
+		InterpolateFloat(a.Z, b.Z, c.Z, u, v),
+	}
+}
+
+// InterpolatePoint2d is like InterpolateFloat for 2D points such as texture
+// coordinates.
+func InterpolatePoint2d(a, b, c Point2d, u, v float64) Point2d {
+	return Point2d{
+		InterpolateFloat(a.X, b.X, c.X, u, v),
+		InterpolateFloat(a.Y, b.Y, c.Y, u, v),
+	}
+}
+
+// Return the point where r intersects tr.  Set ok to false if there is no
+// intersection.  t is the parameter of the intersection point along r.  (u, v)
+// are the barycentric coordinates of the intersection point relative to tr[1]
+// and tr[2], that is p = (1-u-v)*tr[0] + u*tr[1] + v*tr[2].  Both faces of tr
+// are hit.
+//
+// Algorithm taken from:
+// 	Moller & Trumbore, "Fast, Minimum Storage Ray/Triangle Intersection",
+// 	Journal of Graphics Tools, 1997.
+func RayTriangleIntersection(tr Triangle, r Ray) (p Point, t, u, v float64, ok bool) {
+	p = Origin
+	t = math.MaxFloat64
+
+	e1 := MakeVector(tr[1], tr[0])
+	e2 := MakeVector(tr[2], tr[0])
+	pv := CrossProduct(&r.Dir, &e2)
+	det := DotProduct(&e1, &pv)
+	if det == 0 {
+		// r parallel to tr
 		return
 	}
-	ok = true
+	inv := 1 / det
 
-	t = (-b - math.Sqrt(d)) / (2 * a)
-	p = Point{l[0].X + t*dx, l[0].Y + t*dy, l[0].Z + t*dz}
+	tv := MakeVector(r.Origin, tr[0])
+	u = DotProduct(&tv, &pv) * inv
+	if u < 0 || u > 1 {
+		return
+	}
 
+	qv := CrossProduct(&tv, &e1)
+	v = DotProduct(&r.Dir, &qv) * inv
+	if v < 0 || u+v > 1 {
+		return
+	}
+
+	tt := DotProduct(&e2, &qv) * inv
+	if tt < r.TMin || tt > r.TMax {
+		return
+	}
+
+	t = tt
+	ok = true
+	p = r.At(t)
 	return
 }
 
+// SolveQuadratic returns the real roots x0 <= x1 of a*x*x + b*x + c.  Set ok to
+// false if there is none.  x0 == x1 for a double root or when a is null and
+// the equation is linear.
+//
+// Avoids the catastrophic cancellation the textbook formula suffers from when
+// b*b is much larger than 4*a*c by computing the root of larger magnitude
+// first and deriving the other one from x0*x1 == c/a.
+//
+// Algorithm taken from:
+// 	Press et al., "Numerical Recipes", section 5.6.
+func SolveQuadratic(a, b, c float64) (x0, x1 float64, ok bool) {
+	if a == 0 {
+		if b == 0 {
+			return 0, 0, false
+		}
+		x0 = -c / b
+		return x0, x0, true
+	}
+
+	d := b*b - 4*a*c
+	if d < 0 {
+		return 0, 0, false
+	}
+
+	q := -0.5 * (b + math.Copysign(math.Sqrt(d), b))
+	if q == 0 {
+		// b == 0 and c == 0
+		return 0, 0, true
+	}
+	x0 = q / a
+	x1 = c / q
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	return x0, x1, true
+}
+
 func FloatsEqual(lhs, rhs, epsilon float64) bool {
 	return math.Abs(lhs-rhs) < epsilon
 }
@@ -23,6 +23,7 @@ SOFTWARE.
 package geom
 
 import (
+	"math"
 	"testing"
 )
 
@@ -44,6 +45,10 @@ var testData = [...]struct {
 		true, Point{6.27, 0, 3.13}},
 	{Line{Point{1, 0.5, 0}, Point{4, 2, 0}}, Sphere{Point{7, 5, 1}, 1},
 		false, Origin},
+	{Line{Point{7, 5, 0}, Point{8, 5, 0}}, Sphere{Point{7, 5, 0}, 2},
+		true, Point{9, 5, 0}},
+	{Line{Point{6, 5, 0}, Point{4, 5, 0}}, Sphere{Point{7, 5, 0}, 2},
+		true, Point{5, 5, 0}},
 }
 
 func TestSphereLineIntersection(t *testing.T) {
@@ -68,6 +73,23 @@ func TestSphereNormalVector(t *testing.T) {
 	}
 }
 
+func TestSphereContains(t *testing.T) {
+	s := Sphere{Point{1, 1, 1}, 2}
+	for _, td := range []struct {
+		p      Point
+		inside bool
+	}{
+		{Point{1, 1, 1}, true},
+		{Point{2.9, 1, 1}, true},
+		{Point{3, 1, 1}, false},
+		{Point{4, 4, 4}, false},
+	} {
+		if act := s.Contains(&td.p); act != td.inside {
+			t.Fatalf("%v: exp: %v act: %v", td.p, td.inside, act)
+		}
+	}
+}
+
 func TestDotProduct(t *testing.T) {
 	v1 := Vector{2, 3, 4}
 	v2 := Vector{3, 4, 5}
@@ -77,3 +99,402 @@ func TestDotProduct(t *testing.T) {
 		t.Fatalf("exp: %v act: %v", exp, dot)
 	}
 }
+
+func TestCrossProduct(t *testing.T) {
+	x := Vector{1, 0, 0}
+	y := Vector{0, 1, 0}
+	if act, exp := CrossProduct(&x, &y), (Vector{0, 0, 1}); !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+	if act, exp := CrossProduct(&y, &x), (Vector{0, 0, -1}); !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+
+	v1 := Vector{2, 3, 4}
+	v2 := Vector{3, 4, 5}
+	cross := CrossProduct(&v1, &v2)
+	if exp := (Vector{-1, 2, -1}); !VectorsEqual(cross, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, cross)
+	}
+	if dot := DotProduct(&cross, &v1); dot != 0 {
+		t.Fatalf("cross product not orthogonal: %v", dot)
+	}
+}
+
+func TestVectorArithmetic(t *testing.T) {
+	v := Vector{1, 2, 3}
+	w := Vector{4, -5, 6}
+	for _, td := range []struct {
+		act, exp Vector
+	}{
+		{v.Add(w), Vector{5, -3, 9}},
+		{v.Sub(w), Vector{-3, 7, -3}},
+		{v.Scale(2), Vector{2, 4, 6}},
+		{v.Neg(), Vector{-1, -2, -3}},
+	} {
+		if !VectorsEqual(td.act, td.exp, epsilon) {
+			t.Fatalf("exp: %v act: %v", td.exp, td.act)
+		}
+	}
+}
+
+func TestPointTranslate(t *testing.T) {
+	p := Point{1, 2, 3}
+	act := p.Translate(Vector{1, -1, 0.5})
+	if exp := (Point{2, 1, 3.5}); !PointsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestReflect(t *testing.T) {
+	i := Vector{1, -1, 0}
+	n := Vector{0, 1, 0}
+	if act, exp := Reflect(i, n), (Vector{1, 1, 0}); !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestRefract(t *testing.T) {
+	n := Vector{0, 1, 0}
+
+	// Straight through at normal incidence.
+	act, ok := Refract(Vector{0, -1, 0}, n, 1.5)
+	if exp := (Vector{0, -1, 0}); !ok || !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v ok: %v", exp, act, ok)
+	}
+
+	// Snell's law: sin(theta_t) = eta * sin(theta_i)
+	i := Vector{math.Sin(math.Pi / 6), -math.Cos(math.Pi / 6), 0}
+	eta := 1 / 1.5
+	act, ok = Refract(i, n, eta)
+	if !ok || !FloatsEqual(act.X, eta*i.X, epsilon) || !FloatsEqual(act.Module(), 1, epsilon) {
+		t.Fatalf("bad refraction: %v ok: %v", act, ok)
+	}
+
+	// Total internal reflection when leaving a dense medium at grazing angle.
+	i = Vector{math.Sin(math.Pi / 3), -math.Cos(math.Pi / 3), 0}
+	if _, ok = Refract(i, n, 1.5); ok {
+		t.Fatalf("no total internal reflection")
+	}
+}
+
+func TestRaySphereIntersection(t *testing.T) {
+	s := Sphere{Point{0, 0, 10}, 2}
+	for _, td := range []struct {
+		r         Ray
+		intersect bool
+		t         float64
+	}{
+		{MakeRay(Origin, Vector{0, 0, 1}), true, 8},
+		{MakeRay(Origin, Vector{0, 0, 2}), true, 4},
+		{MakeRay(Point{0, 0, 10}, Vector{0, 0, 1}), true, 2},
+		{MakeRay(Point{0, 0, 20}, Vector{0, 0, 1}), false, 0},
+		{Ray{Origin, Vector{0, 0, 1}, 0, 5}, false, 0},
+		{Ray{Origin, Vector{0, 0, 1}, 9, 20}, true, 12},
+	} {
+		p, act, ok := RaySphereIntersection(s, td.r)
+		if ok != td.intersect {
+			t.Fatalf("%v: bad ok: exp: %v act: %v", td.r, td.intersect, ok)
+		}
+		if !ok {
+			continue
+		}
+		if !FloatsEqual(act, td.t, epsilon) {
+			t.Fatalf("%v: bad t: exp: %v act: %v", td.r, td.t, act)
+		}
+		if exp := td.r.At(act); !PointsEqual(p, exp, epsilon) {
+			t.Fatalf("%v: bad point: exp: %v act: %v", td.r, exp, p)
+		}
+	}
+}
+
+var planeTestData = [...]struct {
+	origin    Point
+	normal    Vector
+	r         Ray
+	intersect bool
+	p         Point
+}{
+	{Origin, Vector{0, 1, 0}, MakeRay(Point{1, 5, 1}, Vector{0, -1, 0}),
+		true, Point{1, 0, 1}},
+	{Origin, Vector{0, 1, 0}, MakeRay(Point{1, 5, 1}, Vector{1, -1, 0}),
+		true, Point{6, 0, 1}},
+	{Point{0, 0, 10}, Vector{0, 0, -1}, MakeRay(Origin, Vector{1, 1, 2}),
+		true, Point{5, 5, 10}},
+	{Origin, Vector{0, 1, 0}, MakeRay(Point{1, 5, 1}, Vector{1, 0, 0}),
+		false, Origin},
+	{Origin, Vector{0, 1, 0}, MakeRay(Point{1, 5, 1}, Vector{0, 1, 0}),
+		false, Origin},
+	{Origin, Vector{0, 1, 0}, Ray{Point{1, 5, 1}, Vector{0, -1, 0}, 0, 4},
+		false, Origin},
+}
+
+func TestRayPlaneIntersection(t *testing.T) {
+	for _, td := range planeTestData {
+		i, _, ok := RayPlaneIntersection(td.origin, td.normal, td.r)
+		if td.intersect != ok {
+			t.Fatalf("bad ok: exp: %v act: %v", td.intersect, ok)
+		}
+		if ok && !PointsEqual(i, td.p, epsilon) {
+			t.Fatalf("bad intersection: exp: %v act: %v", td.p, i)
+		}
+	}
+}
+
+func TestRayTriangleIntersection(t *testing.T) {
+	tr := Triangle{Point{0, 0, 5}, Point{4, 0, 5}, Point{0, 4, 5}}
+	for _, td := range []struct {
+		r         Ray
+		intersect bool
+		p         Point
+		u, v      float64
+	}{
+		{MakeRay(Point{1, 1, 0}, Vector{0, 0, 1}), true, Point{1, 1, 5}, 0.25, 0.25},
+		{MakeRay(Point{1, 1, 10}, Vector{0, 0, -1}), true, Point{1, 1, 5}, 0.25, 0.25},
+		{MakeRay(Origin, Vector{0, 0, 1}), true, Point{0, 0, 5}, 0, 0},
+		{MakeRay(Origin, Vector{4, 0, 5}), true, Point{4, 0, 5}, 1, 0},
+		{MakeRay(Point{3, 3, 0}, Vector{0, 0, 1}), false, Origin, 0, 0},
+		{MakeRay(Point{1, 1, 6}, Vector{0, 0, 1}), false, Origin, 0, 0},
+		{MakeRay(Point{1, 1, 0}, Vector{1, 0, 0}), false, Origin, 0, 0},
+	} {
+		p, _, u, v, ok := RayTriangleIntersection(tr, td.r)
+		if ok != td.intersect {
+			t.Fatalf("%v: bad ok: exp: %v act: %v", td.r, td.intersect, ok)
+		}
+		if !ok {
+			continue
+		}
+		if !PointsEqual(p, td.p, epsilon) {
+			t.Fatalf("%v: bad intersection: exp: %v act: %v", td.r, td.p, p)
+		}
+		if !FloatsEqual(u, td.u, epsilon) || !FloatsEqual(v, td.v, epsilon) {
+			t.Fatalf("%v: bad barycentric coordinates: exp: %v,%v act: %v,%v",
+				td.r, td.u, td.v, u, v)
+		}
+	}
+}
+
+func TestTriangleNormal(t *testing.T) {
+	tr := Triangle{Origin, Point{2, 0, 0}, Point{0, 3, 0}}
+	if act, exp := tr.Normal(), (Vector{0, 0, 1}); !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestRaySphereRoots(t *testing.T) {
+	s := Sphere{Point{0, 0, 10}, 2}
+	for _, td := range []struct {
+		r         Ray
+		intersect bool
+		t0, t1    float64
+	}{
+		{MakeRay(Origin, Vector{0, 0, 1}), true, 8, 12},
+		{MakeRay(Point{0, 0, 10}, Vector{0, 0, 1}), true, -2, 2},
+		{MakeRay(Point{0, 0, 20}, Vector{0, 0, 1}), true, -12, -8},
+		{MakeRay(Point{0, 3, 0}, Vector{0, 0, 1}), false, 0, 0},
+	} {
+		t0, t1, ok := RaySphereRoots(s, td.r)
+		if ok != td.intersect {
+			t.Fatalf("%v: bad ok: exp: %v act: %v", td.r, td.intersect, ok)
+		}
+		if ok && (!FloatsEqual(t0, td.t0, epsilon) || !FloatsEqual(t1, td.t1, epsilon)) {
+			t.Fatalf("%v: exp: %v, %v act: %v, %v", td.r, td.t0, td.t1, t0, t1)
+		}
+	}
+}
+
+// Regression test: intersections behind the ray origin used to be reported.
+func TestSphereBehindRayOrigin(t *testing.T) {
+	s := Sphere{Point{0, 0, -10}, 2}
+	if _, _, ok := RaySphereIntersection(s, MakeRay(Origin, Vector{0, 0, 1})); ok {
+		t.Fatalf("sphere behind ray origin intersected")
+	}
+	if _, _, ok := SphereLineIntersection(s, Line{Origin, Point{0, 0, 1}}); ok {
+		t.Fatalf("sphere behind line origin intersected")
+	}
+}
+
+func TestSolveQuadratic(t *testing.T) {
+	for _, td := range []struct {
+		a, b, c float64
+		ok      bool
+		x0, x1  float64
+	}{
+		{1, -3, 2, true, 1, 2},
+		{1, 2, 1, true, -1, -1},
+		{2, 0, -8, true, -2, 2},
+		{1, 0, 1, false, 0, 0},
+		{0, 2, -4, true, 2, 2},
+		{0, 0, 1, false, 0, 0},
+		// b*b >> 4*a*c: the textbook formula loses the small root.
+		{1, -1e8, 1, true, 1e-8, 1e8},
+	} {
+		x0, x1, ok := SolveQuadratic(td.a, td.b, td.c)
+		if ok != td.ok {
+			t.Fatalf("%v: bad ok: exp: %v act: %v", td, td.ok, ok)
+		}
+		if !ok {
+			continue
+		}
+		if math.Abs(x0-td.x0) > 1e-9*math.Abs(td.x0) || math.Abs(x1-td.x1) > 1e-9*math.Abs(td.x1) {
+			t.Fatalf("%v: exp: %v, %v act: %v, %v", td, td.x0, td.x1, x0, x1)
+		}
+	}
+}
+
+func TestBarycentric(t *testing.T) {
+	tr := Triangle{Point{1, 1, 2}, Point{5, 1, 2}, Point{1, 3, 2}}
+	for _, td := range []struct {
+		p    Point
+		u, v float64
+	}{
+		{Point{1, 1, 2}, 0, 0},
+		{Point{5, 1, 2}, 1, 0},
+		{Point{1, 3, 2}, 0, 1},
+		{Point{2, 1.5, 2}, 0.25, 0.25},
+		{Point{2, 1.5, 7}, 0.25, 0.25},
+		{Point{9, 1, 2}, 2, 0},
+	} {
+		u, v := tr.Barycentric(td.p)
+		if !FloatsEqual(u, td.u, epsilon) || !FloatsEqual(v, td.v, epsilon) {
+			t.Fatalf("%v: exp: %v, %v act: %v, %v", td.p, td.u, td.v, u, v)
+		}
+	}
+
+	// Consistent with RayTriangleIntersection.
+	p, _, u, v, _ := RayTriangleIntersection(tr, MakeRay(Point{2, 2, 0}, Vector{0.1, 0.2, 1}))
+	bu, bv := tr.Barycentric(p)
+	if !FloatsEqual(u, bu, epsilon) || !FloatsEqual(v, bv, epsilon) {
+		t.Fatalf("exp: %v, %v act: %v, %v", u, v, bu, bv)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	if act := InterpolateFloat(1, 2, 3, 0.5, 0.25); !FloatsEqual(act, 2, epsilon) {
+		t.Fatalf("exp: %v act: %v", 2, act)
+	}
+	act := InterpolateVector(Vector{1, 0, 0}, Vector{0, 1, 0}, Vector{0, 0, 1}, 0.5, 0.25)
+	if exp := (Vector{0.25, 0.5, 0.25}); !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+	uv := InterpolatePoint2d(Point2d{0, 0}, Point2d{1, 0}, Point2d{0, 1}, 0.3, 0.6)
+	if !FloatsEqual(uv.X, 0.3, epsilon) || !FloatsEqual(uv.Y, 0.6, epsilon) {
+		t.Fatalf("exp: %v act: %v", Point2d{0.3, 0.6}, uv)
+	}
+}
+
+func TestSphereUV(t *testing.T) {
+	s := Sphere{Point{1, 1, 1}, 2}
+	for _, td := range []struct {
+		p  Point
+		uv Point2d
+	}{
+		{Point{1, 1, -1}, Point2d{0.5, 0.5}},
+		{Point{3, 1, 1}, Point2d{0.75, 0.5}},
+		{Point{-1, 1, 1}, Point2d{0.25, 0.5}},
+		{Point{1, 3, 1}, Point2d{-1, 0}},
+		{Point{1, -1, 1}, Point2d{-1, 1}},
+	} {
+		// u is arbitrary at poles
+		uv := s.UVAt(&td.p)
+		if (td.uv.X >= 0 && !FloatsEqual(uv.X, td.uv.X, epsilon)) || !FloatsEqual(uv.Y, td.uv.Y, epsilon) {
+			t.Fatalf("%v: exp: %v act: %v", td.p, td.uv, uv)
+		}
+	}
+}
+
+func TestSphereTangent(t *testing.T) {
+	s := Sphere{Point{1, 1, 1}, 2}
+	for _, td := range []struct {
+		p Point
+		t Vector
+	}{
+		{Point{1, 1, -1}, Vector{1, 0, 0}},
+		{Point{3, 1, 1}, Vector{0, 0, 1}},
+		{Point{1, 1, 3}, Vector{-1, 0, 0}},
+		{Point{1, 3, 1}, Vector{0, 0, 0}},
+	} {
+		if act := s.TangentAt(&td.p); !VectorsEqual(act, td.t, epsilon) {
+			t.Fatalf("%v: exp: %v act: %v", td.p, td.t, act)
+		}
+	}
+}
+
+func TestONB(t *testing.T) {
+	for _, w := range []Vector{
+		{0, 0, 1}, {0, 0, -1}, {1, 0, 0}, {0, 3, 0}, {1, 2, 3}, {-1, 0.5, -0.01},
+	} {
+		o := MakeONB(w)
+		for _, v := range []Vector{o.U, o.V, o.W} {
+			if !FloatsEqual(v.Module(), 1, 1e-9) {
+				t.Fatalf("%v: not unit: %v", w, v)
+			}
+		}
+		if d1, d2, d3 := DotProduct(&o.U, &o.V), DotProduct(&o.V, &o.W), DotProduct(&o.U, &o.W); !FloatsEqual(d1, 0, 1e-9) || !FloatsEqual(d2, 0, 1e-9) || !FloatsEqual(d3, 0, 1e-9) {
+			t.Fatalf("%v: not orthogonal: %v", w, o)
+		}
+		if uv := CrossProduct(&o.U, &o.V); !VectorsEqual(uv, o.W, 1e-9) {
+			t.Fatalf("%v: not right-handed: %v", w, o)
+		}
+		if unit := w.UnitVector(); !VectorsEqual(o.W, unit, 1e-9) {
+			t.Fatalf("%v: bad W: %v", w, o.W)
+		}
+
+		v := Vector{0.3, -2, 5}
+		if act := o.ToLocal(o.ToWorld(v)); !VectorsEqual(act, v, 1e-9) {
+			t.Fatalf("%v: round trip: exp: %v act: %v", w, v, act)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	a := Point{1, 2, 3}
+	b := Point{4, 6, 3}
+	if d := DistanceSquared(a, b); d != 25 {
+		t.Fatalf("exp: %v act: %v", 25, d)
+	}
+	if d := Distance(a, b); d != 5 {
+		t.Fatalf("exp: %v act: %v", 5, d)
+	}
+}
+
+func TestLerp(t *testing.T) {
+	if act, exp := LerpPoint(Point{0, 0, 0}, Point{2, 4, -2}, 0.25), (Point{0.5, 1, -0.5}); !PointsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+	if act, exp := LerpVector(Vector{1, 0, 0}, Vector{0, 1, 0}, 0.5), (Vector{0.5, 0.5, 0}); !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestPointLineDistance(t *testing.T) {
+	l := Line{Point{0, 0, 0}, Point{2, 0, 0}}
+	for _, td := range []struct {
+		p Point
+		d float64
+	}{
+		{Point{1, 3, 0}, 3},
+		{Point{-5, 0, 4}, 4},
+		{Point{7, 0, 0}, 0},
+	} {
+		if act := PointLineDistance(td.p, l); !FloatsEqual(act, td.d, epsilon) {
+			t.Fatalf("%v: exp: %v act: %v", td.p, td.d, act)
+		}
+	}
+}
+
+func TestPointPlaneDistance(t *testing.T) {
+	pl := Plane{Point{0, 1, 0}, Vector{0, 2, 0}, Vector{}, Vector{}}
+	for _, td := range []struct {
+		p Point
+		d float64
+	}{
+		{Point{5, 4, 5}, 3},
+		{Point{-1, -1, 0}, -2},
+		{Point{3, 1, 3}, 0},
+	} {
+		if act := PointPlaneDistance(td.p, pl); !FloatsEqual(act, td.d, epsilon) {
+			t.Fatalf("%v: exp: %v act: %v", td.p, td.d, act)
+		}
+	}
+}
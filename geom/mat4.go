@@ -0,0 +1,172 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+)
+
+// A Mat4 is a 4x4 matrix representing an affine transform in homogeneous
+// coordinates.  Elements are stored in row-major order and points and vectors
+// are column vectors so m.Mul(&n) applies n first then m.
+type Mat4 [4][4]float64
+
+// Identity returns the identity transform.
+func Identity() Mat4 {
+	return Mat4{
+		{1, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Translate returns the transform moving points by v.
+func Translate(v Vector) Mat4 {
+	return Mat4{
+		{1, 0, 0, v.X},
+		{0, 1, 0, v.Y},
+		{0, 0, 1, v.Z},
+		{0, 0, 0, 1},
+	}
+}
+
+// Scale returns the transform scaling coordinates by sx, sy and sz.
+func Scale(sx, sy, sz float64) Mat4 {
+	return Mat4{
+		{sx, 0, 0, 0},
+		{0, sy, 0, 0},
+		{0, 0, sz, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Rotate returns the transform rotating counterclockwise by angle radians
+// around axis, as seen from the tip of axis.
+func Rotate(axis Vector, angle float64) Mat4 {
+	a := axis.UnitVector()
+	c := math.Cos(angle)
+	s := math.Sin(angle)
+	t := 1 - c
+	return Mat4{
+		{t*a.X*a.X + c, t*a.X*a.Y - s*a.Z, t*a.X*a.Z + s*a.Y, 0},
+		{t*a.X*a.Y + s*a.Z, t*a.Y*a.Y + c, t*a.Y*a.Z - s*a.X, 0},
+		{t*a.X*a.Z - s*a.Y, t*a.Y*a.Z + s*a.X, t*a.Z*a.Z + c, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Mul returns the matrix product m * n.
+func (m *Mat4) Mul(n *Mat4) Mat4 {
+	var r Mat4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				r[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return r
+}
+
+func (m *Mat4) Transpose() Mat4 {
+	var r Mat4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			r[i][j] = m[j][i]
+		}
+	}
+	return r
+}
+
+// Inverse returns the inverse of m.  Set ok to false if m is singular.
+//
+// Uses Gauss-Jordan elimination with partial pivoting.
+func (m *Mat4) Inverse() (inv Mat4, ok bool) {
+	a := *m
+	inv = Identity()
+	for col := 0; col < 4; col++ {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if a[pivot][col] == 0 {
+			return Mat4{}, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		k := 1 / a[col][col]
+		for j := 0; j < 4; j++ {
+			a[col][j] *= k
+			inv[col][j] *= k
+		}
+		for row := 0; row < 4; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col]
+			for j := 0; j < 4; j++ {
+				a[row][j] -= f * a[col][j]
+				inv[row][j] -= f * inv[col][j]
+			}
+		}
+	}
+	return inv, true
+}
+
+func (m *Mat4) TransformPoint(p Point) Point {
+	return Point{
+		m[0][0]*p.X + m[0][1]*p.Y + m[0][2]*p.Z + m[0][3],
+		m[1][0]*p.X + m[1][1]*p.Y + m[1][2]*p.Z + m[1][3],
+		m[2][0]*p.X + m[2][1]*p.Y + m[2][2]*p.Z + m[2][3],
+	}
+}
+
+// TransformVector transforms v, ignoring the translation part of m.
+func (m *Mat4) TransformVector(v Vector) Vector {
+	return Vector{
+		m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+// TransformRay transforms r.  The direction is not normalized so parameters
+// along the transformed ray match those along r.
+func (m *Mat4) TransformRay(r Ray) Ray {
+	return Ray{m.TransformPoint(r.Origin), m.TransformVector(r.Dir), r.TMin, r.TMax}
+}
+
+func Mat4sEqual(lhs, rhs Mat4, epsilon float64) bool {
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			if !FloatsEqual(lhs[i][j], rhs[i][j], epsilon) {
+				return false
+			}
+		}
+	}
+	return true
+}
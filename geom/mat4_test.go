@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMat4TransformPoint(t *testing.T) {
+	p := Point{1, 2, 3}
+	for _, td := range []struct {
+		m   Mat4
+		exp Point
+	}{
+		{Identity(), Point{1, 2, 3}},
+		{Translate(Vector{1, -1, 2}), Point{2, 1, 5}},
+		{Scale(2, 3, -1), Point{2, 6, -3}},
+		{Rotate(Vector{0, 0, 1}, math.Pi/2), Point{-2, 1, 3}},
+		{Rotate(Vector{1, 0, 0}, math.Pi), Point{1, -2, -3}},
+	} {
+		if act := td.m.TransformPoint(p); !PointsEqual(act, td.exp, epsilon) {
+			t.Fatalf("%v: exp: %v act: %v", td.m, td.exp, act)
+		}
+	}
+}
+
+func TestMat4TransformVector(t *testing.T) {
+	m := Translate(Vector{5, 5, 5})
+	v := Vector{1, 2, 3}
+	if act := m.TransformVector(v); !VectorsEqual(act, v, epsilon) {
+		t.Fatalf("vector translated: %v", act)
+	}
+}
+
+func TestMat4Mul(t *testing.T) {
+	tr := Translate(Vector{1, 0, 0})
+	sc := Scale(2, 2, 2)
+	m := tr.Mul(&sc) // scale then translate
+	act := m.TransformPoint(Point{1, 1, 1})
+	if exp := (Point{3, 2, 2}); !PointsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestMat4Inverse(t *testing.T) {
+	tr := Translate(Vector{1, 2, 3})
+	rot := Rotate(Vector{1, 1, 0}, 0.7)
+	sc := Scale(2, 0.5, 4)
+	m := tr.Mul(&rot)
+	m = m.Mul(&sc)
+	inv, ok := m.Inverse()
+	if !ok {
+		t.Fatalf("invertible matrix reported singular")
+	}
+	if id := m.Mul(&inv); !Mat4sEqual(id, Identity(), 1e-9) {
+		t.Fatalf("m * inverse(m) != identity: %v", id)
+	}
+
+	singular := Scale(1, 0, 1)
+	if _, ok := singular.Inverse(); ok {
+		t.Fatalf("singular matrix inverted")
+	}
+}
+
+func TestMat4TransformRay(t *testing.T) {
+	m := Translate(Vector{0, 0, 10})
+	r := m.TransformRay(MakeRay(Origin, Vector{0, 0, 2}))
+	if exp := (Point{0, 0, 14}); !PointsEqual(r.At(2), exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, r.At(2))
+	}
+}
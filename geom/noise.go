@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+	"math/rand"
+)
+
+// perm is the permutation table hashing lattice points to gradients, stored
+// twice to avoid wrapping indices.
+var perm [512]int
+
+func init() {
+	// A fixed seed makes noise, and procedural textures, reproducible.
+	for i, v := range rand.New(rand.NewSource(0)).Perm(256) {
+		perm[i] = v
+		perm[i+256] = v
+	}
+}
+
+// Noise returns Perlin gradient noise at p.  Noise varies smoothly in about
+// [-1..1], is zero at integer coordinates and its features are about one
+// unit wide.
+//
+// Algorithm taken from:
+// 	Perlin, "Improving Noise", SIGGRAPH 2002.
+func Noise(p Point) float64 {
+	fx, fy, fz := math.Floor(p.X), math.Floor(p.Y), math.Floor(p.Z)
+	x, y, z := p.X-fx, p.Y-fy, p.Z-fz
+	// Lattice cell, wrapped to the permutation table.
+	xi, yi, zi := int(fx)&255, int(fy)&255, int(fz)&255
+	u, v, w := fade(x), fade(y), fade(z)
+
+	a := perm[xi] + yi
+	aa, ab := perm[a]+zi, perm[a+1]+zi
+	b := perm[xi+1] + yi
+	ba, bb := perm[b]+zi, perm[b+1]+zi
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(perm[aa], x, y, z), grad(perm[ba], x-1, y, z)),
+			lerp(u, grad(perm[ab], x, y-1, z), grad(perm[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(perm[aa+1], x, y, z-1), grad(perm[ba+1], x-1, y, z-1)),
+			lerp(u, grad(perm[ab+1], x, y-1, z-1), grad(perm[bb+1], x-1, y-1, z-1))))
+}
+
+// fade is the quintic interpolant of Perlin noise, with null first and second
+// derivatives at 0 and 1.
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// grad returns the dot product between (x, y, z) and one of 12 gradients
+// directed toward the edges of a cube, selected by hash.
+func grad(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	var v float64
+	switch {
+	case h < 4:
+		v = y
+	case h == 12 || h == 14:
+		v = x
+	default:
+		v = z
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+// FractalNoise sums octaves of Noise at p, each one of twice the frequency
+// and half the amplitude of the previous one.  The result lies in about
+// [-1..1] and has details down to 2^-octaves units.  octaves must be
+// positive.
+func FractalNoise(p Point, octaves int) float64 {
+	sum, amp := 0.0, 1.0
+	for i := 0; i < octaves; i++ {
+		sum += amp * Noise(p)
+		p = Point{p.X * 2, p.Y * 2, p.Z * 2}
+		amp /= 2
+	}
+	return sum / (2 - 2*amp)
+}
+
+// Turbulence is like FractalNoise but sums the absolute values of octaves,
+// which creates sharp creases where noise changes sign.  The result lies in
+// about [0..1].
+func Turbulence(p Point, octaves int) float64 {
+	sum, amp := 0.0, 1.0
+	for i := 0; i < octaves; i++ {
+		sum += amp * math.Abs(Noise(p))
+		p = Point{p.X * 2, p.Y * 2, p.Z * 2}
+		amp /= 2
+	}
+	return sum / (2 - 2*amp)
+}
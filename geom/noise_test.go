@@ -0,0 +1,69 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNoise(t *testing.T) {
+	for _, p := range []Point{{0, 0, 0}, {1, 2, 3}, {-5, 7, -300}} {
+		if n := Noise(p); n != 0 {
+			t.Errorf("%v: noise at lattice point: %v", p, n)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	var min, max float64
+	for i := 0; i < nsamples; i++ {
+		p := Point{rng.Float64() * 100, rng.Float64() * 100, rng.Float64()*200 - 100}
+		n := Noise(p)
+		if n < -1.1 || n > 1.1 {
+			t.Fatalf("%v: noise out-of-range: %v", p, n)
+		}
+		min, max = math.Min(min, n), math.Max(max, n)
+		// Noise is continuous.
+		q := p.Translate(Vector{1e-6, -1e-6, 1e-6})
+		if d := math.Abs(Noise(q) - n); d > 1e-4 {
+			t.Fatalf("%v: noise discontinuity: %v", p, d)
+		}
+		if f := FractalNoise(p, 4); f < -1.1 || f > 1.1 {
+			t.Fatalf("%v: fractal noise out-of-range: %v", p, f)
+		}
+		if tu := Turbulence(p, 4); tu < 0 || tu > 1.1 {
+			t.Fatalf("%v: turbulence out-of-range: %v", p, tu)
+		}
+	}
+	if min > -0.5 || max < 0.5 {
+		t.Errorf("noise too flat: [%v..%v]", min, max)
+	}
+
+	if a, b := Noise(Point{0.3, 0.7, 0.1}), Noise(Point{0.3, 0.7, 0.1}); a != b {
+		t.Errorf("noise not reproducible: %v %v", a, b)
+	}
+	if a, b := FractalNoise(Point{0.3, 0.7, 0.1}, 1), Noise(Point{0.3, 0.7, 0.1}); a != b {
+		t.Errorf("single octave: exp: %v act: %v", b, a)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Plane is an oriented plane of arbitrary orientation.  Its front face is
+// the one Normal points to.
+//
+// The plane is infinite when U and V are null.  Otherwise, it is bounded to
+// the rectangle of center Point and orthogonal half-edges U and V, that is the
+// points Point + a*U + b*V with a and b in [-1..1].
+type Plane struct {
+	Point  Point
+	Normal Vector
+	U, V   Vector
+}
+
+func (pl *Plane) Bounded() bool {
+	return pl.U != (Vector{}) || pl.V != (Vector{})
+}
+
+func (pl *Plane) Validate() error {
+	if pl.Normal.Module() == 0 {
+		return fmt.Errorf("invalid plane: null normal")
+	}
+	if !pl.Bounded() {
+		return nil
+	}
+	if pl.U.Module() == 0 || pl.V.Module() == 0 {
+		return fmt.Errorf("invalid bounded plane: null edge")
+	}
+	n := pl.Normal.UnitVector()
+	u := pl.U.UnitVector()
+	v := pl.V.UnitVector()
+	if math.Abs(DotProduct(&n, &u)) > 1e-9 || math.Abs(DotProduct(&n, &v)) > 1e-9 {
+		return fmt.Errorf("invalid bounded plane: edges not orthogonal to normal")
+	}
+	if math.Abs(DotProduct(&u, &v)) > 1e-9 {
+		return fmt.Errorf("invalid bounded plane: edges not orthogonal")
+	}
+	return nil
+}
+
+// Intersect returns the point where r intersects pl.  Set ok to false if there
+// is no intersection.  t is the parameter of the intersection point along r.
+func (pl *Plane) Intersect(r Ray) (p Point, t float64, ok bool) {
+	p, t, ok = RayPlaneIntersection(pl.Point, pl.Normal, r)
+	if !ok || !pl.Bounded() {
+		return
+	}
+	if a, b := pl.Coordinates(p); math.Abs(a) > 1 || math.Abs(b) > 1 {
+		return Origin, math.MaxFloat64, false
+	}
+	return
+}
+
+// Coordinates returns the coordinates (a, b) of the projection of p onto the
+// plane in the (Point, U, V) frame.  The result is undefined if pl is not
+// bounded or U and V are not orthogonal.
+func (pl *Plane) Coordinates(p Point) (a, b float64) {
+	d := MakeVector(p, pl.Point)
+	a = DotProduct(&d, &pl.U) / DotProduct(&pl.U, &pl.U)
+	b = DotProduct(&d, &pl.V) / DotProduct(&pl.V, &pl.V)
+	return a, b
+}
+
+// UVAt maps point p of pl to texture coordinates.  Bounded planes are mapped
+// to [0..1], u growing along U and v against V so that v grows downward like
+// image rows when V points up.  Infinite planes are mapped in world units
+// along an arbitrary orthonormal basis of the plane, the origin at Point.
+// Either way, the mapping is not mirrored when seen from the front face.
+func (pl *Plane) UVAt(p *Point) Point2d {
+	if pl.Bounded() {
+		a, b := pl.Coordinates(*p)
+		return Point2d{(a + 1) / 2, (1 - b) / 2}
+	}
+	onb := MakeONB(pl.Normal)
+	d := MakeVector(*p, pl.Point)
+	return Point2d{DotProduct(&d, &onb.U), DotProduct(&d, &onb.V)}
+}
+
+// Tangent returns the unit vector of pl in the direction in which the u
+// texture coordinate grows (see UVAt).
+func (pl *Plane) Tangent() Vector {
+	if pl.Bounded() {
+		return pl.U.UnitVector()
+	}
+	onb := MakeONB(pl.Normal)
+	return onb.U
+}
+
+// Plane converts p to an equivalent bounded plane facing -z.
+func (p *Plane2d) Plane() Plane {
+	return Plane{
+		Point{(p.Tl.X + p.Br.X) / 2, (p.Tl.Y + p.Br.Y) / 2, p.Z},
+		Vector{0, 0, -1},
+		Vector{p.Dx() / 2, 0, 0},
+		Vector{0, p.Dy() / 2, 0},
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+func TestPlaneValidate(t *testing.T) {
+	for _, td := range []struct {
+		pl    Plane
+		valid bool
+	}{
+		{Plane{Origin, Vector{0, 1, 0}, Vector{}, Vector{}}, true},
+		{Plane{Origin, Vector{0, 0, 0}, Vector{}, Vector{}}, false},
+		{Plane{Origin, Vector{0, 1, 0}, Vector{1, 0, 0}, Vector{0, 0, 2}}, true},
+		{Plane{Origin, Vector{0, 1, 0}, Vector{1, 0, 0}, Vector{}}, false},
+		{Plane{Origin, Vector{0, 1, 0}, Vector{1, 1, 0}, Vector{0, 0, 2}}, false},
+		{Plane{Origin, Vector{0, 1, 0}, Vector{1, 0, 1}, Vector{0, 0, 2}}, false},
+	} {
+		if err := td.pl.Validate(); (err == nil) != td.valid {
+			t.Fatalf("%v: exp valid: %v act: %v", td.pl, td.valid, err)
+		}
+	}
+}
+
+func TestPlaneIntersect(t *testing.T) {
+	// Tilted 2x2 square centered on (0, 0, 10).
+	sq := Plane{Point{0, 0, 10}, Vector{0, -1, -1}, Vector{1, 0, 0}, Vector{0, 1, -1}}
+	inf := Plane{sq.Point, sq.Normal, Vector{}, Vector{}}
+	for _, td := range []struct {
+		pl        Plane
+		r         Ray
+		intersect bool
+		p         Point
+	}{
+		{sq, MakeRay(Origin, Vector{0, 0, 1}), true, Point{0, 0, 10}},
+		{sq, MakeRay(Point{0.5, 0, 0}, Vector{0, 0, 1}), true, Point{0.5, 0, 10}},
+		{sq, MakeRay(Point{0, 0.9, 0}, Vector{0, 0, 1}), true, Point{0, 0.9, 9.1}},
+		{sq, MakeRay(Point{1.5, 0, 0}, Vector{0, 0, 1}), false, Origin},
+		{sq, MakeRay(Point{0, 1.5, 0}, Vector{0, 0, 1}), false, Origin},
+		{inf, MakeRay(Point{0, 1.5, 0}, Vector{0, 0, 1}), true, Point{0, 1.5, 8.5}},
+	} {
+		p, _, ok := td.pl.Intersect(td.r)
+		if ok != td.intersect {
+			t.Fatalf("%v: bad ok: exp: %v act: %v", td.r, td.intersect, ok)
+		}
+		if ok && !PointsEqual(p, td.p, epsilon) {
+			t.Fatalf("%v: bad intersection: exp: %v act: %v", td.r, td.p, p)
+		}
+	}
+}
+
+func TestPlane2dPlane(t *testing.T) {
+	p2 := Plane2d{Point2d{-4, 2}, Point2d{2, -2}, 5}
+	pl := p2.Plane()
+	if err := pl.Validate(); err != nil {
+		t.Fatalf("invalid plane: %v", err)
+	}
+	if _, _, ok := pl.Intersect(MakeRay(Point{-3.9, 1.9, 0}, Vector{0, 0, 1})); !ok {
+		t.Fatalf("corner missed")
+	}
+	if _, _, ok := pl.Intersect(MakeRay(Point{2.1, 0, 0}, Vector{0, 0, 1})); ok {
+		t.Fatalf("point outside bounds hit")
+	}
+}
+
+func TestPlaneUVAt(t *testing.T) {
+	sq := Plane{Point{0, 0, 10}, Vector{0, 0, -1}, Vector{2, 0, 0}, Vector{0, 2, 0}}
+	floor := Plane{Point{0, -1, 0}, Vector{0, 1, 0}, Vector{}, Vector{}}
+	for _, td := range []struct {
+		pl Plane
+		p  Point
+		uv Point2d
+	}{
+		{sq, Point{0, 0, 10}, Point2d{0.5, 0.5}},
+		{sq, Point{-2, 2, 10}, Point2d{0, 0}},
+		{sq, Point{2, -2, 10}, Point2d{1, 1}},
+		{sq, Point{1, 0, 10}, Point2d{0.75, 0.5}},
+		{floor, Point{0, -1, 0}, Point2d{0, 0}},
+		{floor, Point{3, -1, 0}, Point2d{3, 0}},
+		{floor, Point{0, -1, 4}, Point2d{0, -4}},
+	} {
+		uv := td.pl.UVAt(&td.p)
+		if !FloatsEqual(uv.X, td.uv.X, epsilon) || !FloatsEqual(uv.Y, td.uv.Y, epsilon) {
+			t.Errorf("%v: exp: %v act: %v", td.p, td.uv, uv)
+		}
+	}
+
+	// Tangents point where u grows.
+	for _, pl := range []Plane{sq, floor} {
+		tg := pl.Tangent()
+		q := pl.Point.Translate(tg)
+		if uv0, uv1 := pl.UVAt(&pl.Point), pl.UVAt(&q); uv1.X <= uv0.X || !FloatsEqual(uv1.Y, uv0.Y, epsilon) {
+			t.Errorf("%v: bad tangent: %v", pl, tg)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+	"sort"
+)
+
+// SolveCubic returns the n distinct real roots of a*x^3 + b*x^2 + c*x + d in
+// ascending order in roots[:n].  Falls back to SolveQuadratic when a is null.
+//
+// Algorithm taken from:
+// 	Press et al., "Numerical Recipes", section 5.6.
+func SolveCubic(a, b, c, d float64) (roots [3]float64, n int) {
+	if a == 0 {
+		x0, x1, ok := SolveQuadratic(b, c, d)
+		if !ok {
+			return roots, 0
+		}
+		roots[0] = x0
+		if x1 == x0 {
+			return roots, 1
+		}
+		roots[1] = x1
+		return roots, 2
+	}
+
+	// Normalized cubic x^3 + A*x^2 + B*x + C.
+	A, B, C := b/a, c/a, d/a
+	Q := (A*A - 3*B) / 9
+	R := (2*A*A*A - 9*A*B + 27*C) / 54
+	if R*R < Q*Q*Q {
+		// three real roots
+		theta := math.Acos(R / math.Sqrt(Q*Q*Q))
+		s := -2 * math.Sqrt(Q)
+		roots[0] = s*math.Cos(theta/3) - A/3
+		roots[1] = s*math.Cos((theta+2*math.Pi)/3) - A/3
+		roots[2] = s*math.Cos((theta-2*math.Pi)/3) - A/3
+		sort.Float64s(roots[:])
+		return roots, 3
+	}
+
+	u := -math.Copysign(math.Cbrt(math.Abs(R)+math.Sqrt(R*R-Q*Q*Q)), R)
+	v := 0.0
+	if u != 0 {
+		v = Q / u
+	}
+	roots[0] = u + v - A/3
+	if R*R == Q*Q*Q && u != 0 {
+		// double root
+		x := -(u+v)/2 - A/3
+		if x != roots[0] {
+			roots[1] = x
+			if roots[1] < roots[0] {
+				roots[0], roots[1] = roots[1], roots[0]
+			}
+			return roots, 2
+		}
+	}
+	return roots, 1
+}
+
+// SolveQuartic returns the real roots of a*x^4 + b*x^3 + c*x^2 + d*x + e in
+// ascending order in roots[:n].  Double roots may be reported once or twice.
+// Falls back to SolveCubic when a is null.
+//
+// Uses Ferrari's method: the depressed quartic is factored into two quadratics
+// thanks to a root of its resolvent cubic.  The roots are then refined with a
+// couple of Newton iterations to compensate for the poor numerical stability of
+// the method.
+func SolveQuartic(a, b, c, d, e float64) (roots [4]float64, n int) {
+	if a == 0 {
+		r, m := SolveCubic(b, c, d, e)
+		copy(roots[:], r[:m])
+		return roots, m
+	}
+
+	// Normalized quartic x^4 + A*x^3 + B*x^2 + C*x + D, depressed into
+	// y^4 + p*y^2 + q*y + r with x == y - A/4.
+	A, B, C, D := b/a, c/a, d/a, e/a
+	A2 := A * A
+	p := B - 3*A2/8
+	q := C - A*B/2 + A2*A/8
+	r := D - A*C/4 + A2*B/16 - 3*A2*A2/256
+
+	add := func(y float64) {
+		roots[n] = y - A/4
+		n++
+	}
+	quadratic := func(qa, qb, qc float64) {
+		if y0, y1, ok := SolveQuadratic(qa, qb, qc); ok {
+			add(y0)
+			add(y1)
+		}
+	}
+
+	// m is the largest root of the resolvent cubic, positive unless q == 0.
+	rc, nrc := SolveCubic(1, p, p*p/4-r, -q*q/8)
+	m := 0.0
+	if nrc > 0 {
+		m = rc[nrc-1]
+	}
+	if m <= 0 || math.Abs(q) < 1e-12 {
+		// Biquadratic y^4 + p*y^2 + r.
+		z0, z1, ok := SolveQuadratic(1, p, r)
+		if ok {
+			for _, z := range [...]float64{z0, z1} {
+				if z >= 0 {
+					add(-math.Sqrt(z))
+					add(math.Sqrt(z))
+				}
+			}
+		}
+	} else {
+		s := math.Sqrt(2 * m)
+		quadratic(1, -s, p/2+m+q/(2*s))
+		quadratic(1, s, p/2+m-q/(2*s))
+	}
+
+	for i := 0; i < n; i++ {
+		roots[i] = polishQuarticRoot(A, B, C, D, roots[i])
+	}
+	sort.Float64s(roots[:n])
+	return roots, n
+}
+
+// polishQuarticRoot refines approximate root x of x^4 + a*x^3 + b*x^2 + c*x + d
+// with Newton's method.
+func polishQuarticRoot(a, b, c, d, x float64) float64 {
+	for i := 0; i < 2; i++ {
+		f := (((x+a)*x+b)*x+c)*x + d
+		df := ((4*x+3*a)*x+2*b)*x + c
+		if df == 0 {
+			break
+		}
+		x -= f / df
+	}
+	return x
+}
@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+func TestSolveCubic(t *testing.T) {
+	for _, td := range []struct {
+		a, b, c, d float64
+		roots      []float64
+	}{
+		{1, -6, 11, -6, []float64{1, 2, 3}},
+		{2, -12, 22, -12, []float64{1, 2, 3}},
+		{1, -1, 1, -1, []float64{1}},
+		{1, -3, 0, 4, []float64{-1, 2}},
+		{1, 0, 0, 0, []float64{0}},
+		{1, 0, 0, -8, []float64{2}},
+		{0, 1, -3, 2, []float64{1, 2}},
+		{0, 1, 0, 1, nil},
+	} {
+		roots, n := SolveCubic(td.a, td.b, td.c, td.d)
+		checkRoots(t, td.roots, roots[:n])
+	}
+}
+
+func TestSolveQuartic(t *testing.T) {
+	for _, td := range []struct {
+		a, b, c, d, e float64
+		roots         []float64
+	}{
+		{1, -10, 35, -50, 24, []float64{1, 2, 3, 4}},
+		{-1, 10, -35, 50, -24, []float64{1, 2, 3, 4}},
+		{1, 1, -1, 1, -2, []float64{-2, 1}},
+		{1, 0, -5, 0, 4, []float64{-2, -1, 1, 2}},
+		{1, 0, 0, 0, 1, nil},
+		{1, 0, 1, 0, 0, []float64{0, 0}},
+		{0, 1, -6, 11, -6, []float64{1, 2, 3}},
+		// Torus-like coefficients with roots far from the origin.
+		{1, -400, 59995, -3999000, 99950004, []float64{98, 99, 101, 102}},
+	} {
+		roots, n := SolveQuartic(td.a, td.b, td.c, td.d, td.e)
+		checkRoots(t, td.roots, roots[:n])
+	}
+}
+
+func checkRoots(t *testing.T, exp, act []float64) {
+	if len(exp) != len(act) {
+		t.Errorf("exp: %v act: %v", exp, act)
+		return
+	}
+	for i := range exp {
+		if !FloatsEqual(exp[i], act[i], 1e-6) {
+			t.Errorf("exp: %v act: %v", exp, act)
+			return
+		}
+	}
+}
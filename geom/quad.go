@@ -0,0 +1,80 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Quad is the parallelogram made of points Corner + a*U + b*V with a and b
+// in [0..1].  Its front face is the one from which U and V are seen in
+// counterclockwise order.
+type Quad struct {
+	Corner Point
+	U, V   Vector
+}
+
+func (q *Quad) Validate() error {
+	if n := CrossProduct(&q.U, &q.V); n.Module() == 0 {
+		return fmt.Errorf("invalid quad: null or parallel edges")
+	}
+	return nil
+}
+
+// Normal returns the unit normal vector of the front face of q.
+func (q *Quad) Normal() Vector {
+	n := CrossProduct(&q.U, &q.V)
+	return n.UnitVector()
+}
+
+// Bounds returns the smallest box enclosing q.
+func (q *Quad) Bounds() AABB {
+	b := EmptyAABB()
+	b = b.Expand(q.Corner)
+	b = b.Expand(q.Corner.Translate(q.U))
+	b = b.Expand(q.Corner.Translate(q.V))
+	return b.Expand(q.Corner.Translate(q.U.Add(q.V)))
+}
+
+// Return the point where r intersects q, in [r.TMin..r.TMax].  Set ok to
+// false if there is no intersection.  t is the parameter of the intersection
+// point along r and (a, b) its coordinates in the (Corner, U, V) frame.
+func RayQuadIntersection(q Quad, r Ray) (p Point, t, a, b float64, ok bool) {
+	n := CrossProduct(&q.U, &q.V)
+	p, t, ok = RayPlaneIntersection(q.Corner, n, r)
+	if !ok {
+		return
+	}
+	// Coordinates of p in the possibly non-orthogonal (U, V) frame.
+	d := MakeVector(p, q.Corner)
+	w := n.Scale(1 / DotProduct(&n, &n))
+	dv := CrossProduct(&d, &q.V)
+	ud := CrossProduct(&q.U, &d)
+	a = DotProduct(&w, &dv)
+	b = DotProduct(&w, &ud)
+	if a < 0 || a > 1 || b < 0 || b > 1 {
+		return Origin, math.MaxFloat64, 0, 0, false
+	}
+	return p, t, a, b, true
+}
@@ -0,0 +1,61 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+func TestRayQuadIntersection(t *testing.T) {
+	// Parallelogram in the z = 10 plane with a slanted V edge.
+	q := Quad{Point{0, 0, 10}, Vector{4, 0, 0}, Vector{2, 2, 0}}
+	for i, td := range []struct {
+		r    Ray
+		ok   bool
+		p    Point
+		a, b float64
+	}{
+		{MakeRay(Point{3, 1, 0}, Vector{0, 0, 1}), true, Point{3, 1, 10}, 0.5, 0.5},
+		{MakeRay(Point{6, 2, 0}, Vector{0, 0, 1}), true, Point{6, 2, 10}, 1, 1},
+		{MakeRay(Point{0.5, 1, 0}, Vector{0, 0, 1}), false, Origin, 0, 0},
+		{MakeRay(Point{3, -1, 0}, Vector{0, 0, 1}), false, Origin, 0, 0},
+	} {
+		p, _, a, b, ok := RayQuadIntersection(q, td.r)
+		if ok != td.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, td.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !PointsEqual(p, td.p, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, td.p, p)
+		}
+		if !FloatsEqual(a, td.a, 1e-9) || !FloatsEqual(b, td.b, 1e-9) {
+			t.Errorf("#%d: exp: %v %v act: %v %v", i, td.a, td.b, a, b)
+		}
+	}
+	if n := q.Normal(); !VectorsEqual(n, Vector{0, 0, 1}, 1e-9) {
+		t.Errorf("exp: %v act: %v", Vector{0, 0, 1}, n)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Quadric is the surface made of points (x, y, z) such that:
+//
+// 	A*x*x + B*y*y + C*z*z + D*x*y + E*x*z + F*y*z + G*x + H*y + I*z + J = 0
+//
+// Spheres, ellipsoids, paraboloids, hyperboloids, cylinders and cones are all
+// quadrics.  Points where the left-hand side is negative are inside the
+// quadric.
+type Quadric struct {
+	A, B, C, D, E, F, G, H, I, J float64
+}
+
+func (q *Quadric) Validate() error {
+	if q.A == 0 && q.B == 0 && q.C == 0 && q.D == 0 && q.E == 0 &&
+		q.F == 0 && q.G == 0 && q.H == 0 && q.I == 0 {
+		return fmt.Errorf("invalid quadric: null coefficients")
+	}
+	return nil
+}
+
+// ValueAt returns the left-hand side of the equation of q at p.
+func (q *Quadric) ValueAt(p *Point) float64 {
+	return q.A*p.X*p.X + q.B*p.Y*p.Y + q.C*p.Z*p.Z +
+		q.D*p.X*p.Y + q.E*p.X*p.Z + q.F*p.Y*p.Z +
+		q.G*p.X + q.H*p.Y + q.I*p.Z + q.J
+}
+
+// Contains returns whether p lies inside q or on its surface.
+func (q *Quadric) Contains(p *Point) bool {
+	return q.ValueAt(p) <= 0
+}
+
+// NormalVectorAt returns the outward unit normal vector of q at p, the
+// normalized gradient of its equation.
+func (q *Quadric) NormalVectorAt(p *Point) Vector {
+	n := Vector{
+		2*q.A*p.X + q.D*p.Y + q.E*p.Z + q.G,
+		2*q.B*p.Y + q.D*p.X + q.F*p.Z + q.H,
+		2*q.C*p.Z + q.E*p.X + q.F*p.Y + q.I,
+	}
+	return n.UnitVector()
+}
+
+// Return the parameters t0 <= t1 of the points where the line supporting r
+// crosses q, regardless of r.TMin and r.TMax.  Set ok to false if the line
+// misses q.  Both parameters are equal when the line crosses q once.
+func RayQuadricRoots(q Quadric, r Ray) (t0, t1 float64, ok bool) {
+	o, d := r.Origin, r.Dir
+	a := q.A*d.X*d.X + q.B*d.Y*d.Y + q.C*d.Z*d.Z +
+		q.D*d.X*d.Y + q.E*d.X*d.Z + q.F*d.Y*d.Z
+	b := 2*(q.A*o.X*d.X+q.B*o.Y*d.Y+q.C*o.Z*d.Z) +
+		q.D*(o.X*d.Y+o.Y*d.X) + q.E*(o.X*d.Z+o.Z*d.X) + q.F*(o.Y*d.Z+o.Z*d.Y) +
+		q.G*d.X + q.H*d.Y + q.I*d.Z
+	c := q.ValueAt(&o)
+	t0, t1, ok = SolveQuadratic(a, b, c)
+	if !ok {
+		return math.MaxFloat64, math.MaxFloat64, false
+	}
+	return t0, t1, true
+}
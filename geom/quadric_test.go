@@ -0,0 +1,76 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+func TestRayQuadricRoots(t *testing.T) {
+	sphere := Quadric{A: 1, B: 1, C: 1, J: -4}
+	paraboloid := Quadric{A: 1, B: 1, I: -1}
+	hyperboloid := Quadric{A: 1, B: 1, C: -1, J: -1}
+	plane := Quadric{G: 1, J: -2}
+	for i, td := range []struct {
+		q      Quadric
+		r      Ray
+		ok     bool
+		t0, t1 float64
+	}{
+		{sphere, MakeRay(Point{-5, 0, 0}, Vector{1, 0, 0}), true, 3, 7},
+		{paraboloid, MakeRay(Point{0, 0, 5}, Vector{0, 0, -1}), true, 5, 5},
+		{paraboloid, MakeRay(Point{-5, 0, 4}, Vector{1, 0, 0}), true, 3, 7},
+		{hyperboloid, MakeRay(Point{-5, 0, 0}, Vector{1, 0, 0}), true, 4, 6},
+		{hyperboloid, MakeRay(Point{0, 0, -5}, Vector{0, 0, 1}), false, 0, 0},
+		{plane, MakeRay(Point{0, 0, 0}, Vector{1, 0, 0}), true, 2, 2},
+		{plane, MakeRay(Point{0, 0, 0}, Vector{0, 1, 0}), false, 0, 0},
+	} {
+		t0, t1, ok := RayQuadricRoots(td.q, td.r)
+		if ok != td.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, td.ok, ok)
+			continue
+		}
+		if ok && (!FloatsEqual(t0, td.t0, 1e-9) || !FloatsEqual(t1, td.t1, 1e-9)) {
+			t.Errorf("#%d: exp: %v %v act: %v %v", i, td.t0, td.t1, t0, t1)
+		}
+	}
+}
+
+func TestQuadricNormalVectorAt(t *testing.T) {
+	paraboloid := Quadric{A: 1, B: 1, I: -1}
+	slope := Vector{2, 0, -1}
+	for _, td := range []struct {
+		p   Point
+		exp Vector
+	}{
+		{Point{0, 0, 0}, Vector{0, 0, -1}},
+		{Point{1, 0, 1}, slope.UnitVector()},
+	} {
+		if act := paraboloid.NormalVectorAt(&td.p); !VectorsEqual(act, td.exp, 1e-9) {
+			t.Errorf("%v: exp: %v act: %v", td.p, td.exp, act)
+		}
+	}
+	if !paraboloid.Contains(&Point{0, 0, 1}) || paraboloid.Contains(&Point{0, 0, -1}) {
+		t.Errorf("bad paraboloid inside")
+	}
+}
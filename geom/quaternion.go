@@ -0,0 +1,111 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+)
+
+// A Quaternion W + Xi + Yj + Zk.  Unit quaternions represent rotations.
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+// QuaternionFromAxisAngle returns the unit quaternion rotating
+// counterclockwise by angle radians around axis, as seen from the tip of axis.
+func QuaternionFromAxisAngle(axis Vector, angle float64) Quaternion {
+	a := axis.UnitVector()
+	s := math.Sin(angle / 2)
+	return Quaternion{math.Cos(angle / 2), a.X * s, a.Y * s, a.Z * s}
+}
+
+// Mul returns the Hamilton product q * r, the rotation applying r then q.
+func (q *Quaternion) Mul(r Quaternion) Quaternion {
+	return Quaternion{
+		q.W*r.W - q.X*r.X - q.Y*r.Y - q.Z*r.Z,
+		q.W*r.X + q.X*r.W + q.Y*r.Z - q.Z*r.Y,
+		q.W*r.Y - q.X*r.Z + q.Y*r.W + q.Z*r.X,
+		q.W*r.Z + q.X*r.Y - q.Y*r.X + q.Z*r.W,
+	}
+}
+
+func (q *Quaternion) Conjugate() Quaternion {
+	return Quaternion{q.W, -q.X, -q.Y, -q.Z}
+}
+
+func (q *Quaternion) Norm() float64 {
+	return math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+}
+
+func (q *Quaternion) Normalize() Quaternion {
+	n := q.Norm()
+	return Quaternion{q.W / n, q.X / n, q.Y / n, q.Z / n}
+}
+
+// Rotate applies the rotation represented by unit quaternion q to v.
+func (q *Quaternion) Rotate(v Vector) Vector {
+	p := Quaternion{0, v.X, v.Y, v.Z}
+	r := q.Mul(p)
+	r = r.Mul(q.Conjugate())
+	return Vector{r.X, r.Y, r.Z}
+}
+
+// Mat4 returns the rotation matrix equivalent to unit quaternion q.
+func (q *Quaternion) Mat4() Mat4 {
+	w, x, y, z := q.W, q.X, q.Y, q.Z
+	return Mat4{
+		{1 - 2*(y*y+z*z), 2 * (x*y - w*z), 2 * (x*z + w*y), 0},
+		{2 * (x*y + w*z), 1 - 2*(x*x+z*z), 2 * (y*z - w*x), 0},
+		{2 * (x*z - w*y), 2 * (y*z + w*x), 1 - 2*(x*x+y*y), 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Slerp interpolates spherically between unit quaternions a (t == 0) and b
+// (t == 1) along the shortest arc.
+func Slerp(a, b Quaternion, t float64) Quaternion {
+	dot := a.W*b.W + a.X*b.X + a.Y*b.Y + a.Z*b.Z
+	if dot < 0 {
+		// q and -q are the same rotation: take shortest path
+		b = Quaternion{-b.W, -b.X, -b.Y, -b.Z}
+		dot = -dot
+	}
+
+	var ka, kb float64
+	if dot > 0.9995 {
+		// Nearly identical rotations: lerp avoids dividing by sin(~0).
+		ka, kb = 1-t, t
+	} else {
+		theta := math.Acos(dot)
+		sin := math.Sin(theta)
+		ka = math.Sin((1-t)*theta) / sin
+		kb = math.Sin(t*theta) / sin
+	}
+	q := Quaternion{
+		ka*a.W + kb*b.W,
+		ka*a.X + kb*b.X,
+		ka*a.Y + kb*b.Y,
+		ka*a.Z + kb*b.Z,
+	}
+	return q.Normalize()
+}
@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuaternionRotate(t *testing.T) {
+	q := QuaternionFromAxisAngle(Vector{0, 0, 1}, math.Pi/2)
+	act := q.Rotate(Vector{1, 0, 0})
+	if exp := (Vector{0, 1, 0}); !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestQuaternionMat4(t *testing.T) {
+	axis := Vector{1, 2, 3}
+	q := QuaternionFromAxisAngle(axis, 1.2)
+	if m := q.Mat4(); !Mat4sEqual(m, Rotate(axis, 1.2), 1e-9) {
+		t.Fatalf("exp: %v act: %v", Rotate(axis, 1.2), m)
+	}
+}
+
+func TestQuaternionMul(t *testing.T) {
+	z := Vector{0, 0, 1}
+	a := QuaternionFromAxisAngle(z, 0.5)
+	b := QuaternionFromAxisAngle(z, 0.25)
+	ab := a.Mul(b)
+	exp := QuaternionFromAxisAngle(z, 0.75)
+	act := ab.Rotate(Vector{1, 0, 0})
+	if v := exp.Rotate(Vector{1, 0, 0}); !VectorsEqual(act, v, epsilon) {
+		t.Fatalf("exp: %v act: %v", v, act)
+	}
+}
+
+func TestSlerp(t *testing.T) {
+	z := Vector{0, 0, 1}
+	a := QuaternionFromAxisAngle(z, 0)
+	b := QuaternionFromAxisAngle(z, math.Pi/2)
+	for _, tt := range []float64{0, 0.25, 0.5, 1} {
+		q := Slerp(a, b, tt)
+		act := q.Rotate(Vector{1, 0, 0})
+		angle := tt * math.Pi / 2
+		if exp := (Vector{math.Cos(angle), math.Sin(angle), 0}); !VectorsEqual(act, exp, epsilon) {
+			t.Fatalf("t=%v: exp: %v act: %v", tt, exp, act)
+		}
+	}
+
+	// Shortest arc even when quaternions lie in opposite hemispheres.
+	c := QuaternionFromAxisAngle(z, math.Pi/2)
+	c = Quaternion{-c.W, -c.X, -c.Y, -c.Z}
+	q := Slerp(a, c, 0.5)
+	act := q.Rotate(Vector{1, 0, 0})
+	if exp := (Vector{math.Sqrt2 / 2, math.Sqrt2 / 2, 0}); !VectorsEqual(act, exp, epsilon) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Sampling functions map random numbers drawn from rng to points distributed
+// over simple domains.  Hemispheres are centered on the +z axis: use an ONB to
+// orient them around an arbitrary direction.
+//
+// Formulas taken from:
+// 	Pharr et al., "Physically Based Rendering", 3rd edition, section 13.6.
+
+// UniformSampleSphere returns a unit vector uniformly distributed over the
+// unit sphere.
+func UniformSampleSphere(rng *rand.Rand) Vector {
+	z := 1 - 2*rng.Float64()
+	r := math.Sqrt(math.Max(0, 1-z*z))
+	phi := 2 * math.Pi * rng.Float64()
+	return Vector{r * math.Cos(phi), r * math.Sin(phi), z}
+}
+
+// UniformSampleHemisphere returns a unit vector uniformly distributed over the
+// z >= 0 hemisphere.
+func UniformSampleHemisphere(rng *rand.Rand) Vector {
+	z := rng.Float64()
+	r := math.Sqrt(math.Max(0, 1-z*z))
+	phi := 2 * math.Pi * rng.Float64()
+	return Vector{r * math.Cos(phi), r * math.Sin(phi), z}
+}
+
+// UniformSampleCone returns a unit vector uniformly distributed over the cone
+// of directions around the +z axis whose angle with it has cosine cosMax.
+func UniformSampleCone(rng *rand.Rand, cosMax float64) Vector {
+	z := 1 - rng.Float64()*(1-cosMax)
+	r := math.Sqrt(math.Max(0, 1-z*z))
+	phi := 2 * math.Pi * rng.Float64()
+	return Vector{r * math.Cos(phi), r * math.Sin(phi), z}
+}
+
+// CosineSampleHemisphere returns a unit vector over the z >= 0 hemisphere
+// with a density proportional to the cosine of its angle with the z axis.
+func CosineSampleHemisphere(rng *rand.Rand) Vector {
+	d := ConcentricSampleDisk(rng)
+	z := math.Sqrt(math.Max(0, 1-d.X*d.X-d.Y*d.Y))
+	return Vector{d.X, d.Y, z}
+}
+
+// ConcentricSampleDisk returns a point uniformly distributed over the unit
+// disk.  It uses Shirley's concentric mapping which preserves the
+// stratification of its inputs better than the polar one.
+func ConcentricSampleDisk(rng *rand.Rand) Point2d {
+	ux := 2*rng.Float64() - 1
+	uy := 2*rng.Float64() - 1
+	if ux == 0 && uy == 0 {
+		return Point2d{0, 0}
+	}
+	var r, theta float64
+	if math.Abs(ux) > math.Abs(uy) {
+		r = ux
+		theta = math.Pi / 4 * (uy / ux)
+	} else {
+		r = uy
+		theta = math.Pi/2 - math.Pi/4*(ux/uy)
+	}
+	return Point2d{r * math.Cos(theta), r * math.Sin(theta)}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const nsamples = 10000
+
+func TestUniformSampleSphere(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var mean Vector
+	for i := 0; i < nsamples; i++ {
+		v := UniformSampleSphere(rng)
+		if !FloatsEqual(v.Module(), 1, 1e-9) {
+			t.Fatalf("not a unit vector: %v", v)
+		}
+		mean = mean.Add(v)
+	}
+	mean = mean.Scale(1.0 / nsamples)
+	if !VectorsEqual(mean, Vector{0, 0, 0}, 0.05) {
+		t.Fatalf("not uniform: mean: %v", mean)
+	}
+}
+
+func TestSampleHemisphere(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, td := range []struct {
+		name   string
+		sample func(*rand.Rand) Vector
+		meanZ  float64
+	}{
+		{"uniform", UniformSampleHemisphere, 1.0 / 2},
+		{"cosine", CosineSampleHemisphere, 2.0 / 3},
+	} {
+		var mean Vector
+		for i := 0; i < nsamples; i++ {
+			v := td.sample(rng)
+			if !FloatsEqual(v.Module(), 1, 1e-9) || v.Z < 0 {
+				t.Fatalf("%s: not in hemisphere: %v", td.name, v)
+			}
+			mean = mean.Add(v)
+		}
+		mean = mean.Scale(1.0 / nsamples)
+		if exp := (Vector{0, 0, td.meanZ}); !VectorsEqual(mean, exp, 0.02) {
+			t.Fatalf("%s: bad mean: exp: %v act: %v", td.name, exp, mean)
+		}
+	}
+}
+
+func TestUniformSampleCone(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const cosMax = 0.8
+	var mean Vector
+	for i := 0; i < nsamples; i++ {
+		v := UniformSampleCone(rng, cosMax)
+		if !FloatsEqual(v.Module(), 1, 1e-9) || v.Z < cosMax {
+			t.Fatalf("not in cone: %v", v)
+		}
+		mean = mean.Add(v)
+	}
+	mean = mean.Scale(1.0 / nsamples)
+	if exp := (Vector{0, 0, (1 + cosMax) / 2}); !VectorsEqual(mean, exp, 0.02) {
+		t.Fatalf("bad mean: exp: %v act: %v", exp, mean)
+	}
+}
+
+func TestConcentricSampleDisk(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	inner := 0
+	for i := 0; i < nsamples; i++ {
+		p := ConcentricSampleDisk(rng)
+		r2 := p.X*p.X + p.Y*p.Y
+		if r2 > 1+1e-9 {
+			t.Fatalf("outside unit disk: %v", p)
+		}
+		if r2 < 0.25 {
+			inner++
+		}
+	}
+	// A disk of radius 1/2 covers a quarter of the unit disk area.
+	if frac := float64(inner) / nsamples; !FloatsEqual(frac, 0.25, 0.02) {
+		t.Fatalf("not uniform: inner fraction: %v", frac)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Torus is the surface swept by a circle of radius Minor whose center moves
+// along a circle of radius Major around Axis.
+type Torus struct {
+	Center Point
+	Axis   Vector // normal to the plane of the major circle
+	Major  float64
+	Minor  float64
+}
+
+func (to *Torus) Validate() error {
+	if to.Minor <= 0 || to.Major <= 0 {
+		return fmt.Errorf("invalid torus: negative or null radius")
+	}
+	if to.Minor > to.Major {
+		return fmt.Errorf("invalid torus: minor radius larger than major one")
+	}
+	if to.Axis.Module() == 0 {
+		return fmt.Errorf("invalid torus: null axis")
+	}
+	return nil
+}
+
+// coreOffset returns the vector from the point of the major circle of to
+// nearest from p to p.
+func (to *Torus) coreOffset(p *Point) Vector {
+	up := to.Axis.UnitVector()
+	v := MakeVector(*p, to.Center)
+	radial := v.Sub(up.Scale(DotProduct(&v, &up)))
+	if radial.Module() == 0 {
+		// p on the axis: all points of the major circle are equally near.
+		radial = MakeONB(up).U
+	} else {
+		radial = radial.UnitVector()
+	}
+	return v.Sub(radial.Scale(to.Major))
+}
+
+// Contains returns whether p lies inside to or on its surface.
+func (to *Torus) Contains(p *Point) bool {
+	d := to.coreOffset(p)
+	return DotProduct(&d, &d) <= to.Minor*to.Minor
+}
+
+// NormalVectorAt returns the outward unit normal vector of to at p.
+func (to *Torus) NormalVectorAt(p *Point) Vector {
+	d := to.coreOffset(p)
+	return d.UnitVector()
+}
+
+// Return the point of r nearest from r.Origin intersecting to, in
+// [r.TMin..r.TMax].  Set ok to false if there is no intersection.  t is the
+// parameter of the intersection point along r.
+//
+// The ray is expressed in a frame centered on the torus whose z-axis is the
+// torus axis, where the torus is the set of points such that
+// (x^2 + y^2 + z^2 + R^2 - r^2)^2 == 4*R^2*(x^2 + y^2).  Substituting the ray
+// equation yields a quartic in t.
+func RayTorusIntersection(to Torus, r Ray) (p Point, t float64, ok bool) {
+	// Start from the bounding sphere and use a unit direction to keep the
+	// quartic coefficients well-scaled.
+	bound := Sphere{to.Center, to.Major + to.Minor}
+	t0, t1, hit := RaySphereRoots(bound, r)
+	if !hit || t1 < r.TMin || t0 > r.TMax {
+		return Origin, math.MaxFloat64, false
+	}
+	dlen := r.Dir.Module()
+	start := math.Max(t0, r.TMin)
+
+	frame := MakeONB(to.Axis)
+	o := frame.ToLocal(MakeVector(r.At(start), to.Center))
+	d := frame.ToLocal(r.Dir.Scale(1 / dlen))
+
+	R2 := to.Major * to.Major
+	dd := DotProduct(&d, &d)
+	od := DotProduct(&o, &d)
+	k := DotProduct(&o, &o) + R2 - to.Minor*to.Minor
+	roots, n := SolveQuartic(
+		dd*dd,
+		4*dd*od,
+		2*dd*k+4*od*od-4*R2*(d.X*d.X+d.Y*d.Y),
+		4*od*k-8*R2*(o.X*d.X+o.Y*d.Y),
+		k*k-4*R2*(o.X*o.X+o.Y*o.Y))
+	for _, s := range roots[:n] {
+		t := start + s/dlen
+		if t >= r.TMin && t <= r.TMax {
+			return r.At(t), t, true
+		}
+	}
+	return Origin, math.MaxFloat64, false
+}
@@ -0,0 +1,68 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package geom
+
+import (
+	"testing"
+)
+
+func TestRayTorusIntersection(t *testing.T) {
+	// Torus in the xz plane of radii 3 and 1 centered on (0, 0, 10).
+	to := Torus{Point{0, 0, 10}, Vector{0, 2, 0}, 3, 1}
+	for _, td := range []solidTestCase{
+		{MakeRay(Point{-10, 0, 10}, Vector{1, 0, 0}), true, Point{-4, 0, 10}, Vector{-1, 0, 0}},
+		{MakeRay(Point{0, 0, 10}, Vector{1, 0, 0}), true, Point{2, 0, 10}, Vector{-1, 0, 0}},
+		{MakeRay(Point{3, 10, 10}, Vector{0, -1, 0}), true, Point{3, 1, 10}, Vector{0, 1, 0}},
+		{MakeRay(Point{0, 0, 0}, Vector{0, 0, 1}), true, Point{0, 0, 6}, Vector{0, 0, -1}},
+		{MakeRay(Point{0, 10, 10}, Vector{0, -1, 0}), false, Origin, Vector{}},
+		{MakeRay(Point{-10, 1.5, 10}, Vector{1, 0, 0}), false, Origin, Vector{}},
+		// Starts inside the tube.
+		{MakeRay(Point{3, 0, 10}, Vector{1, 0, 0}), true, Point{4, 0, 10}, Vector{1, 0, 0}},
+	} {
+		checkSolidHit(t, td, func(r Ray) (Point, float64, Vector, bool) {
+			p, tt, ok := RayTorusIntersection(to, r)
+			if !ok {
+				return p, tt, Vector{}, ok
+			}
+			return p, tt, to.NormalVectorAt(&p), ok
+		})
+	}
+}
+
+func TestTorusContains(t *testing.T) {
+	to := Torus{Point{0, 0, 0}, Vector{0, 0, 1}, 3, 1}
+	for _, td := range []struct {
+		p   Point
+		exp bool
+	}{
+		{Point{3, 0, 0}, true},
+		{Point{0, -3.5, 0.5}, true},
+		{Point{0, 0, 0}, false},
+		{Point{3, 0, 1.1}, false},
+		{Point{4.1, 0, 0}, false},
+	} {
+		if act := to.Contains(&td.p); act != td.exp {
+			t.Errorf("%v: exp: %v act: %v", td.p, td.exp, act)
+		}
+	}
+}
@@ -47,6 +47,13 @@ var (
 	outfile    = flag.String("o", "", "output file")
 	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 	loop       = flag.Int("l", 1, "# of rendering loop (for profiling)")
+	tile       = flag.Int("tile", 0, "tile size in pixels (0 for one stripe per job)")
+	order      = flag.String("order", "scanline", "tile order: scanline, spiral or hilbert")
+	tsamples   = flag.Int("t", 1, "# of time samples per pixel (motion blur)")
+	maxsamples = flag.Int("maxsamples", 0, "max # of samples per noisy pixel (adaptive sampling)")
+	debug      = flag.Bool("debug", false, "report and highlight NaN/Inf pixels")
+	alpha      = flag.Bool("alpha", false, "transparent background and shadow catchers")
+	frame      = flag.Int("frame", 0, "animation frame to render (camera keys)")
 )
 
 func main() {
@@ -105,8 +112,22 @@ func renderScene(s *raytracer.Scene) (*image.RGBA, error) {
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
+	tileOrder, err := raytracer.ParseTileOrder(*order)
+	if err != nil {
+		return nil, err
+	}
+	opts := raytracer.Options{
+		Stripes:     *njobs,
+		TileSize:    *tile,
+		TileOrder:   tileOrder,
+		TimeSamples: *tsamples,
+		MaxSamples:  *maxsamples,
+		CheckNaN:    *debug,
+		Alpha:       *alpha,
+		Frame:       *frame,
+	}
 	for i := 0; i < *loop-1; i++ {
-		s.Render(*njobs)
+		s.RenderWithOptions(&opts)
 	}
-	return s.Render(*njobs)
+	return s.RenderWithOptions(&opts)
 }
@@ -0,0 +1,318 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package jobs queues rendering jobs and runs them on a bounded pool of
+// workers.  It lets long-running processes such as a rendering server accept
+// many requests without starting more renders than the machine can handle.
+package jobs
+
+import (
+	"container/heap"
+	"container/list"
+	"errors"
+	"github.com/nthery/goraytracer/raytracer"
+	"image"
+	"sync"
+)
+
+var (
+	ErrClosed     = errors.New("job manager closed")
+	ErrUnknownJob = errors.New("unknown job")
+	ErrCancelled  = errors.New("job cancelled")
+)
+
+// An ID identifies a job submitted to a Manager.
+type ID uint64
+
+// A State is a step in the life cycle of a job.
+type State int
+
+const (
+	Queued State = iota
+	Running
+	Done
+	Failed
+	Cancelled
+)
+
+func (s State) String() string {
+	switch s {
+	case Queued:
+		return "queued"
+	case Running:
+		return "running"
+	case Done:
+		return "done"
+	case Failed:
+		return "failed"
+	case Cancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// finished returns whether a job in state s will not change state anymore.
+func (s State) finished() bool {
+	return s == Done || s == Failed || s == Cancelled
+}
+
+type job struct {
+	id       ID
+	scene    *raytracer.Scene
+	nstripes int
+	priority int
+	seq      uint64 // submission order, breaks priority ties
+	index    int    // position in queue, -1 when not queued
+	state    State
+	img      *image.RGBA
+	err      error
+	done     chan struct{} // closed when job finished
+	cancel   chan struct{} // closed to stop rendering
+	retained *list.Element // in Manager.retained once finished
+}
+
+// jobQueue is a heap of jobs ordered by decreasing priority then submission
+// order.
+type jobQueue []*job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x interface{}) {
+	j := x.(*job)
+	j.index = len(*q)
+	*q = append(*q, j)
+}
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	j.index = -1
+	*q = old[:n-1]
+	return j
+}
+
+// A Manager runs submitted jobs on a fixed number of workers.  Pending jobs
+// are started in decreasing priority order.  Jobs with the same priority are
+// started in submission order.
+//
+// Finished jobs are kept until their result is claimed with Result.  To bound
+// the memory used by results never claimed, only the last MaxFinished
+// finished jobs are kept, older ones being forgotten.
+type Manager struct {
+	mu     sync.Mutex
+	cond   *sync.Cond // signaled when queue grows or manager closes
+	queue  jobQueue
+	jobs   map[ID]*job
+	nextID ID
+	seq    uint64
+	closed bool
+	wg     sync.WaitGroup
+
+	retained    list.List // finished jobs in m.jobs, oldest first
+	maxFinished int       // MaxFinished if zero
+}
+
+// MaxFinished is the number of finished jobs whose results a Manager keeps
+// until claimed.
+const MaxFinished = 64
+
+// NewManager creates a manager running at most nworkers jobs concurrently.
+func NewManager(nworkers int) *Manager {
+	if nworkers < 1 {
+		nworkers = 1
+	}
+	m := &Manager{jobs: make(map[ID]*job)}
+	m.cond = sync.NewCond(&m.mu)
+	m.wg.Add(nworkers)
+	for i := 0; i < nworkers; i++ {
+		go m.work()
+	}
+	return m
+}
+
+// Submit queues a job rendering s with nstripes stripes (see
+// raytracer.Scene.Render).  The job renders a copy of s so the caller is free
+// to modify s once Submit returns.
+func (m *Manager) Submit(s *raytracer.Scene, nstripes, priority int) (ID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+	m.nextID++
+	m.seq++
+	j := &job{
+		id:       m.nextID,
+		scene:    s.Clone(),
+		nstripes: nstripes,
+		priority: priority,
+		seq:      m.seq,
+		state:    Queued,
+		done:     make(chan struct{}),
+		cancel:   make(chan struct{}),
+	}
+	m.jobs[j.id] = j
+	heap.Push(&m.queue, j)
+	m.cond.Signal()
+	return j.id, nil
+}
+
+// Status returns the current state of a job.
+func (m *Manager) Status(id ID) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return 0, ErrUnknownJob
+	}
+	return j.state, nil
+}
+
+// Cancel cancels a job, which is forgotten and its ID becomes unknown.  A
+// queued job is removed from the queue.  A running job stops rendering within
+// a row of pixels per stripe, freeing its worker.  Pending calls to Result
+// return ErrCancelled.  Cancelling a finished job has no effect.
+func (m *Manager) Cancel(id ID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrUnknownJob
+	}
+	if j.state.finished() {
+		return nil
+	}
+	if j.index >= 0 {
+		heap.Remove(&m.queue, j.index)
+	}
+	close(j.cancel)
+	m.finish(j, nil, ErrCancelled, Cancelled)
+	m.forget(j)
+	return nil
+}
+
+// Result blocks until a job finishes and returns the rendered image.  The job
+// is forgotten afterwards and its ID becomes unknown.
+func (m *Manager) Result(id ID) (*image.RGBA, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+
+	<-j.done
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.forget(j)
+	return j.img, j.err
+}
+
+// Close stops accepting new jobs, cancels queued ones and waits for running
+// ones to finish.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	for m.queue.Len() > 0 {
+		j := heap.Pop(&m.queue).(*job)
+		m.finish(j, nil, ErrCancelled, Cancelled)
+	}
+	m.cond.Broadcast()
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// finish records the outcome of j and forgets the oldest finished jobs
+// beyond the retention limit.  Must be called with m.mu held.
+func (m *Manager) finish(j *job, img *image.RGBA, err error, state State) {
+	j.img = img
+	j.err = err
+	j.state = state
+	close(j.done)
+	j.retained = m.retained.PushBack(j)
+	limit := m.maxFinished
+	if limit == 0 {
+		limit = MaxFinished
+	}
+	for m.retained.Len() > limit {
+		m.forget(m.retained.Front().Value.(*job))
+	}
+}
+
+// forget removes j from m, if not done already.  Must be called with m.mu
+// held.
+func (m *Manager) forget(j *job) {
+	if m.jobs[j.id] != j {
+		return
+	}
+	delete(m.jobs, j.id)
+	if j.retained != nil {
+		m.retained.Remove(j.retained)
+		j.retained = nil
+	}
+}
+
+func (m *Manager) work() {
+	defer m.wg.Done()
+	for {
+		m.mu.Lock()
+		for m.queue.Len() == 0 && !m.closed {
+			m.cond.Wait()
+		}
+		if m.queue.Len() == 0 {
+			m.mu.Unlock()
+			return
+		}
+		j := heap.Pop(&m.queue).(*job)
+		j.state = Running
+		m.mu.Unlock()
+
+		opts := raytracer.Options{Stripes: j.nstripes, Cancel: j.cancel}
+		img, err := j.scene.RenderWithOptions(&opts)
+
+		m.mu.Lock()
+		if j.state == Running {
+			if err != nil {
+				m.finish(j, nil, err, Failed)
+			} else {
+				m.finish(j, img, nil, Done)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package jobs
+
+import (
+	"container/heap"
+	"github.com/nthery/goraytracer/geom"
+	"github.com/nthery/goraytracer/raytracer"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testScene() *raytracer.Scene {
+	return &raytracer.Scene{
+		ViewFrustum: raytracer.Frustum{
+			Near: geom.Plane2d{geom.Point2d{-10, 10}, geom.Point2d{10, -10}, 0},
+			Far:  geom.Plane2d{geom.Point2d{-20, 20}, geom.Point2d{20, -20}, 100},
+		},
+		Lights: []raytracer.Light{{Position: geom.Point{-100, 30, 0}}},
+		Objects: raytracer.ObjectList{
+			&raytracer.Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 80}, 10}, Color: raytracer.Color{1, 0, 0}},
+		},
+		Bg: raytracer.Color{0.5, 0.5, 0.5},
+		Kd: 0.9,
+	}
+}
+
+func TestSubmitAndResult(t *testing.T) {
+	m := NewManager(2)
+	defer m.Close()
+
+	var ids []ID
+	for i := 0; i < 5; i++ {
+		id, err := m.Submit(testScene(), 2, i)
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		img, err := m.Result(id)
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		if w := img.Bounds().Dx(); w != 20 {
+			t.Fatalf("bad width: exp: %v act: %v", 20, w)
+		}
+		if _, err := m.Status(id); err != ErrUnknownJob {
+			t.Fatalf("job not forgotten: %v", err)
+		}
+	}
+}
+
+func TestFailedJob(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+
+	s := testScene()
+	s.Kd = 2
+	id, _ := m.Submit(s, 1, 0)
+	if _, err := m.Result(id); err == nil {
+		t.Fatalf("invalid scene rendered")
+	}
+}
+
+func TestCancelQueued(t *testing.T) {
+	// No worker so that the job stays queued.
+	m := &Manager{jobs: make(map[ID]*job)}
+	m.cond = sync.NewCond(&m.mu)
+	defer m.Close()
+
+	id, _ := m.Submit(testScene(), 1, 0)
+	j := m.jobs[id]
+	if err := m.Cancel(id); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	if j.state != Cancelled || j.err != ErrCancelled {
+		t.Fatalf("bad outcome: exp: %v act: %v %v", Cancelled, j.state, j.err)
+	}
+	if m.queue.Len() != 0 {
+		t.Fatalf("cancelled job still queued")
+	}
+	if _, err := m.Status(id); err != ErrUnknownJob {
+		t.Fatalf("cancelled job not forgotten: %v", err)
+	}
+	if _, err := m.Result(id); err != ErrUnknownJob {
+		t.Fatalf("bad error: exp: %v act: %v", ErrUnknownJob, err)
+	}
+}
+
+func TestFinishedJobsEvicted(t *testing.T) {
+	m := NewManager(1)
+	m.maxFinished = 2
+	defer m.Close()
+
+	var ids []ID
+	for i := 0; i < 4; i++ {
+		id, _ := m.Submit(testScene(), 1, 0)
+		ids = append(ids, id)
+	}
+	// Wait for the last job, run last by the only worker.
+	m.mu.Lock()
+	last := m.jobs[ids[3]]
+	m.mu.Unlock()
+	<-last.done
+
+	for i, id := range ids {
+		_, err := m.Status(id)
+		if exp := i >= 2; (err == nil) != exp {
+			t.Errorf("#%d: exp: retained %v act: %v", i, exp, err)
+		}
+	}
+	if _, err := m.Result(ids[2]); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	m.mu.Lock()
+	n := m.retained.Len()
+	m.mu.Unlock()
+	if n != 1 {
+		t.Errorf("exp: 1 retained job act: %d", n)
+	}
+}
+
+func TestCancelRunning(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+
+	// Image large enough to take much longer to render than the timeout
+	// below.
+	big := testScene()
+	big.ViewFrustum.Near = geom.Plane2d{geom.Point2d{-2000, 2000}, geom.Point2d{2000, -2000}, 0}
+	big.ViewFrustum.Far = geom.Plane2d{geom.Point2d{-4000, 4000}, geom.Point2d{4000, -4000}, 100}
+	id, _ := m.Submit(big, 1, 0)
+	for {
+		if st, _ := m.Status(id); st == Running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := m.Cancel(id); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+
+	// The worker is freed for the next job.
+	next, _ := m.Submit(testScene(), 1, 0)
+	res := make(chan error, 1)
+	go func() {
+		_, err := m.Result(next)
+		res <- err
+	}()
+	select {
+	case err := <-res:
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("cancelled job still rendering")
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	var q jobQueue
+	for i, p := range []int{1, 3, 2, 3} {
+		heap.Push(&q, &job{id: ID(i), priority: p, seq: uint64(i)})
+	}
+	for _, exp := range []ID{1, 3, 2, 0} {
+		if act := heap.Pop(&q).(*job).id; act != exp {
+			t.Fatalf("bad order: exp: %v act: %v", exp, act)
+		}
+	}
+}
+
+func TestSubmitAfterClose(t *testing.T) {
+	m := NewManager(1)
+	m.Close()
+	if _, err := m.Submit(testScene(), 1, 0); err != ErrClosed {
+		t.Fatalf("bad error: exp: %v act: %v", ErrClosed, err)
+	}
+}
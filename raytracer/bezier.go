@@ -0,0 +1,144 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+)
+
+// defaultBezierDivisions is the number of subdivisions along each parameter
+// of Bézier patches when Bezier.Divisions is zero.
+const defaultBezierDivisions = 8
+
+// Bezier objects are surfaces made of bicubic Bézier patches, such as the
+// Utah teapot.  They are rendered as a smooth triangle mesh built by
+// tessellating each patch into Divisions x Divisions quads before rendering.
+// Like meshes, they are assumed to be closed.
+type Bezier struct {
+	Patches   []geom.BezierPatch
+	Divisions int // subdivisions along each parameter of each patch
+	Color     Color
+	Material  *Material
+	Visibility
+
+	mesh *Mesh // tessellation, set by prepare
+}
+
+func init() {
+	RegisterObjectType("Bezier", func() Object { return new(Bezier) })
+}
+
+func (b *Bezier) prepare(protos ObjectMap) error {
+	b.mesh = b.Mesh()
+	return nil
+}
+
+// Mesh returns the tessellation of b into a triangle mesh with normals
+// computed from the patches.
+func (b *Bezier) Mesh() *Mesh {
+	n := b.Divisions
+	if n == 0 {
+		n = defaultBezierDivisions
+	}
+	m := &Mesh{Color: b.Color}
+	for pi := range b.Patches {
+		bp := &b.Patches[pi]
+		base := len(m.Vertices)
+		for i := 0; i <= n; i++ {
+			v := float64(i) / float64(n)
+			for j := 0; j <= n; j++ {
+				u := float64(j) / float64(n)
+				p, _, _ := bp.Evaluate(u, v)
+				m.Vertices = append(m.Vertices, p)
+				m.Normals = append(m.Normals, bp.NormalAt(u, v))
+			}
+		}
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				k := base + i*(n+1) + j
+				m.Indices = append(m.Indices, k, k+1, k+n+2, k, k+n+2, k+n+1)
+			}
+		}
+	}
+	return m
+}
+
+func (b *Bezier) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	h, ok := b.mesh.Intersect(ray, time)
+	h.Object = b
+	return h, ok
+}
+
+func (b *Bezier) NormalAt(h *Hit, time float64) geom.Vector {
+	return b.mesh.NormalAt(h, time)
+}
+
+func (b *Bezier) Bounds() geom.AABB {
+	bb := geom.EmptyAABB()
+	for i := range b.Patches {
+		bb = bb.Union(b.Patches[i].Bounds())
+	}
+	return bb
+}
+
+func (b *Bezier) Inside(p geom.Point, time float64) bool {
+	return b.mesh.Inside(p, time)
+}
+
+func (b *Bezier) ColorAt(h *Hit) Color {
+	return b.Color
+}
+
+func (b *Bezier) MaterialAt(h *Hit) *Material {
+	return b.Material
+}
+
+func (b *Bezier) Clone() Object {
+	c := *b
+	c.Patches = append([]geom.BezierPatch(nil), b.Patches...)
+	c.mesh = nil
+	c.Material = b.Material.clone()
+	return &c
+}
+
+func (b *Bezier) Validate() error {
+	if len(b.Patches) == 0 {
+		return fmt.Errorf("invalid Bézier surface: no patch")
+	}
+	if b.Divisions < 0 {
+		return fmt.Errorf("invalid Bézier surface: negative divisions: %d", b.Divisions)
+	}
+	if err := b.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid Bézier surface: %v", err)
+	}
+	if b.Material != nil {
+		if err := b.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid Bézier surface: %v", err)
+		}
+		if err := b.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid Bézier surface: %v", err)
+		}
+	}
+	return nil
+}
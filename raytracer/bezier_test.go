@@ -0,0 +1,82 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+// flatBezier returns a Bézier surface made of a single flat patch covering
+// [-5..5]x[-5..5] in the plane z = 60 and facing the eye.
+func flatBezier() *Bezier {
+	var bp geom.BezierPatch
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			bp[4*i+j] = geom.Point{5 - 10*float64(j)/3, -5 + 10*float64(i)/3, 60}
+		}
+	}
+	return &Bezier{Patches: []geom.BezierPatch{bp}, Divisions: 2, Color: Color{0, 1, 0}}
+}
+
+func TestBezierMesh(t *testing.T) {
+	m := flatBezier().Mesh()
+	if err := m.Validate(); err != nil {
+		t.Fatalf("invalid tessellation: %v", err)
+	}
+	if act := len(m.Indices) / 3; act != 8 {
+		t.Errorf("exp: 8 triangles act: %d", act)
+	}
+	for i := 0; i < len(m.Indices)/3; i++ {
+		tr := m.triangle(i)
+		if n, exp := tr.Normal(), (geom.Vector{0, 0, -1}); !geom.VectorsEqual(n, exp, 1e-9) {
+			t.Errorf("triangle #%d: exp: %v act: %v", i, exp, n)
+		}
+	}
+}
+
+func TestBezierRender(t *testing.T) {
+	s := testScene()
+	s.Objects = append(s.Objects, flatBezier())
+	// Round-trip through JSON.
+	data, err := json.Marshal(s.Objects)
+	if err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.Objects); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(10, 10); act.G == 0 || act.R != act.B {
+		t.Fatalf("patch not rendered: %v", act)
+	}
+
+	s.Objects[1].(*Bezier).Patches = nil
+	if _, err := s.Render(1); err == nil {
+		t.Fatalf("invalid Bézier surface accepted")
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// A BlobCenter is a source of the field defining a Blob.  Its contribution to
+// the field decreases smoothly from Strength at its center down to zero at
+// Radius.  Negative strengths carve the blob.
+type BlobCenter struct {
+	Center   geom.Point
+	Radius   float64
+	Strength float64
+}
+
+// contribution returns the field generated by c at p.
+func (c *BlobCenter) contribution(p geom.Point) float64 {
+	d2 := geom.DistanceSquared(p, c.Center) / (c.Radius * c.Radius)
+	if d2 >= 1 {
+		return 0
+	}
+	k := 1 - d2
+	return c.Strength * k * k
+}
+
+// Blob objects are metaballs: the iso-surface where the sum of the fields of
+// the centers equals Threshold.  Points where the field exceeds Threshold are
+// inside the blob.
+type Blob struct {
+	Centers   []BlobCenter
+	Threshold float64
+	Color     Color
+	Material  *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Blob", func() Object { return new(Blob) })
+}
+
+// blobSteps is the # of steps per radius of the smallest center when marching
+// along rays.  Thinner features may be missed.
+const blobSteps = 8
+
+func (b *Blob) field(p geom.Point) float64 {
+	f := 0.0
+	for i := range b.Centers {
+		f += b.Centers[i].contribution(p)
+	}
+	return f
+}
+
+// Intersect marches along ray inside the spheres of influence of the centers
+// until the field crosses the threshold and then locates the crossing by
+// bisection.
+func (b *Blob) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	tlo, thi := math.Inf(1), math.Inf(-1)
+	minRadius := math.Inf(1)
+	for _, c := range b.Centers {
+		t0, t1, ok := geom.RaySphereRoots(geom.Sphere{c.Center, c.Radius}, ray)
+		if ok {
+			tlo = math.Min(tlo, t0)
+			thi = math.Max(thi, t1)
+		}
+		minRadius = math.Min(minRadius, c.Radius)
+	}
+	tlo = math.Max(tlo, ray.TMin)
+	thi = math.Min(thi, ray.TMax)
+	if tlo > thi {
+		return Hit{}, false
+	}
+
+	dt := minRadius / blobSteps / ray.Dir.Module()
+	g := func(t float64) float64 { return b.field(ray.At(t)) - b.Threshold }
+	prev := g(tlo)
+	for t0 := tlo; t0 < thi; t0 += dt {
+		t1 := math.Min(t0+dt, thi)
+		cur := g(t1)
+		if (prev < 0) != (cur < 0) {
+			t := bisect(g, t0, t1, prev < 0)
+			return Hit{T: t, Point: ray.At(t), Object: b}, true
+		}
+		prev = cur
+	}
+	return Hit{}, false
+}
+
+// bisect returns the root of g in [lo..hi].  neg tells whether g(lo) < 0.
+func bisect(g func(float64) float64, lo, hi float64, neg bool) float64 {
+	for i := 0; i < 50; i++ {
+		mid := (lo + hi) / 2
+		if (g(mid) < 0) == neg {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// NormalAt returns the normalized opposite of the field gradient.
+func (b *Blob) NormalAt(h *Hit, time float64) geom.Vector {
+	var grad geom.Vector
+	for _, c := range b.Centers {
+		v := geom.MakeVector(h.Point, c.Center)
+		r2 := c.Radius * c.Radius
+		d2 := geom.DotProduct(&v, &v) / r2
+		if d2 >= 1 {
+			continue
+		}
+		grad = grad.Add(v.Scale(-4 * c.Strength * (1 - d2) / r2))
+	}
+	n := grad.Neg()
+	return n.UnitVector()
+}
+
+func (b *Blob) Bounds() geom.AABB {
+	bb := geom.EmptyAABB()
+	for _, c := range b.Centers {
+		if c.Strength > 0 {
+			bb = bb.Union(sphereBounds(geom.Sphere{c.Center, c.Radius}))
+		}
+	}
+	return bb
+}
+
+func (b *Blob) Inside(p geom.Point, time float64) bool {
+	return b.field(p) >= b.Threshold
+}
+
+func (b *Blob) ColorAt(h *Hit) Color {
+	return b.Color
+}
+
+func (b *Blob) MaterialAt(h *Hit) *Material {
+	return b.Material
+}
+
+func (b *Blob) Clone() Object {
+	c := *b
+	c.Centers = append([]BlobCenter(nil), b.Centers...)
+	c.Material = b.Material.clone()
+	return &c
+}
+
+func (b *Blob) Validate() error {
+	if len(b.Centers) == 0 {
+		return fmt.Errorf("invalid blob: no center")
+	}
+	for _, c := range b.Centers {
+		if c.Radius <= 0 {
+			return fmt.Errorf("invalid blob: negative or null radius")
+		}
+	}
+	if b.Threshold <= 0 {
+		return fmt.Errorf("invalid blob: negative or null threshold")
+	}
+	if err := b.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid blob: %v", err)
+	}
+	if b.Material != nil {
+		if err := b.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid blob: %v", err)
+		}
+		if err := b.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid blob: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"testing"
+)
+
+func TestBlobSingleCenter(t *testing.T) {
+	// A single center yields a sphere of radius sqrt(0.5)*2.
+	b := &Blob{
+		Centers:   []BlobCenter{{geom.Point{0, 0, 10}, 2, 1}},
+		Threshold: 0.25,
+	}
+	r := 2 * math.Sqrt(0.5)
+	for _, ray := range []geom.Ray{
+		geom.MakeRay(geom.Point{0, 0, 0}, geom.Vector{0, 0, 1}),
+		geom.MakeRay(geom.Point{0, 0, 0}, geom.Vector{0, 0, 100}),
+		geom.MakeRay(geom.Point{0, 0, 10}, geom.Vector{0, 1, 0}),
+	} {
+		h, ok := b.Intersect(ray, 0)
+		if !ok {
+			t.Errorf("%v: blob missed", ray)
+			continue
+		}
+		exp := geom.Point{0, 0, 10 - r}
+		n := geom.Vector{0, 0, -1}
+		if ray.Origin.Z == 10 {
+			exp = geom.Point{0, r, 10}
+			n = geom.Vector{0, 1, 0}
+		}
+		if !geom.PointsEqual(h.Point, exp, 1e-6) {
+			t.Errorf("%v: exp: %v act: %v", ray, exp, h.Point)
+		}
+		if act := b.NormalAt(&h, 0); !geom.VectorsEqual(act, n, 1e-6) {
+			t.Errorf("%v: exp: %v act: %v", ray, n, act)
+		}
+	}
+
+	if _, ok := b.Intersect(geom.MakeRay(geom.Point{1.5, 0, 0}, geom.Vector{0, 0, 1}), 0); ok {
+		t.Errorf("blob hit outside iso-surface")
+	}
+}
+
+func TestBlobMerge(t *testing.T) {
+	b := &Blob{
+		Centers: []BlobCenter{
+			{geom.Point{-1.5, 0, 0}, 2, 1},
+			{geom.Point{1.5, 0, 0}, 2, 1},
+		},
+		Threshold: 0.25,
+	}
+	// The origin is outside the iso-surfaces of each center but inside that
+	// of the blob.
+	if !b.Inside(geom.Point{0, 0, 0}, 0) {
+		t.Fatalf("centers not merged")
+	}
+	if _, ok := b.Intersect(geom.MakeRay(geom.Point{0, 0, -10}, geom.Vector{0, 0, 1}), 0); !ok {
+		t.Fatalf("blob missed between centers")
+	}
+	exp := geom.AABB{geom.Point{-3.5, -2, -2}, geom.Point{3.5, 2, 2}}
+	if act := b.Bounds(); act != exp {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
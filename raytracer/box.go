@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// Box objects are axis-aligned boxes.
+type Box struct {
+	Box      geom.AABB
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Box", func() Object { return new(Box) })
+}
+
+// Intersect returns the nearest intersection between ray and the faces of b.
+// The inner side of the faces is hit when the ray starts inside b.
+func (b *Box) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	line := ray
+	line.TMin, line.TMax = math.Inf(-1), math.Inf(1)
+	t0, t1, ok := geom.RayAABBIntersection(b.Box, line)
+	if !ok {
+		return Hit{}, false
+	}
+	for _, t := range [2]float64{t0, t1} {
+		if ray.TMin <= t && t <= ray.TMax {
+			return Hit{T: t, Point: ray.At(t), Object: b}, true
+		}
+	}
+	return Hit{}, false
+}
+
+// NormalAt returns the normal of the face of b nearest to the intersection
+// point.
+func (b *Box) NormalAt(h *Hit, time float64) geom.Vector {
+	p := &h.Point
+	faces := [6]struct {
+		d float64
+		n geom.Vector
+	}{
+		{p.X - b.Box.Min.X, geom.Vector{-1, 0, 0}},
+		{b.Box.Max.X - p.X, geom.Vector{1, 0, 0}},
+		{p.Y - b.Box.Min.Y, geom.Vector{0, -1, 0}},
+		{b.Box.Max.Y - p.Y, geom.Vector{0, 1, 0}},
+		{p.Z - b.Box.Min.Z, geom.Vector{0, 0, -1}},
+		{b.Box.Max.Z - p.Z, geom.Vector{0, 0, 1}},
+	}
+	best := 0
+	for i := range faces {
+		if math.Abs(faces[i].d) < math.Abs(faces[best].d) {
+			best = i
+		}
+	}
+	return faces[best].n
+}
+
+func (b *Box) Bounds() geom.AABB {
+	return b.Box
+}
+
+func (b *Box) Inside(p geom.Point, time float64) bool {
+	return b.Box.Contains(&p)
+}
+
+func (b *Box) ColorAt(h *Hit) Color {
+	return b.Color
+}
+
+func (b *Box) MaterialAt(h *Hit) *Material {
+	return b.Material
+}
+
+func (b *Box) Clone() Object {
+	c := *b
+	c.Material = b.Material.clone()
+	return &c
+}
+
+func (b *Box) Validate() error {
+	if b.Box.Empty() {
+		return fmt.Errorf("invalid box: empty: %v", b.Box)
+	}
+	if err := b.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid box: %v", err)
+	}
+	if b.Material != nil {
+		if err := b.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid box: %v", err)
+		}
+		if err := b.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid box: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+func TestBoxIntersect(t *testing.T) {
+	b := &Box{Box: geom.AABB{geom.Point{-1, -2, -3}, geom.Point{1, 2, 3}}}
+	data := []struct {
+		r  geom.Ray
+		ok bool
+		p  geom.Point
+		n  geom.Vector
+	}{
+		{geom.MakeRay(geom.Point{0, 0, -10}, geom.Vector{0, 0, 1}), true, geom.Point{0, 0, -3}, geom.Vector{0, 0, -1}},
+		{geom.MakeRay(geom.Point{5, 1, 1}, geom.Vector{-1, 0, 0}), true, geom.Point{1, 1, 1}, geom.Vector{1, 0, 0}},
+		{geom.MakeRay(geom.Point{0, 0, 0}, geom.Vector{0, -1, 0}), true, geom.Point{0, -2, 0}, geom.Vector{0, -1, 0}},
+		{geom.MakeRay(geom.Point{0, 5, 0}, geom.Vector{0, 1, 0}), false, geom.Point{}, geom.Vector{}},
+		{geom.MakeRay(geom.Point{2, 0, -10}, geom.Vector{0, 0, 1}), false, geom.Point{}, geom.Vector{}},
+	}
+	for i, d := range data {
+		h, ok := b.Intersect(d.r, 0)
+		if ok != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !geom.PointsEqual(h.Point, d.p, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.p, h.Point)
+		}
+		if n := b.NormalAt(&h, 0); n != d.n {
+			t.Errorf("#%d: exp: %v act: %v", i, d.n, n)
+		}
+	}
+}
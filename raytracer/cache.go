@@ -0,0 +1,86 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"crypto/sha256"
+	"os"
+	"sync"
+)
+
+// maxCachedAssets bounds the number of entries kept by assets.
+const maxCachedAssets = 64
+
+// assets holds data decoded or derived from files, such as texture images,
+// their mipmaps and IES profiles, keyed by the SHA-256 hash of the file
+// contents so that animation frames and server jobs rendering scenes with the
+// same files load them once.  Edited files hash differently and are reloaded.
+// The cache lives in memory only.  Meshes are not cached as they are part of
+// scene files, which are decoded by callers.  Cached data are shared and must
+// not be modified.
+var assets = assetCache{entries: make(map[assetKey]interface{})}
+
+// An assetKey identifies data of some kind derived from file contents.
+type assetKey struct {
+	kind string
+	sum  [sha256.Size]byte
+}
+
+// An assetCache is a content-addressed cache evicting the oldest entries
+// first.
+type assetCache struct {
+	mu      sync.Mutex
+	entries map[assetKey]interface{}
+	keys    []assetKey // in insertion order
+}
+
+// readAsset returns the contents of file and the key of the data of the
+// given kind derived from it.
+func readAsset(file, kind string) ([]byte, assetKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, assetKey{}, err
+	}
+	return data, assetKey{kind, sha256.Sum256(data)}, nil
+}
+
+func (c *assetCache) get(k assetKey) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries[k]
+	return v, ok
+}
+
+// put caches v under k, replacing the previous entry if any.
+func (c *assetCache) put(k assetKey, v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[k]; !ok {
+		if len(c.keys) == maxCachedAssets {
+			delete(c.entries, c.keys[0])
+			c.keys = c.keys[1:]
+		}
+		c.keys = append(c.keys, k)
+	}
+	c.entries[k] = v
+}
@@ -0,0 +1,553 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"sort"
+)
+
+// A view projects the scene onto the plane of rendered images.  Points of the
+// image plane are measured in pixels from the view axis, y pointing up.
+type view interface {
+	// size returns the size of images in pixels.
+	size() (w, h int)
+
+	// pixel returns the point of the image plane sampled by image pixel
+	// (px, py).
+	pixel(px, py int) (x, y float64)
+
+	// ray returns the camera ray through point (x, y) of the image plane
+	// at the given time in the shutter interval.
+	ray(x, y, time float64) viewRay
+
+	// chromaticAberration returns the magnification of the red channel
+	// relative to the green one minus 1, that of the blue one being 1
+	// minus it.  Zero disables the effect.
+	chromaticAberration() float64
+
+	// magnify returns the point of the image plane seen at (x, y) through
+	// a lens magnifying images by f.
+	magnify(x, y, f float64) (mx, my float64)
+}
+
+// A viewRay is a ray cast from the camera through the image plane.
+type viewRay struct {
+	geom.Ray
+
+	// The footprint of pixels at parameter t along the ray is
+	// footprint[0] + footprint[1]*t.
+	footprint [2]float64
+
+	// backdrop is the parameter along the ray of the point of the
+	// background darkened by shadows, zero for none.
+	backdrop float64
+
+	// blank is set for points of the image plane the camera does not see
+	// through, which are rendered black.
+	blank bool
+}
+
+// A Projection selects how a camera maps directions to images.
+type Projection string
+
+const (
+	// PerspectiveProjection maps straight lines to straight lines, like
+	// pinhole cameras.
+	PerspectiveProjection Projection = "perspective"
+
+	// FisheyeProjection is an equidistant fisheye lens: the angle between
+	// directions and the view axis is proportional to the distance from the
+	// center of images.
+	FisheyeProjection Projection = "fisheye"
+
+	// EquirectProjection sees the whole sphere of directions, with
+	// longitudes along the width of images and latitudes along their height.
+	EquirectProjection Projection = "equirect"
+)
+
+// A StereoView selects the eyes whose view stereo cameras render.
+type StereoView string
+
+const (
+	LeftEye    StereoView = "left"         // left eye only
+	RightEye   StereoView = "right"        // right eye only
+	SideBySide StereoView = "side-by-side" // left eye on the left half, right eye on the right one
+)
+
+// A CameraKey is the position, look-at point and field of view of an animated
+// camera at a given time, in seconds.  A zero FOV keeps that of the camera.
+type CameraKey struct {
+	Time        float64
+	Eye, LookAt geom.Point
+	FOV         float64
+}
+
+// A Camera is a perspective camera at Eye looking toward LookAt, an
+// alternative to Frustum that can be placed and oriented anywhere.  Up, zero
+// meaning +y, is the direction appearing upward in images.  Alternatively,
+// Orientation is the rotation, not necessarily normalized, from a camera
+// looking along +z with +y up, as exported by animation packages.  Roll then
+// turns the camera counterclockwise around its view axis by the given angle
+// in degrees, tilting the horizon for "Dutch angle" shots.  FOV is the
+// vertical field of view in degrees.  Images are Width pixels wide and their
+// height follows from Aspect, the ratio of their width over their height,
+// zero meaning 1.
+//
+// Far, when positive, is the distance along the view axis of a backdrop on
+// which shadows darken the background color as they do on the far plane of
+// frustums.
+//
+// Projection selects the lens, zero selecting a perspective one.  Fisheye
+// lenses see FOV degrees across the height of images, up to 360 degrees, and
+// pixels seeing past 180 degrees from the view axis are black.  With a square
+// image and 180 degrees, they capture a whole hemisphere in the inscribed
+// circle, as needed for dome projection.
+//
+// K1 and K2, when set, distort perspective images radially as real lenses do,
+// so that renders line up with footage when composited.  They follow the
+// Brown-Conrady model used by camera calibration tools: a point seen at
+// distance r from the view axis on the image plane at distance 1 from the eye
+// appears at distance r(1 + K1 r^2 + K2 r^4).  Negative coefficients yield
+// barrel distortion and positive ones pincushion distortion.  Pixels beyond
+// the reach of the lens are black.
+//
+// ChromaticAberration simulates a lens refracting color channels differently
+// as for Frustum.  Stereo cameras magnify the view of each eye around its
+// center.
+//
+// Equirectangular cameras render 360 degree panoramas for VR viewers.  Their
+// images are twice as wide as high, whatever FOV and Aspect.  They follow the
+// conventions of equirectangular environments, so that panoramas rendered
+// looking along +z with +y up can light other scenes.
+//
+// Stereo, when set, turns the camera into a pair of eyes Interocular apart on
+// either side of Eye along the horizontal axis of images, looking in parallel
+// directions.  It selects the eye to render or renders both side by side in
+// images twice as wide.  Rendering each eye separately yields images for VR
+// headsets, anaglyphs or stereoscopes.
+//
+// EndEye and EndLookAt, when set, are the positions of the eye and of the
+// look-at point when the shutter closes, Eye and LookAt being those when it
+// opens.  Both move linearly in between, blurring the whole image when
+// rendering several time samples per pixel.
+//
+// Keys, when set, animate the camera for fly-throughs.  They are sorted by
+// time and the camera of each frame of the animation, FrameRate frames per
+// second, zero meaning 24, is interpolated between them (see animate).
+type Camera struct {
+	Eye, LookAt geom.Point
+	Up          geom.Vector
+	Orientation *geom.Quaternion
+	Roll        float64
+	FOV         float64
+	Aspect      float64
+	K1, K2      float64
+
+	ChromaticAberration float64
+
+	Width       int
+	Far         float64
+	Projection  Projection
+	Stereo      StereoView
+	Interocular float64
+	EndEye      *geom.Point
+	EndLookAt   *geom.Point
+	Keys        []CameraKey
+	FrameRate   float64
+
+	basis geom.ONB // right, up and forward directions, set by prepare
+	dist  float64  // distance from Eye to the image plane in pixels, set by prepare
+}
+
+func (c *Camera) Validate() error {
+	if c.Width <= 0 {
+		return fmt.Errorf("invalid camera: non-positive width: %v", c.Width)
+	}
+	switch c.Projection {
+	case "", PerspectiveProjection:
+		if c.FOV <= 0 || c.FOV >= 180 {
+			return fmt.Errorf("invalid camera: field of view out-of-range: %v", c.FOV)
+		}
+	case FisheyeProjection:
+		if c.FOV <= 0 || c.FOV > 360 {
+			return fmt.Errorf("invalid camera: field of view out-of-range: %v", c.FOV)
+		}
+	case EquirectProjection:
+	default:
+		return fmt.Errorf("invalid camera projection: %q", c.Projection)
+	}
+	if c.Aspect < 0 {
+		return fmt.Errorf("invalid camera: negative aspect ratio: %v", c.Aspect)
+	}
+	switch c.Stereo {
+	case "":
+	case LeftEye, RightEye, SideBySide:
+		if c.Interocular <= 0 {
+			return fmt.Errorf("invalid camera: non-positive interocular distance: %v", c.Interocular)
+		}
+	default:
+		return fmt.Errorf("invalid camera stereo view: %q", c.Stereo)
+	}
+	if _, h := c.size(); h <= 0 {
+		return fmt.Errorf("invalid camera: empty image: %v", c.Aspect)
+	}
+	if c.Far < 0 {
+		return fmt.Errorf("invalid camera: negative far distance: %v", c.Far)
+	}
+	if math.Abs(c.ChromaticAberration) >= 1 {
+		return fmt.Errorf("invalid camera: chromatic aberration out-of-range: %v", c.ChromaticAberration)
+	}
+	if (c.K1 != 0 || c.K2 != 0) && c.Projection != "" && c.Projection != PerspectiveProjection {
+		return fmt.Errorf("invalid camera: lens distortion with %s projection", c.Projection)
+	}
+	if c.FrameRate < 0 {
+		return fmt.Errorf("invalid camera: negative frame rate: %v", c.FrameRate)
+	}
+	for i, k := range c.Keys {
+		if i > 0 && k.Time <= c.Keys[i-1].Time {
+			return fmt.Errorf("invalid camera key #%d: time not increasing: %v", i, k.Time)
+		}
+		if k.FOV < 0 {
+			return fmt.Errorf("invalid camera key #%d: negative field of view: %v", i, k.FOV)
+		}
+	}
+	if q := c.Orientation; q != nil {
+		if q.Norm() == 0 {
+			return fmt.Errorf("invalid camera: null orientation")
+		}
+		return nil
+	}
+	if err := c.validateAxis(c.Eye, c.LookAt); err != nil {
+		return err
+	}
+	if c.moving() {
+		end := c.at(1)
+		if err := end.validateAxis(end.Eye, end.LookAt); err != nil {
+			return fmt.Errorf("%v at shutter closing", err)
+		}
+	}
+	return nil
+}
+
+// validateAxis checks the view axis from eye to lookAt.
+func (c *Camera) validateAxis(eye, lookAt geom.Point) error {
+	fwd := geom.MakeVector(lookAt, eye)
+	if fwd.Module() == 0 {
+		return fmt.Errorf("invalid camera: eye on look-at point")
+	}
+	up := c.up()
+	if r := geom.CrossProduct(&up, &fwd); r.Module() == 0 {
+		return fmt.Errorf("invalid camera: up vector along view axis: %v", up)
+	}
+	return nil
+}
+
+// moving returns whether c moves during the shutter interval.
+func (c *Camera) moving() bool {
+	return c.EndEye != nil || c.EndLookAt != nil
+}
+
+// at returns a still and prepared copy of c at the given time.
+func (c *Camera) at(time float64) Camera {
+	lerp := func(p geom.Point, end *geom.Point) geom.Point {
+		if end == nil {
+			return p
+		}
+		return geom.Point{p.X + time*(end.X-p.X), p.Y + time*(end.Y-p.Y), p.Z + time*(end.Z-p.Z)}
+	}
+	m := *c
+	m.Eye, m.LookAt = lerp(c.Eye, c.EndEye), lerp(c.LookAt, c.EndLookAt)
+	m.EndEye, m.EndLookAt = nil, nil
+	m.prepare()
+	return m
+}
+
+// clone returns a copy of c, nil if c is nil.
+func (c *Camera) clone() *Camera {
+	if c == nil {
+		return nil
+	}
+	cc := *c
+	if c.Orientation != nil {
+		q := *c.Orientation
+		cc.Orientation = &q
+	}
+	for _, p := range []**geom.Point{&cc.EndEye, &cc.EndLookAt} {
+		if *p != nil {
+			q := **p
+			*p = &q
+		}
+	}
+	cc.Keys = append([]CameraKey(nil), c.Keys...)
+	return &cc
+}
+
+// defaultFrameRate is the number of frames per second of camera animations
+// when Camera.FrameRate is zero.
+const defaultFrameRate = 24
+
+// animate moves c to where its keys place it at the given animation frame.
+// Eye and look-at points follow smooth curves through the keys, Catmull-Rom
+// splines accounting for the time between keys, and the field of view
+// changes linearly.  Before the first key and after the last one, the camera
+// stays still.
+func (c *Camera) animate(frame int) {
+	keys := c.Keys
+	if len(keys) == 0 {
+		return
+	}
+	rate := c.FrameRate
+	if rate == 0 {
+		rate = defaultFrameRate
+	}
+	t := float64(frame) / rate
+	i := sort.Search(len(keys), func(i int) bool { return keys[i].Time > t })
+	var k0, k1 CameraKey
+	var s float64
+	switch {
+	case i == 0:
+		k0, k1 = keys[0], keys[0]
+	case i == len(keys):
+		k0, k1 = keys[i-1], keys[i-1]
+	default:
+		k0, k1 = keys[i-1], keys[i]
+		s = (t - k0.Time) / (k1.Time - k0.Time)
+	}
+	// tangent returns the derivative over time of p at key j.
+	tangent := func(j int, p func(k *CameraKey) geom.Point) geom.Vector {
+		a, b := j-1, j+1
+		if a < 0 {
+			a = j
+		}
+		if b >= len(keys) {
+			b = j
+		}
+		if a == b {
+			return geom.Vector{}
+		}
+		v := geom.MakeVector(p(&keys[b]), p(&keys[a]))
+		return v.Scale(1 / (keys[b].Time - keys[a].Time))
+	}
+	hermite := func(p func(k *CameraKey) geom.Point) geom.Point {
+		if s == 0 {
+			return p(&k0)
+		}
+		dt := k1.Time - k0.Time
+		m0, m1 := tangent(i-1, p), tangent(i, p)
+		s2, s3 := s*s, s*s*s
+		h00, h10, h01, h11 := 2*s3-3*s2+1, s3-2*s2+s, -2*s3+3*s2, s3-s2
+		p0, p1 := p(&k0), p(&k1)
+		return geom.Point{
+			h00*p0.X + h10*dt*m0.X + h01*p1.X + h11*dt*m1.X,
+			h00*p0.Y + h10*dt*m0.Y + h01*p1.Y + h11*dt*m1.Y,
+			h00*p0.Z + h10*dt*m0.Z + h01*p1.Z + h11*dt*m1.Z,
+		}
+	}
+	c.Eye = hermite(func(k *CameraKey) geom.Point { return k.Eye })
+	c.LookAt = hermite(func(k *CameraKey) geom.Point { return k.LookAt })
+	fov0, fov1 := k0.FOV, k1.FOV
+	if fov0 == 0 {
+		fov0 = c.FOV
+	}
+	if fov1 == 0 {
+		fov1 = c.FOV
+	}
+	c.FOV = fov0 + s*(fov1-fov0)
+}
+
+func (c *Camera) up() geom.Vector {
+	if c.Up.Module() == 0 {
+		return geom.Vector{0, 1, 0}
+	}
+	return c.Up
+}
+
+func (c *Camera) aspect() float64 {
+	if c.Aspect == 0 {
+		return 1
+	}
+	return c.Aspect
+}
+
+// prepare computes the orientation of c.
+func (c *Camera) prepare() {
+	var right, up, fwd geom.Vector
+	if c.Orientation != nil {
+		q := c.Orientation.Normalize()
+		right, up, fwd = q.Rotate(geom.Vector{1, 0, 0}), q.Rotate(geom.Vector{0, 1, 0}), q.Rotate(geom.Vector{0, 0, 1})
+	} else {
+		fwd = geom.MakeVector(c.LookAt, c.Eye)
+		fwd = fwd.UnitVector()
+		up = c.up()
+		right = geom.CrossProduct(&up, &fwd)
+		right = right.UnitVector()
+		up = geom.CrossProduct(&fwd, &right)
+	}
+	if c.Roll != 0 {
+		sin, cos := math.Sincos(c.Roll * math.Pi / 180)
+		r, u := right.Scale(cos), up.Scale(cos)
+		right, up = r.Add(up.Scale(sin)), u.Sub(right.Scale(sin))
+	}
+	c.basis = geom.ONB{U: right, V: up, W: fwd}
+	_, h := c.viewSize()
+	c.dist = float64(h) / 2 / math.Tan(c.FOV*math.Pi/360)
+}
+
+func (c *Camera) size() (w, h int) {
+	w, h = c.viewSize()
+	if c.Stereo == SideBySide {
+		w *= 2
+	}
+	return w, h
+}
+
+// viewSize returns the size in pixels of the view of each eye.
+func (c *Camera) viewSize() (w, h int) {
+	if c.Projection == EquirectProjection {
+		return c.Width, (c.Width + 1) / 2
+	}
+	return c.Width, int(math.Round(float64(c.Width) / c.aspect()))
+}
+
+// pixel samples the centers of pixels.
+func (c *Camera) pixel(px, py int) (x, y float64) {
+	w, h := c.size()
+	return float64(px) + 0.5 - float64(w)/2, float64(h)/2 - float64(py) - 0.5
+}
+
+func (c *Camera) chromaticAberration() float64 {
+	return c.ChromaticAberration
+}
+
+func (c *Camera) magnify(x, y, f float64) (mx, my float64) {
+	var cx float64 // center of the view of the eye
+	if c.Stereo == SideBySide {
+		w, _ := c.viewSize()
+		cx = float64(w) / 2
+		if x < 0 {
+			cx = -cx
+		}
+	}
+	return cx + (x-cx)*f, y * f
+}
+
+func (c *Camera) ray(x, y, time float64) viewRay {
+	if c.moving() {
+		m := c.at(time)
+		return m.ray(x, y, time)
+	}
+	b := &c.basis
+	eye := c.Eye
+	if c.Stereo != "" {
+		right := c.Stereo == RightEye
+		if c.Stereo == SideBySide {
+			// Move to the center of the view of the eye.
+			w, _ := c.viewSize()
+			right = x > 0
+			if right {
+				x -= float64(w) / 2
+			} else {
+				x += float64(w) / 2
+			}
+		}
+		d := c.Interocular / 2
+		if !right {
+			d = -d
+		}
+		eye = eye.Translate(b.U.Scale(d))
+	}
+	switch c.Projection {
+	case EquirectProjection:
+		w, h := c.viewSize()
+		u, v := (x+float64(w)/2)/float64(w), (float64(h)/2-y)/float64(h)
+		dir := b.ToWorld(equirectDir(u, v))
+		return viewRay{Ray: geom.MakeRay(eye, dir), footprint: [2]float64{0, 2 * math.Pi / float64(w)}, backdrop: c.Far}
+	case FisheyeProjection:
+		_, h := c.viewSize()
+		k := c.FOV * math.Pi / 180 / float64(h) // angle per pixel
+		theta := k * math.Hypot(x, y)
+		if theta > math.Pi {
+			return viewRay{blank: true}
+		}
+		phi := math.Atan2(y, x)
+		sin := math.Sin(theta)
+		dir := b.ToWorld(geom.Vector{sin * math.Cos(phi), sin * math.Sin(phi), math.Cos(theta)})
+		return viewRay{Ray: geom.MakeRay(eye, dir), footprint: [2]float64{0, k}, backdrop: c.Far}
+	}
+
+	// Directions are scaled to unit length along the view axis so that
+	// ray parameters are distances along it.
+	x, y = x/c.dist, y/c.dist
+	k := 1 / c.dist
+	if c.K1 != 0 || c.K2 != 0 {
+		s, ds, ok := c.undistort(math.Hypot(x, y))
+		if !ok {
+			return viewRay{blank: true}
+		}
+		x, y, k = s*x, s*y, k*ds
+	}
+	dir := b.ToWorld(geom.Vector{x, y, 1})
+	return viewRay{Ray: geom.MakeRay(eye, dir), footprint: [2]float64{0, k}, backdrop: c.Far}
+}
+
+// undistortIterations bounds the Newton iterations inverting lens distortion.
+const undistortIterations = 20
+
+// undistort inverts the radial distortion of c.  It returns the ratio between
+// the distances from the view axis, on the image plane at distance 1 from the
+// eye, at which a point appearing at distance rd is seen without and with
+// distortion, and the derivative of the former over the latter.  ok is false
+// when no point of the scene appears at rd.
+func (c *Camera) undistort(rd float64) (s, ds float64, ok bool) {
+	if rd == 0 {
+		return 1, 1, true
+	}
+	// Solve r(1 + K1 r^2 + K2 r^4) = rd with Newton's method.  The
+	// distortion must keep increasing from the view axis to the solution,
+	// past which the lens folds the image over itself.
+	r := rd
+	for i := 0; i < undistortIterations; i++ {
+		r2 := r * r
+		d := 1 + 3*c.K1*r2 + 5*c.K2*r2*r2
+		if d <= 0 {
+			return 0, 0, false
+		}
+		step := (r*(1+c.K1*r2+c.K2*r2*r2) - rd) / d
+		r -= step
+		if r <= 0 {
+			return 0, 0, false
+		}
+		if math.Abs(step) < 1e-12*rd {
+			break
+		}
+	}
+	r2 := r * r
+	d := 1 + 3*c.K1*r2 + 5*c.K2*r2*r2
+	if d <= 0 || math.Abs(r*(1+c.K1*r2+c.K2*r2*r2)-rd) > 1e-9*rd {
+		return 0, 0, false
+	}
+	return r / rd, 1 / d, true
+}
@@ -0,0 +1,549 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"testing"
+)
+
+func TestCameraValidate(t *testing.T) {
+	data := []struct {
+		c  Camera
+		ok bool
+	}{
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100}, true},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100, Aspect: 1.5, Up: geom.Vector{1, 1, 0}, Far: 10}, true},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, Width: 100}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 180, Width: 100}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100, Aspect: -1}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100, Aspect: 1000}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100, Far: -1}, false},
+		{Camera{FOV: 60, Width: 100}, false},
+		{Camera{LookAt: geom.Point{0, 1, 0}, FOV: 60, Width: 100}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 200, Width: 100, Projection: FisheyeProjection}, true},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 400, Width: 100, Projection: FisheyeProjection}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100, Projection: "orthographic"}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, Width: 100, Projection: EquirectProjection}, true},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100, Stereo: SideBySide, Interocular: 0.1}, true},
+		{Camera{Orientation: &geom.Quaternion{1, 0, 0, 0}, FOV: 60, Width: 100}, true},
+		{Camera{Orientation: &geom.Quaternion{}, LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100, Stereo: LeftEye}, false},
+		{Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 100, Stereo: "top-bottom", Interocular: 0.1}, false},
+	}
+	for i, d := range data {
+		if err := d.c.Validate(); (err == nil) != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, err)
+		}
+	}
+}
+
+func TestCameraRay(t *testing.T) {
+	c := Camera{Eye: geom.Point{1, 2, 3}, LookAt: geom.Point{1, 2, -7}, FOV: 90, Width: 40, Aspect: 2}
+	c.prepare()
+	if w, h := c.size(); w != 40 || h != 20 {
+		t.Fatalf("size: exp: 40x20 act: %vx%v", w, h)
+	}
+	if x, y := c.pixel(0, 0); x != -19.5 || y != 9.5 {
+		t.Errorf("top-left pixel: exp: (-19.5, 9.5) act: (%v, %v)", x, y)
+	}
+	data := []struct {
+		x, y float64
+		exp  geom.Vector
+	}{
+		// Looking along -z, +x lies to the left.
+		{0, 0, geom.Vector{0, 0, -1}},
+		// The top of the image lies 45 degrees up.
+		{0, 10, geom.Vector{0, 1, -1}},
+		{-10, 0, geom.Vector{1, 0, -1}},
+	}
+	for i, d := range data {
+		r := c.ray(d.x, d.y, 0)
+		if r.Origin != c.Eye {
+			t.Errorf("#%d: origin: exp: %v act: %v", i, c.Eye, r.Origin)
+		}
+		if act := r.Dir.UnitVector(); !geom.VectorsEqual(act, d.exp.UnitVector(), 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+	// Pixels are as wide as the image plane over the image width.
+	r := c.ray(0, 0, 0)
+	if act, exp := r.footprint[0]+r.footprint[1]*10, 2*10*math.Tan(math.Pi/4)/20; !geom.FloatsEqual(act, exp, 1e-9) {
+		t.Errorf("footprint: exp: %v act: %v", exp, act)
+	}
+
+	// Up tilts the image.
+	c.Up = geom.Vector{1, 0, 0}
+	c.prepare()
+	exp := geom.Vector{1, 0, -1}
+	if act := c.ray(0, 10, 0).Dir; !geom.VectorsEqual(act.UnitVector(), exp.UnitVector(), 1e-9) {
+		t.Errorf("tilted: exp: %v act: %v", exp, act)
+	}
+}
+
+func TestFisheyeCamera(t *testing.T) {
+	c := Camera{LookAt: geom.Point{0, 0, 1}, FOV: 180, Width: 20, Projection: FisheyeProjection}
+	c.prepare()
+	data := []struct {
+		x, y float64
+		exp  geom.Vector
+	}{
+		{0, 0, geom.Vector{0, 0, 1}},
+		// Angles from the axis grow linearly up to 90 degrees at the
+		// edges of the image.
+		{0, 5, geom.Vector{0, 1, 1}},
+		{10, 0, geom.Vector{1, 0, 0}},
+		{0, -10, geom.Vector{0, -1, 0}},
+	}
+	for i, d := range data {
+		r := c.ray(d.x, d.y, 0)
+		if r.blank {
+			t.Errorf("#%d: blank", i)
+			continue
+		}
+		if act := r.Dir.UnitVector(); !geom.VectorsEqual(act, d.exp.UnitVector(), 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+
+	// Wider lenses see behind the camera, up to 180 degrees.
+	c.FOV = 300
+	r := c.ray(0, 10, 0)
+	if exp := (geom.Vector{0, 0.5, -math.Sqrt(3) / 2}); !geom.VectorsEqual(r.Dir, exp, 1e-9) {
+		t.Errorf("wide: exp: %v act: %v", exp, r.Dir)
+	}
+	if r := c.ray(13, 0, 0); !r.blank {
+		t.Errorf("pixel past 180 degrees not blank: %v", r.Ray)
+	}
+
+	s := testScene()
+	s.Camera = &Camera{LookAt: geom.Point{0, 0, 1}, FOV: 360, Width: 40, Projection: FisheyeProjection}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(20, 20); act.R == 0 || act.G != 0 {
+		t.Errorf("sphere not seen at the center: %v", act)
+	}
+	black := Color{}
+	if exp, act := black.toRGBA(), img.RGBAAt(0, 0); act != exp {
+		t.Errorf("corner: exp: %v act: %v", exp, act)
+	}
+}
+
+func TestEquirectCamera(t *testing.T) {
+	c := Camera{Eye: geom.Point{0, 0, 5}, LookAt: geom.Point{0, 0, 10}, Width: 40, Aspect: 1, Projection: EquirectProjection}
+	c.prepare()
+	if w, h := c.size(); w != 40 || h != 20 {
+		t.Fatalf("size: exp: 40x20 act: %vx%v", w, h)
+	}
+	data := []struct {
+		x, y float64
+		exp  geom.Vector
+	}{
+		{0, 0, geom.Vector{0, 0, 1}},
+		{10, 0, geom.Vector{1, 0, 0}},
+		{-10, 0, geom.Vector{-1, 0, 0}},
+		{20, 0, geom.Vector{0, 0, -1}},
+		{0, 10, geom.Vector{0, 1, 0}},
+		{5, -5, geom.Vector{0.5, -math.Sqrt2 / 2, 0.5}},
+	}
+	for i, d := range data {
+		// Directions match those of equirectangular environments.
+		r := c.ray(d.x, d.y, 0)
+		if act := r.Dir.UnitVector(); !geom.VectorsEqual(act, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+		u, v := equirectUV(d.exp)
+		if x, y := u*40-20, 10-v*20; !geom.FloatsEqual(math.Mod(x-d.x, 40), 0, 1e-9) || !geom.FloatsEqual(y, d.y, 1e-9) {
+			t.Errorf("#%d: environment coordinates: exp: (%v, %v) act: (%v, %v)", i, d.x, d.y, x, y)
+		}
+	}
+
+	s := testScene()
+	s.Camera = &Camera{Width: 80, LookAt: geom.Point{0, 0, 1}, Projection: EquirectProjection}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 80 || b.Dy() != 40 {
+		t.Fatalf("size: exp: 80x40 act: %v", b)
+	}
+	if act := img.RGBAAt(40, 20); act.R <= act.G {
+		t.Errorf("sphere not seen at the center: %v", act)
+	}
+	if exp, act := s.Bg.toRGBA(), img.RGBAAt(0, 20); act != exp {
+		t.Errorf("behind: exp: %v act: %v", exp, act)
+	}
+}
+
+func TestStereoCamera(t *testing.T) {
+	// Looking along -z, the left eye lies toward +x.
+	c := Camera{Eye: geom.Point{0, 0, 10}, FOV: 90, Width: 20, Stereo: LeftEye, Interocular: 2}
+	c.prepare()
+	// mono returns the camera at the same place as the eye at x.
+	mono := func(x float64) *Camera {
+		m := &Camera{Eye: geom.Point{x, 0, 10}, LookAt: geom.Point{x, 0, 0}, FOV: 90, Width: 20}
+		m.prepare()
+		return m
+	}
+	if act, exp := c.ray(3, 4, 0).Ray, mono(1).ray(3, 4, 0).Ray; act != exp {
+		t.Errorf("left: exp: %v act: %v", exp, act)
+	}
+	c.Stereo = RightEye
+	if act, exp := c.ray(3, 4, 0).Ray, mono(-1).ray(3, 4, 0).Ray; act != exp {
+		t.Errorf("right: exp: %v act: %v", exp, act)
+	}
+
+	// Side by side, each half of images is the view of an eye.
+	c.Stereo = SideBySide
+	if w, h := c.size(); w != 40 || h != 20 {
+		t.Fatalf("size: exp: 40x20 act: %vx%v", w, h)
+	}
+	for _, d := range []struct {
+		px, mpx int
+		eye     float64
+	}{
+		{33, 13, -1},
+		{2, 2, 1},
+	} {
+		m := mono(d.eye)
+		x, y := c.pixel(d.px, 4)
+		mx, my := m.pixel(d.mpx, 4)
+		if act, exp := c.ray(x, y, 0).Ray, m.ray(mx, my, 0).Ray; act != exp {
+			t.Errorf("side by side pixel %v: exp: %v act: %v", d.px, exp, act)
+		}
+	}
+
+	// Nearby objects appear further left to the right eye.
+	s := testScene()
+	s.Camera = &Camera{LookAt: geom.Point{-10, 0, 80}, FOV: 60, Width: 60, Stereo: SideBySide, Interocular: 20}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	leftmost := func(x0 int) int {
+		for x := x0; x < x0+60; x++ {
+			if c := img.RGBAAt(x, 30); c.R > c.G {
+				return x - x0
+			}
+		}
+		return -1
+	}
+	if l, r := leftmost(0), leftmost(60); l < 0 || r < 0 || r >= l {
+		t.Errorf("no parallax: left: %v right: %v", l, r)
+	}
+}
+
+func TestCameraOrientation(t *testing.T) {
+	// Rolled 90 degrees, the camera sees along its right what was above.
+	c := Camera{LookAt: geom.Point{0, 0, 1}, Roll: 90, FOV: 90, Width: 20}
+	c.prepare()
+	exp := geom.Vector{0, 1, 1}
+	if act := c.ray(10, 0, 0).Dir; !geom.VectorsEqual(act.UnitVector(), exp.UnitVector(), 1e-9) {
+		t.Errorf("roll: exp: %v act: %v", exp, act)
+	}
+
+	// Turning a camera looking along +z by 90 degrees around +y makes it
+	// look along +x like one oriented by its look-at point.
+	q := geom.QuaternionFromAxisAngle(geom.Vector{0, 1, 0}, math.Pi/2)
+	q = geom.Quaternion{2 * q.W, 2 * q.X, 2 * q.Y, 2 * q.Z}
+	oc := Camera{Eye: geom.Point{1, 2, 3}, Orientation: &q, Roll: 30, FOV: 90, Width: 20}
+	lc := Camera{Eye: oc.Eye, LookAt: geom.Point{2, 2, 3}, Roll: 30, FOV: 90, Width: 20}
+	oc.prepare()
+	lc.prepare()
+	for _, p := range [][2]float64{{0, 0}, {3, 4}, {-7, 2}} {
+		if act, exp := oc.ray(p[0], p[1], 0).Dir, lc.ray(p[0], p[1], 0).Dir; !geom.VectorsEqual(act, exp, 1e-9) {
+			t.Errorf("%v: exp: %v act: %v", p, exp, act)
+		}
+	}
+}
+
+func TestMovingCamera(t *testing.T) {
+	end := geom.Point{10, 0, 1}
+	c := Camera{LookAt: geom.Point{0, 0, 1}, EndEye: &geom.Point{10, 0, 0}, EndLookAt: &end, FOV: 60, Width: 20}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	c.prepare()
+	mid := Camera{Eye: geom.Point{5, 0, 0}, LookAt: geom.Point{5, 0, 1}, FOV: 60, Width: 20}
+	mid.prepare()
+	if act, exp := c.ray(3, 4, 0.5).Ray, mid.ray(3, 4, 0).Ray; act != exp {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+
+	// The eye lands on the look-at point when the shutter closes.
+	c.EndLookAt = &geom.Point{10, 0, 0}
+	if err := c.Validate(); err == nil {
+		t.Errorf("invalid end position accepted")
+	}
+
+	cc := c.clone()
+	cc.EndEye.X = 0
+	if c.EndEye.X != 10 {
+		t.Errorf("end eye shared by clone")
+	}
+
+	// Panning the camera blurs the still scene.
+	s := testScene()
+	s.Camera = &Camera{LookAt: geom.Point{0, 0, 1}, FOV: 30, Width: 20}
+	still, err := s.RenderWithOptions(&Options{TimeSamples: 4})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	s.Camera.EndLookAt = &geom.Point{0.1, 0, 1}
+	blurred, err := s.RenderWithOptions(&Options{TimeSamples: 4})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if bytes.Equal(blurred.Pix, still.Pix) {
+		t.Errorf("panning camera not blurred")
+	}
+}
+
+func TestCameraRender(t *testing.T) {
+	// Look at the sphere of the test scene from behind, off its side.
+	s := testScene()
+	s.Camera = &Camera{Eye: geom.Point{60, 0, 140}, LookAt: geom.Point{0, 0, 80}, FOV: 30, Width: 30, Aspect: 1.5}
+	img, err := s.Render(2)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 30 || b.Dy() != 20 {
+		t.Fatalf("size: exp: 30x20 act: %v", b)
+	}
+	if act := img.RGBAAt(15, 10); act.R == 0 || act.G != 0 {
+		t.Errorf("sphere not seen at the center: %v", act)
+	}
+	if exp, act := s.Bg.toRGBA(), img.RGBAAt(0, 0); act != exp {
+		t.Errorf("background: exp: %v act: %v", exp, act)
+	}
+
+	s.Camera.LookAt = s.Camera.Eye
+	if _, err := s.Render(1); err == nil {
+		t.Errorf("invalid camera accepted")
+	}
+}
+
+func TestCameraKeys(t *testing.T) {
+	keys := []CameraKey{
+		{Time: 0, Eye: geom.Point{0, 0, 0}, LookAt: geom.Point{0, 0, 1}, FOV: 60},
+		{Time: 1, Eye: geom.Point{10, 0, 0}, LookAt: geom.Point{10, 0, 1}},
+		{Time: 2, Eye: geom.Point{20, 0, 0}, LookAt: geom.Point{20, 0, 1}, FOV: 20},
+	}
+	data := []struct {
+		frame int
+		eye   geom.Point
+		fov   float64
+	}{
+		{-5, geom.Point{0, 0, 0}, 60},  // before the first key
+		{0, geom.Point{0, 0, 0}, 60},   // on a key
+		{5, geom.Point{5, 0, 0}, 50},   // between keys
+		{10, geom.Point{10, 0, 0}, 40}, // key keeping the camera FOV
+		{15, geom.Point{15, 0, 0}, 30},
+		{50, geom.Point{20, 0, 0}, 20}, // after the last key
+	}
+	for i, d := range data {
+		c := Camera{FOV: 40, Width: 20, Keys: keys, FrameRate: 10}
+		c.animate(d.frame)
+		if !geom.PointsEqual(c.Eye, d.eye, 1e-9) || !geom.FloatsEqual(c.FOV, d.fov, 1e-9) {
+			t.Errorf("#%d: exp: %v %v act: %v %v", i, d.eye, d.fov, c.Eye, c.FOV)
+		}
+		if exp := (geom.Point{d.eye.X, 0, 1}); !geom.PointsEqual(c.LookAt, exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, exp, c.LookAt)
+		}
+	}
+
+	// Paths bend smoothly through keys rather than turning sharply: the
+	// eye keeps moving along x for a while past the corner.
+	c := Camera{FOV: 40, Width: 20, FrameRate: 10, Keys: []CameraKey{
+		{Time: 0, Eye: geom.Point{0, 0, 0}, LookAt: geom.Point{0, 0, 1}},
+		{Time: 1, Eye: geom.Point{10, 0, 0}, LookAt: geom.Point{10, 0, 1}},
+		{Time: 2, Eye: geom.Point{10, 10, 0}, LookAt: geom.Point{10, 10, 1}},
+	}}
+	c.animate(11)
+	if c.Eye.X <= 10 {
+		t.Errorf("path not smooth: %v", c.Eye)
+	}
+
+	c.Keys = []CameraKey{{Time: 1}, {Time: 1}}
+	if err := c.Validate(); err == nil {
+		t.Errorf("unsorted keys accepted")
+	}
+	c.Keys = []CameraKey{{FOV: -1}}
+	if err := c.Validate(); err == nil {
+		t.Errorf("negative key FOV accepted")
+	}
+	c.Keys = keys
+	cc := c.clone()
+	cc.Keys[0].FOV = 10
+	if c.Keys[0].FOV != 60 {
+		t.Errorf("keys shared by clone")
+	}
+
+	// Frames of a fly-through differ.
+	s := testScene()
+	s.Camera = &Camera{FOV: 30, Width: 20, Keys: []CameraKey{
+		{Time: 0, LookAt: geom.Point{0, 0, 1}},
+		{Time: 1, Eye: geom.Point{0, 0, 40}, LookAt: geom.Point{0, 0, 41}},
+	}}
+	first, err := s.RenderWithOptions(&Options{})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	last, err := s.RenderWithOptions(&Options{Frame: 24})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if bytes.Equal(first.Pix, last.Pix) {
+		t.Errorf("camera not animated")
+	}
+	if s.Camera.Eye != (geom.Point{}) {
+		t.Errorf("scene camera modified: %v", s.Camera.Eye)
+	}
+}
+
+func TestLensDistortion(t *testing.T) {
+	data := []struct {
+		k1, k2 float64
+	}{
+		{-0.2, 0},   // barrel
+		{0.1, 0.05}, // pincushion
+		{-0.1, 0.02},
+	}
+	for i, d := range data {
+		c := Camera{LookAt: geom.Point{0, 0, 1}, FOV: 90, Width: 20, K1: d.k1, K2: d.k2}
+		if err := c.Validate(); err != nil {
+			t.Fatalf("#%d: validation failed: %v", i, err)
+		}
+		c.prepare()
+		// Distorting the undistorted direction lands back on the pixel.
+		x, y := 6.0, -3.0
+		r := c.ray(x, y, 0).Dir
+		u, v := r.X/r.Z, r.Y/r.Z
+		r2 := u*u + v*v
+		f := (1 + d.k1*r2 + d.k2*r2*r2) * c.dist
+		if !geom.FloatsEqual(u*f, x, 1e-6) || !geom.FloatsEqual(v*f, y, 1e-6) {
+			t.Errorf("#%d: exp: %v %v act: %v %v", i, x, y, u*f, v*f)
+		}
+	}
+
+	// Barrel distortion widens the view at the edges, pincushion narrows it,
+	// and the view axis is unaffected.
+	angle := func(k1 float64) float64 {
+		c := Camera{LookAt: geom.Point{0, 0, 1}, FOV: 90, Width: 20, K1: k1}
+		c.prepare()
+		if r := c.ray(0, 0, 0); r.Dir != (geom.Vector{0, 0, 1}) {
+			t.Errorf("k1 %v: view axis distorted: %v", k1, r.Dir)
+		}
+		r := c.ray(9.5, 0, 0).Dir
+		return math.Atan2(r.X, r.Z)
+	}
+	if barrel, none, pin := angle(-0.1), angle(0), angle(0.1); barrel <= none || pin >= none {
+		t.Errorf("exp: %v > %v > %v", barrel, none, pin)
+	}
+
+	// Strong barrel distortion leaves corners out of reach of the lens.
+	c := Camera{LookAt: geom.Point{0, 0, 1}, FOV: 90, Width: 20, K1: -0.5}
+	c.prepare()
+	if !c.ray(9.5, 9.5, 0).blank {
+		t.Errorf("corner beyond lens not blank")
+	}
+
+	c = Camera{LookAt: geom.Point{0, 0, 1}, FOV: 180, Width: 20, K1: 0.1, Projection: FisheyeProjection}
+	if err := c.Validate(); err == nil {
+		t.Errorf("distorted fisheye accepted")
+	}
+}
+
+func TestChromaticAberration(t *testing.T) {
+	s := testScene()
+	s.Bg = Color{}
+	s.Objects[0].(*Sphere).Sphere.Center = geom.Point{12, 0, 80}
+	s.Objects[0].(*Sphere).Sphere.Radius = 3
+	s.Objects[0].(*Sphere).Color = Color{1, 1, 1}
+	// offsets counts the pixels where red or blue light is seen without
+	// green light.  Red images are magnified, so red spills past the edge
+	// of the white sphere facing the center of the image and blue past the
+	// opposite one.
+	offsets := func() (red, blue int) {
+		img, err := s.Render(1)
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		for py := 0; py < 20; py++ {
+			for px := 0; px < 20; px++ {
+				c := img.RGBAAt(px, py)
+				if c.G == 0 && c.R > 0 {
+					red++
+					if px > 16 {
+						t.Errorf("red past outer edge: (%d, %d)", px, py)
+					}
+				}
+				if c.G == 0 && c.B > 0 {
+					blue++
+					if px < 16 {
+						t.Errorf("blue past inner edge: (%d, %d)", px, py)
+					}
+				}
+			}
+		}
+		return red, blue
+	}
+	for _, k := range []struct {
+		name string
+		set  func(float64)
+	}{
+		{"frustum", func(k float64) { s.ViewFrustum.ChromaticAberration = k }},
+		{"camera", func(k float64) {
+			s.Camera = &Camera{Eye: geom.Point{0, 0, -100}, FOV: 2 * math.Atan(0.1) * 180 / math.Pi,
+				Width: 20, ChromaticAberration: k}
+		}},
+	} {
+		k.set(0)
+		if r, b := offsets(); r != 0 || b != 0 {
+			t.Errorf("%s: aberration without effect: %d %d", k.name, r, b)
+		}
+		k.set(0.15)
+		if r, b := offsets(); r == 0 || b == 0 {
+			t.Errorf("%s: no aberration: %d %d", k.name, r, b)
+		}
+	}
+
+	c := Camera{LookAt: geom.Point{0, 0, 1}, FOV: 60, Width: 20, ChromaticAberration: 1}
+	if err := c.Validate(); err == nil {
+		t.Errorf("invalid chromatic aberration accepted")
+	}
+	// Each eye of side-by-side stereo cameras is magnified around its
+	// own center.
+	c = Camera{Width: 20, Stereo: SideBySide}
+	if x, y := c.magnify(5, 2, 2); x != 0 || y != 4 {
+		t.Errorf("left eye: %v %v", x, y)
+	}
+	if x, y := c.magnify(15, 2, 2); x != 20 || y != 4 {
+		t.Errorf("right eye: %v %v", x, y)
+	}
+}
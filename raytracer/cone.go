@@ -0,0 +1,144 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// Cone objects are cones and truncated cones.
+type Cone struct {
+	Cone     geom.Cone
+	Open     bool // remove the caps
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Cone", func() Object { return new(Cone) })
+}
+
+// Faces of a cone.
+const (
+	coneSide = iota
+	coneBottom
+	coneTop
+)
+
+func (c *Cone) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	var p geom.Point
+	var t float64
+	var n geom.Vector
+	var ok bool
+	if c.Open {
+		p, t, n, ok = geom.RayConeSideIntersection(c.Cone, ray)
+	} else {
+		p, t, n, ok = geom.RayConeIntersection(c.Cone, ray)
+	}
+	if !ok {
+		return Hit{}, false
+	}
+	// Cap normals are aligned with the axis.  Side normals are not, except at
+	// the apex where both are the same.
+	up := c.Cone.Axis.UnitVector()
+	face := coneSide
+	if d := geom.DotProduct(&n, &up); d < -1+1e-9 {
+		face = coneBottom
+	} else if d > 1-1e-9 {
+		face = coneTop
+	}
+	return Hit{T: t, Point: p, Object: c, Face: face}, true
+}
+
+func (c *Cone) NormalAt(h *Hit, time float64) geom.Vector {
+	up := c.Cone.Axis.UnitVector()
+	switch h.Face {
+	case coneBottom:
+		return up.Neg()
+	case coneTop:
+		return up
+	}
+	return c.Cone.SideNormalAt(&h.Point)
+}
+
+func (c *Cone) Bounds() geom.AABB {
+	g := &c.Cone
+	a := g.Axis.UnitVector()
+	// Half-extent along each axis of a cap of unit radius.
+	e := geom.Vector{
+		math.Sqrt(math.Max(0, 1-a.X*a.X)),
+		math.Sqrt(math.Max(0, 1-a.Y*a.Y)),
+		math.Sqrt(math.Max(0, 1-a.Z*a.Z)),
+	}
+	b := geom.EmptyAABB()
+	for _, end := range [2]struct {
+		center geom.Point
+		radius float64
+	}{{g.Base, g.BaseRadius}, {g.Base.Translate(g.Axis), g.TopRadius}} {
+		re := e.Scale(end.radius)
+		b = b.Expand(end.center.Translate(re))
+		b = b.Expand(end.center.Translate(re.Neg()))
+	}
+	return b
+}
+
+// Inside returns whether p lies inside the volume bounded by the lateral
+// surface and the planes of the caps, even if c is open.
+func (c *Cone) Inside(p geom.Point, time float64) bool {
+	return c.Cone.Contains(&p)
+}
+
+func (c *Cone) ColorAt(h *Hit) Color {
+	return c.Color
+}
+
+func (c *Cone) MaterialAt(h *Hit) *Material {
+	return c.Material
+}
+
+func (c *Cone) Clone() Object {
+	cc := *c
+	cc.Material = c.Material.clone()
+	return &cc
+}
+
+func (c *Cone) Validate() error {
+	if err := c.Cone.Validate(); err != nil {
+		return err
+	}
+	if err := c.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid cone: %v", err)
+	}
+	if c.Material != nil {
+		if err := c.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid cone: %v", err)
+		}
+		if err := c.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid cone: %v", err)
+		}
+	}
+	return nil
+}
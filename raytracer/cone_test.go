@@ -0,0 +1,76 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+func TestConeNormals(t *testing.T) {
+	// Truncated cone of radii 2 at y == 0 and 1 at y == 2.
+	c := &Cone{Cone: geom.Cone{geom.Point{0, 0, 0}, geom.Vector{0, 2, 0}, 2, 1}}
+	side := geom.Vector{2, 1, 0}
+	side = side.UnitVector()
+	data := []struct {
+		r  geom.Ray
+		ok bool
+		n  geom.Vector
+	}{
+		{geom.MakeRay(geom.Point{5, 1, 0}, geom.Vector{-1, 0, 0}), true, side},
+		{geom.MakeRay(geom.Point{0, 10, 0}, geom.Vector{0, -1, 0}), true, geom.Vector{0, 1, 0}},
+		{geom.MakeRay(geom.Point{0.5, -10, 0}, geom.Vector{0, 1, 0}), true, geom.Vector{0, -1, 0}},
+		{geom.MakeRay(geom.Point{-5, 5, 0}, geom.Vector{1, 0, 0}), false, geom.Vector{}},
+	}
+	for i, d := range data {
+		h, ok := c.Intersect(d.r, 0)
+		if ok != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if n := c.NormalAt(&h, 0); !geom.VectorsEqual(n, d.n, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.n, n)
+		}
+	}
+
+	c.Open = true
+	h, ok := c.Intersect(geom.MakeRay(geom.Point{0, 3, 0}, geom.Vector{0.8, -1, 0}), 0)
+	if !ok {
+		t.Fatalf("inner side of open cone missed")
+	}
+	if n := c.NormalAt(&h, 0); !geom.VectorsEqual(n, side, 1e-9) {
+		t.Errorf("exp: %v act: %v", side, n)
+	}
+}
+
+func TestConeBounds(t *testing.T) {
+	c := &Cone{Cone: geom.Cone{geom.Point{0, 0, 0}, geom.Vector{0, 2, 0}, 2, 1}}
+	exp := geom.AABB{geom.Point{-2, 0, -2}, geom.Point{2, 2, 2}}
+	b := c.Bounds()
+	if !geom.PointsEqual(b.Min, exp.Min, 1e-9) || !geom.PointsEqual(b.Max, exp.Max, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, b)
+	}
+}
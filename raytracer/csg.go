@@ -0,0 +1,205 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// A CSGOperation is a boolean set operation combining solids.
+type CSGOperation string
+
+const (
+	CSGUnion        CSGOperation = "union"        // points inside any object
+	CSGIntersection CSGOperation = "intersection" // points inside all objects
+	CSGDifference   CSGOperation = "difference"   // points inside the first object only
+)
+
+// CSG objects are solids obtained by combining other objects with a
+// constructive solid geometry operation.  The surface of each combined object
+// keeps its color.
+//
+// Combined objects are treated as solids whose inside is defined by their
+// Inside method.  For instance planes are half-spaces and can be used to cut
+// other objects.
+type CSG struct {
+	Operation CSGOperation
+	Objects   ObjectList
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("CSG", func() Object { return new(CSG) })
+}
+
+// Intersect returns the nearest intersection between ray and the surface of
+// c.  The part of the returned hit is the intersection with the combined
+// object owning this surface.
+//
+// Intersections with combined objects are enumerated in ray order and
+// intersections not on the surface of the result of the operation skipped.
+func (c *CSG) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	r := ray
+	for {
+		var h Hit
+		hit := -1
+		for i, o := range c.Objects {
+			if oh, ok := o.Intersect(r, time); ok && (hit == -1 || oh.T < h.T) {
+				h = oh
+				hit = i
+			}
+		}
+		if hit == -1 {
+			return Hit{}, false
+		}
+		if c.onSurface(hit, h.Point, time) {
+			return Hit{T: h.T, Point: h.Point, Object: c, Face: hit, Part: &h}, true
+		}
+		// Resume the search just past h.
+		r.TMin = h.T + 1e-9*math.Max(1, math.Abs(h.T))
+	}
+}
+
+// onSurface returns whether point p of the surface of the i-th object of c
+// lies on the surface of c.
+func (c *CSG) onSurface(i int, p geom.Point, time float64) bool {
+	for j, o := range c.Objects {
+		if j == i {
+			continue
+		}
+		in := o.Inside(p, time)
+		switch c.Operation {
+		case CSGUnion:
+			if in {
+				return false
+			}
+		case CSGIntersection:
+			if !in {
+				return false
+			}
+		case CSGDifference:
+			if (j == 0) != in {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NormalAt returns the normal of the combined object owning the surface at h.
+// Normals of the surfaces of subtracted objects are flipped as their inner
+// side forms the surface of c.
+func (c *CSG) NormalAt(h *Hit, time float64) geom.Vector {
+	n := c.Objects[h.Face].NormalAt(h.Part, time)
+	if c.Operation == CSGDifference && h.Face > 0 {
+		n = n.Neg()
+	}
+	return n
+}
+
+func (c *CSG) Bounds() geom.AABB {
+	if len(c.Objects) == 0 {
+		return geom.EmptyAABB()
+	}
+	b := c.Objects[0].Bounds()
+	if c.Operation == CSGDifference {
+		return b
+	}
+	for _, o := range c.Objects[1:] {
+		ob := o.Bounds()
+		if c.Operation == CSGUnion {
+			b = b.Union(ob)
+		} else {
+			b = geom.AABB{
+				geom.Point{math.Max(b.Min.X, ob.Min.X), math.Max(b.Min.Y, ob.Min.Y), math.Max(b.Min.Z, ob.Min.Z)},
+				geom.Point{math.Min(b.Max.X, ob.Max.X), math.Min(b.Max.Y, ob.Max.Y), math.Min(b.Max.Z, ob.Max.Z)},
+			}
+		}
+	}
+	return b
+}
+
+func (c *CSG) Inside(p geom.Point, time float64) bool {
+	for i, o := range c.Objects {
+		in := o.Inside(p, time)
+		switch {
+		case c.Operation == CSGUnion && in:
+			return true
+		case c.Operation == CSGIntersection && !in:
+			return false
+		case c.Operation == CSGDifference && (i == 0) != in:
+			return false
+		}
+	}
+	return c.Operation != CSGUnion && len(c.Objects) > 0
+}
+
+func (c *CSG) ColorAt(h *Hit) Color {
+	return c.Objects[h.Face].ColorAt(h.Part)
+}
+
+func (c *CSG) MaterialAt(h *Hit) *Material {
+	return c.Objects[h.Face].MaterialAt(h.Part)
+}
+
+func (c *CSG) Clone() Object {
+	cc := *c
+	cc.Objects = make(ObjectList, len(c.Objects))
+	for i, o := range c.Objects {
+		cc.Objects[i] = o.Clone()
+	}
+	return &cc
+}
+
+func (c *CSG) prepare(protos ObjectMap) error {
+	for _, o := range c.Objects {
+		if p, ok := o.(preparer); ok {
+			if err := p.prepare(protos); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *CSG) Validate() error {
+	switch c.Operation {
+	case CSGUnion, CSGIntersection, CSGDifference:
+	default:
+		return fmt.Errorf("invalid CSG operation: %q", c.Operation)
+	}
+	if len(c.Objects) == 0 {
+		return fmt.Errorf("invalid CSG: no object")
+	}
+	for _, o := range c.Objects {
+		if o == nil {
+			return fmt.Errorf("invalid CSG: null object")
+		}
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("invalid CSG object: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+// csgSpheres returns CSG objects combining spheres of radius 2 centered on
+// (-1, 0, 0) and (1, 0, 0).
+func csgSpheres(op CSGOperation) *CSG {
+	return &CSG{
+		Operation: op,
+		Objects: ObjectList{
+			&Sphere{Sphere: geom.Sphere{geom.Point{-1, 0, 0}, 2}, Color: Color{1, 0, 0}},
+			&Sphere{Sphere: geom.Sphere{geom.Point{1, 0, 0}, 2}, Color: Color{0, 1, 0}},
+		},
+	}
+}
+
+func TestCSGIntersect(t *testing.T) {
+	fromLeft := geom.MakeRay(geom.Point{-10, 0, 0}, geom.Vector{1, 0, 0})
+	fromRight := geom.MakeRay(geom.Point{10, 0, 0}, geom.Vector{-1, 0, 0})
+	data := []struct {
+		op    CSGOperation
+		r     geom.Ray
+		ok    bool
+		p     geom.Point
+		n     geom.Vector
+		color Color
+	}{
+		{CSGUnion, fromLeft, true, geom.Point{-3, 0, 0}, geom.Vector{-1, 0, 0}, Color{1, 0, 0}},
+		{CSGUnion, fromRight, true, geom.Point{3, 0, 0}, geom.Vector{1, 0, 0}, Color{0, 1, 0}},
+		{CSGIntersection, fromLeft, true, geom.Point{-1, 0, 0}, geom.Vector{-1, 0, 0}, Color{0, 1, 0}},
+		{CSGIntersection, fromRight, true, geom.Point{1, 0, 0}, geom.Vector{1, 0, 0}, Color{1, 0, 0}},
+		{CSGDifference, fromLeft, true, geom.Point{-3, 0, 0}, geom.Vector{-1, 0, 0}, Color{1, 0, 0}},
+		{CSGDifference, fromRight, true, geom.Point{-1, 0, 0}, geom.Vector{1, 0, 0}, Color{0, 1, 0}},
+		{CSGDifference, geom.MakeRay(geom.Point{0, 10, 0}, geom.Vector{0, -1, 0}), false, geom.Point{}, geom.Vector{}, Color{}},
+		{CSGIntersection, geom.MakeRay(geom.Point{-2, 10, 0}, geom.Vector{0, -1, 0}), false, geom.Point{}, geom.Vector{}, Color{}},
+	}
+	for i, d := range data {
+		c := csgSpheres(d.op)
+		h, ok := c.Intersect(d.r, 0)
+		if ok != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !geom.PointsEqual(h.Point, d.p, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.p, h.Point)
+		}
+		if n := c.NormalAt(&h, 0); !geom.VectorsEqual(n, d.n, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.n, n)
+		}
+		if color := c.ColorAt(&h); color != d.color {
+			t.Errorf("#%d: exp: %v act: %v", i, d.color, color)
+		}
+	}
+}
+
+func TestCSGInside(t *testing.T) {
+	data := []struct {
+		op  CSGOperation
+		p   geom.Point
+		exp bool
+	}{
+		{CSGUnion, geom.Point{-2.5, 0, 0}, true},
+		{CSGUnion, geom.Point{0, 2.5, 0}, false},
+		{CSGIntersection, geom.Point{0, 0, 0}, true},
+		{CSGIntersection, geom.Point{-2.5, 0, 0}, false},
+		{CSGDifference, geom.Point{-2.5, 0, 0}, true},
+		{CSGDifference, geom.Point{0, 0, 0}, false},
+		{CSGDifference, geom.Point{2.5, 0, 0}, false},
+	}
+	for _, d := range data {
+		c := csgSpheres(d.op)
+		if act := c.Inside(d.p, 0); act != d.exp {
+			t.Errorf("%v %v: exp: %v act: %v", d.op, d.p, d.exp, act)
+		}
+	}
+}
+
+func TestCSGBounds(t *testing.T) {
+	data := []struct {
+		op  CSGOperation
+		exp geom.AABB
+	}{
+		{CSGUnion, geom.AABB{geom.Point{-3, -2, -2}, geom.Point{3, 2, 2}}},
+		{CSGIntersection, geom.AABB{geom.Point{-1, -2, -2}, geom.Point{1, 2, 2}}},
+		{CSGDifference, geom.AABB{geom.Point{-3, -2, -2}, geom.Point{1, 2, 2}}},
+	}
+	for _, d := range data {
+		c := csgSpheres(d.op)
+		if act := c.Bounds(); act != d.exp {
+			t.Errorf("%v: exp: %v act: %v", d.op, d.exp, act)
+		}
+	}
+}
+
+func TestCSGRender(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	// Test sphere cut in half by a plane facing the eye.
+	data := `[{ "Type": "CSG", "Operation": "intersection",
+		"Objects": [
+			{ "Sphere": { "Center": {"X":0,"Y":0,"Z":80}, "Radius":10 },
+			  "Color": {"R":1, "G":0, "B":0} },
+			{ "Type": "Plane",
+			  "Plane": { "Point": {"X":0,"Y":0,"Z":80}, "Normal": {"X":0,"Y":0,"Z":-1} },
+			  "Color": {"R":0, "G":1, "B":0} }
+		] }]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = l
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// Only the disk of the cut is visible.
+	if act := img.RGBAAt(10, 10); act.G <= act.R || act.G <= act.B {
+		t.Fatalf("cut not rendered: %v", act)
+	}
+
+	s.Objects[0].(*CSG).Operation = "xor"
+	if _, err := s.Render(1); err == nil {
+		t.Fatalf("invalid operation accepted")
+	}
+}
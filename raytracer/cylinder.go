@@ -0,0 +1,142 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// Cylinder objects are finite cylinders.  The height of the cylinder is the
+// length of its axis.
+type Cylinder struct {
+	Cylinder geom.Cylinder
+	Open     bool // remove the caps
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Cylinder", func() Object { return new(Cylinder) })
+}
+
+// Faces of a cylinder.
+const (
+	cylinderTube = iota
+	cylinderBottom
+	cylinderTop
+)
+
+func (c *Cylinder) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	var p geom.Point
+	var t float64
+	var n geom.Vector
+	var ok bool
+	if c.Open {
+		p, t, n, ok = geom.RayTubeIntersection(c.Cylinder, ray)
+	} else {
+		p, t, n, ok = geom.RayCylinderIntersection(c.Cylinder, ray)
+	}
+	if !ok {
+		return Hit{}, false
+	}
+	up := c.Cylinder.Axis.UnitVector()
+	face := cylinderTube
+	if d := geom.DotProduct(&n, &up); d < -0.5 {
+		face = cylinderBottom
+	} else if d > 0.5 {
+		face = cylinderTop
+	}
+	return Hit{T: t, Point: p, Object: c, Face: face}, true
+}
+
+func (c *Cylinder) NormalAt(h *Hit, time float64) geom.Vector {
+	up := c.Cylinder.Axis.UnitVector()
+	switch h.Face {
+	case cylinderBottom:
+		return up.Neg()
+	case cylinderTop:
+		return up
+	}
+	// Remove the axial component of the vector from the base to the point.
+	v := geom.MakeVector(h.Point, c.Cylinder.Base)
+	n := v.Sub(up.Scale(geom.DotProduct(&v, &up)))
+	return n.UnitVector()
+}
+
+func (c *Cylinder) Bounds() geom.AABB {
+	g := &c.Cylinder
+	a := g.Axis.UnitVector()
+	// Half-extent of the caps along each axis.
+	e := geom.Vector{
+		g.Radius * math.Sqrt(math.Max(0, 1-a.X*a.X)),
+		g.Radius * math.Sqrt(math.Max(0, 1-a.Y*a.Y)),
+		g.Radius * math.Sqrt(math.Max(0, 1-a.Z*a.Z)),
+	}
+	b := geom.EmptyAABB()
+	for _, center := range [2]geom.Point{g.Base, g.Base.Translate(g.Axis)} {
+		b = b.Expand(center.Translate(e))
+		b = b.Expand(center.Translate(e.Neg()))
+	}
+	return b
+}
+
+// Inside returns whether p lies inside the volume bounded by the tube and
+// the planes of the caps, even if c is open.
+func (c *Cylinder) Inside(p geom.Point, time float64) bool {
+	return c.Cylinder.Contains(&p)
+}
+
+func (c *Cylinder) ColorAt(h *Hit) Color {
+	return c.Color
+}
+
+func (c *Cylinder) MaterialAt(h *Hit) *Material {
+	return c.Material
+}
+
+func (c *Cylinder) Clone() Object {
+	cc := *c
+	cc.Material = c.Material.clone()
+	return &cc
+}
+
+func (c *Cylinder) Validate() error {
+	if err := c.Cylinder.Validate(); err != nil {
+		return err
+	}
+	if err := c.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid cylinder: %v", err)
+	}
+	if c.Material != nil {
+		if err := c.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid cylinder: %v", err)
+		}
+		if err := c.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid cylinder: %v", err)
+		}
+	}
+	return nil
+}
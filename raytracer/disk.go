@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+)
+
+// Disk objects are flat disks.  Like planes, both sides are visible and the
+// half-space behind the disk is considered its inside.
+type Disk struct {
+	Disk     geom.Disk
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Disk", func() Object { return new(Disk) })
+}
+
+func (d *Disk) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	p, t, ok := geom.RayDiskIntersection(d.Disk, ray)
+	if !ok {
+		return Hit{}, false
+	}
+	return Hit{T: t, Point: p, Object: d}, true
+}
+
+func (d *Disk) NormalAt(h *Hit, time float64) geom.Vector {
+	return d.Disk.Normal.UnitVector()
+}
+
+func (d *Disk) Bounds() geom.AABB {
+	return d.Disk.Bounds()
+}
+
+func (d *Disk) Inside(p geom.Point, time float64) bool {
+	v := geom.MakeVector(p, d.Disk.Center)
+	return geom.DotProduct(&v, &d.Disk.Normal) < 0
+}
+
+func (d *Disk) ColorAt(h *Hit) Color {
+	return d.Color
+}
+
+func (d *Disk) MaterialAt(h *Hit) *Material {
+	return d.Material
+}
+
+func (d *Disk) Clone() Object {
+	c := *d
+	c.Material = d.Material.clone()
+	return &c
+}
+
+func (d *Disk) Validate() error {
+	if err := d.Disk.Validate(); err != nil {
+		return err
+	}
+	if err := d.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid disk: %v", err)
+	}
+	if d.Material != nil {
+		if err := d.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid disk: %v", err)
+		}
+		if err := d.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid disk: %v", err)
+		}
+	}
+	return nil
+}
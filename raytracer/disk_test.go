@@ -0,0 +1,51 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+func TestDiskTwoSided(t *testing.T) {
+	s := testScene()
+	// Disk facing the eye in front of the sphere.
+	d := &Disk{
+		Disk:  geom.Disk{Center: geom.Point{0, 0, 60}, Normal: geom.Vector{0, 0, -1}, Radius: 5},
+		Color: Color{0, 1, 0},
+	}
+	s.Objects = append(s.Objects, d)
+	front, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	d.Disk.Normal = geom.Vector{0, 0, 1}
+	back, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !bytes.Equal(front.Pix, back.Pix) {
+		t.Fatalf("back side shaded differently")
+	}
+}
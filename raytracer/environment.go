@@ -0,0 +1,384 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// An EnvironmentType selects how an environment maps directions to images.
+type EnvironmentType string
+
+const (
+	// EquirectEnvironment maps a single image with longitudes along its
+	// width and latitudes along its height.  The center of the image is
+	// seen along +z and its top row along +y.
+	EquirectEnvironment EnvironmentType = "equirect"
+
+	// CubeEnvironment maps six images onto the faces of a cube around the
+	// scene, in the order +x, -x, +y, -y, +z, -z, following the
+	// orientation conventions of OpenGL cube maps.
+	CubeEnvironment EnvironmentType = "cube"
+
+	// SkyEnvironment is a procedural clear sky following the analytic
+	// model of Preetham, Shirley and Smits, "A Practical Analytic Model for
+	// Daylight".  It needs no image and lights the scene with a matching
+	// sun.
+	SkyEnvironment EnvironmentType = "sky"
+)
+
+// An Environment surrounds the scene with images seen infinitely far away
+// along rays missing all objects, replacing the background color.  Files are
+// PNG, JPEG or Radiance HDR images loaded when rendering starts, relative
+// paths being relative to the current directory.
+//
+// Sky environments are computed from the direction Sun toward the sun, +y
+// pointing up, and the Turbidity of the atmosphere, from 2 for a clear sky to
+// 10 for a hazy one, zero selecting 3.  Directions below the horizon see the
+// color of the horizon.  While the sun is above the horizon, it adds to the
+// scene lights a directional light named "sun" of intensity SunIntensity,
+// zero meaning 1.
+type Environment struct {
+	Type  EnvironmentType
+	File  string    // image of equirectangular environments
+	Faces [6]string // images of cube environments
+
+	Sun          geom.Vector
+	Turbidity    float64
+	SunIntensity float64
+
+	// Intensity scales the colors of the environment, zero meaning 1.  HDR
+	// images of bright skies usually need scaling down.
+	Intensity float64
+
+	// Lighting, when positive, makes the environment light objects
+	// diffusely from all directions in addition to the scene light, scaled
+	// by Lighting.  The light reaching each orientation of surfaces is
+	// integrated once before rendering, ignoring objects in the way.
+	Lighting float64
+
+	maps       []texelMap // images loaded by prepare
+	irradiance texelMap   // diffuse light per normal direction, set by prepare
+	sky        *skyModel  // sky radiance model, set by prepare
+}
+
+// defaultTurbidity is the turbidity of sky environments when
+// Environment.Turbidity is zero.
+const defaultTurbidity = 3
+
+func (e *Environment) Validate() error {
+	switch e.Type {
+	case EquirectEnvironment:
+		if e.File == "" {
+			return fmt.Errorf("invalid environment: no image file")
+		}
+	case CubeEnvironment:
+		for i, f := range e.Faces {
+			if f == "" {
+				return fmt.Errorf("invalid environment: no image file for face #%d", i)
+			}
+		}
+	case SkyEnvironment:
+		if e.Sun.Module() == 0 {
+			return fmt.Errorf("invalid environment: null sun direction")
+		}
+		if t := e.Turbidity; t != 0 && (t < 2 || t > 10) {
+			return fmt.Errorf("invalid environment: turbidity out-of-range: %v", t)
+		}
+		if e.SunIntensity < 0 {
+			return fmt.Errorf("invalid environment: negative sun intensity: %v", e.SunIntensity)
+		}
+	default:
+		return fmt.Errorf("invalid environment type: %q", e.Type)
+	}
+	if e.Intensity < 0 {
+		return fmt.Errorf("invalid environment: negative intensity: %v", e.Intensity)
+	}
+	if e.Lighting < 0 {
+		return fmt.Errorf("invalid environment: negative lighting: %v", e.Lighting)
+	}
+	return nil
+}
+
+// clone returns a copy of e, nil if e is nil.
+func (e *Environment) clone() *Environment {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	return &c
+}
+
+// prepare loads the images of e.
+func (e *Environment) prepare() error {
+	if e.Type == SkyEnvironment {
+		if e.sky == nil {
+			e.sky = newSkyModel(e.Sun, e.turbidity())
+			if e.Lighting > 0 {
+				e.irradiance = e.prefilter()
+			}
+		}
+		return nil
+	}
+	if e.maps != nil {
+		return nil
+	}
+	files := e.Faces[:]
+	if e.Type == EquirectEnvironment {
+		files = []string{e.File}
+	}
+	maps := make([]texelMap, len(files))
+	for i, f := range files {
+		var err error
+		if maps[i], err = loadTexelMap(f); err != nil {
+			return fmt.Errorf("invalid environment: %v", err)
+		}
+	}
+	e.maps = maps
+	if e.Lighting > 0 {
+		e.irradiance = e.prefilter()
+	}
+	return nil
+}
+
+// colorAt returns the color of e seen along direction d.
+func (e *Environment) colorAt(d geom.Vector) Color {
+	c := e.lookup(d)
+	if k := e.Intensity; k != 0 {
+		c = Color{k * c.R, k * c.G, k * c.B}
+	}
+	return c
+}
+
+// lookup returns the color of the images of e seen along direction d.
+func (e *Environment) lookup(d geom.Vector) Color {
+	d = d.UnitVector()
+	if e.Type == SkyEnvironment {
+		return e.sky.colorAt(d)
+	}
+	if e.Type == EquirectEnvironment {
+		m := &e.maps[0]
+		u, v := equirectUV(d)
+		return m.bilinear(u, clampTexel(v, m.h), RepeatWrap)
+	}
+
+	// Project d onto the face of its major axis.
+	ax, ay, az := math.Abs(d.X), math.Abs(d.Y), math.Abs(d.Z)
+	var face int
+	var sc, tc, ma float64
+	switch {
+	case ax >= ay && ax >= az:
+		ma = ax
+		if d.X > 0 {
+			face, sc, tc = 0, -d.Z, -d.Y
+		} else {
+			face, sc, tc = 1, d.Z, -d.Y
+		}
+	case ay >= az:
+		ma = ay
+		if d.Y > 0 {
+			face, sc, tc = 2, d.X, d.Z
+		} else {
+			face, sc, tc = 3, d.X, -d.Z
+		}
+	default:
+		ma = az
+		if d.Z > 0 {
+			face, sc, tc = 4, d.X, -d.Y
+		} else {
+			face, sc, tc = 5, -d.X, -d.Y
+		}
+	}
+	m := &e.maps[face]
+	return m.bilinear(clampTexel((sc/ma+1)/2, m.w), clampTexel((tc/ma+1)/2, m.h), RepeatWrap)
+}
+
+func (e *Environment) turbidity() float64 {
+	if e.Turbidity == 0 {
+		return defaultTurbidity
+	}
+	return e.Turbidity
+}
+
+// sunLight returns the light cast by the sun of sky environment e, false if
+// e has no sun or it lies below the horizon.
+func (e *Environment) sunLight() (Light, bool) {
+	if e.Type != SkyEnvironment || e.Sun.Y <= 0 {
+		return Light{}, false
+	}
+	return Light{Name: "sun", Type: DirectionalLight, Direction: e.Sun.Neg(), Intensity: e.SunIntensity}, true
+}
+
+// A skyModel computes the radiance of a clear sky with the model of Preetham
+// et al.  Luminance Y and chromaticities x and y are each the value at the
+// zenith scaled by a Perez distribution depending on the angles from the
+// zenith and from the sun.
+type skyModel struct {
+	sun    geom.Vector   // unit direction toward the sun
+	perez  [3][5]float64 // coefficients A to E of Y, x and y
+	zenith [3]float64    // Y, x and y at the zenith over the Perez distribution there
+}
+
+// newSkyModel returns the model of a sky of turbidity t lit by the sun in
+// direction sun.
+func newSkyModel(sun geom.Vector, t float64) *skyModel {
+	m := &skyModel{sun: sun.UnitVector()}
+	// The model does not hold for the sun below the horizon.
+	cosS := math.Max(m.sun.Y, 0)
+	th := math.Acos(cosS)
+	m.perez = [3][5]float64{
+		{0.1787*t - 1.4630, -0.3554*t + 0.4275, -0.0227*t + 5.3251, 0.1206*t - 2.5771, -0.0670*t + 0.3703},
+		{-0.0193*t - 0.2592, -0.0665*t + 0.0008, -0.0004*t + 0.2125, -0.0641*t - 0.8989, -0.0033*t + 0.0452},
+		{-0.0167*t - 0.2608, -0.0950*t + 0.0092, -0.0079*t + 0.2102, -0.0441*t - 1.6537, -0.0109*t + 0.0529},
+	}
+
+	th2, th3 := th*th, th*th*th
+	chi := (4.0/9 - t/120) * (math.Pi - 2*th)
+	zy := (4.0453*t-4.9710)*math.Tan(chi) - 0.2155*t + 2.4192
+	zx := t*t*(0.00166*th3-0.00375*th2+0.00209*th) +
+		t*(-0.02903*th3+0.06377*th2-0.03202*th+0.00394) +
+		(0.11693*th3 - 0.21196*th2 + 0.06052*th + 0.25886)
+	zyy := t*t*(0.00275*th3-0.00610*th2+0.00317*th) +
+		t*(-0.04214*th3+0.08970*th2-0.04153*th+0.00516) +
+		(0.15346*th3 - 0.26756*th2 + 0.06670*th + 0.26688)
+	for i, z := range []float64{zy, zx, zyy} {
+		m.zenith[i] = z / perez(&m.perez[i], 1, cosS, th)
+	}
+	return m
+}
+
+// perez returns the Perez distribution of coefficients c for a direction at
+// an angle of cosine cosTheta from the zenith and gamma from the sun.
+func perez(c *[5]float64, cosTheta, cosGamma, gamma float64) float64 {
+	return (1 + c[0]*math.Exp(c[1]/cosTheta)) *
+		(1 + c[2]*math.Exp(c[3]*gamma) + c[4]*cosGamma*cosGamma)
+}
+
+// skyExposure scales sky luminances, in thousands of candelas per square
+// meter, to colors mostly below 1 away from the sun.  Environment.Intensity
+// adjusts it further.
+const skyExposure = 1.0 / 16
+
+// colorAt returns the color of the sky seen along unit direction d.
+func (m *skyModel) colorAt(d geom.Vector) Color {
+	if d.Y < 0 {
+		if d = (geom.Vector{d.X, 0, d.Z}); d.Module() == 0 {
+			d = geom.Vector{1, 0, 0}
+		}
+		d = d.UnitVector()
+	}
+	cosTheta := math.Max(d.Y, 1e-3)
+	cosGamma := math.Max(-1, math.Min(1, geom.DotProduct(&d, &m.sun)))
+	gamma := math.Acos(cosGamma)
+	var v [3]float64
+	for i := range v {
+		v[i] = m.zenith[i] * perez(&m.perez[i], cosTheta, cosGamma, gamma)
+	}
+	// Convert from xyY to XYZ then to linear sRGB.
+	lum, x, y := v[0]*skyExposure, v[1], v[2]
+	if y <= 0 {
+		return Color{}
+	}
+	cx, cz := x/y*lum, (1-x-y)/y*lum
+	return Color{
+		math.Max(0, 3.2406*cx-1.5372*lum-0.4986*cz),
+		math.Max(0, -0.9689*cx+1.8758*lum+0.0415*cz),
+		math.Max(0, 0.0557*cx-0.2040*lum+1.0570*cz),
+	}
+}
+
+// clampTexel clamps texture coordinate c to the centers of the first and last
+// of n texels so that bilinear interpolation does not wrap around the image.
+func clampTexel(c float64, n int) float64 {
+	half := 0.5 / float64(n)
+	return math.Max(half, math.Min(1-half, c))
+}
+
+// equirectUV returns the coordinates in [0..1] of unit direction d in
+// equirectangular images.
+func equirectUV(d geom.Vector) (u, v float64) {
+	u = 0.5 + math.Atan2(d.X, d.Z)/(2*math.Pi)
+	v = 0.5 - math.Asin(math.Max(-1, math.Min(1, d.Y)))/math.Pi
+	return u, v
+}
+
+// equirectDir returns the unit direction of coordinates (u, v) in
+// equirectangular images.
+func equirectDir(u, v float64) geom.Vector {
+	lon, lat := (u-0.5)*2*math.Pi, (0.5-v)*math.Pi
+	return geom.Vector{math.Sin(lon) * math.Cos(lat), math.Sin(lat), math.Cos(lon) * math.Cos(lat)}
+}
+
+// Sizes of the grid of directions the environment is sampled on and of the
+// equirectangular irradiance maps computed from these samples.
+const (
+	envSamplesW, envSamplesH       = 128, 64
+	irradianceMapW, irradianceMapH = 32, 16
+)
+
+// prefilter returns the equirectangular map of the irradiance, divided by pi,
+// of surfaces lit by e for each direction of their normal.
+func (e *Environment) prefilter() texelMap {
+	type sample struct {
+		d geom.Vector
+		c Color // radiance times solid angle over pi
+	}
+	samples := make([]sample, 0, envSamplesW*envSamplesH)
+	for j := 0; j < envSamplesH; j++ {
+		v := (float64(j) + 0.5) / envSamplesH
+		w := 2 * math.Pi / envSamplesW * math.Pi / envSamplesH * math.Sin(math.Pi*v) / math.Pi
+		for i := 0; i < envSamplesW; i++ {
+			d := equirectDir((float64(i)+0.5)/envSamplesW, v)
+			c := e.colorAt(d)
+			samples = append(samples, sample{d, Color{w * c.R, w * c.G, w * c.B}})
+		}
+	}
+	m := texelMap{irradianceMapW, irradianceMapH, make([]Color, irradianceMapW*irradianceMapH)}
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			n := equirectDir((float64(x)+0.5)/float64(m.w), (float64(y)+0.5)/float64(m.h))
+			var sum Color
+			for _, s := range samples {
+				if k := geom.DotProduct(&n, &s.d); k > 0 {
+					sum.R += k * s.c.R
+					sum.G += k * s.c.G
+					sum.B += k * s.c.B
+				}
+			}
+			m.pix[y*m.w+x] = sum
+		}
+	}
+	return m
+}
+
+// irradianceAt returns the diffuse light cast by e on surfaces of unit normal
+// n, scaled by e.Lighting.
+func (e *Environment) irradianceAt(n geom.Vector) Color {
+	m := &e.irradiance
+	u, v := equirectUV(n)
+	c := m.bilinear(u, clampTexel(v, m.h), RepeatWrap)
+	k := e.Lighting
+	return Color{k * c.R, k * c.G, k * c.B}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSolidImage saves a one-pixel PNG image of color c in a temporary
+// directory and returns its path.
+func writeSolidImage(t *testing.T, c color.RGBA) string {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, c)
+	f, err := os.CreateTemp(t.TempDir(), "face*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestEnvironmentValidate(t *testing.T) {
+	data := []struct {
+		e  Environment
+		ok bool
+	}{
+		{Environment{Type: EquirectEnvironment, File: "sky.png"}, true},
+		{Environment{Type: EquirectEnvironment}, false},
+		{Environment{Type: CubeEnvironment, Faces: [6]string{"a", "b", "c", "d", "e", "f"}}, true},
+		{Environment{Type: CubeEnvironment, Faces: [6]string{"a", "b", "c", "d", "e"}}, false},
+		{Environment{Type: "sphere", File: "sky.png"}, false},
+		{Environment{Type: EquirectEnvironment, File: "sky.hdr", Intensity: 0.1, Lighting: 2}, true},
+		{Environment{Type: EquirectEnvironment, File: "sky.hdr", Intensity: -1}, false},
+		{Environment{Type: EquirectEnvironment, File: "sky.hdr", Lighting: -1}, false},
+		{Environment{Type: SkyEnvironment, Sun: geom.Vector{0, 1, 1}, Turbidity: 4, SunIntensity: 2}, true},
+		{Environment{Type: SkyEnvironment}, false},
+		{Environment{Type: SkyEnvironment, Sun: geom.Vector{0, 1, 1}, Turbidity: 1}, false},
+		{Environment{Type: SkyEnvironment, Sun: geom.Vector{0, 1, 1}, SunIntensity: -1}, false},
+	}
+	for i, d := range data {
+		if err := d.e.Validate(); (err == nil) != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, err)
+		}
+	}
+}
+
+func TestCubeEnvironment(t *testing.T) {
+	cols := []Color{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {1, 1, 0}, {0, 1, 1}, {1, 0, 1}}
+	e := Environment{Type: CubeEnvironment}
+	for i, c := range cols {
+		e.Faces[i] = writeSolidImage(t, c.toRGBA())
+	}
+	if err := e.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	for i, d := range []geom.Vector{
+		{2, 0.5, -1}, {-1, 0, 0.2}, {0.3, 1, 0.3}, {0, -5, 1}, {0.1, -0.1, 1}, {0, 0.9, -1},
+	} {
+		if act := e.colorAt(d); act != cols[i] {
+			t.Errorf("%v: exp: %v act: %v", d, cols[i], act)
+		}
+	}
+
+	e.Faces[3] = filepath.Join(t.TempDir(), "missing.png")
+	e.maps = nil
+	if err := e.prepare(); err == nil {
+		t.Errorf("missing face accepted")
+	}
+}
+
+func TestEquirectEnvironment(t *testing.T) {
+	// Red on the left half, green on the right one.
+	e := Environment{Type: EquirectEnvironment, File: writeTestImage(t)}
+	if err := e.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	data := []struct {
+		d   geom.Vector
+		exp Color
+	}{
+		{geom.Vector{-1, 0, 0}, Color{1, 0, 0}},
+		{geom.Vector{1, 0.5, 0}, Color{0, 1, 0}},
+		// Center of the image, between both halves.
+		{geom.Vector{0, 0, 1}, Color{0.5, 0.5, 0}},
+	}
+	for _, d := range data {
+		if act := e.colorAt(d.d); !colorsEqual(act, d.exp) {
+			t.Errorf("%v: exp: %v act: %v", d.d, d.exp, act)
+		}
+	}
+}
+
+func TestEnvironmentRender(t *testing.T) {
+	s := testScene()
+	sky := Color{0, 0, 1}
+	s.Environment = &Environment{Type: EquirectEnvironment, File: writeSolidImage(t, sky.toRGBA())}
+	// Mirror sphere reflecting the environment.
+	s.Objects[0].(*Sphere).Material = &Material{Reflectivity: 1}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if exp, act := sky.toRGBA(), img.RGBAAt(0, 0); act != exp {
+		t.Errorf("background: exp: %v act: %v", exp, act)
+	}
+	if exp, act := sky.toRGBA(), img.RGBAAt(10, 10); act != exp {
+		t.Errorf("reflection: exp: %v act: %v", exp, act)
+	}
+
+	s.Environment.File = ""
+	if _, err := s.Render(1); err == nil {
+		t.Errorf("invalid environment accepted")
+	}
+}
+
+func TestEnvironmentLighting(t *testing.T) {
+	// White sky above a black ground.
+	e := Environment{Type: EquirectEnvironment, Lighting: 2}
+	e.maps = []texelMap{{1, 2, []Color{{1, 1, 1}, {0, 0, 0}}}}
+	e.irradiance = e.prefilter()
+	data := []struct {
+		n        geom.Vector
+		min, max float64
+	}{
+		{geom.Vector{0, 1, 0}, 1.8, 2},
+		{geom.Vector{1, 0, 0}, 0.9, 1.1},
+		{geom.Vector{0, -1, 0}, 0, 0.2},
+	}
+	for _, d := range data {
+		if act := e.irradianceAt(d.n); act.R < d.min || act.R > d.max || act.R != act.B {
+			t.Errorf("%v: exp: [%v..%v] act: %v", d.n, d.min, d.max, act)
+		}
+	}
+
+	// Uniform environment lighting a sphere in the shade.
+	s := testScene()
+	s.Objects = append(s.Objects, &Sphere{Sphere: geom.Sphere{geom.Point{-88, 27, 9}, 8}})
+	s.Objects[0].(*Sphere).Material = &Material{Ambient: 0.1, Diffuse: 0.5}
+	s.Environment = &Environment{Type: EquirectEnvironment,
+		File: writeSolidImage(t, color.RGBA{255, 255, 255, 255}), Intensity: 0.5, Lighting: 1}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// 0.1 ambient + 0.5 diffuse * 0.5 environment.
+	if act := img.RGBAAt(10, 10); act.R < 88 || act.R > 91 || act.G != 0 {
+		t.Errorf("exp: ~89 act: %v", act)
+	}
+}
+
+func TestSkyEnvironment(t *testing.T) {
+	e := Environment{Type: SkyEnvironment, Sun: geom.Vector{0, 1, 1}}
+	if err := e.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	zenith := e.colorAt(geom.Vector{0, 1, 0})
+	if zenith.B <= zenith.R {
+		t.Errorf("zenith not blue: %v", zenith)
+	}
+	// The sky brightens toward the sun.
+	near, away := e.colorAt(geom.Vector{0, 1, 1.2}), e.colorAt(geom.Vector{0, 1, -1.2})
+	if near.mean() <= away.mean() {
+		t.Errorf("sky not brighter toward the sun: %v %v", near, away)
+	}
+	// Below the horizon, it looks like the horizon.
+	horizon := e.colorAt(geom.Vector{1, 0, 0})
+	if act := e.colorAt(geom.Vector{1, -1, 0}); !colorsEqual(act, horizon) {
+		t.Errorf("ground: exp: %v act: %v", horizon, act)
+	}
+	// Haze whitens the sky.
+	hazy := Environment{Type: SkyEnvironment, Sun: e.Sun, Turbidity: 10}
+	if err := hazy.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if act := hazy.colorAt(geom.Vector{0, 1, 0}); act.B/act.R >= zenith.B/zenith.R {
+		t.Errorf("hazy zenith %v not whiter than %v", act, zenith)
+	}
+
+	// The sun lights the scene from its direction.
+	s := testScene()
+	s.Lights = nil
+	s.Environment = &Environment{Type: SkyEnvironment, Sun: geom.Vector{0, 1, 0}}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if top, bottom := img.RGBAAt(10, 6), img.RGBAAt(10, 14); top.R <= bottom.R {
+		t.Errorf("sphere not lit from above: top: %v bottom: %v", top, bottom)
+	}
+	if l, ok := s.Environment.sunLight(); !ok || l.Name != "sun" || l.Type != DirectionalLight {
+		t.Errorf("sun light: %+v %v", l, ok)
+	}
+	s.Environment.Sun = geom.Vector{0, -1, 1}
+	if _, ok := s.Environment.sunLight(); ok {
+		t.Errorf("sun below the horizon lights the scene")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+)
+
+// A Group is a set of objects positioned, rotated and scaled as a unit by its
+// transform.  Groups can be nested to build hierarchical scenes.  The volume
+// of a group is the union of the volumes of its children.
+type Group struct {
+	Transform Transform
+	Objects   ObjectList
+	Visibility
+
+	// Matrix of Transform and its inverse, set by prepare.
+	xform, inv geom.Mat4
+}
+
+func init() {
+	RegisterObjectType("Group", func() Object { return new(Group) })
+}
+
+func (g *Group) prepare(protos ObjectMap) error {
+	g.xform = g.Transform.Matrix()
+	g.inv, _ = g.xform.Inverse()
+	for _, o := range g.Objects {
+		if p, ok := o.(preparer); ok {
+			if err := p.prepare(protos); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Intersect returns the nearest intersection between the ray expressed in the
+// frame of g and its children.  Parameters along both rays match.
+func (g *Group) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	local := g.inv.TransformRay(ray)
+	var best Hit
+	ok := false
+	for i, o := range g.Objects {
+		if h, hit := o.Intersect(local, time); hit && (!ok || h.T < best.T) {
+			best = Hit{T: h.T, Object: g, Face: i, Part: &h}
+			ok = true
+		}
+	}
+	if !ok {
+		return Hit{}, false
+	}
+	best.Point = ray.At(best.T)
+	return best, true
+}
+
+// NormalAt transforms the normal of the intersected child by the inverse
+// transpose of the group matrix.
+func (g *Group) NormalAt(h *Hit, time float64) geom.Vector {
+	n := g.Objects[h.Face].NormalAt(h.Part, time)
+	invT := g.inv.Transpose()
+	n = invT.TransformVector(n)
+	return n.UnitVector()
+}
+
+func (g *Group) Bounds() geom.AABB {
+	b := geom.EmptyAABB()
+	for _, o := range g.Objects {
+		b = b.Union(o.Bounds())
+	}
+	return transformBounds(&g.xform, b)
+}
+
+func (g *Group) Inside(p geom.Point, time float64) bool {
+	p = g.inv.TransformPoint(p)
+	for _, o := range g.Objects {
+		if o.Inside(p, time) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Group) ColorAt(h *Hit) Color {
+	return g.Objects[h.Face].ColorAt(h.Part)
+}
+
+func (g *Group) MaterialAt(h *Hit) *Material {
+	return g.Objects[h.Face].MaterialAt(h.Part)
+}
+
+func (g *Group) Clone() Object {
+	c := *g
+	c.Objects = make(ObjectList, len(g.Objects))
+	for i, o := range g.Objects {
+		c.Objects[i] = o.Clone()
+	}
+	return &c
+}
+
+func (g *Group) Validate() error {
+	if err := g.Transform.Validate(); err != nil {
+		return fmt.Errorf("invalid group: %v", err)
+	}
+	for _, o := range g.Objects {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("invalid group: %v", err)
+		}
+	}
+	return nil
+}
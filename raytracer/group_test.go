@@ -0,0 +1,140 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+// testGroup returns a group moved to (10, 0, 0) containing a nested group
+// scaling a red unit sphere by 2 and a green unit sphere centered on
+// (0, 3, 0).
+func testGroup(t *testing.T) *Group {
+	g := &Group{
+		Transform: Transform{Translate: geom.Vector{10, 0, 0}},
+		Objects: ObjectList{
+			&Group{
+				Transform: Transform{Scale: geom.Vector{2, 2, 2}},
+				Objects: ObjectList{
+					&Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 0}, 1}, Color: Color{1, 0, 0}},
+				},
+			},
+			&Sphere{Sphere: geom.Sphere{geom.Point{0, 3, 0}, 1}, Color: Color{0, 1, 0}},
+		},
+	}
+	if err := g.prepare(nil); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	return g
+}
+
+func TestGroupIntersect(t *testing.T) {
+	g := testGroup(t)
+	data := []struct {
+		r     geom.Ray
+		ok    bool
+		p     geom.Point
+		n     geom.Vector
+		color Color
+	}{
+		{geom.MakeRay(geom.Point{0, 0, 0}, geom.Vector{1, 0, 0}), true, geom.Point{8, 0, 0}, geom.Vector{-1, 0, 0}, Color{1, 0, 0}},
+		{geom.MakeRay(geom.Point{10, 10, 0}, geom.Vector{0, -1, 0}), true, geom.Point{10, 4, 0}, geom.Vector{0, 1, 0}, Color{0, 1, 0}},
+		{geom.MakeRay(geom.Point{10, -10, 0}, geom.Vector{0, 1, 0}), true, geom.Point{10, -2, 0}, geom.Vector{0, -1, 0}, Color{1, 0, 0}},
+		{geom.MakeRay(geom.Point{0, 5, 0}, geom.Vector{1, 0, 0}), false, geom.Point{}, geom.Vector{}, Color{}},
+	}
+	for i, d := range data {
+		h, ok := g.Intersect(d.r, 0)
+		if ok != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !geom.PointsEqual(h.Point, d.p, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.p, h.Point)
+		}
+		if n := g.NormalAt(&h, 0); !geom.VectorsEqual(n, d.n, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.n, n)
+		}
+		if color := g.ColorAt(&h); color != d.color {
+			t.Errorf("#%d: exp: %v act: %v", i, d.color, color)
+		}
+	}
+}
+
+func TestGroupBoundsAndInside(t *testing.T) {
+	g := testGroup(t)
+	exp := geom.AABB{geom.Point{8, -2, -2}, geom.Point{12, 4, 2}}
+	if act := g.Bounds(); !geom.PointsEqual(act.Min, exp.Min, 1e-9) || !geom.PointsEqual(act.Max, exp.Max, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+	data := []struct {
+		p   geom.Point
+		exp bool
+	}{
+		{geom.Point{11.5, 0, 0}, true},
+		{geom.Point{10, 3.5, 0}, true},
+		{geom.Point{10, 5, 0}, false},
+		{geom.Point{0, 0, 0}, false},
+	}
+	for _, d := range data {
+		if act := g.Inside(d.p, 0); act != d.exp {
+			t.Errorf("%v: exp: %v act: %v", d.p, d.exp, act)
+		}
+	}
+}
+
+func TestGroupRender(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	// Green sphere moved in front of the eye by its group.
+	data := `[{ "Type": "Group",
+		"Transform": { "Translate": {"X":0,"Y":0,"Z":80} },
+		"Objects": [
+			{ "Type": "Group",
+			  "Transform": { "Scale": {"X":5,"Y":5,"Z":5} },
+			  "Objects": [
+				{ "Sphere": { "Center": {"X":0,"Y":0,"Z":0}, "Radius":1 },
+				  "Color": {"R":0, "G":1, "B":0} }
+			  ] }
+		] }]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = l
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(10, 10); act.G == 0 || act.R != act.B {
+		t.Fatalf("group not rendered: %v", act)
+	}
+
+	s.Objects[0].(*Group).Transform.Scale = geom.Vector{1, 0, 1}
+	if _, err := s.Render(1); err == nil {
+		t.Fatalf("null scaling factor accepted")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// isHDR returns whether the data read by r starts like a Radiance HDR image.
+func isHDR(r *bufio.Reader) bool {
+	magic, err := r.Peek(2)
+	return err == nil && string(magic) == "#?"
+}
+
+// decodeHDR decodes a Radiance HDR image in RGBE format.  Colors are not
+// clamped so that light sources brighter than white keep their intensity.
+// Only the standard orientation, with rows from top to bottom, and the flat
+// and run-length encoded scanlines written by current tools are supported.
+//
+// Format described in:
+// 	Ward, "Real Pixels", Graphics Gems II, 1991.
+func decodeHDR(r *bufio.Reader) (texelMap, error) {
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "#?") {
+		return texelMap{}, fmt.Errorf("not a Radiance HDR image")
+	}
+	for {
+		if line, err = r.ReadString('\n'); err != nil {
+			return texelMap{}, fmt.Errorf("truncated HDR header: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return texelMap{}, fmt.Errorf("unsupported HDR format: %s", line[len("FORMAT="):])
+		}
+	}
+	if line, err = r.ReadString('\n'); err != nil {
+		return texelMap{}, fmt.Errorf("truncated HDR header: %v", err)
+	}
+	var w, h int
+	if _, err := fmt.Sscanf(line, "-Y %d +X %d", &h, &w); err != nil || w <= 0 || h <= 0 {
+		return texelMap{}, fmt.Errorf("unsupported HDR resolution: %q", strings.TrimSpace(line))
+	}
+
+	m := texelMap{w, h, make([]Color, w*h)}
+	scan := make([]byte, 4*w)
+	for y := 0; y < h; y++ {
+		if err := readHDRScanline(r, scan); err != nil {
+			return texelMap{}, fmt.Errorf("invalid HDR scanline #%d: %v", y, err)
+		}
+		for x := 0; x < w; x++ {
+			m.pix[y*w+x] = rgbeColor(scan[4*x : 4*x+4])
+		}
+	}
+	return m, nil
+}
+
+// readHDRScanline reads a row of RGBE pixels into scan.
+func readHDRScanline(r *bufio.Reader, scan []byte) error {
+	w := len(scan) / 4
+	if _, err := io.ReadFull(r, scan[:4]); err != nil {
+		return err
+	}
+	if w < 8 || w > 0x7fff || scan[0] != 2 || scan[1] != 2 || scan[2]&0x80 != 0 {
+		// Flat scanline.
+		_, err := io.ReadFull(r, scan[4:])
+		return err
+	}
+	if n := int(scan[2])<<8 | int(scan[3]); n != w {
+		return fmt.Errorf("width mismatch: %d", n)
+	}
+	// Each channel is run-length encoded separately.
+	for c := 0; c < 4; c++ {
+		for x := 0; x < w; {
+			count, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				n := int(count) - 128
+				v, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+n > w {
+					return fmt.Errorf("run overflow")
+				}
+				for ; n > 0; n-- {
+					scan[4*x+c] = v
+					x++
+				}
+				continue
+			}
+			n := int(count)
+			if n == 0 || x+n > w {
+				return fmt.Errorf("invalid literal count: %d", n)
+			}
+			for ; n > 0; n-- {
+				v, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				scan[4*x+c] = v
+				x++
+			}
+		}
+	}
+	return nil
+}
+
+// rgbeColor converts a pixel made of three mantissas sharing an exponent.
+func rgbeColor(p []byte) Color {
+	if p[3] == 0 {
+		return Color{}
+	}
+	f := math.Ldexp(1, int(p[3])-(128+8))
+	return Color{(float64(p[0]) + 0.5) * f, (float64(p[1]) + 0.5) * f, (float64(p[2]) + 0.5) * f}
+}
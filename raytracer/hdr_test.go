@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testHDR is a 8x2 Radiance image whose first row is run-length encoded and
+// second one flat.
+func testHDR() []byte {
+	var b bytes.Buffer
+	b.WriteString("#?RADIANCE\n# comment\nFORMAT=32-bit_rle_rgbe\n\n-Y 2 +X 8\n")
+	b.Write([]byte{2, 2, 0, 8})
+	b.Write([]byte{128 + 8, 128})                         // red: run
+	b.Write([]byte{8, 0, 32, 64, 96, 128, 160, 192, 224}) // green: literals
+	b.Write([]byte{128 + 4, 0, 4, 255, 255, 255, 255})    // blue: both
+	b.Write([]byte{128 + 8, 129})                         // exponent
+	for i := 0; i < 8; i++ {
+		b.Write([]byte{64, 0, 0, 136})
+	}
+	return b.Bytes()
+}
+
+func TestDecodeHDR(t *testing.T) {
+	m, err := decodeHDR(bufio.NewReader(bytes.NewReader(testHDR())))
+	if err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	if m.w != 8 || m.h != 2 {
+		t.Fatalf("exp: 8x2 act: %dx%d", m.w, m.h)
+	}
+	data := []struct {
+		x, y int
+		exp  Color
+	}{
+		{0, 0, Color{128.5 / 128, 0.5 / 128, 0.5 / 128}},
+		{3, 0, Color{128.5 / 128, 96.5 / 128, 0.5 / 128}},
+		{7, 0, Color{128.5 / 128, 224.5 / 128, 255.5 / 128}},
+		// Colors brighter than white are kept.
+		{5, 1, Color{64.5, 0.5, 0.5}},
+	}
+	for _, d := range data {
+		if act := m.at(d.x, d.y, RepeatWrap); !colorsEqual(act, d.exp) {
+			t.Errorf("(%d, %d): exp: %v act: %v", d.x, d.y, d.exp, act)
+		}
+	}
+
+	for _, bad := range []string{
+		"P6\n8 2\n",
+		"#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 2 +X 8\n",
+		"#?RADIANCE\n\n+Y 2 +X 8\n",
+		"#?RADIANCE\n\n-Y 2 +X 8\n\x02\x02\x00\x08",
+	} {
+		if _, err := decodeHDR(bufio.NewReader(bytes.NewReader([]byte(bad)))); err == nil {
+			t.Errorf("%q: invalid image accepted", bad)
+		}
+	}
+}
+
+func TestHDRTexture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "texture.hdr")
+	if err := os.WriteFile(path, testHDR(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	tex := Texture{Type: ImageTexture, File: path, Filter: NearestFilter}
+	if err := tex.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if exp, act := (Color{64.5, 0.5, 0.5}), tex.imageColorAt(0.5, 0.75, 0, 0); !colorsEqual(act, exp) {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+}
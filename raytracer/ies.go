@@ -0,0 +1,207 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// An iesProfile is the photometric distribution of a light fixture, its
+// luminous intensity in each direction.  Directions are given by a vertical
+// angle from the nadir of the fixture, in [0..180], and a horizontal angle
+// around it, in [0..360[, both in degrees.
+type iesProfile struct {
+	v, h []float64 // increasing vertical and horizontal angles
+	cd   []float64 // intensities per horizontal then vertical angle, peak 1
+}
+
+// loadIESProfile loads the IES file at path, or returns the profile cached in
+// assets for the same contents.
+func loadIESProfile(path string) (*iesProfile, error) {
+	data, key, err := readAsset(path, "ies")
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := assets.get(key); ok {
+		return v.(*iesProfile), nil
+	}
+	p, err := parseIES(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	assets.put(key, p)
+	return p, nil
+}
+
+// parseIES decodes an IES photometric file.  Only type C photometry, used by
+// nearly all architectural fixtures, is supported.  Tilt data are ignored as
+// they only matter for fixtures mounted tilted.  Intensities are normalized
+// so that the peak is 1.
+//
+// Format described in:
+// 	ANSI/IES LM-63, "Standard File Format for the Electronic Transfer of
+// 	Photometric Data and Related Information".
+func parseIES(r io.Reader) (*iesProfile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	i := strings.Index(text, "TILT=")
+	if i < 0 {
+		return nil, fmt.Errorf("not an IES file: no TILT line")
+	}
+	text = text[i+len("TILT="):]
+	i = strings.IndexByte(text, '\n')
+	if i < 0 {
+		return nil, fmt.Errorf("truncated IES file")
+	}
+	tilt := strings.TrimSpace(text[:i])
+	fields := strings.FieldsFunc(text[i+1:], func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	nums := make([]float64, len(fields))
+	for i, f := range fields {
+		if nums[i], err = strconv.ParseFloat(f, 64); err != nil {
+			return nil, fmt.Errorf("invalid IES number: %q", f)
+		}
+	}
+	take := func(n int) ([]float64, error) {
+		if n < 0 || n > len(nums) {
+			return nil, fmt.Errorf("truncated IES file")
+		}
+		v := nums[:n]
+		nums = nums[n:]
+		return v, nil
+	}
+
+	if tilt == "INCLUDE" {
+		// Lamp-to-luminaire geometry, angles and multipliers.
+		hdr, err := take(2)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := take(2 * int(hdr[1])); err != nil {
+			return nil, err
+		}
+	}
+	hdr, err := take(13)
+	if err != nil {
+		return nil, err
+	}
+	nv, nh, kind := int(hdr[3]), int(hdr[4]), hdr[5]
+	if nv < 1 || nh < 1 {
+		return nil, fmt.Errorf("invalid IES angle counts: %v %v", nv, nh)
+	}
+	if kind != 1 {
+		return nil, fmt.Errorf("unsupported IES photometric type: %v", kind)
+	}
+	p := &iesProfile{}
+	if p.v, err = take(nv); err != nil {
+		return nil, err
+	}
+	if p.h, err = take(nh); err != nil {
+		return nil, err
+	}
+	if p.cd, err = take(nv * nh); err != nil {
+		return nil, err
+	}
+	if !sort.Float64sAreSorted(p.v) || !sort.Float64sAreSorted(p.h) {
+		return nil, fmt.Errorf("invalid IES angles: not increasing")
+	}
+	peak := 0.0
+	for _, c := range p.cd {
+		peak = math.Max(peak, c)
+	}
+	if peak <= 0 {
+		return nil, fmt.Errorf("invalid IES intensities: no light")
+	}
+	for i := range p.cd {
+		p.cd[i] /= peak
+	}
+	return p, nil
+}
+
+// intensity returns the normalized intensity of p at vertical angle theta and
+// horizontal angle phi, in degrees.  Fixtures emit no light outside of the
+// vertical angles measured, e.g. upward for downlights.
+func (p *iesProfile) intensity(theta, phi float64) float64 {
+	if theta < p.v[0] || theta > p.v[len(p.v)-1] {
+		return 0
+	}
+	// Unmeasured horizontal angles follow from the symmetries implied by
+	// the last angle measured.
+	switch last := p.h[len(p.h)-1]; {
+	case len(p.h) == 1:
+		phi = 0
+	case last == 90:
+		if phi > 180 {
+			phi = 360 - phi
+		}
+		if phi > 90 {
+			phi = 180 - phi
+		}
+	case last == 180:
+		if phi > 180 {
+			phi = 360 - phi
+		}
+	}
+	i, s := bracket(p.v, theta)
+	j, t := bracket(p.h, phi)
+	nv := len(p.v)
+	at := func(i, j int) float64 {
+		return p.cd[j*nv+i]
+	}
+	i1, j1 := i, j
+	if s > 0 {
+		i1++
+	}
+	if t > 0 {
+		j1++
+	}
+	return (1-t)*((1-s)*at(i, j)+s*at(i1, j)) + t*((1-s)*at(i, j1)+s*at(i1, j1))
+}
+
+// bracket returns the index i of the last element of increasing angles a not
+// above x and the position of x between a[i] and a[i+1] in [0..1], 0 beyond
+// the ends of a.
+func bracket(a []float64, x float64) (i int, t float64) {
+	i = sort.SearchFloat64s(a, x)
+	if i < len(a) && a[i] == x {
+		return i, 0
+	}
+	if i == 0 {
+		return 0, 0
+	}
+	if i == len(a) {
+		return len(a) - 1, 0
+	}
+	return i - 1, (x - a[i-1]) / (a[i] - a[i-1])
+}
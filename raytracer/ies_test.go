@@ -0,0 +1,146 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testIES is a downlight whose intensity halves from the nadir to 45 degrees
+// and vanishes at 90 degrees, brighter along horizontal angle 0 than 90.
+const testIES = `IESNA:LM-63-2002
+[TEST] downlight
+[MANUFAC] none
+TILT=NONE
+1 1000 1 3 2 1 2 0 0 0
+1, 1, 50
+0 45 90
+0 90
+200 100 0
+200 50 0
+`
+
+func TestParseIES(t *testing.T) {
+	p, err := parseIES(strings.NewReader(testIES))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	data := []struct {
+		theta, phi, exp float64
+	}{
+		{0, 0, 1},
+		{45, 0, 0.5},
+		{45, 90, 0.25},
+		{22.5, 0, 0.75},
+		{90, 0, 0},
+		{120, 0, 0},
+		// Quadrant symmetry.
+		{45, 180, 0.5},
+		{45, 270, 0.25},
+		{45, 135, 0.375},
+	}
+	for i, d := range data {
+		if act := p.intensity(d.theta, d.phi); !geom.FloatsEqual(act, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+
+	for i, bad := range []string{
+		"IESNA:LM-63-2002\n1 1000 1 3 1 1 2 0 0 0\n",
+		strings.Replace(testIES, "200 50 0", "200 50", 1),
+		strings.Replace(testIES, "1 1000 1 3 2 1", "1 1000 1 3 2 3", 1),
+		strings.Replace(testIES, "0 45 90", "0 x 90", 1),
+		strings.Replace(testIES, "0 45 90", "0 90 45", 1),
+	} {
+		if _, err := parseIES(strings.NewReader(bad)); err == nil {
+			t.Errorf("#%d: invalid file accepted", i)
+		}
+	}
+
+	tilted := strings.Replace(testIES, "TILT=NONE", "TILT=INCLUDE\n1\n2\n0 90\n1 0.5", 1)
+	if p, err := parseIES(strings.NewReader(tilted)); err != nil || p.intensity(45, 0) != 0.5 {
+		t.Errorf("tilt data not skipped: %v", err)
+	}
+}
+
+func TestLightProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "downlight.ies")
+	if err := os.WriteFile(path, []byte(testIES), 0666); err != nil {
+		t.Fatal(err)
+	}
+	l := Light{Position: geom.Point{0, 10, 0}, Profile: path, Intensity: 2}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	if err := l.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	data := []struct {
+		p   geom.Point
+		exp float64
+	}{
+		{geom.Point{0, 0, 0}, 2},
+		{geom.Point{10, 0, 0}, 1},
+		{geom.Point{0, 0, 10}, 0.5},
+		{geom.Point{0, 20, 0}, 0},
+	}
+	for i, d := range data {
+		if act := l.emission(d.p); !geom.FloatsEqual(act, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+
+	// Aimed toward +z, the fixture lights along z.
+	l = Light{Profile: path, Direction: geom.Vector{0, 0, 1}}
+	if err := l.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if act := l.emission(geom.Point{0, 0, 5}); act != 1 {
+		t.Errorf("aimed: exp: 1 act: %v", act)
+	}
+
+	// Profiles are parsed once per file contents.
+	first := l.profile
+	l = Light{Profile: path}
+	if err := l.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if l.profile != first {
+		t.Errorf("profile parsed twice")
+	}
+
+	l = Light{Type: AreaLight, U: geom.Vector{1, 0, 0}, V: geom.Vector{0, 1, 0}, Profile: path}
+	if err := l.Validate(); err == nil {
+		t.Errorf("profile on area light accepted")
+	}
+
+	s := testScene()
+	s.Lights[0].Profile = filepath.Join(t.TempDir(), "missing.ies")
+	if _, err := s.Render(1); err == nil {
+		t.Errorf("missing profile accepted")
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// An Instance places a copy of a scene prototype (see Scene.Prototypes) with
+// its own transform and optionally its own color.  Instances share the
+// geometry of their prototype, which is stored once whatever the number of
+// copies.
+//
+// EndTransform, when set, is the transform of the instance when the camera
+// shutter closes, Transform being the one when it opens.  The instance moves
+// smoothly from one to the other in between (see lerpTransform) and is
+// blurred when rendering several time samples per pixel.
+type Instance struct {
+	Prototype    string // name of the instantiated prototype
+	Transform    Transform
+	EndTransform *Transform
+	Color        *Color    // overrides the color of the prototype when set
+	Material     *Material // overrides the material of the prototype when set
+	Visibility
+
+	// Prototype object and matrix of Transform and its inverse, set by
+	// prepare.
+	proto      Object
+	xform, inv geom.Mat4
+}
+
+// motionBoundsSamples is the number of times at which the bounds of moving
+// instances are sampled.
+const motionBoundsSamples = 16
+
+func init() {
+	RegisterObjectType("Instance", func() Object { return new(Instance) })
+}
+
+func (in *Instance) prepare(protos ObjectMap) error {
+	proto, ok := protos[in.Prototype]
+	if !ok {
+		return fmt.Errorf("invalid instance: unknown prototype %q", in.Prototype)
+	}
+	in.proto = proto
+	in.xform = in.Transform.Matrix()
+	in.inv, _ = in.xform.Inverse()
+	return nil
+}
+
+// matrices returns the matrix of the transform of in at the given time and
+// its inverse.
+func (in *Instance) matrices(time float64) (xform, inv geom.Mat4) {
+	if in.EndTransform == nil {
+		return in.xform, in.inv
+	}
+	xform = lerpTransform(&in.Transform, in.EndTransform, time)
+	inv, _ = xform.Inverse()
+	return xform, inv
+}
+
+// Intersect intersects the prototype with the ray expressed in the frame of
+// the instance.  Parameters along both rays match.
+func (in *Instance) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	_, inv := in.matrices(time)
+	h, ok := in.proto.Intersect(inv.TransformRay(ray), time)
+	if !ok {
+		return Hit{}, false
+	}
+	return Hit{T: h.T, Point: ray.At(h.T), Object: in, Part: &h}, true
+}
+
+// NormalAt transforms the normal of the prototype by the inverse transpose of
+// the instance matrix.
+func (in *Instance) NormalAt(h *Hit, time float64) geom.Vector {
+	n := in.proto.NormalAt(h.Part, time)
+	_, inv := in.matrices(time)
+	invT := inv.Transpose()
+	n = invT.TransformVector(n)
+	return n.UnitVector()
+}
+
+// Bounds of moving instances enclose their bounds at regularly sampled times,
+// padded by how far their corners move between samples to cover the paths in
+// between.
+func (in *Instance) Bounds() geom.AABB {
+	b := in.proto.Bounds()
+	if in.EndTransform == nil || b.Empty() || unbounded(b) {
+		return transformBounds(&in.xform, b)
+	}
+	r := geom.EmptyAABB()
+	var prev geom.AABB
+	pad := 0.0
+	for i := 0; i < motionBoundsSamples; i++ {
+		m, _ := in.matrices(float64(i) / (motionBoundsSamples - 1))
+		tb := transformBounds(&m, b)
+		if i > 0 {
+			for _, d := range []geom.Vector{geom.MakeVector(tb.Min, prev.Min), geom.MakeVector(tb.Max, prev.Max)} {
+				pad = math.Max(pad, d.Module())
+			}
+		}
+		r = r.Union(tb)
+		prev = tb
+	}
+	return geom.AABB{r.Min.Translate(geom.Vector{-pad, -pad, -pad}), r.Max.Translate(geom.Vector{pad, pad, pad})}
+}
+
+func (in *Instance) Inside(p geom.Point, time float64) bool {
+	_, inv := in.matrices(time)
+	return in.proto.Inside(inv.TransformPoint(p), time)
+}
+
+func (in *Instance) ColorAt(h *Hit) Color {
+	if in.Color != nil {
+		return *in.Color
+	}
+	return in.proto.ColorAt(h.Part)
+}
+
+func (in *Instance) MaterialAt(h *Hit) *Material {
+	if in.Material != nil {
+		return in.Material
+	}
+	return in.proto.MaterialAt(h.Part)
+}
+
+func (in *Instance) Clone() Object {
+	c := *in
+	if in.Color != nil {
+		col := *in.Color
+		c.Color = &col
+	}
+	c.Material = in.Material.clone()
+	if in.EndTransform != nil {
+		tr := *in.EndTransform
+		c.EndTransform = &tr
+	}
+	return &c
+}
+
+func (in *Instance) Validate() error {
+	if in.Prototype == "" {
+		return fmt.Errorf("invalid instance: no prototype")
+	}
+	if err := in.Transform.Validate(); err != nil {
+		return fmt.Errorf("invalid instance: %v", err)
+	}
+	if in.EndTransform != nil {
+		if err := in.EndTransform.Validate(); err != nil {
+			return fmt.Errorf("invalid instance: end %v", err)
+		}
+	}
+	if in.Color != nil {
+		if err := in.Color.Validate(); err != nil {
+			return fmt.Errorf("invalid instance: %v", err)
+		}
+	}
+	if in.Material != nil {
+		if err := in.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid instance: %v", err)
+		}
+		if err := in.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid instance: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+// testInstance returns an instance of a unit sphere stretched along the x-axis
+// and moved to (10, 0, 0).
+func testInstance(t *testing.T) *Instance {
+	protos := ObjectMap{
+		"ball": &Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 0}, 1}, Color: Color{1, 0, 0}},
+	}
+	in := &Instance{
+		Prototype: "ball",
+		Transform: Transform{Scale: geom.Vector{2, 1, 1}, Translate: geom.Vector{10, 0, 0}},
+	}
+	if err := in.prepare(protos); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	return in
+}
+
+func TestInstanceIntersect(t *testing.T) {
+	in := testInstance(t)
+	data := []struct {
+		r  geom.Ray
+		ok bool
+		p  geom.Point
+		n  geom.Vector
+	}{
+		{geom.MakeRay(geom.Point{0, 0, 0}, geom.Vector{1, 0, 0}), true, geom.Point{8, 0, 0}, geom.Vector{-1, 0, 0}},
+		{geom.MakeRay(geom.Point{10, 5, 0}, geom.Vector{0, -1, 0}), true, geom.Point{10, 1, 0}, geom.Vector{0, 1, 0}},
+		{geom.MakeRay(geom.Point{0, 2, 0}, geom.Vector{1, 0, 0}), false, geom.Point{}, geom.Vector{}},
+	}
+	for i, d := range data {
+		h, ok := in.Intersect(d.r, 0)
+		if ok != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !geom.PointsEqual(h.Point, d.p, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.p, h.Point)
+		}
+		if n := in.NormalAt(&h, 0); !geom.VectorsEqual(n, d.n, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.n, n)
+		}
+	}
+}
+
+func TestInstanceBoundsAndInside(t *testing.T) {
+	in := testInstance(t)
+	exp := geom.AABB{geom.Point{8, -1, -1}, geom.Point{12, 1, 1}}
+	if act := in.Bounds(); !geom.PointsEqual(act.Min, exp.Min, 1e-9) || !geom.PointsEqual(act.Max, exp.Max, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+	data := []struct {
+		p   geom.Point
+		exp bool
+	}{
+		{geom.Point{11.5, 0, 0}, true},
+		{geom.Point{10, 1.5, 0}, false},
+		{geom.Point{0, 0, 0}, false},
+	}
+	for _, d := range data {
+		if act := in.Inside(d.p, 0); act != d.exp {
+			t.Errorf("%v: exp: %v act: %v", d.p, d.exp, act)
+		}
+	}
+}
+
+func TestMovingInstance(t *testing.T) {
+	in := testInstance(t)
+	in.EndTransform = &Transform{Axis: geom.Vector{0, 0, 1}, Angle: 180, Translate: geom.Vector{-10, 0, 0}}
+	if err := in.Validate(); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	// The ball moves to (-10, 0, 0), turning and losing its stretching.
+	data := []struct {
+		time float64
+		ray  geom.Ray
+		p    geom.Point
+	}{
+		{0, geom.MakeRay(geom.Point{20, 0, 0}, geom.Vector{-1, 0, 0}), geom.Point{12, 0, 0}},
+		{1, geom.MakeRay(geom.Point{-20, 0, 0}, geom.Vector{1, 0, 0}), geom.Point{-11, 0, 0}},
+		// Halfway, the ball is stretched along y.
+		{0.5, geom.MakeRay(geom.Point{-20, 0, 0}, geom.Vector{1, 0, 0}), geom.Point{-1, 0, 0}},
+	}
+	for i, d := range data {
+		h, ok := in.Intersect(d.ray, d.time)
+		if !ok || !geom.PointsEqual(h.Point, d.p, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v %v", i, d.p, h.Point, ok)
+			continue
+		}
+		if n := in.NormalAt(&h, d.time); geom.DotProduct(&n, &d.ray.Dir) >= 0 {
+			t.Errorf("#%d: normal %v facing away", i, n)
+		}
+	}
+	if !in.Inside(geom.Point{-10, 0, 0}, 1) || in.Inside(geom.Point{-10, 0, 0}, 0) {
+		t.Errorf("inside not following the instance")
+	}
+
+	// Bounds enclose the instance all along its path.
+	b := in.Bounds()
+	for i := 0; i <= 100; i++ {
+		m, _ := in.matrices(float64(i) / 100)
+		tb := transformBounds(&m, in.proto.Bounds())
+		if u := b.Union(tb); u != b {
+			t.Errorf("time %v: %v not in %v", float64(i)/100, tb, b)
+		}
+	}
+
+	c := in.Clone().(*Instance)
+	c.EndTransform.Angle = 0
+	if in.EndTransform.Angle != 180 {
+		t.Errorf("end transform shared by clone")
+	}
+}
+
+func TestInstanceRender(t *testing.T) {
+	s := testScene()
+	// Two instances of a small red sphere, the one facing the eye being
+	// enlarged and colored in green.
+	data := `{
+		"Prototypes": {
+			"ball": { "Sphere": { "Center": {"X":0,"Y":0,"Z":0}, "Radius":1 },
+			          "Color": {"R":1, "G":0, "B":0} }
+		},
+		"Objects": [
+			{ "Type": "Instance", "Prototype": "ball",
+			  "Transform": { "Scale": {"X":5,"Y":5,"Z":5}, "Translate": {"X":0,"Y":0,"Z":80} },
+			  "Color": {"R":0, "G":1, "B":0} },
+			{ "Type": "Instance", "Prototype": "ball",
+			  "Transform": { "Translate": {"X":50,"Y":0,"Z":80} } }
+		] }`
+	if err := json.Unmarshal([]byte(data), s); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(10, 10); act.G == 0 || act.R != act.B {
+		t.Fatalf("instance not rendered: %v", act)
+	}
+
+	s.Objects[1].(*Instance).Prototype = "cube"
+	if _, err := s.Render(1); err == nil {
+		t.Fatalf("unknown prototype accepted")
+	}
+}
@@ -0,0 +1,328 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"math/rand"
+)
+
+// A LightType selects the shape of a light source.
+type LightType string
+
+const (
+	PointLight       LightType = "point"       // light radiating from Position
+	DirectionalLight LightType = "directional" // parallel light along Direction
+	AreaLight        LightType = "area"        // rectangle centered on Position
+	SphereLight      LightType = "sphere"      // ball centered on Position
+)
+
+// A Light is a source of light, a point light unless Type says otherwise.
+// Intensity scales its light, zero meaning 1, so that scenes can combine a
+// key light with dimmer fill lights.  When Scene.LightFlux is set, each light
+// emits Intensity times that flux.  NoShadow lights cast no shadows, which
+// spares tracing shadow rays toward them.  Name identifies the light in
+// Material.Lights.
+//
+// Directional lights model distant sources such as the sun: their rays all
+// travel along Direction and shadows are cast by parallel rays.  Their light
+// does not fall off with distance and, when Scene.LightFlux is set, is that
+// of a point light at unit distance.
+//
+// Area lights are rectangles of edges U and V centered on Position casting
+// soft shadows.  They shade surfaces like a point light at their center but
+// the fraction of light reaching surfaces is estimated with Samples shadow
+// rays toward jittered points of the rectangle, zero selecting
+// defaultLightSamples, which produces penumbras.
+//
+// Sphere lights are balls of the given Radius centered on Position, sampled
+// like area lights over the disk they cover as seen from surfaces.  The
+// larger the radius, the softer the shadows.
+//
+// Point lights can shape their light after the photometric distribution of a
+// real fixture measured in the IES file Profile, loaded when rendering
+// starts.  Their light in each direction is scaled by the intensity of the
+// profile there relative to its peak.  The nadir of the fixture points along
+// Direction, zero meaning down (-y), and its horizontal angles 0 and 90
+// degrees lie toward +x and +z when pointing down.
+type Light struct {
+	Name      string
+	Type      LightType
+	Position  geom.Point
+	Direction geom.Vector
+	U, V      geom.Vector
+	Radius    float64
+	Samples   int
+	Intensity float64
+	NoShadow  bool
+	Profile   string
+
+	profile *iesProfile // loaded by prepare
+	frame   geom.ONB    // horizontal angle 0 and 90 directions and nadir of profile
+}
+
+// defaultLightSamples is the number of shadow rays toward area lights when
+// Light.Samples is zero.
+const defaultLightSamples = 16
+
+func (l *Light) Validate() error {
+	switch l.Type {
+	case "", PointLight:
+	case DirectionalLight:
+		if l.Direction.Module() == 0 {
+			return fmt.Errorf("invalid light: null direction")
+		}
+	case AreaLight:
+		if n := geom.CrossProduct(&l.U, &l.V); n.Module() == 0 {
+			return fmt.Errorf("invalid light: degenerate rectangle: %v %v", l.U, l.V)
+		}
+	case SphereLight:
+		if l.Radius <= 0 {
+			return fmt.Errorf("invalid light: non-positive radius: %v", l.Radius)
+		}
+	default:
+		return fmt.Errorf("invalid light type: %q", l.Type)
+	}
+	if l.Intensity < 0 {
+		return fmt.Errorf("invalid light: negative intensity: %v", l.Intensity)
+	}
+	if l.Samples < 0 {
+		return fmt.Errorf("invalid light: negative samples: %v", l.Samples)
+	}
+	if l.Profile != "" && l.Type != "" && l.Type != PointLight {
+		return fmt.Errorf("invalid light: profile on %s light", l.Type)
+	}
+	return nil
+}
+
+// prepare loads the profile of l.
+func (l *Light) prepare() error {
+	if l.Profile == "" || l.profile != nil {
+		return nil
+	}
+	p, err := loadIESProfile(l.Profile)
+	if err != nil {
+		return fmt.Errorf("invalid light: %v", err)
+	}
+	l.profile = p
+	w := l.Direction
+	if w.Module() == 0 {
+		w = geom.Vector{0, -1, 0}
+	}
+	w = w.UnitVector()
+	// Horizontal angle 0 lies toward +x, or +z for fixtures pointing
+	// along x.
+	u := geom.Vector{1, 0, 0}
+	if math.Abs(w.X) > 0.999 {
+		u = geom.Vector{0, 0, 1}
+	}
+	u = u.Sub(w.Scale(geom.DotProduct(&u, &w)))
+	u = u.UnitVector()
+	l.frame = geom.ONB{U: u, V: geom.CrossProduct(&w, &u), W: w}
+	return nil
+}
+
+func (l *Light) samples() int {
+	if l.Samples == 0 {
+		return defaultLightSamples
+	}
+	return l.Samples
+}
+
+// intensity returns the factor scaling the light of l.
+func (l *Light) intensity() float64 {
+	if l.Intensity == 0 {
+		return 1
+	}
+	return l.Intensity
+}
+
+// emission returns the factor scaling the light l casts toward point p.
+func (l *Light) emission(p geom.Point) float64 {
+	k := l.intensity()
+	if l.profile == nil {
+		return k
+	}
+	d := geom.MakeVector(p, l.Position)
+	if d.Module() == 0 {
+		return k
+	}
+	d = d.UnitVector()
+	f := &l.frame
+	theta := math.Acos(math.Max(-1, math.Min(1, geom.DotProduct(&d, &f.W)))) * 180 / math.Pi
+	phi := math.Atan2(geom.DotProduct(&d, &f.V), geom.DotProduct(&d, &f.U)) * 180 / math.Pi
+	if phi < 0 {
+		phi += 360
+	}
+	return k * l.profile.intensity(theta, phi)
+}
+
+// toward returns the unit direction from p to l and the squared distance
+// light travels, 1 for directional lights.
+func (l *Light) toward(p geom.Point) (dir geom.Vector, d2 float64) {
+	if l.Type == DirectionalLight {
+		dir = l.Direction.Neg()
+		return dir.UnitVector(), 1
+	}
+	dir = geom.MakeVector(l.Position, p)
+	d2 = geom.DotProduct(&dir, &dir)
+	return dir.UnitVector(), d2
+}
+
+// shadowRay returns the ray along which objects shadow point p from l.
+func (l *Light) shadowRay(p geom.Point) geom.Ray {
+	if l.Type == DirectionalLight {
+		return geom.Ray{p, l.Direction.Neg(), 0, math.Inf(1)}
+	}
+	// Objects beyond p or the light can not shadow p.
+	return geom.Ray{l.Position, geom.MakeVector(p, l.Position), 0, 1}
+}
+
+// visibility returns the fraction of the light of l of each color channel
+// reaching point p at the given time through the objects of s other than
+// skip.  Area lights are
+// sampled at random points of the cells of a grid covering their rectangle
+// and sphere lights at random points of the disk they cover as seen from p,
+// drawn with rng.
+func (s *Scene) visibility(l *Light, p geom.Point, time float64, skip Object, rng *rand.Rand) Color {
+	if l.NoShadow {
+		return Color{1, 1, 1}
+	}
+	var sample func(i int) geom.Point
+	n := l.samples()
+	switch l.Type {
+	case AreaLight:
+		k := int(math.Ceil(math.Sqrt(float64(n))))
+		sample = func(i int) geom.Point {
+			u := (float64(i%k)+rng.Float64())/float64(k) - 0.5
+			v := (float64(i/k)+rng.Float64())/float64(k) - 0.5
+			q := l.Position.Translate(l.U.Scale(u))
+			return q.Translate(l.V.Scale(v))
+		}
+	case SphereLight:
+		onb := geom.MakeONB(geom.MakeVector(p, l.Position))
+		sample = func(i int) geom.Point {
+			d := geom.ConcentricSampleDisk(rng)
+			q := l.Position.Translate(onb.U.Scale(d.X * l.Radius))
+			return q.Translate(onb.V.Scale(d.Y * l.Radius))
+		}
+	default:
+		return s.transmittance(l.shadowRay(p), time, skip)
+	}
+	var f Color
+	for i := 0; i < n; i++ {
+		q := sample(i)
+		t := s.transmittance(geom.Ray{q, geom.MakeVector(p, q), 0, 1}, time, skip)
+		f = Color{f.R + t.R, f.G + t.G, f.B + t.B}
+	}
+	k := 1 / float64(n)
+	return Color{k * f.R, k * f.G, k * f.B}
+}
+
+// inside returns whether l lies inside object o at the given time.
+// Directional lights lie infinitely far away, outside objects.
+func (l *Light) inside(o Object, time float64) bool {
+	return l.Type != DirectionalLight && o.Inside(l.Position, time)
+}
+
+// shadow returns the fraction by which shadows darken surfaces receiving
+// fraction f(l) of the light of each light l of s, half of it for surfaces
+// in the shadow of all lights.  Lights weigh according to their intensity.
+func (s *Scene) shadow(f func(l *Light) float64) float64 {
+	var sum, w float64
+	for i := range s.Lights {
+		l := &s.Lights[i]
+		k := l.intensity()
+		sum += k * (1 - f(l))
+		w += k
+	}
+	if w == 0 {
+		return 0
+	}
+	return sum / w / 2
+}
+
+// isEmitter returns whether o is a surface sampler with an emissive PBR
+// material.
+func isEmitter(o Object) bool {
+	sm, ok := o.(surfaceSampler)
+	if !ok || sm.surfaceArea() == 0 {
+		return false
+	}
+	m := o.MaterialAt(&Hit{Object: o})
+	return m != nil && m.PBR != nil && m.PBR.Emissive != (Color{})
+}
+
+func (s *Scene) emitterSamples() int {
+	if s.EmitterSamples == 0 {
+		return defaultLightSamples
+	}
+	return s.EmitterSamples
+}
+
+// emitterLight returns the light cast by the emissive objects of s and
+// diffusely reflected at intersection h of ray at the given time.  It is
+// estimated by sampling points of their surface with rng, each casting light
+// like a small patch of the emitted radiance.
+func (s *Scene) emitterLight(h *Hit, ray *geom.Ray, time float64, rng *rand.Rand) Color {
+	normal, _ := facingNormal(h, ray, time)
+	n := s.emitterSamples()
+	var e Color
+	for _, o := range s.emitters {
+		if o == h.Object {
+			continue
+		}
+		sm := o.(surfaceSampler)
+		area := sm.surfaceArea()
+		for i := 0; i < n; i++ {
+			q, nq := sm.sampleSurface(time, rng)
+			dir := geom.MakeVector(q, h.Point)
+			d2 := geom.DotProduct(&dir, &dir)
+			if d2 == 0 {
+				continue
+			}
+			dir = dir.UnitVector()
+			cos, cosl := geom.DotProduct(&dir, &normal), math.Abs(geom.DotProduct(&dir, &nq))
+			if cos <= 0 {
+				continue
+			}
+			// Keep the shadow ray slightly off both surfaces so that
+			// they do not shadow themselves due to rounding errors.
+			p := h.Point.Translate(normal.Scale(s.shadowBias(&h.Point)))
+			sray := geom.Ray{q, geom.MakeVector(p, q), 0, 1}
+			sray.TMin = s.shadowBias(&q) / sray.Dir.Module()
+			v := s.transmittance(sray, time, nil)
+			if v == (Color{}) {
+				continue
+			}
+			le := o.MaterialAt(&Hit{Point: q, Object: o}).PBR.emission()
+			// Irradiance of a lambertian patch of area area/n over pi.
+			k := cos * cosl * area / (math.Pi * d2 * float64(n))
+			e = Color{e.R + k*v.R*le.R, e.G + k*v.G*le.G, e.B + k*v.B*le.B}
+		}
+	}
+	r := s.diffuseReflectance(h)
+	return Color{e.R * r.R, e.G * r.G, e.B * r.B}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"testing"
+)
+
+func TestLightValidate(t *testing.T) {
+	data := []struct {
+		l  Light
+		ok bool
+	}{
+		{Light{}, true},
+		{Light{Type: PointLight, Intensity: 2}, true},
+		{Light{Intensity: -1}, false},
+		{Light{Type: DirectionalLight, Direction: geom.Vector{0, -1, 0}}, true},
+		{Light{Type: DirectionalLight}, false},
+		{Light{Type: AreaLight, U: geom.Vector{1, 0, 0}, V: geom.Vector{0, 1, 0}, Samples: 4}, true},
+		{Light{Type: AreaLight, U: geom.Vector{1, 0, 0}, V: geom.Vector{2, 0, 0}}, false},
+		{Light{Type: AreaLight, U: geom.Vector{1, 0, 0}, V: geom.Vector{0, 1, 0}, Samples: -1}, false},
+		{Light{Type: SphereLight, Radius: 2}, true},
+		{Light{Type: SphereLight}, false},
+		{Light{Type: "spot"}, false},
+	}
+	for i, d := range data {
+		if err := d.l.Validate(); (err == nil) != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, err)
+		}
+	}
+}
+
+func TestDirectionalLight(t *testing.T) {
+	s := testScene()
+	sun := Light{Type: DirectionalLight, Direction: geom.Vector{1, -1, 0}}
+	s.Lights = []Light{sun}
+	ray := geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1})
+	h, _ := s.Objects[0].Intersect(ray, 0)
+
+	// Same as a point light far away in the opposite direction.
+	far := Light{Position: h.Point.Translate(geom.Vector{-1e9, 1e9, 0})}
+	exp := s.computeObjectColorAt(&h, &ray, 0, &far)
+	if act := s.computeObjectColorAt(&h, &ray, 0, &sun); !colorsEqual(act, exp) {
+		t.Errorf("shading: exp: %v act: %v", exp, act)
+	}
+
+	if f := s.lightFraction(&h, ray.Origin, 0, &sun, nil); f != 1 {
+		t.Errorf("unshadowed: exp: 1 act: %v", f)
+	}
+	// Objects however far toward the sun shadow the sphere.
+	s.Objects = append(s.Objects, &Sphere{Sphere: geom.Sphere{h.Point.Translate(geom.Vector{-1e4, 1e4, 0}), 10}})
+	if f := s.lightFraction(&h, ray.Origin, 0, &sun, nil); f != 0 {
+		t.Errorf("shadowed: exp: 0 act: %v", f)
+	}
+}
+
+func TestAreaLight(t *testing.T) {
+	w := &wall{Z: 100}
+	s := &Scene{Objects: ObjectList{w}}
+	l := &Light{Type: AreaLight, U: geom.Vector{20, 0, 0}, V: geom.Vector{0, 20, 0}}
+	h := &Hit{T: 100, Point: geom.Point{0, 0, 100}, Object: w}
+	rng := pixelRand(0, 0)
+	if f := s.lightFraction(h, geom.Origin, 0, l, rng); f != 1 {
+		t.Errorf("lit: exp: 1 act: %v", f)
+	}
+
+	// Shadow rays cross the z=50 plane within [-5..5] along x and y.
+	s.Objects = append(s.Objects, &Sphere{Sphere: geom.Sphere{geom.Point{5, 0, 50}, 3}})
+	if f := s.lightFraction(h, geom.Origin, 0, l, rng); f <= 0 || f >= 1 {
+		t.Errorf("penumbra: exp: in ]0..1[ act: %v", f)
+	}
+	s.Objects[1] = &Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 50}, 10}}
+	if f := s.lightFraction(h, geom.Origin, 0, l, rng); f != 0 {
+		t.Errorf("umbra: exp: 0 act: %v", f)
+	}
+}
+
+func TestSphereLight(t *testing.T) {
+	w := &wall{Z: 100}
+	s := &Scene{Objects: ObjectList{w}}
+	l := &Light{Type: SphereLight, Radius: 10, Samples: 64}
+	h := &Hit{T: 100, Point: geom.Point{0, 0, 100}, Object: w}
+	rng := pixelRand(0, 0)
+	if f := s.lightFraction(h, geom.Origin, 0, l, rng); f != 1 {
+		t.Errorf("lit: exp: 1 act: %v", f)
+	}
+
+	// Shadow rays cross the z=50 plane within 5 units of the z-axis.
+	s.Objects = append(s.Objects, &Sphere{Sphere: geom.Sphere{geom.Point{5, 0, 50}, 3}})
+	if f := s.lightFraction(h, geom.Origin, 0, l, rng); f <= 0 || f >= 1 {
+		t.Errorf("penumbra: exp: in ]0..1[ act: %v", f)
+	}
+	s.Objects[1] = &Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 50}, 10}}
+	if f := s.lightFraction(h, geom.Origin, 0, l, rng); f != 0 {
+		t.Errorf("umbra: exp: 0 act: %v", f)
+	}
+
+	// The larger the light, the softer the shadows.
+	s.Objects[1] = &Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 50}, 2}}
+	l.Radius = 2
+	small := s.lightFraction(h, geom.Origin, 0, l, rng)
+	l.Radius = 20
+	if large := s.lightFraction(h, geom.Origin, 0, l, rng); large <= small {
+		t.Errorf("exp: larger light less shadowed act: %v <= %v", large, small)
+	}
+}
+
+func TestEmitterLight(t *testing.T) {
+	w := &wall{Z: 100, Color: Color{1, 1, 1}}
+	lamp := &Quad{
+		Quad:     geom.Quad{Corner: geom.Point{-0.5, -0.5, 90}, U: geom.Vector{1, 0, 0}, V: geom.Vector{0, 1, 0}},
+		Material: &Material{PBR: &PBR{Emissive: Color{1, 1, 0}, EmissiveIntensity: 2}},
+	}
+	s := &Scene{Kd: 0.9, Objects: ObjectList{w, lamp}}
+	if err := s.prepare(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	ray := geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1})
+	h, _ := w.Intersect(ray, 0)
+	// The lamp is small enough to light the wall like a point 10 units away.
+	k := 0.9 * 2 / (math.Pi * 100)
+	exp := Color{0.1 + k, 0.1 + k, 0.1}
+	if act := s.shadeHit(&h, ray, 0, 0, pixelRand(0, 0), rayPath{}); !geom.FloatsEqual(act.R, exp.R, 1e-4) ||
+		!geom.FloatsEqual(act.G, exp.G, 1e-4) || !geom.FloatsEqual(act.B, exp.B, 1e-9) {
+		t.Errorf("lit: exp: %v act: %v", exp, act)
+	}
+
+	// Emitters cast shadows on what they light.
+	s.Objects = append(s.Objects, &Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 95}, 1}})
+	if err := s.prepare(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	exp = Color{0.1, 0.1, 0.1}
+	if act := s.shadeHit(&h, ray, 0, 0, pixelRand(0, 0), rayPath{}); !colorsEqual(act, exp) {
+		t.Errorf("shadowed: exp: %v act: %v", exp, act)
+	}
+}
+
+func TestLightNoShadow(t *testing.T) {
+	w := &wall{Z: 100}
+	s := &Scene{Objects: ObjectList{w, &Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 50}, 10}}}}
+	h := &Hit{T: 100, Point: geom.Point{0, 0, 100}, Object: w}
+	for _, l := range []*Light{
+		{Position: geom.Origin},
+		{Type: AreaLight, U: geom.Vector{1, 0, 0}, V: geom.Vector{0, 1, 0}},
+	} {
+		if f := s.lightFraction(h, geom.Origin, 0, l, pixelRand(0, 0)); f != 0 {
+			t.Errorf("%v: shadow: exp: 0 act: %v", l.Type, f)
+		}
+		l.NoShadow = true
+		if f := s.lightFraction(h, geom.Origin, 0, l, nil); f != 1 {
+			t.Errorf("%v: no shadow: exp: 1 act: %v", l.Type, f)
+		}
+	}
+}
+
+func TestLegacyLightJSON(t *testing.T) {
+	var s Scene
+	if err := json.Unmarshal([]byte(`{"Light": {"X":-100, "Y":30, "Z":0}}`), &s); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	exp := []Light{{Position: geom.Point{-100, 30, 0}}}
+	if len(s.Lights) != 1 || s.Lights[0].Position != exp[0].Position {
+		t.Fatalf("exp: %v act: %v", exp, s.Lights)
+	}
+	data := `{"Light": {"X":0, "Y":0, "Z":0}, "Lights": [{"Position": {"X":1, "Y":0, "Z":0}}]}`
+	if err := json.Unmarshal([]byte(data), &s); err == nil {
+		t.Fatalf("both Light and Lights accepted")
+	}
+}
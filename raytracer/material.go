@@ -0,0 +1,450 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// A Material describes how the surface of an object responds to light.  The
+// ambient and diffuse coefficients scale the color of the object while
+// specular highlights have their own color.  Alternatively, a PBR model
+// describes the surface in physical terms.
+//
+// Surfaces both reflective and transparent also reflect part of the light
+// they would transmit, more so at grazing angles, following Fresnel
+// equations.
+//
+// Objects without material are shaded with the scene diffuse coefficient
+// Scene.Kd and no highlight.
+type Material struct {
+	Ambient   float64 // fraction of the object color seen under ambient light
+	Diffuse   float64 // fraction of the object color diffusely reflecting light
+	Specular  Color   // color of specular highlights, black for none
+	Shininess float64 // Blinn-Phong exponent, the higher the sharper highlights
+
+	// Subsurface approximates light scattering beneath the surface of
+	// translucent materials such as skin, wax or marble by wrapping diffuse
+	// lighting past the terminator, from 0 for none to 1 for light reaching
+	// the whole surface.  ScatterColor tints the wrapped light, nil for the
+	// object color.
+	Subsurface   float64
+	ScatterColor *Color
+
+	Reflectivity float64 // fraction of light mirrored by the surface
+
+	// Roughness blurs reflections by scattering reflected rays in a cone
+	// around the mirror direction.  Its half-angle ranges from 0 for
+	// perfect mirrors to 90 degrees for 1.  GlossySamples is the number
+	// of rays averaged, zero selecting defaultGlossySamples.
+	Roughness     float64
+	GlossySamples int
+
+	Transparency float64 // fraction of light transmitted through the surface
+	IOR          float64 // index of refraction of the object, 0 meaning 1
+	Dispersion   float64 // Cauchy B coefficient in square micrometers, see iorAt
+	Transmission *Color  // filters light transmitted through the surface, nil for none
+
+	// Opacity blends the color of the surface with what lies behind it
+	// along the same ray, without bending the ray like Transparency does,
+	// and lets the remaining fraction of light through to shadows.  It
+	// ranges from 0 exclusive to 1 for opaque surfaces, 0 meaning 1.
+	Opacity float64
+
+	PBR *PBR // physically-based shading model, nil for the terms above
+
+	// Texture varies the color of the surface, nil for none.  NormalMap
+	// bends its normals to simulate surface details.  Both apply to
+	// spheres, planes, quads and meshes only, and other objects reject
+	// them.  Colors of normal maps encode normals in the tangent space of
+	// the surface: red, green and blue in [0..1] map to [-1..1] along the
+	// directions in which u grows, v decreases, and the normal.
+	Texture   *Texture
+	NormalMap *Texture
+
+	// By default, the back side of a surface, such as the inner side of an
+	// object seen from inside, is only lit by a light on the same side.
+	// TwoSided lights the side seen from whichever side the light is, as
+	// for thin sheets like paper or leaves.
+	TwoSided bool
+
+	// ShadowCatcher makes the surface invisible except for the shadows it
+	// receives, which darken what lies behind it.  Rendered with
+	// Options.Alpha, such surfaces let compositors lay the shadows of
+	// rendered objects onto photographs.  Shadow catchers cast no shadows.
+	ShadowCatcher bool
+
+	// Lights, when set, restricts the lights lighting the surface to those
+	// named, e.g. to add a rim light to a single object.  Ambient and
+	// environment light are not affected.
+	Lights []string
+}
+
+func (m *Material) Validate() error {
+	for _, k := range []struct {
+		name string
+		v    float64
+	}{
+		{"ambient", m.Ambient},
+		{"diffuse", m.Diffuse},
+		{"subsurface", m.Subsurface},
+		{"reflectivity", m.Reflectivity},
+		{"roughness", m.Roughness},
+		{"transparency", m.Transparency},
+		{"opacity", m.Opacity},
+	} {
+		if k.v < 0 || k.v > 1 {
+			return fmt.Errorf("invalid material: %s coefficient out-of-range: %v", k.name, k.v)
+		}
+	}
+	if m.Reflectivity+m.Transparency > 1 {
+		return fmt.Errorf("invalid material: reflectivity and transparency exceed 1")
+	}
+	if err := m.Specular.Validate(); err != nil {
+		return fmt.Errorf("invalid material: specular %v", err)
+	}
+	if m.Transmission != nil {
+		if err := m.Transmission.Validate(); err != nil {
+			return fmt.Errorf("invalid material: transmission %v", err)
+		}
+	}
+	if m.ScatterColor != nil {
+		if err := m.ScatterColor.Validate(); err != nil {
+			return fmt.Errorf("invalid material: scatter %v", err)
+		}
+	}
+	if m.PBR != nil {
+		if err := m.PBR.Validate(); err != nil {
+			return err
+		}
+	}
+	if m.Texture != nil {
+		if err := m.Texture.Validate(); err != nil {
+			return fmt.Errorf("invalid material: %v", err)
+		}
+	}
+	if m.NormalMap != nil {
+		if err := m.NormalMap.Validate(); err != nil {
+			return fmt.Errorf("invalid material: normal map: %v", err)
+		}
+	}
+	if m.GlossySamples < 0 {
+		return fmt.Errorf("invalid material: negative glossy samples: %v", m.GlossySamples)
+	}
+	if m.Shininess < 0 {
+		return fmt.Errorf("invalid material: negative shininess: %v", m.Shininess)
+	}
+	if m.IOR < 0 {
+		return fmt.Errorf("invalid material: negative index of refraction: %v", m.IOR)
+	}
+	if m.Dispersion < 0 {
+		return fmt.Errorf("invalid material: negative dispersion: %v", m.Dispersion)
+	}
+	return nil
+}
+
+// clone returns a copy of m, nil if m is nil.
+func (m *Material) clone() *Material {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	if m.Transmission != nil {
+		f := *m.Transmission
+		c.Transmission = &f
+	}
+	if m.ScatterColor != nil {
+		sc := *m.ScatterColor
+		c.ScatterColor = &sc
+	}
+	c.Lights = append([]string(nil), m.Lights...)
+	c.PBR = m.PBR.clone()
+	c.Texture = m.Texture.clone()
+	c.NormalMap = m.NormalMap.clone()
+	return &c
+}
+
+// textured returns whether m, possibly nil, has a texture or normal map and
+// hits on it need texture coordinates.
+func (m *Material) textured() bool {
+	return m != nil && (m.Texture != nil || m.NormalMap != nil)
+}
+
+// needsUV returns whether m, possibly nil, has a texture or normal map looked
+// up with texture coordinates rather than surface points.
+func (m *Material) needsUV() bool {
+	return m != nil && (m.NormalMap != nil || m.Texture != nil && !m.Texture.solid())
+}
+
+// checkUntextured returns an error if m, possibly nil, has a texture or
+// normal map, which objects without texture coordinates would ignore.
+func (m *Material) checkUntextured() error {
+	switch {
+	case m == nil:
+	case m.Texture != nil:
+		return fmt.Errorf("texture not supported")
+	case m.NormalMap != nil:
+		return fmt.Errorf("normal map not supported")
+	}
+	return nil
+}
+
+// opacity returns the opacity of m, possibly nil.
+func (m *Material) opacity() float64 {
+	if m == nil || m.Opacity == 0 {
+		return 1
+	}
+	return m.Opacity
+}
+
+// shadowCatcher returns whether m, possibly nil, is a shadow catcher.
+func (m *Material) shadowCatcher() bool {
+	return m != nil && m.ShadowCatcher
+}
+
+// litBy returns whether light l lights surfaces of material m, possibly nil.
+func (m *Material) litBy(l *Light) bool {
+	if m == nil || len(m.Lights) == 0 {
+		return true
+	}
+	for _, name := range m.Lights {
+		if name == l.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// twoSided returns whether m, possibly nil, lights both sides of surfaces.
+func (m *Material) twoSided() bool {
+	return m != nil && m.TwoSided
+}
+
+// prepare loads the resources m needs for rendering.
+func (m *Material) prepare() error {
+	for _, t := range []*Texture{m.Texture, m.NormalMap} {
+		if t != nil {
+			if err := t.prepare(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// colorAt returns the color at h of a surface of color col and material m,
+// possibly nil.  size is the length spanned on the surface by a unit of
+// texture coordinates.
+func (m *Material) colorAt(h *Hit, col Color, size float64) Color {
+	if m != nil && m.Texture != nil {
+		return m.Texture.colorAt(h, size)
+	}
+	return col
+}
+
+// normalAt returns normal n at h bent by the normal map of material m,
+// possibly nil.  tangent is the direction in which the u texture coordinate
+// grows and size is as described in colorAt.
+func (m *Material) normalAt(h *Hit, n, tangent geom.Vector, size float64) geom.Vector {
+	if m == nil || m.NormalMap == nil {
+		return n
+	}
+	// Make the tangent space orthonormal.
+	t := tangent.Sub(n.Scale(geom.DotProduct(&tangent, &n)))
+	if t.Module() == 0 {
+		return n
+	}
+	t = t.UnitVector()
+	b := geom.CrossProduct(&t, &n)
+	c := m.NormalMap.colorAt(h, size)
+	bent := t.Scale(2*c.R - 1)
+	bent = bent.Add(b.Scale(2*c.G - 1))
+	bent = bent.Add(n.Scale(2*c.B - 1))
+	if bent.Module() == 0 {
+		return n
+	}
+	return bent.UnitVector()
+}
+
+// defaultGlossySamples is the number of rays averaged on rough reflective
+// surfaces when Material.GlossySamples is zero.
+const defaultGlossySamples = 16
+
+func (m *Material) glossySamples() int {
+	if m.GlossySamples == 0 {
+		return defaultGlossySamples
+	}
+	return m.GlossySamples
+}
+
+// A channel selects the color channels of the light carried by rays.
+type channel int
+
+const (
+	allChannels channel = iota
+	redChannel
+	greenChannel
+	blueChannel
+)
+
+// channelWavelengths are the wavelengths in micrometers standing for color
+// channels, that of the sodium D line for all of them, at which indices of
+// refraction of glasses are usually given.
+var channelWavelengths = [...]float64{
+	allChannels:  0.5893,
+	redChannel:   0.61,
+	greenChannel: 0.55,
+	blueChannel:  0.465,
+}
+
+// iorAt returns the index of refraction of the inside of objects of material
+// m for light of color channel ch.  Dispersive materials follow Cauchy's
+// equation n = A + B / wavelength^2, B being m.Dispersion, e.g. 0.0042 for
+// borosilicate crown glass, and A such that m.IOR is the index at the
+// wavelength of allChannels.
+func (m *Material) iorAt(ch channel) float64 {
+	n := m.IOR
+	if n == 0 {
+		n = 1
+	}
+	if m.Dispersion == 0 || ch == allChannels {
+		return n
+	}
+	l, ld := channelWavelengths[ch], channelWavelengths[allChannels]
+	return n + m.Dispersion*(1/(l*l)-1/(ld*ld))
+}
+
+// A rayPath is what secondary rays inherit from the rays they are spawned
+// by: the media they travel inside and the color channels of their light.
+type rayPath struct {
+	media   *medium
+	channel channel
+}
+
+// A medium is an entry of the stack of the transparent objects a refracted ray
+// travels inside, innermost first, so that indices of refraction are relative
+// to the medium actually surrounding surfaces, as for glass in water.  The
+// nil stack is vacuum.  Composite objects count as a single volume.
+type medium struct {
+	obj  Object
+	mat  *Material
+	next *medium
+}
+
+// indexOfRefraction returns the index of refraction of the innermost medium
+// of md for light of color channel ch.
+func (md *medium) indexOfRefraction(ch channel) float64 {
+	if md == nil {
+		return 1
+	}
+	return md.mat.iorAt(ch)
+}
+
+// without returns md without the entry of obj, which rays leave.  Entries are
+// shared between stacks and never modified.
+func (md *medium) without(obj Object) *medium {
+	if md == nil {
+		return nil
+	}
+	if md.obj == obj {
+		return md.next
+	}
+	next := md.next.without(obj)
+	if next == md.next {
+		return md
+	}
+	return &medium{md.obj, md.mat, next}
+}
+
+// cross returns the ratio of the indices of refraction for light of color
+// channel ch of the media a ray traveling inside md leaves and enters when
+// crossing the surface of obj, of material m, from inside obj or not, and the
+// media the ray then travels inside.
+func (md *medium) cross(obj Object, m *Material, inside bool, ch channel) (eta float64, next *medium) {
+	if inside {
+		next = md.without(obj)
+		return m.iorAt(ch) / next.indexOfRefraction(ch), next
+	}
+	return md.indexOfRefraction(ch) / m.iorAt(ch), &medium{obj, m, md}
+}
+
+// shade computes the color of a surface of material m and color col under
+// ambient light amb.  dot is the cosine of the angle of incidence of light and
+// spec the one between the normal and the half-way vector between the light
+// and eye directions.  Direct light is scaled by k.
+func (m *Material) shade(col, amb Color, dot, spec, k float64) Color {
+	d := k * m.Diffuse * dot
+	h := 0.0
+	if dot > 0 && spec > 0 {
+		h = k * math.Pow(spec, m.Shininess)
+	}
+	return Color{
+		(m.Ambient*amb.R+d)*col.R + h*m.Specular.R,
+		(m.Ambient*amb.G+d)*col.G + h*m.Specular.G,
+		(m.Ambient*amb.B+d)*col.B + h*m.Specular.B,
+	}
+}
+
+// scatter returns the light wrapped past the terminator of a surface of
+// material m and color col by subsurface scattering, on top of the diffuse
+// light computed by shade.  cos is the cosine of the angle of incidence of
+// light, negative on the dark side, and direct light is scaled by k.
+func (m *Material) scatter(col Color, cos, k float64) Color {
+	w := m.Subsurface
+	d := k * m.Diffuse * (math.Max(0, (cos+w)/(1+w)) - math.Max(0, cos))
+	if m.ScatterColor != nil {
+		col = *m.ScatterColor
+	}
+	return Color{d * col.R, d * col.G, d * col.B}
+}
+
+// resolveMaterials replaces material names in decoded JSON value v with their
+// definition in lib.  Material names are strings standing for an object
+// "Material" field.
+func resolveMaterials(v interface{}, lib map[string]interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if name, ok := e.(string); ok && k == "Material" {
+				m, ok := lib[name]
+				if !ok {
+					return fmt.Errorf("unknown material %q", name)
+				}
+				v[k] = m
+				continue
+			}
+			if err := resolveMaterials(e, lib); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := resolveMaterials(e, lib); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,603 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMaterialValidate(t *testing.T) {
+	data := []struct {
+		m  Material
+		ok bool
+	}{
+		{Material{Ambient: 0.1, Diffuse: 0.9, Specular: Color{1, 1, 1}, Shininess: 20}, true},
+		{Material{Reflectivity: 0.5, Transparency: 0.5, IOR: 1.5}, true},
+		{Material{Diffuse: 1.5}, false},
+		{Material{Ambient: -0.1}, false},
+		{Material{Reflectivity: 0.6, Transparency: 0.6}, false},
+		{Material{Reflectivity: 1, Roughness: 0.2, GlossySamples: 4}, true},
+		{Material{Roughness: 1.5}, false},
+		{Material{GlossySamples: -1}, false},
+		{Material{Specular: Color{2, 0, 0}}, false},
+		{Material{Shininess: -1}, false},
+		{Material{IOR: -1}, false},
+		{Material{Transparency: 1, IOR: 1.5, Dispersion: 0.0042}, true},
+		{Material{Dispersion: -0.01}, false},
+		{Material{Diffuse: 0.8, Subsurface: 0.5, ScatterColor: &Color{1, 0.2, 0.1}}, true},
+		{Material{Subsurface: 1.5}, false},
+		{Material{ScatterColor: &Color{-1, 0, 0}}, false},
+	}
+	for i, d := range data {
+		if err := d.m.Validate(); (err == nil) != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, err)
+		}
+	}
+}
+
+func TestMaterialShade(t *testing.T) {
+	m := Material{Ambient: 0.2, Diffuse: 0.6, Specular: Color{1, 1, 1}, Shininess: 2}
+	col := Color{1, 0, 0}
+	data := []struct {
+		dot, spec, k float64
+		exp          Color
+	}{
+		{0, 0, 1, Color{0.2, 0, 0}},
+		{1, 0, 1, Color{0.8, 0, 0}},
+		{1, 0.5, 1, Color{1.05, 0.25, 0.25}},
+		{0.5, 1, 2, Color{0.8 + 2, 2, 2}},
+		// No highlight on unlit sides.
+		{0, 1, 1, Color{0.2, 0, 0}},
+	}
+	for i, d := range data {
+		if act := m.shade(col, Color{1, 1, 1}, d.dot, d.spec, d.k); act != d.exp {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+}
+
+func TestMaterialScatter(t *testing.T) {
+	m := Material{Diffuse: 0.8, Subsurface: 1}
+	col := Color{1, 0.5, 0}
+	data := []struct {
+		cos, k float64
+		exp    Color
+	}{
+		{1, 1, Color{0, 0, 0}},
+		{0, 1, Color{0.4, 0.2, 0}},
+		{-0.5, 2, Color{0.4, 0.2, 0}},
+		{-1, 1, Color{0, 0, 0}},
+	}
+	for i, d := range data {
+		if act := m.scatter(col, d.cos, d.k); !colorsEqual(act, d.exp) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+	m.ScatterColor = &Color{1, 0, 0}
+	if exp, act := (Color{0.4, 0, 0}), m.scatter(col, 0, 1); !colorsEqual(act, exp) {
+		t.Errorf("tinted: exp: %v act: %v", exp, act)
+	}
+}
+
+func TestMaterialRender(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	// Red sphere with white highlights in front of the default one.
+	data := `[{ "Sphere": { "Center": {"X":0,"Y":0,"Z":60}, "Radius":5 },
+		"Color": {"R":1, "G":0, "B":0},
+		"Material": { "Ambient": 0.1, "Diffuse": 0.7,
+			"Specular": {"R":1, "G":1, "B":1}, "Shininess": 10 } }]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l...)
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	highlight := false
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			if c := img.RGBAAt(x, y); c.R > 0 && c.G > 0 && c.G == c.B && c.G < c.R {
+				highlight = true
+			}
+		}
+	}
+	if !highlight {
+		t.Fatalf("no highlight rendered")
+	}
+
+	s.Objects[1].(*Sphere).Material.Diffuse = 2
+	if _, err := s.Render(1); err == nil {
+		t.Fatalf("invalid material accepted")
+	}
+}
+
+func TestReflection(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	// Mirror behind the red sphere reflecting a green sphere behind the eye.
+	data := `[
+		{ "Type": "Plane",
+		  "Plane": { "Point": {"X":0,"Y":0,"Z":95}, "Normal": {"X":0,"Y":0,"Z":-1} },
+		  "Color": {"R":1, "G":1, "B":1},
+		  "Material": { "Reflectivity": 1 } },
+		{ "Sphere": { "Center": {"X":0,"Y":0,"Z":-50}, "Radius":30 },
+		  "Color": {"R":0, "G":1, "B":0} }
+	]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l...)
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// Pixel (0, 0) sees the reflection of the green sphere and (10, 10) the
+	// red sphere.
+	if act := img.RGBAAt(0, 0); act.G == 0 || act.R != act.B {
+		t.Fatalf("reflection not rendered: %v", act)
+	}
+	if act := img.RGBAAt(10, 10); act.R == 0 || act.G != act.B {
+		t.Fatalf("red sphere not rendered: %v", act)
+	}
+
+	s.Objects[1].(*Plane).Material.Reflectivity = 0
+	img, err = s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(0, 0); act.G != 0 {
+		t.Fatalf("reflection without reflectivity: %v", act)
+	}
+
+	s.MaxDepth = -1
+	if _, err := s.Render(1); err == nil {
+		t.Fatalf("negative maximum depth accepted")
+	}
+}
+
+func TestGlossyReflection(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	// Rough mirror behind the red sphere.
+	data := `[
+		{ "Type": "Plane",
+		  "Plane": { "Point": {"X":0,"Y":0,"Z":95}, "Normal": {"X":0,"Y":0,"Z":-1} },
+		  "Color": {"R":1, "G":1, "B":1},
+		  "Material": { "Reflectivity": 1, "Roughness": 0.3 } }
+	]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l...)
+	glossy, err := s.Render(4)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	again, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !bytes.Equal(glossy.Pix, again.Pix) {
+		t.Fatalf("glossy reflections not reproducible")
+	}
+
+	s.Objects[1].(*Plane).Material.Roughness = 0
+	mirror, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if bytes.Equal(glossy.Pix, mirror.Pix) {
+		t.Fatalf("rough and perfect mirrors rendered the same")
+	}
+}
+
+func TestRefraction(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	// Glass sphere in front of the red one.
+	data := `[{ "Sphere": { "Center": {"X":0,"Y":0,"Z":50}, "Radius":5 },
+		"Color": {"R":1, "G":1, "B":1},
+		"Material": { "Transparency": 1, "IOR": 1.5 } }]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l...)
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(10, 10); act.R == 0 || act.G != act.B {
+		t.Fatalf("red sphere not seen through glass: %v", act)
+	}
+
+	s.Objects[1].(*Sphere).Material.Transmission = &Color{0, 1, 0}
+	img, err = s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(10, 10); act.R != 0 {
+		t.Fatalf("transmitted light not filtered: %v", act)
+	}
+}
+
+func TestNestedMedia(t *testing.T) {
+	water := &Sphere{Material: &Material{Transparency: 1, IOR: 1.33}}
+	glass := &Sphere{Material: &Material{Transparency: 1, IOR: 1.5}}
+	// Into water, into glass, out of glass, out of water.
+	var md *medium
+	data := []struct {
+		obj    *Sphere
+		inside bool
+		eta    float64
+		ior    float64 // of the medium entered
+	}{
+		{water, false, 1 / 1.33, 1.33},
+		{glass, false, 1.33 / 1.5, 1.5},
+		{glass, true, 1.5 / 1.33, 1.33},
+		{water, true, 1.33, 1},
+	}
+	for i, d := range data {
+		var eta float64
+		eta, md = md.cross(d.obj, d.obj.Material, d.inside, allChannels)
+		if !geom.FloatsEqual(eta, d.eta, 1e-9) || md.indexOfRefraction(allChannels) != d.ior {
+			t.Errorf("#%d: exp: %v %v act: %v %v", i, d.eta, d.ior, eta, md.indexOfRefraction(allChannels))
+		}
+	}
+
+	// Leaving overlapping objects in the order they were entered.
+	md = nil
+	_, md = md.cross(water, water.Material, false, allChannels)
+	_, md = md.cross(glass, glass.Material, false, allChannels)
+	if eta, md := md.cross(water, water.Material, true, allChannels); eta != 1.33/1.5 || md.obj != glass || md.next != nil {
+		t.Errorf("leaving outer medium: %v %v", eta, md)
+	}
+
+	// A sphere of water enclosing a sphere of glass with the same index of
+	// refraction looks like the water sphere alone: rays crossing the
+	// glass surface are not bent.
+	s := testScene()
+	var l ObjectList
+	spheres := `[{ "Sphere": { "Center": {"X":0,"Y":0,"Z":40}, "Radius":15 },
+		"Color": {"R":1, "G":1, "B":1},
+		"Material": { "Transparency": 1, "IOR": 1.33 } },
+		{ "Sphere": { "Center": {"X":3,"Y":2,"Z":40}, "Radius":8 },
+		"Color": {"R":1, "G":1, "B":1},
+		"Material": { "Transparency": 1, "IOR": 1.33 } }]`
+	if err := json.Unmarshal([]byte(spheres), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l[0])
+	alone, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l[1])
+	nested, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	for i := 0; i < len(alone.Pix); i++ {
+		if d := int(alone.Pix[i]) - int(nested.Pix[i]); d < -1 || d > 1 {
+			t.Fatalf("pixel (%d, %d): exp: %v act: %v", i/4%20, i/80,
+				alone.Pix[i], nested.Pix[i])
+		}
+	}
+}
+
+func TestDispersion(t *testing.T) {
+	m := Material{IOR: 1.5, Dispersion: 0.0042}
+	r, g, b := m.iorAt(redChannel), m.iorAt(greenChannel), m.iorAt(blueChannel)
+	if m.iorAt(allChannels) != 1.5 || !(r < g && g < b) {
+		t.Errorf("indices not increasing toward blue: %v %v %v", r, g, b)
+	}
+	m.Dispersion = 0
+	if act := m.iorAt(blueChannel); act != 1.5 {
+		t.Errorf("exp: 1.5 act: %v", act)
+	}
+
+	// A glass sphere in front of a white one splits its edges into color
+	// fringes when dispersive.
+	s := testScene()
+	s.Objects[0].(*Sphere).Color = Color{1, 1, 1}
+	glass := &Sphere{
+		Sphere:   geom.Sphere{Center: geom.Point{0, 0, 50}, Radius: 12},
+		Color:    Color{1, 1, 1},
+		Material: &Material{Transparency: 1, IOR: 1.5},
+	}
+	s.Objects = append(s.Objects, glass)
+	fringes := func() bool {
+		img, err := s.Render(1)
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		for i := 0; i < len(img.Pix); i += 4 {
+			if img.Pix[i] != img.Pix[i+2] {
+				return true
+			}
+		}
+		return false
+	}
+	if fringes() {
+		t.Errorf("color fringes without dispersion")
+	}
+	glass.Material.Dispersion = 0.05
+	if !fringes() {
+		t.Errorf("no color fringes with dispersion")
+	}
+}
+
+func TestOpacity(t *testing.T) {
+	s := testScene()
+	// Blue pane in front of the red sphere, shadowing part of it.
+	pane := &Plane{
+		Plane:    geom.Plane{Point: geom.Point{0, 0, 50}, Normal: geom.Vector{0, 0, -1}},
+		Color:    Color{0, 0, 1},
+		Material: &Material{Ambient: 1, Opacity: 0.25},
+	}
+	s.Objects = append(s.Objects, pane)
+	ray := geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1})
+
+	sh, _ := s.Objects[0].Intersect(ray, 0)
+	l, a := s.computeObjectColorAt(&sh, &ray, 0, &s.Lights[0]), s.ambientColor(&sh)
+	behind := s.shadeHit(&sh, ray, 0, 1, nil, rayPath{})
+	exp := Color{0.75*l.R + 0.25*a.R, 0.75*l.G + 0.25*a.G, 0.75*l.B + 0.25*a.B}
+	if !colorsEqual(behind, exp) {
+		t.Errorf("partial shadow: exp: %v act: %v", exp, behind)
+	}
+
+	ph, _ := pane.Intersect(ray, 0)
+	exp = Color{0.75 * behind.R, 0.75 * behind.G, 0.75*behind.B + 0.25}
+	if act := s.shadeHit(&ph, ray, 0, 1, nil, rayPath{}); !colorsEqual(act, exp) {
+		t.Errorf("blending: exp: %v act: %v", exp, act)
+	}
+
+	pane.Material.Opacity = 1.5
+	if err := s.Validate(); err == nil {
+		t.Errorf("invalid opacity accepted")
+	}
+}
+
+func TestShadowCatcher(t *testing.T) {
+	s := testScene()
+	// Catcher behind the sphere receiving its shadow.
+	s.Objects = append(s.Objects, &Plane{
+		Plane:    geom.Plane{Point: geom.Point{0, 0, 95}, Normal: geom.Vector{0, 0, -1}},
+		Color:    Color{0, 0, 1},
+		Material: &Material{ShadowCatcher: true},
+	})
+
+	img, err := s.RenderWithOptions(&Options{Alpha: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clear, shadow int
+	b := img.Bounds()
+	for x := b.Min.X; x < b.Max.X; x++ {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			c := img.RGBAAt(x, y)
+			switch {
+			case c.A == 0:
+				clear++
+			case c.A < 255 && c.R == 0 && c.G == 0 && c.B == 0:
+				shadow++
+			}
+		}
+	}
+	if clear == 0 || shadow == 0 {
+		t.Errorf("exp: clear and shadow pixels act: %d clear %d shadow", clear, shadow)
+	}
+	if c := img.RGBAAt(10, 10); c.A != 255 {
+		t.Errorf("sphere: exp: opaque act: %v", c)
+	}
+
+	// Without alpha, unshadowed parts of the catcher show the background.
+	img, err = s.Render(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bg := s.Bg.toRGBA()
+	if c := img.RGBAAt(0, 0); c != bg {
+		t.Errorf("background: exp: %v act: %v", bg, c)
+	}
+}
+
+func TestLightLinking(t *testing.T) {
+	s := testScene()
+	key, rim := Light{Name: "key", Position: geom.Point{-100, 30, 0}}, Light{Name: "rim", Position: geom.Point{100, 30, 0}}
+	s.Lights = []Light{key, rim}
+	m := &Material{Ambient: 0.1, Diffuse: 0.9}
+	s.Objects[0].(*Sphere).Material = m
+	ray := geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1})
+	h, _ := s.Objects[0].Intersect(ray, 0)
+
+	if f := s.lightFraction(&h, ray.Origin, 0, &rim, nil); f != 1 {
+		t.Errorf("unlinked: exp: 1 act: %v", f)
+	}
+	m.Lights = []string{"key"}
+	if f := s.lightFraction(&h, ray.Origin, 0, &rim, nil); f != 0 {
+		t.Errorf("excluded: exp: 0 act: %v", f)
+	}
+	exp := s.computeObjectColorAt(&h, &ray, 0, &key)
+	if act := s.shadeHit(&h, ray, 0, 0, nil, rayPath{}); !colorsEqual(act, exp) {
+		t.Errorf("key only: exp: %v act: %v", exp, act)
+	}
+}
+
+func TestColoredShadows(t *testing.T) {
+	w := &wall{Z: 100}
+	pane := &Plane{
+		Plane:    geom.Plane{Point: geom.Point{0, 0, 50}, Normal: geom.Vector{0, 0, -1}},
+		Material: &Material{Transparency: 0.8, IOR: 1.5, Transmission: &Color{1, 0.5, 0}},
+	}
+	l := &Light{Position: geom.Origin}
+	s := &Scene{Objects: ObjectList{w, pane}, Lights: []Light{*l}}
+	h := &Hit{T: 100, Point: geom.Point{0, 0, 100}, Object: w}
+	data := []struct {
+		opacity float64
+		exp     Color
+	}{
+		{1, Color{0.8, 0.4, 0}},
+		// The transparent part of the pane filters the light its opaque
+		// part lets through.
+		{0.5, Color{0.9, 0.7, 0.5}},
+	}
+	for i, d := range data {
+		pane.Material.Opacity = d.opacity
+		if act := s.lightFilter(h, geom.Origin, 0, l, nil); !colorsEqual(act, d.exp) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+}
+
+func TestSchlick(t *testing.T) {
+	data := []struct {
+		cosi, eta, exp float64
+	}{
+		// Normal incidence on glass reflects 4% of light.
+		{1, 1 / 1.5, 0.04},
+		{1, 1.5, 0.04},
+		// Grazing incidence reflects everything.
+		{0, 1 / 1.5, 1},
+		// Beyond the critical angle from inside glass.
+		{0.5, 1.5, 1},
+	}
+	for i, d := range data {
+		if act := schlick(d.cosi, d.eta); !geom.FloatsEqual(act, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+}
+
+func TestNormalMap(t *testing.T) {
+	// uniform returns a normal map encoding the same normal everywhere.
+	uniform := func(c Color) *Material {
+		return &Material{NormalMap: &Texture{Type: CheckerTexture, Colors: [2]Color{c, c}, Scale: 1}}
+	}
+	n, tangent := geom.Vector{0, 0, -1}, geom.Vector{1, 0, 0}
+	data := []struct {
+		m   *Material
+		exp geom.Vector
+	}{
+		{nil, n},
+		{&Material{}, n},
+		{uniform(Color{0.5, 0.5, 1}), n},
+		{uniform(Color{1, 0.5, 0.5}), tangent},
+		{uniform(Color{0.5, 1, 0.5}), geom.Vector{0, 1, 0}},
+		{uniform(Color{0, 0.5, 0.5}), tangent.Neg()},
+	}
+	for i, d := range data {
+		if act := d.m.normalAt(&Hit{}, n, tangent, 0); !geom.VectorsEqual(act, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+
+	// Tangents are made orthogonal to the normal.
+	m := uniform(Color{1, 0.5, 0.5})
+	if act := m.normalAt(&Hit{}, n, geom.Vector{1, 0, 1}, 0); !geom.VectorsEqual(act, tangent, 1e-9) {
+		t.Errorf("exp: %v act: %v", tangent, act)
+	}
+
+	s := testScene()
+	sp := s.Objects[0].(*Sphere)
+	sp.Material = &Material{Ambient: 0.1, Diffuse: 0.9}
+	flat, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	sp.Material.NormalMap = uniform(Color{0.9, 0.5, 0.6}).NormalMap
+	bent, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if flat.RGBAAt(10, 10) == bent.RGBAAt(10, 10) {
+		t.Errorf("normal map ignored")
+	}
+}
+
+func TestUntexturedObjects(t *testing.T) {
+	tex := &Texture{Type: CheckerTexture, Scale: 1}
+	data := []Object{
+		&Box{Box: geom.AABB{geom.Point{0, 0, 0}, geom.Point{1, 1, 1}},
+			Material: &Material{Texture: tex}},
+		&Cylinder{Cylinder: geom.Cylinder{geom.Point{0, 0, 0}, geom.Vector{0, 1, 0}, 1},
+			Material: &Material{NormalMap: tex}},
+		&Torus{Torus: geom.Torus{geom.Point{0, 0, 0}, geom.Vector{0, 1, 0}, 2, 1},
+			Material: &Material{Texture: tex}},
+	}
+	for i, o := range data {
+		if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("#%d: texture accepted: %v", i, err)
+		}
+	}
+}
+
+func TestSceneMaterials(t *testing.T) {
+	data := `{
+		"Materials": {
+			"mirror": { "Reflectivity": 1 },
+			"matte": { "Ambient": 0.1, "Diffuse": 0.9 }
+		},
+		"Objects": [
+			{ "Sphere": { "Radius": 1 }, "Material": "mirror" },
+			{ "Type": "Group", "Objects": [
+				{ "Sphere": { "Radius": 1 }, "Material": "matte" } ] },
+			{ "Type": "Instance", "Prototype": "ball" }
+		],
+		"Prototypes": {
+			"ball": { "Sphere": { "Radius": 1 }, "Material": "matte" }
+		}
+	}`
+	var s Scene
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	if len(s.Materials) != 2 {
+		t.Fatalf("bad material library: %v", s.Materials)
+	}
+	mirror := Material{Reflectivity: 1}
+	matte := Material{Ambient: 0.1, Diffuse: 0.9}
+	for _, d := range []struct {
+		m   *Material
+		exp Material
+	}{
+		{s.Objects[0].(*Sphere).Material, mirror},
+		{s.Objects[1].(*Group).Objects[0].(*Sphere).Material, matte},
+		{s.Prototypes["ball"].(*Sphere).Material, matte},
+	} {
+		if d.m == nil || !reflect.DeepEqual(*d.m, d.exp) {
+			t.Errorf("exp: %v act: %v", d.exp, d.m)
+		}
+	}
+
+	data = `{ "Materials": {}, "Objects": [ { "Sphere": { "Radius": 1 }, "Material": "gold" } ] }`
+	if err := json.Unmarshal([]byte(data), &s); err == nil {
+		t.Errorf("unknown material accepted")
+	}
+}
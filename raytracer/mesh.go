@@ -0,0 +1,363 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// Mesh objects are triangle meshes.  Each triplet of Indices refers to the
+// Vertices of one triangle, counterclockwise when seen from outside.
+//
+// Meshes are assumed to be closed: a point is inside a mesh if a ray from this
+// point crosses an odd number of triangles.  The inner side of open meshes may
+// be shaded inconsistently.
+type Mesh struct {
+	Vertices []geom.Point
+	Indices  []int
+
+	// Normals optionally holds one normal per vertex.  When set, normals
+	// are interpolated across triangles to smooth the mesh.  Otherwise,
+	// each triangle is flat.
+	Normals []geom.Vector
+
+	// Smooth makes rendering compute Normals with ComputeNormals when they
+	// are not set.
+	Smooth bool
+
+	// UVs optionally holds the texture coordinates of each vertex, needed
+	// by image and checker textures and normal maps.  Tangents optionally
+	// holds one direction per vertex in which u grows, otherwise derived
+	// from UVs for normal mapping.
+	UVs      []geom.Point2d
+	Tangents []geom.Vector
+
+	// LODs optionally holds simplified versions of the mesh, traded for
+	// speed where details matter less: camera rays hit the mesh itself,
+	// reflection and refraction rays LODs[0] and shadow rays the last LOD,
+	// the coarsest.  Only the geometry of LODs is used, shaded with the
+	// Color and Material of the mesh.  As with Visibility, only the LODs of
+	// top-level scene objects are honored.
+	LODs []*Mesh
+
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Mesh", func() Object { return new(Mesh) })
+}
+
+// triangle returns the i-th triangle of m.
+func (m *Mesh) triangle(i int) geom.Triangle {
+	return geom.Triangle{
+		m.Vertices[m.Indices[3*i]],
+		m.Vertices[m.Indices[3*i+1]],
+		m.Vertices[m.Indices[3*i+2]],
+	}
+}
+
+func (m *Mesh) prepare(protos ObjectMap) error {
+	if m.Smooth && len(m.Normals) == 0 {
+		m.ComputeNormals()
+	}
+	if m.Material != nil {
+		if err := m.Material.prepare(); err != nil {
+			return fmt.Errorf("invalid mesh: %v", err)
+		}
+	}
+	for _, lod := range m.LODs {
+		lod.Color, lod.Material, lod.Smooth = m.Color, m.Material, m.Smooth
+		if err := lod.prepare(protos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ComputeNormals sets the normal of each vertex of m to the average of the
+// normals of the triangles sharing it, weighted by their area.  This smoothes
+// meshes imported without normals.
+func (m *Mesh) ComputeNormals() {
+	m.Normals = make([]geom.Vector, len(m.Vertices))
+	for i := 0; i < len(m.Indices)/3; i++ {
+		tr := m.triangle(i)
+		e1 := geom.MakeVector(tr[1], tr[0])
+		e2 := geom.MakeVector(tr[2], tr[0])
+		// The length of the cross product is twice the triangle area.
+		n := geom.CrossProduct(&e1, &e2)
+		for _, vi := range m.Indices[3*i : 3*i+3] {
+			m.Normals[vi] = m.Normals[vi].Add(n)
+		}
+	}
+	for i := range m.Normals {
+		if m.Normals[i].Module() != 0 {
+			m.Normals[i] = m.Normals[i].UnitVector()
+		}
+	}
+}
+
+// Intersect returns the nearest intersection between ray and the triangles of
+// m.  The face of the returned hit is the index of the intersected triangle
+// and (U, V) the barycentric coordinates of the intersection point in it.
+func (m *Mesh) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	var h Hit
+	found := false
+	for i := 0; i < len(m.Indices)/3; i++ {
+		p, t, u, v, ok := geom.RayTriangleIntersection(m.triangle(i), ray)
+		if ok {
+			// Only nearer triangles are of interest from now on.
+			ray.TMax = t
+			h = Hit{T: t, Point: p, Object: m, Face: i, U: u, V: v}
+			found = true
+		}
+	}
+	return h, found
+}
+
+// lod returns the level of detail of m hit by rays of the given kind.
+func (m *Mesh) lod(kind rayKind) *Mesh {
+	if len(m.LODs) == 0 {
+		return m
+	}
+	switch kind {
+	case reflectionRay:
+		return m.LODs[0]
+	case shadowRay:
+		return m.LODs[len(m.LODs)-1]
+	}
+	return m
+}
+
+// intersectLOD is like Intersect but intersects the level of detail of m
+// suited to rays of the given kind.  Hits on LODs record the hit on the LOD
+// as their part.
+func (m *Mesh) intersectLOD(ray geom.Ray, time float64, kind rayKind) (Hit, bool) {
+	lod := m.lod(kind)
+	if lod == m {
+		return m.Intersect(ray, time)
+	}
+	h, ok := lod.Intersect(ray, time)
+	if !ok {
+		return Hit{}, false
+	}
+	return Hit{T: h.T, Point: h.Point, Object: m, Part: &h}, true
+}
+
+func (m *Mesh) NormalAt(h *Hit, time float64) geom.Vector {
+	if h.Part != nil {
+		return h.Part.Object.NormalAt(h.Part, time)
+	}
+	var n geom.Vector
+	if len(m.Normals) == 0 {
+		tr := m.triangle(h.Face)
+		n = tr.Normal()
+	} else {
+		i := 3 * h.Face
+		n = geom.InterpolateVector(
+			m.Normals[m.Indices[i]],
+			m.Normals[m.Indices[i+1]],
+			m.Normals[m.Indices[i+2]],
+			h.U, h.V)
+		n = n.UnitVector()
+	}
+	if m.Material == nil || m.Material.NormalMap == nil {
+		return n
+	}
+	th := m.textureHit(h)
+	tangent, size := m.uvFrame(h)
+	return m.Material.normalAt(&th, n, tangent, size)
+}
+
+func (m *Mesh) Bounds() geom.AABB {
+	b := geom.EmptyAABB()
+	for _, v := range m.Vertices {
+		b = b.Expand(v)
+	}
+	for _, lod := range m.LODs {
+		b = b.Union(lod.Bounds())
+	}
+	return b
+}
+
+func (m *Mesh) Inside(p geom.Point, time float64) bool {
+	b := m.Bounds()
+	if !b.Contains(&p) {
+		return false
+	}
+	// Arbitrary direction unlikely to graze edges of real-world meshes.
+	ray := geom.MakeRay(p, geom.Vector{0.5773, 0.5774, 0.5775})
+	n := 0
+	for i := 0; i < len(m.Indices)/3; i++ {
+		if _, _, _, _, ok := geom.RayTriangleIntersection(m.triangle(i), ray); ok {
+			n++
+		}
+	}
+	return n%2 == 1
+}
+
+func (m *Mesh) ColorAt(h *Hit) Color {
+	if h.Part != nil {
+		return h.Part.Object.ColorAt(h.Part)
+	}
+	if m.Material == nil || m.Material.Texture == nil {
+		return m.Color
+	}
+	th := m.textureHit(h)
+	_, size := m.uvFrame(h)
+	return m.Material.colorAt(&th, m.Color, size)
+}
+
+// textureHit returns a copy of h whose (U, V) are the texture coordinates of
+// the hit point rather than its barycentric coordinates.
+func (m *Mesh) textureHit(h *Hit) Hit {
+	th := *h
+	if len(m.UVs) != 0 {
+		i := 3 * h.Face
+		uv := geom.InterpolatePoint2d(
+			m.UVs[m.Indices[i]],
+			m.UVs[m.Indices[i+1]],
+			m.UVs[m.Indices[i+2]],
+			h.U, h.V)
+		th.U, th.V = uv.X, uv.Y
+	}
+	return th
+}
+
+// uvFrame returns the direction in which u grows at h and the length
+// spanned on the hit triangle by a unit of texture coordinates, zero if
+// unknown.
+//
+// Tangents are derived by solving e = du*T + dv*B for the edges of the
+// triangle, taken from:
+// Lengyel, "Mathematics for 3D Game Programming and Computer Graphics".
+func (m *Mesh) uvFrame(h *Hit) (tangent geom.Vector, size float64) {
+	if len(m.UVs) == 0 {
+		return geom.Vector{}, 0
+	}
+	i := 3 * h.Face
+	tr := m.triangle(h.Face)
+	uv0, uv1, uv2 := m.UVs[m.Indices[i]], m.UVs[m.Indices[i+1]], m.UVs[m.Indices[i+2]]
+	e1 := geom.MakeVector(tr[1], tr[0])
+	e2 := geom.MakeVector(tr[2], tr[0])
+	du1, dv1 := uv1.X-uv0.X, uv1.Y-uv0.Y
+	du2, dv2 := uv2.X-uv0.X, uv2.Y-uv0.Y
+	det := du1*dv2 - du2*dv1
+	if det == 0 {
+		return geom.Vector{}, 0
+	}
+	if len(m.Tangents) != 0 {
+		tangent = geom.InterpolateVector(
+			m.Tangents[m.Indices[i]],
+			m.Tangents[m.Indices[i+1]],
+			m.Tangents[m.Indices[i+2]],
+			h.U, h.V)
+	} else {
+		a, b := e1.Scale(dv2), e2.Scale(dv1)
+		tangent = a.Sub(b)
+		tangent = tangent.Scale(1 / det)
+	}
+	c := geom.CrossProduct(&e1, &e2)
+	return tangent, math.Sqrt(c.Module() / math.Abs(det))
+}
+
+func (m *Mesh) MaterialAt(h *Hit) *Material {
+	return m.Material
+}
+
+func (m *Mesh) Clone() Object {
+	c := *m
+	c.Vertices = append([]geom.Point(nil), m.Vertices...)
+	c.Indices = append([]int(nil), m.Indices...)
+	c.Normals = append([]geom.Vector(nil), m.Normals...)
+	c.UVs = append([]geom.Point2d(nil), m.UVs...)
+	c.Tangents = append([]geom.Vector(nil), m.Tangents...)
+	c.Material = m.Material.clone()
+	c.LODs = nil
+	for _, lod := range m.LODs {
+		c.LODs = append(c.LODs, lod.Clone().(*Mesh))
+	}
+	return &c
+}
+
+func (m *Mesh) Validate() error {
+	if len(m.Indices)%3 != 0 {
+		return fmt.Errorf("invalid mesh: %d indices not multiple of 3", len(m.Indices))
+	}
+	for _, i := range m.Indices {
+		if i < 0 || i >= len(m.Vertices) {
+			return fmt.Errorf("invalid mesh: vertex index out of range: %d", i)
+		}
+	}
+	if len(m.Normals) != 0 && len(m.Normals) != len(m.Vertices) {
+		return fmt.Errorf("invalid mesh: %d normals for %d vertices",
+			len(m.Normals), len(m.Vertices))
+	}
+	for _, n := range m.Normals {
+		if n.Module() == 0 {
+			return fmt.Errorf("invalid mesh: null normal")
+		}
+	}
+	if len(m.UVs) != 0 && len(m.UVs) != len(m.Vertices) {
+		return fmt.Errorf("invalid mesh: %d texture coordinates for %d vertices",
+			len(m.UVs), len(m.Vertices))
+	}
+	if len(m.Tangents) != 0 && len(m.Tangents) != len(m.Vertices) {
+		return fmt.Errorf("invalid mesh: %d tangents for %d vertices",
+			len(m.Tangents), len(m.Vertices))
+	}
+	for _, t := range m.Tangents {
+		if t.Module() == 0 {
+			return fmt.Errorf("invalid mesh: null tangent")
+		}
+	}
+	if err := m.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid mesh: %v", err)
+	}
+	if m.Material != nil {
+		if err := m.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid mesh: %v", err)
+		}
+		if m.Material.needsUV() && len(m.UVs) == 0 {
+			return fmt.Errorf("invalid mesh: texture without texture coordinates")
+		}
+	}
+	for _, lod := range m.LODs {
+		if lod == nil {
+			return fmt.Errorf("invalid mesh: null LOD")
+		}
+		if len(lod.LODs) != 0 {
+			return fmt.Errorf("invalid mesh: nested LODs")
+		}
+		if err := lod.Validate(); err != nil {
+			return fmt.Errorf("invalid mesh LOD: %v", err)
+		}
+		if m.Material.needsUV() && len(lod.UVs) == 0 {
+			return fmt.Errorf("invalid mesh LOD: texture without texture coordinates")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+// cubeMesh returns a mesh of the cube of given center and half-edge.
+func cubeMesh(c geom.Point, r float64, color Color) *Mesh {
+	m := &Mesh{Color: color}
+	for i := 0; i < 8; i++ {
+		d := geom.Vector{-r, -r, -r}
+		if i&1 != 0 {
+			d.X = r
+		}
+		if i&2 != 0 {
+			d.Y = r
+		}
+		if i&4 != 0 {
+			d.Z = r
+		}
+		m.Vertices = append(m.Vertices, c.Translate(d))
+	}
+	// Each face as a quad of vertices in cyclic order.
+	quads := [][4]int{
+		{0, 1, 3, 2}, {4, 5, 7, 6}, // z
+		{0, 1, 5, 4}, {2, 3, 7, 6}, // y
+		{0, 2, 6, 4}, {1, 3, 7, 5}, // x
+	}
+	for _, q := range quads {
+		tr := geom.Triangle{m.Vertices[q[0]], m.Vertices[q[1]], m.Vertices[q[2]]}
+		n := tr.Normal()
+		out := geom.MakeVector(m.Vertices[q[0]], c)
+		if geom.DotProduct(&n, &out) < 0 {
+			q[1], q[3] = q[3], q[1]
+		}
+		m.Indices = append(m.Indices, q[0], q[1], q[2], q[0], q[2], q[3])
+	}
+	return m
+}
+
+func TestMeshIntersect(t *testing.T) {
+	m := cubeMesh(geom.Point{0, 0, 80}, 10, Color{1, 0, 0})
+	if err := m.Validate(); err != nil {
+		t.Fatalf("valid mesh rejected: %v", err)
+	}
+	h, ok := m.Intersect(geom.MakeRay(geom.Point{1, 2, 0}, geom.Vector{0, 0, 1}), 0)
+	if !ok {
+		t.Fatalf("ray missed mesh")
+	}
+	if exp := (geom.Point{1, 2, 70}); !geom.PointsEqual(h.Point, exp, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, h.Point)
+	}
+	if n, exp := m.NormalAt(&h, 0), (geom.Vector{0, 0, -1}); !geom.VectorsEqual(n, exp, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, n)
+	}
+
+	if _, ok := m.Intersect(geom.MakeRay(geom.Point{11, 0, 0}, geom.Vector{0, 0, 1}), 0); ok {
+		t.Fatalf("ray hit mesh")
+	}
+}
+
+func TestMeshInside(t *testing.T) {
+	m := cubeMesh(geom.Point{0, 0, 80}, 10, Color{1, 0, 0})
+	data := []struct {
+		p   geom.Point
+		exp bool
+	}{
+		{geom.Point{0, 0, 80}, true},
+		{geom.Point{9, -9, 71}, true},
+		{geom.Point{0, 0, 0}, false},
+		{geom.Point{0, 0, 91}, false},
+	}
+	for _, d := range data {
+		if act := m.Inside(d.p, 0); act != d.exp {
+			t.Errorf("%v: exp: %v act: %v", d.p, d.exp, act)
+		}
+	}
+}
+
+func TestMeshSmoothNormals(t *testing.T) {
+	m := &Mesh{
+		Vertices: []geom.Point{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		Indices:  []int{0, 1, 2},
+		Normals:  []geom.Vector{{0, 0, 1}, {1, 0, 1}, {0, 1, 1}},
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("valid mesh rejected: %v", err)
+	}
+	h := Hit{Object: m, U: 0.5, V: 0}
+	n := m.NormalAt(&h, 0)
+	exp := geom.Vector{0.5, 0, 1}
+	exp = exp.UnitVector()
+	if !geom.VectorsEqual(n, exp, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, n)
+	}
+}
+
+func TestMeshComputeNormals(t *testing.T) {
+	// Two triangles of equal area facing x and z and sharing an edge along
+	// the y-axis.
+	m := &Mesh{
+		Vertices: []geom.Point{{0, 0, 0}, {0, 1, 0}, {0, 0, 1}, {1, 0, 0}},
+		Indices:  []int{0, 1, 2, 0, 3, 1},
+		Smooth:   true,
+	}
+	if err := m.prepare(nil); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	diag := geom.Vector{1, 0, 1}
+	diag = diag.UnitVector()
+	exp := []geom.Vector{diag, diag, {1, 0, 0}, {0, 0, 1}}
+	if len(m.Normals) != len(exp) {
+		t.Fatalf("exp: %d normals act: %d", len(exp), len(m.Normals))
+	}
+	for i, n := range m.Normals {
+		if !geom.VectorsEqual(n, exp[i], 1e-9) {
+			t.Errorf("vertex #%d: exp: %v act: %v", i, exp[i], n)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("smoothed mesh rejected: %v", err)
+	}
+}
+
+func TestMeshValidate(t *testing.T) {
+	data := []Mesh{
+		{Vertices: []geom.Point{{0, 0, 0}}, Indices: []int{0, 0}},
+		{Vertices: []geom.Point{{0, 0, 0}}, Indices: []int{0, 0, 1}},
+		{Vertices: []geom.Point{{0, 0, 0}}, Indices: []int{0, 0, 0}, Normals: []geom.Vector{{0, 0, 1}, {0, 0, 1}}},
+		{Vertices: []geom.Point{{0, 0, 0}}, Indices: []int{0, 0, 0}, UVs: []geom.Point2d{{0, 0}, {1, 1}}},
+		{Vertices: []geom.Point{{0, 0, 0}}, Indices: []int{0, 0, 0}, Tangents: []geom.Vector{{0, 0, 0}}},
+		{Vertices: []geom.Point{{0, 0, 0}}, Indices: []int{0, 0, 0}, Material: &Material{Texture: &Texture{Type: CheckerTexture, Scale: 1}}},
+	}
+	for i, m := range data {
+		if err := m.Validate(); err == nil {
+			t.Errorf("#%d: invalid mesh accepted", i)
+		}
+	}
+}
+
+func TestMeshTexture(t *testing.T) {
+	green, blue := Color{0, 1, 0}, Color{0, 0, 1}
+	m := &Mesh{
+		Vertices: []geom.Point{{0, 0, 0}, {2, 0, 0}, {0, 2, 0}},
+		Indices:  []int{0, 1, 2},
+		UVs:      []geom.Point2d{{0, 0}, {2, 0}, {0, 2}},
+		Material: &Material{Texture: &Texture{Type: CheckerTexture, Scale: 1, Colors: [2]Color{green, blue}}},
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("valid mesh rejected: %v", err)
+	}
+	data := []struct {
+		u, v float64
+		exp  Color
+	}{
+		{0.25, 0.25, green},
+		{0.6, 0.1, blue},
+		{0.1, 0.6, blue},
+	}
+	for i, d := range data {
+		if act := m.ColorAt(&Hit{Object: m, U: d.u, V: d.v}); act != d.exp {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+
+	// A normal map bends normals along the direction in which u grows,
+	// derived from texture coordinates or given per vertex.
+	c := Color{1, 0.5, 0.5}
+	m.Material = &Material{NormalMap: &Texture{Type: CheckerTexture, Scale: 1, Colors: [2]Color{c, c}}}
+	h := &Hit{Object: m, U: 0.25, V: 0.25}
+	if act, exp := m.NormalAt(h, 0), (geom.Vector{1, 0, 0}); !geom.VectorsEqual(act, exp, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+	m.Tangents = []geom.Vector{{0, 1, 0}, {0, 1, 0}, {0, 1, 0}}
+	if act, exp := m.NormalAt(h, 0), (geom.Vector{0, 1, 0}); !geom.VectorsEqual(act, exp, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestMeshLODs(t *testing.T) {
+	// A coarse cube shifted along x stands for the simplified mesh.
+	full := cubeMesh(geom.Point{0, 0, 10}, 1, Color{0, 1, 0})
+	coarse := cubeMesh(geom.Point{0.5, 0, 10}, 1, Color{})
+	full.LODs = []*Mesh{coarse}
+	if err := full.Validate(); err != nil {
+		t.Fatalf("valid mesh rejected: %v", err)
+	}
+	if err := full.prepare(nil); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	ray := geom.MakeRay(geom.Point{-5, 0, 10}, geom.Vector{1, 0, 0})
+	data := []struct {
+		kind rayKind
+		exp  float64
+	}{
+		{cameraRay, 4},
+		{reflectionRay, 4.5},
+		{shadowRay, 4.5},
+	}
+	for i, d := range data {
+		h, ok := intersect(full, ray, 0, d.kind)
+		if !ok || !geom.FloatsEqual(h.T, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v %v", i, d.exp, h.T, ok)
+			continue
+		}
+		if h.Object != full {
+			t.Errorf("#%d: hit on LOD not reported on mesh", i)
+		}
+		if c := h.Object.ColorAt(&h); c != full.Color {
+			t.Errorf("#%d: exp: %v act: %v", i, full.Color, c)
+		}
+		if n, exp := h.Object.NormalAt(&h, 0), (geom.Vector{-1, 0, 0}); !geom.VectorsEqual(n, exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, exp, n)
+		}
+	}
+
+	full.LODs = []*Mesh{{Vertices: []geom.Point{{0, 0, 0}}, Indices: []int{0, 1, 0}}}
+	if err := full.Validate(); err == nil {
+		t.Errorf("invalid LOD accepted")
+	}
+}
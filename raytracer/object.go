@@ -0,0 +1,300 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math/rand"
+	"reflect"
+)
+
+// A Hit is an intersection between a ray and a scene object.
+type Hit struct {
+	T      float64    // parameter of Point along the ray
+	Point  geom.Point // intersection point
+	Object Object     // intersected object
+
+	// Face and (U, V) locate Point on the surface of Object in an
+	// object-specific way, such as the index of a mesh triangle and the
+	// barycentric coordinates of Point in it.
+	Face int
+	U, V float64
+
+	// Part is the intersection with the sub-object of Object that was hit
+	// when Object is a composite object.
+	Part *Hit
+
+	// Footprint is the approximate width in world units of the pixel seen
+	// through Point by camera rays, zero for other rays.  It selects the
+	// level of detail of textures.
+	Footprint float64
+}
+
+// setFootprint sets the footprint of h and its parts.
+func (h *Hit) setFootprint(f float64) {
+	for ; h != nil; h = h.Part {
+		h.Footprint = f
+	}
+}
+
+// An Object is a primitive that can be part of the scene to render.  Objects
+// may move during the shutter interval so most methods take the time in
+// [0..1] at which the object is considered.
+//
+// Objects are not modified while rendering and may be shared by concurrent
+// renders.
+type Object interface {
+	// Intersect returns the nearest intersection between ray and the object
+	// inside the [ray.TMin..ray.TMax] range.
+	Intersect(ray geom.Ray, time float64) (Hit, bool)
+
+	// NormalAt returns the outward unit vector normal to the object surface
+	// at intersection h returned by Intersect.
+	NormalAt(h *Hit, time float64) geom.Vector
+
+	// Bounds returns a box enclosing the object during the whole shutter
+	// interval.
+	Bounds() geom.AABB
+
+	// Inside returns whether p lies in the volume enclosed by the visible
+	// surface of the object.  The inner side of the surface is visible from
+	// such points.  Objects enclosing no volume or whose inner side is
+	// invisible return false.
+	Inside(p geom.Point, time float64) bool
+
+	// ColorAt returns the color of the object surface at intersection h
+	// returned by Intersect.
+	ColorAt(h *Hit) Color
+
+	// MaterialAt returns the material of the object surface at intersection
+	// h returned by Intersect, nil for the default one.
+	MaterialAt(h *Hit) *Material
+
+	// Clone returns a deep copy of the object.
+	Clone() Object
+
+	Validate() error
+}
+
+// Visibility selects the rays objects embedding it are hit by.  Objects are
+// hit by all rays by default.  Only the flags of top-level scene objects are
+// honored, those of objects nested in composite ones being ignored.
+type Visibility struct {
+	Invisible              bool // not seen by the camera, e.g. shadow casters
+	NoShadow               bool // casts no shadow
+	InvisibleInReflections bool // not seen in reflections
+}
+
+func (v *Visibility) visibility() *Visibility {
+	return v
+}
+
+// A rayKind tells what a ray is cast for, to honor Visibility flags.
+type rayKind int
+
+const (
+	cameraRay rayKind = iota
+	shadowRay
+	reflectionRay
+)
+
+// hitBy returns whether o can be hit by rays of the given kind.
+func hitBy(o Object, kind rayKind) bool {
+	vo, ok := o.(interface{ visibility() *Visibility })
+	if !ok {
+		return true
+	}
+	v := vo.visibility()
+	switch kind {
+	case cameraRay:
+		return !v.Invisible
+	case shadowRay:
+		return !v.NoShadow
+	case reflectionRay:
+		return !v.InvisibleInReflections
+	}
+	return true
+}
+
+// intersect intersects o with ray at the level of detail of o suited to rays
+// of the given kind, for objects having several (see Mesh.LODs).
+func intersect(o Object, ray geom.Ray, time float64, kind rayKind) (Hit, bool) {
+	if lo, ok := o.(interface {
+		intersectLOD(geom.Ray, float64, rayKind) (Hit, bool)
+	}); ok {
+		return lo.intersectLOD(ray, time, kind)
+	}
+	return o.Intersect(ray, time)
+}
+
+// A preparer is an object precomputing data before rendering, once validated
+// (see Scene.prepare).  Objects referring to prototypes resolve them in
+// protos.
+type preparer interface {
+	prepare(protos ObjectMap) error
+}
+
+// A surfaceSampler is an object whose surface can be sampled uniformly, which
+// lets emissive ones light other objects (see Scene.emitterLight).
+type surfaceSampler interface {
+	// surfaceArea returns the area of the surface, zero when it can not be
+	// sampled.
+	surfaceArea() float64
+
+	// sampleSurface returns a point of the surface at the given time drawn
+	// uniformly with rng and the unit normal there.
+	sampleSurface(time float64, rng *rand.Rand) (geom.Point, geom.Vector)
+}
+
+var (
+	objectTypes     = map[string]func() Object{}
+	objectTypeNames = map[reflect.Type]string{}
+)
+
+// RegisterObjectType makes objects created by newObject loadable from JSON scenes
+// under the given type name (see ObjectList).  It panics if name is already
+// registered.
+func RegisterObjectType(name string, newObject func() Object) {
+	if _, dup := objectTypes[name]; dup {
+		panic("raytracer: object type registered twice: " + name)
+	}
+	objectTypes[name] = newObject
+	objectTypeNames[reflect.TypeOf(newObject())] = name
+}
+
+func init() {
+	RegisterObjectType("Sphere", func() Object { return new(Sphere) })
+}
+
+// An ObjectList is a list of scene objects that can be converted from and to
+// JSON.  Each object is encoded as a JSON object with an extra "Type" field
+// naming its registered type (see RegisterObjectType).  Objects without a
+// "Type" field are spheres.
+type ObjectList []Object
+
+func (l *ObjectList) UnmarshalJSON(data []byte) error {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+	objs := make(ObjectList, 0, len(raws))
+	for i, raw := range raws {
+		o, err := decodeObject(raw)
+		if err != nil {
+			return fmt.Errorf("object #%d: %v", i, err)
+		}
+		objs = append(objs, o)
+	}
+	*l = objs
+	return nil
+}
+
+func (l ObjectList) MarshalJSON() ([]byte, error) {
+	raws := make([]map[string]json.RawMessage, 0, len(l))
+	for i, o := range l {
+		fields, err := encodeObject(o)
+		if err != nil {
+			return nil, fmt.Errorf("object #%d: %v", i, err)
+		}
+		raws = append(raws, fields)
+	}
+	return json.Marshal(raws)
+}
+
+// An ObjectMap is a set of named scene objects that can be converted from and
+// to JSON like ObjectList.
+type ObjectMap map[string]Object
+
+func (m *ObjectMap) UnmarshalJSON(data []byte) error {
+	var raws map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+	objs := make(ObjectMap, len(raws))
+	for name, raw := range raws {
+		o, err := decodeObject(raw)
+		if err != nil {
+			return fmt.Errorf("object %q: %v", name, err)
+		}
+		objs[name] = o
+	}
+	*m = objs
+	return nil
+}
+
+func (m ObjectMap) MarshalJSON() ([]byte, error) {
+	raws := make(map[string]map[string]json.RawMessage, len(m))
+	for name, o := range m {
+		fields, err := encodeObject(o)
+		if err != nil {
+			return nil, fmt.Errorf("object %q: %v", name, err)
+		}
+		raws[name] = fields
+	}
+	return json.Marshal(raws)
+}
+
+// decodeObject creates an object of the type named by the "Type" field of raw
+// and decodes raw into it.
+func decodeObject(raw json.RawMessage) (Object, error) {
+	var tag struct{ Type string }
+	if err := json.Unmarshal(raw, &tag); err != nil {
+		return nil, err
+	}
+	if tag.Type == "" {
+		tag.Type = "Sphere"
+	}
+	newObject, ok := objectTypes[tag.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", tag.Type)
+	}
+	o := newObject()
+	if err := json.Unmarshal(raw, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// encodeObject returns the JSON fields of o with an extra "Type" field naming
+// its registered type.
+func encodeObject(o Object) (map[string]json.RawMessage, error) {
+	name, ok := objectTypeNames[reflect.TypeOf(o)]
+	if !ok {
+		return nil, fmt.Errorf("unregistered type %T", o)
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("not encoded as a JSON object: %v", err)
+	}
+	if fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+	fields["Type"], _ = json.Marshal(name)
+	return fields, nil
+}
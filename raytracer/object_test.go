@@ -0,0 +1,253 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"testing"
+)
+
+// wall is a minimal object used to check that objects other than spheres can
+// be plugged into scenes: the plane z = Z.
+type wall struct {
+	Z     float64
+	Color Color
+}
+
+func (w *wall) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	if ray.Dir.Z == 0 {
+		return Hit{}, false
+	}
+	t := (w.Z - ray.Origin.Z) / ray.Dir.Z
+	if t < ray.TMin || t > ray.TMax {
+		return Hit{}, false
+	}
+	return Hit{T: t, Point: ray.At(t), Object: w}, true
+}
+
+func (w *wall) NormalAt(h *Hit, time float64) geom.Vector {
+	return geom.Vector{0, 0, -1}
+}
+
+func (w *wall) Bounds() geom.AABB {
+	inf := math.Inf(1)
+	return geom.AABB{geom.Point{-inf, -inf, w.Z}, geom.Point{inf, inf, w.Z}}
+}
+
+func (w *wall) Inside(p geom.Point, time float64) bool { return false }
+func (w *wall) ColorAt(h *Hit) Color                   { return w.Color }
+func (w *wall) MaterialAt(h *Hit) *Material            { return nil }
+func (w *wall) Validate() error                        { return w.Color.Validate() }
+
+func (w *wall) Clone() Object {
+	c := *w
+	return &c
+}
+
+func init() {
+	RegisterObjectType("testWall", func() Object { return new(wall) })
+}
+
+func TestCustomObject(t *testing.T) {
+	s := testScene()
+	s.Objects = append(s.Objects, &wall{90, Color{0, 0, 1}})
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// Corner pixels miss the sphere and hit the wall instead of the
+	// background.
+	if act, bg := img.RGBAAt(0, 0), s.Bg.toRGBA(); act == bg {
+		t.Fatalf("wall not rendered")
+	}
+	if act := img.RGBAAt(0, 0); act.B <= act.R {
+		t.Fatalf("bad wall color: %v", act)
+	}
+}
+
+func TestObjectListJSON(t *testing.T) {
+	var l ObjectList
+	data := `[
+		{ "Sphere": { "Center": {"X":1, "Y":2, "Z":3}, "Radius":4 },
+		  "Color": {"R":1, "G":0, "B":0} },
+		{ "Type": "testWall", "Z": 5, "Color": {"R":0, "G":0, "B":1} }
+	]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	if len(l) != 2 {
+		t.Fatalf("exp: 2 objects act: %d", len(l))
+	}
+	s, ok := l[0].(*Sphere)
+	if !ok || s.Sphere.Radius != 4 || s.Color != (Color{1, 0, 0}) {
+		t.Fatalf("bad sphere: %#v", l[0])
+	}
+	w, ok := l[1].(*wall)
+	if !ok || w.Z != 5 {
+		t.Fatalf("bad wall: %#v", l[1])
+	}
+
+	enc, err := json.Marshal(l)
+	if err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	var l2 ObjectList
+	if err := json.Unmarshal(enc, &l2); err != nil {
+		t.Fatalf("decoding %s failed: %v", enc, err)
+	}
+	enc2, err := json.Marshal(l2)
+	if err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	if !bytes.Equal(enc, enc2) {
+		t.Fatalf("exp: %s act: %s", enc, enc2)
+	}
+}
+
+func TestObjectMapJSON(t *testing.T) {
+	var m ObjectMap
+	data := `{ "wall": { "Type": "testWall", "Z": 5, "Color": {"R":0, "G":0, "B":1} } }`
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	if w, ok := m["wall"].(*wall); !ok || w.Z != 5 {
+		t.Fatalf("bad wall: %#v", m["wall"])
+	}
+	enc, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	var m2 ObjectMap
+	if err := json.Unmarshal(enc, &m2); err != nil {
+		t.Fatalf("decoding %s failed: %v", enc, err)
+	}
+	if w, ok := m2["wall"].(*wall); !ok || w.Z != 5 {
+		t.Fatalf("bad wall after round trip: %s", enc)
+	}
+}
+
+func TestObjectListUnknownType(t *testing.T) {
+	var l ObjectList
+	if err := json.Unmarshal([]byte(`[{"Type": "NoSuchThing"}]`), &l); err == nil {
+		t.Fatalf("unknown type accepted")
+	}
+}
+
+func TestSphereBounds(t *testing.T) {
+	s := Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 0}, 1}, Motion: geom.Vector{2, 0, 0}}
+	b := s.Bounds()
+	exp := geom.AABB{geom.Point{-1, -1, -1}, geom.Point{3, 1, 1}}
+	if b != exp {
+		t.Fatalf("exp: %v act: %v", exp, b)
+	}
+}
+
+func TestObjectRender(t *testing.T) {
+	// Each object hides the red sphere behind it from pixel (10, 10) and is
+	// rejected when its variant is decoded instead.
+	data := []struct {
+		object, invalid string
+	}{
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Box",
+			"Box": { "Min": {"X":-5,"Y":-5,"Z":50}, "Max": {"X":5,"Y":5,"Z":60} } }`,
+			`{ "Type": "Box",
+			"Box": { "Min": {"X":-5,"Y":-5,"Z":50}, "Max": {"X":-6,"Y":5,"Z":60} } }`},
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Cylinder",
+			"Cylinder": { "Base": {"X":0,"Y":-5,"Z":60}, "Axis": {"X":0,"Y":10,"Z":0}, "Radius": 3 } }`,
+			`{ "Type": "Cylinder",
+			"Cylinder": { "Base": {"X":0,"Y":-5,"Z":60}, "Axis": {"X":0,"Y":10,"Z":0}, "Radius": 0 } }`},
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Cone",
+			"Cone": { "Base": {"X":0,"Y":-5,"Z":60}, "Axis": {"X":0,"Y":10,"Z":0},
+				"BaseRadius": 4, "TopRadius": 0 } }`,
+			`{ "Type": "Cone",
+			"Cone": { "Base": {"X":0,"Y":-5,"Z":60}, "Axis": {"X":0,"Y":10,"Z":0},
+				"BaseRadius": 4, "TopRadius": -1 } }`},
+		// Torus seen from the side.
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Torus",
+			"Torus": { "Center": {"X":0,"Y":0,"Z":60}, "Axis": {"X":0,"Y":1,"Z":0},
+				"Major": 6, "Minor": 2 } }`,
+			`{ "Type": "Torus",
+			"Torus": { "Center": {"X":0,"Y":0,"Z":60}, "Axis": {"X":0,"Y":1,"Z":0},
+				"Major": 6, "Minor": 7 } }`},
+		// Sphere of radius 5 centered on (0, 0, 60).
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Quadric", "Quadric": { "A": 1, "B": 1, "C": 1, "I": -120, "J": 3575 } }`,
+			`{ "Type": "Quadric", "Quadric": { "J": 1 } }`},
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Disk",
+			"Disk": { "Center": {"X":0,"Y":0,"Z":60}, "Normal": {"X":0,"Y":0,"Z":-1}, "Radius": 5 } }`,
+			`{ "Type": "Disk",
+			"Disk": { "Center": {"X":0,"Y":0,"Z":60}, "Normal": {"X":0,"Y":0,"Z":-1}, "Radius": 0 } }`},
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Quad",
+			"Quad": { "Corner": {"X":-5,"Y":-5,"Z":60}, "U": {"X":10,"Y":0,"Z":0}, "V": {"X":0,"Y":10,"Z":0} } }`,
+			`{ "Type": "Quad",
+			"Quad": { "Corner": {"X":-5,"Y":-5,"Z":60}, "U": {"X":10,"Y":0,"Z":0}, "V": {"X":20,"Y":0,"Z":0} } }`},
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Blob", "Threshold": 0.25, "Centers": [
+				{ "Center": {"X":-4,"Y":0,"Z":60}, "Radius":5, "Strength":1 },
+				{ "Center": {"X":4,"Y":0,"Z":60}, "Radius":5, "Strength":1 } ] }`,
+			`{ "Type": "Blob", "Threshold": 0, "Centers": [
+				{ "Center": {"X":-4,"Y":0,"Z":60}, "Radius":5, "Strength":1 } ] }`},
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "SDF", "Shape": { "Type": "union", "Smoothness": 2, "Children": [
+				{ "Type": "sphere", "Center": {"X":-4,"Y":0,"Z":60}, "Radius": 2 },
+				{ "Type": "box", "Center": {"X":0,"Y":0,"Z":60}, "Size": {"X":2,"Y":2,"Z":2} } ] } }`,
+			`{ "Type": "SDF", "Shape": { "Type": "union", "Children": [
+				{ "Type": "blob", "Center": {"X":-4,"Y":0,"Z":60}, "Radius": 2 } ] } }`},
+		// Triangle facing the eye.
+		{`{ "Color": {"R":0, "G":1, "B":0}, "Type": "Mesh",
+			"Vertices": [{"X":-10,"Y":-10,"Z":60}, {"X":10,"Y":-10,"Z":60}, {"X":0,"Y":10,"Z":60}],
+			"Indices": [0, 2, 1] }`,
+			`{ "Type": "Mesh",
+			"Vertices": [{"X":-10,"Y":-10,"Z":60}, {"X":10,"Y":-10,"Z":60}, {"X":0,"Y":10,"Z":60}],
+			"Indices": [0, 2, 3] }`},
+	}
+	for i, d := range data {
+		for _, valid := range []bool{true, false} {
+			js := d.object
+			if !valid {
+				js = d.invalid
+			}
+			var l ObjectList
+			if err := json.Unmarshal([]byte("["+js+"]"), &l); err != nil {
+				t.Errorf("#%d: decoding failed: %v", i, err)
+				continue
+			}
+			s := testScene()
+			s.Objects = append(s.Objects, l...)
+			img, err := s.Render(1)
+			if !valid {
+				if err == nil {
+					t.Errorf("#%d: invalid %T accepted", i, l[0])
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("#%d: render failed: %v", i, err)
+				continue
+			}
+			if act := img.RGBAAt(10, 10); act.G == 0 || act.R != act.B {
+				t.Errorf("#%d: %T not rendered: %v", i, l[0], act)
+			}
+		}
+	}
+}
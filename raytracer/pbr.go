@@ -0,0 +1,275 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// A PBR describes a surface in the metallic-roughness model popularized by
+// glTF.  Metals have no diffuse component and tint their highlights with
+// their base color while dielectrics reflect about 4% of light at normal
+// incidence whatever their color.
+//
+// Materials with a PBR model are shaded with it instead of their ambient,
+// diffuse, specular and shininess terms.
+type PBR struct {
+	BaseColor *Color  // albedo of the surface, nil for the color of the object
+	Metallic  float64 // 0 for dielectrics, 1 for metals
+	Roughness float64 // 0 for sharp highlights, 1 for fully diffuse surfaces
+	Emissive  Color   // light emitted by the surface, black for none
+	BRDF      BRDF    // model of highlights, empty for Blinn-Phong
+
+	// EmissiveIntensity scales Emissive, zero meaning 1, so that surfaces
+	// can emit more light than their color shows.  Emissive spheres and
+	// quads light other objects (see Scene.EmitterSamples).
+	EmissiveIntensity float64
+
+	// RoughnessV, when set, makes highlights anisotropic as on brushed
+	// metal: Roughness then applies along Tangent and RoughnessV across it.
+	// Tangent is projected onto the surface, nil selecting an arbitrary
+	// direction.
+	RoughnessV float64
+	Tangent    *geom.Vector
+}
+
+// A BRDF is a model of specular reflection of PBR materials.
+type BRDF string
+
+const (
+	BlinnPhongBRDF   BRDF = "blinn-phong"   // normalized Blinn-Phong distribution
+	CookTorranceBRDF BRDF = "cook-torrance" // GGX microfacets
+)
+
+func (p *PBR) Validate() error {
+	if p.Metallic < 0 || p.Metallic > 1 {
+		return fmt.Errorf("invalid PBR material: metallic coefficient out-of-range: %v", p.Metallic)
+	}
+	if p.Roughness < 0 || p.Roughness > 1 {
+		return fmt.Errorf("invalid PBR material: roughness coefficient out-of-range: %v", p.Roughness)
+	}
+	if p.EmissiveIntensity < 0 {
+		return fmt.Errorf("invalid PBR material: negative emissive intensity: %v", p.EmissiveIntensity)
+	}
+	if p.RoughnessV < 0 || p.RoughnessV > 1 {
+		return fmt.Errorf("invalid PBR material: v roughness coefficient out-of-range: %v", p.RoughnessV)
+	}
+	switch p.BRDF {
+	case "", BlinnPhongBRDF, CookTorranceBRDF:
+	default:
+		return fmt.Errorf("invalid PBR material: unknown BRDF: %q", p.BRDF)
+	}
+	if p.BaseColor != nil {
+		if err := p.BaseColor.Validate(); err != nil {
+			return fmt.Errorf("invalid PBR material: base %v", err)
+		}
+	}
+	if err := p.Emissive.Validate(); err != nil {
+		return fmt.Errorf("invalid PBR material: emissive %v", err)
+	}
+	return nil
+}
+
+// clone returns a copy of p, nil if p is nil.
+func (p *PBR) clone() *PBR {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	if p.BaseColor != nil {
+		b := *p.BaseColor
+		c.BaseColor = &b
+	}
+	if p.Tangent != nil {
+		t := *p.Tangent
+		c.Tangent = &t
+	}
+	return &c
+}
+
+// baseColor returns the albedo of a surface of color col.
+func (p *PBR) baseColor(col Color) Color {
+	if p.BaseColor != nil {
+		return *p.BaseColor
+	}
+	return col
+}
+
+// dielectricF0 is the reflectance at normal incidence of common dielectrics.
+const dielectricF0 = 0.04
+
+// shade computes the color of a surface of color col lit with intensity k
+// under ambient light amb.  dot is the cosine of the angle of incidence
+// of light, ndotv the one of the eye direction, ndoth the one between the
+// normal and the half-way vector between the light and eye directions and
+// vdoth the one between the eye direction and the half-way vector.  tdoth
+// and bdoth are the cosines between the half-way vector and the tangent and
+// bitangent returned by tangentFrame, only used by anisotropic surfaces.
+//
+// Highlights follow the distribution selected by the BRDF and are weighted
+// with Schlick's approximation of Fresnel reflectance.
+func (p *PBR) shade(col, amb Color, dot, ndotv, ndoth, vdoth, tdoth, bdoth, k float64) Color {
+	base := p.baseColor(col)
+	c := p.ambient(base, amb)
+	if dot <= 0 {
+		return c
+	}
+	diffuse := k * (1 - p.Metallic) * dot
+	ax, ay := p.alphas()
+	sl := slope(ax, ay, ndoth, tdoth, bdoth)
+	var spec float64
+	switch p.BRDF {
+	case CookTorranceBRDF:
+		spec = k * cookTorrance(ax, ay, dot, ndotv, ndoth, sl)
+	default:
+		spec = k * blinnPhong(ax, ay, dot, ndoth, sl)
+	}
+	fc := math.Pow(1-math.Max(vdoth, 0), 5)
+	f := func(b float64) float64 {
+		f0 := dielectricF0*(1-p.Metallic) + b*p.Metallic
+		return f0 + (1-f0)*fc
+	}
+	c.R += diffuse*base.R + spec*f(base.R)
+	c.G += diffuse*base.G + spec*f(base.G)
+	c.B += diffuse*base.B + spec*f(base.B)
+	return c
+}
+
+// alpha returns the microfacet distribution width of a surface of the given
+// roughness, following:
+// 	Karis, "Real Shading in Unreal Engine 4", SIGGRAPH 2013 course notes.
+// It is kept away from zero to avoid infinitely sharp highlights that a
+// point light would never hit.
+func alpha(roughness float64) float64 {
+	return math.Max(roughness*roughness, 1e-3)
+}
+
+// alphas returns the microfacet distribution widths of p along the tangent
+// and bitangent.
+func (p *PBR) alphas() (ax, ay float64) {
+	ax = alpha(p.Roughness)
+	if p.RoughnessV == 0 {
+		return ax, ax
+	}
+	return ax, alpha(p.RoughnessV)
+}
+
+// slope returns the squared slope of microfacets facing the half-way vector
+// scaled by the distribution widths ax and ay in its direction.  Cosines are
+// those described in PBR.shade.
+func slope(ax, ay, ndoth, tdoth, bdoth float64) float64 {
+	if ax == ay {
+		return (1 - ndoth*ndoth) / (ax * ax)
+	}
+	return tdoth*tdoth/(ax*ax) + bdoth*bdoth/(ay*ay)
+}
+
+// tangentFrame returns the unit tangent and bitangent orienting anisotropic
+// highlights of p at a point of unit normal n.
+func (p *PBR) tangentFrame(n geom.Vector) (t, b geom.Vector) {
+	if p.Tangent != nil {
+		t = p.Tangent.Sub(n.Scale(geom.DotProduct(p.Tangent, &n)))
+		if t.Module() > 0 {
+			t = t.UnitVector()
+			return t, geom.CrossProduct(&n, &t)
+		}
+	}
+	onb := geom.MakeONB(n)
+	return onb.U, onb.V
+}
+
+// blinnPhong returns the specular reflection, Fresnel term excluded, of a
+// surface of distribution widths ax and ay with a normalized Blinn-Phong
+// distribution.  sl is the slope returned by slope and other parameters are
+// the cosines described in PBR.shade.
+//
+// Anisotropic model taken from:
+// 	Ashikhmin and Shirley, "An Anisotropic Phong BRDF Model", Journal of
+// 	Graphics Tools 5 (2), 2000.
+func blinnPhong(ax, ay, dot, ndoth, sl float64) float64 {
+	if ndoth <= 0 {
+		return 0
+	}
+	nu := 2/(ax*ax) - 2
+	if ax == ay {
+		return (nu + 8) / 8 * math.Pow(ndoth, nu) * dot
+	}
+	// The exponent varies with the direction of the half-way vector.
+	nv := 2/(ay*ay) - 2
+	n := (nu + nv) / 2
+	if s2 := 1 - ndoth*ndoth; s2 > 0 {
+		n = 2*sl/s2 - 2
+	}
+	return math.Sqrt((nu+8)*(nv+8)) / 8 * math.Pow(ndoth, n) * dot
+}
+
+// cookTorrance returns the specular reflection, Fresnel term excluded, of a
+// surface of distribution widths ax and ay with the Cook-Torrance microfacet
+// model using the GGX normal distribution and Smith shadowing-masking in
+// Schlick's approximation.  sl is the slope returned by slope and other
+// parameters are the cosines described in PBR.shade.
+//
+// Models taken from:
+// 	Cook and Torrance, "A Reflectance Model for Computer Graphics",
+// 	ACM Transactions on Graphics 1 (1), 1982.
+// 	Walter et al., "Microfacet Models for Refraction through Rough
+// 	Surfaces", EGSR 2007.
+// 	Burley, "Physically-Based Shading at Disney", SIGGRAPH 2012 course
+// 	notes.
+//
+// Light intensity includes the factor pi of the Lambertian diffuse term so
+// that the distribution is scaled accordingly.
+func cookTorrance(ax, ay, dot, ndotv, ndoth, sl float64) float64 {
+	if ndotv <= 0 || ndoth <= 0 {
+		return 0
+	}
+	dd := sl + ndoth*ndoth
+	d := 1 / (ax * ay * dd * dd) // GGX distribution times pi
+	k := math.Sqrt(ax*ay) / 2
+	g1 := func(c float64) float64 { return c / (c*(1-k) + k) }
+	g := g1(dot) * g1(ndotv)
+	// dot cancels out between the BRDF denominator and the cosine term.
+	return d * g / (4 * ndotv)
+}
+
+// ambient returns the color of a surface of albedo base in the shade under
+// ambient light amb.
+func (p *PBR) ambient(base, amb Color) Color {
+	e := p.emission()
+	return Color{
+		amb.R*base.R + e.R,
+		amb.G*base.G + e.G,
+		amb.B*base.B + e.B,
+	}
+}
+
+// emission returns the light emitted by surfaces of model p.
+func (p *PBR) emission() Color {
+	k := p.EmissiveIntensity
+	if k == 0 {
+		return p.Emissive
+	}
+	return Color{k * p.Emissive.R, k * p.Emissive.G, k * p.Emissive.B}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"testing"
+)
+
+func TestPBRValidate(t *testing.T) {
+	data := []struct {
+		pbr PBR
+		ok  bool
+	}{
+		{PBR{Metallic: 1, Roughness: 0.5}, true},
+		{PBR{BaseColor: &Color{1, 0.5, 0}, Emissive: Color{0, 0, 1}}, true},
+		{PBR{Metallic: 1.5}, false},
+		{PBR{Roughness: -0.1}, false},
+		{PBR{BaseColor: &Color{2, 0, 0}}, false},
+		{PBR{Emissive: Color{0, -1, 0}}, false},
+		{PBR{Emissive: Color{1, 1, 1}, EmissiveIntensity: 10}, true},
+		{PBR{EmissiveIntensity: -1}, false},
+		{PBR{BRDF: CookTorranceBRDF}, true},
+		{PBR{BRDF: "phong"}, false},
+		{PBR{Roughness: 0.2, RoughnessV: 0.6, Tangent: &geom.Vector{1, 0, 0}}, true},
+		{PBR{RoughnessV: 1.5}, false},
+	}
+	for _, d := range data {
+		if err := d.pbr.Validate(); (err == nil) != d.ok {
+			t.Errorf("%+v: exp: %v act: %v", d.pbr, d.ok, err)
+		}
+	}
+	m := Material{PBR: &PBR{Metallic: 2}}
+	if err := m.Validate(); err == nil {
+		t.Errorf("material with invalid PBR model accepted")
+	}
+}
+
+func TestPBRShade(t *testing.T) {
+	col := Color{1, 0, 0}
+	data := []struct {
+		pbr                          PBR
+		ka, dot, ndotv, ndoth, vdoth float64
+		exp                          Color
+	}{
+		// Light behind the surface: ambient and emission only.
+		{PBR{Emissive: Color{0, 0, 0.5}}, 0.1, 0, 1, 1, 1, Color{0.1, 0, 0.5}},
+		// Dielectric seen away from its highlight: diffuse only.
+		{PBR{Roughness: 1}, 0, 0.5, 1, 0, 1, Color{0.5, 0, 0}},
+		// Metal seen away from its highlight: no diffuse.
+		{PBR{Metallic: 1, Roughness: 1}, 0, 0.5, 1, 0, 1, Color{0, 0, 0}},
+		// Cook-Torrance highlight of a rough metal seen head-on.
+		{PBR{Metallic: 1, Roughness: 1, BRDF: CookTorranceBRDF}, 0, 1, 1, 1, 1, Color{0.25, 0, 0}},
+		// Base color overriding the object color.
+		{PBR{BaseColor: &Color{0, 1, 0}, Roughness: 1}, 0, 1, 1, 0, 1, Color{0, 1, 0}},
+	}
+	for i, d := range data {
+		if act := d.pbr.shade(col, Color{d.ka, d.ka, d.ka}, d.dot, d.ndotv, d.ndoth, d.vdoth, 0, 0, 1); act != d.exp {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+
+	// Metal highlights take the base color, dielectric ones do not.
+	metal := PBR{Metallic: 1, Roughness: 0.3}
+	if act := metal.shade(col, Color{}, 1, 1, 1, 1, 0, 0, 1); act.R == 0 || act.G != 0 {
+		t.Errorf("metal highlight not tinted: %v", act)
+	}
+	plastic := PBR{Roughness: 0.3}
+	if act := plastic.shade(col, Color{}, 1, 1, 1, 1, 0, 0, 1); act.G == 0 || act.G != act.B {
+		t.Errorf("dielectric highlight tinted: %v", act)
+	}
+}
+
+// isoCookTorrance returns the isotropic Cook-Torrance reflection of a surface
+// of the given roughness.
+func isoCookTorrance(roughness, dot, ndotv, ndoth float64) float64 {
+	a := alpha(roughness)
+	return cookTorrance(a, a, dot, ndotv, ndoth, slope(a, a, ndoth, 0, 0))
+}
+
+func TestCookTorrance(t *testing.T) {
+	// No highlight when the eye or the microfacets face away.
+	if act := isoCookTorrance(0.5, 1, 0, 1); act != 0 {
+		t.Errorf("highlight seen from behind: %v", act)
+	}
+	if act := isoCookTorrance(0.5, 1, 1, 0); act != 0 {
+		t.Errorf("highlight from back-facing microfacets: %v", act)
+	}
+	// Highlights fade away from the mirror direction, faster on smoother
+	// surfaces which have brighter peaks.
+	for _, r := range []float64{0.2, 0.5, 0.8} {
+		if peak, off := isoCookTorrance(r, 1, 1, 1), isoCookTorrance(r, 1, 1, 0.9); peak <= off {
+			t.Errorf("roughness %v: peak %v not above %v", r, peak, off)
+		}
+	}
+	if smooth, rough := isoCookTorrance(0.2, 1, 1, 1), isoCookTorrance(0.8, 1, 1, 1); smooth <= rough {
+		t.Errorf("smooth peak %v not above rough one %v", smooth, rough)
+	}
+}
+
+func TestAnisotropy(t *testing.T) {
+	col := Color{1, 1, 1}
+	ndoth := 0.95
+	sin := math.Sqrt(1 - ndoth*ndoth)
+	for _, brdf := range []BRDF{BlinnPhongBRDF, CookTorranceBRDF} {
+		// Equal roughnesses are isotropic.
+		iso := PBR{Metallic: 1, Roughness: 0.4, BRDF: brdf}
+		aniso := iso
+		aniso.RoughnessV = iso.Roughness
+		exp := iso.shade(col, Color{}, 1, 1, ndoth, 1, 0, 0, 1)
+		if act := aniso.shade(col, Color{}, 1, 1, ndoth, 1, sin, 0, 1); !colorsEqual(act, exp) {
+			t.Errorf("%s: exp: %v act: %v", brdf, exp, act)
+		}
+		// Highlights spread further across the tangent when rougher
+		// that way.
+		aniso.RoughnessV = 0.8
+		along := aniso.shade(col, Color{}, 1, 1, ndoth, 1, sin, 0, 1)
+		across := aniso.shade(col, Color{}, 1, 1, ndoth, 1, 0, sin, 1)
+		if along.R >= across.R {
+			t.Errorf("%s: along: %v not below across: %v", brdf, along, across)
+		}
+	}
+
+	p := PBR{Tangent: &geom.Vector{1, 0, 1}}
+	tg, b := p.tangentFrame(geom.Vector{0, 0, 1})
+	if !geom.VectorsEqual(tg, geom.Vector{1, 0, 0}, 1e-9) || !geom.VectorsEqual(b, geom.Vector{0, 1, 0}, 1e-9) {
+		t.Errorf("tangent frame: %v %v", tg, b)
+	}
+	p.Tangent = &geom.Vector{0, 0, 2}
+	tg, b = p.tangentFrame(geom.Vector{0, 0, 1})
+	if !geom.FloatsEqual(tg.Module(), 1, 1e-9) || !geom.FloatsEqual(geom.DotProduct(&tg, &b), 0, 1e-9) || tg.Z != 0 {
+		t.Errorf("tangent frame along normal: %v %v", tg, b)
+	}
+}
+
+func TestPBRRender(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	data := `[{ "Sphere": { "Center": {"X":0,"Y":0,"Z":60}, "Radius":5 },
+		"Color": {"R":1, "G":0, "B":0},
+		"Material": { "PBR": { "BaseColor": {"R":0, "G":1, "B":0},
+			"Roughness": 0.5, "Emissive": {"R":0, "G":0.2, "B":0} } } }]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l...)
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(10, 10); act.G == 0 || act.R != act.B {
+		t.Fatalf("PBR sphere not rendered: %v", act)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// Plane objects are infinite planes, or rectangles when the geometric
+// plane is bounded.  Both sides are visible.  The half-space behind the plane,
+// opposite to its normal, is considered its inside so that the side facing
+// the eye is shaded and the other side shadowed.
+type Plane struct {
+	Plane    geom.Plane
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Plane", func() Object { return new(Plane) })
+}
+
+func (pl *Plane) prepare(protos ObjectMap) error {
+	if pl.Material != nil {
+		if err := pl.Material.prepare(); err != nil {
+			return fmt.Errorf("invalid plane: %v", err)
+		}
+	}
+	return nil
+}
+
+func (pl *Plane) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	p, t, ok := pl.Plane.Intersect(ray)
+	if !ok {
+		return Hit{}, false
+	}
+	h := Hit{T: t, Point: p, Object: pl}
+	if pl.Material.textured() {
+		uv := pl.Plane.UVAt(&p)
+		h.U, h.V = uv.X, uv.Y
+	}
+	return h, true
+}
+
+func (pl *Plane) NormalAt(h *Hit, time float64) geom.Vector {
+	n := pl.Plane.Normal.UnitVector()
+	if pl.Material == nil || pl.Material.NormalMap == nil {
+		return n
+	}
+	return pl.Material.normalAt(h, n, pl.Plane.Tangent(), pl.uvSize())
+}
+
+func (pl *Plane) Bounds() geom.AABB {
+	g := &pl.Plane
+	if !g.Bounded() {
+		inf := math.Inf(1)
+		return geom.AABB{geom.Point{-inf, -inf, -inf}, geom.Point{inf, inf, inf}}
+	}
+	b := geom.EmptyAABB()
+	for _, u := range []geom.Vector{g.U, g.U.Neg()} {
+		for _, v := range []geom.Vector{g.V, g.V.Neg()} {
+			b = b.Expand(g.Point.Translate(u.Add(v)))
+		}
+	}
+	return b
+}
+
+func (pl *Plane) Inside(p geom.Point, time float64) bool {
+	return geom.PointPlaneDistance(p, pl.Plane) < 0
+}
+
+func (pl *Plane) ColorAt(h *Hit) Color {
+	return pl.Material.colorAt(h, pl.Color, pl.uvSize())
+}
+
+// uvSize returns the length spanned on pl by a unit of texture coordinates.
+func (pl *Plane) uvSize() float64 {
+	g := &pl.Plane
+	if !g.Bounded() {
+		return 1
+	}
+	return 2 * math.Max(g.U.Module(), g.V.Module())
+}
+
+func (pl *Plane) MaterialAt(h *Hit) *Material {
+	return pl.Material
+}
+
+func (pl *Plane) Clone() Object {
+	c := *pl
+	c.Material = pl.Material.clone()
+	return &c
+}
+
+func (pl *Plane) Validate() error {
+	if err := pl.Plane.Validate(); err != nil {
+		return err
+	}
+	if err := pl.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid plane: %v", err)
+	}
+	if pl.Material != nil {
+		if err := pl.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid plane: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+// wallScene returns the test scene with a blue wall behind the sphere.
+func wallScene(normal geom.Vector) *Scene {
+	s := testScene()
+	s.Objects = append(s.Objects, &Plane{
+		Plane: geom.Plane{Point: geom.Point{0, 0, 90}, Normal: normal},
+		Color: Color{0, 0, 1},
+	})
+	return s
+}
+
+func TestPlaneShadowedBySphere(t *testing.T) {
+	s := wallScene(geom.Vector{0, 0, -1})
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act, bg := img.RGBAAt(0, 0), s.Bg.toRGBA(); act == bg {
+		t.Fatalf("plane not rendered")
+	}
+	// Pixel (16, 12) sees the wall through the sphere shadow.
+	shadow := Color{0, 0, 1 - s.Kd}
+	if act, exp := img.RGBAAt(16, 12), shadow.toRGBA(); act != exp {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestPlaneTwoSided(t *testing.T) {
+	front, err := wallScene(geom.Vector{0, 0, -1}).Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	back, err := wallScene(geom.Vector{0, 0, 1}).Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !bytes.Equal(front.Pix, back.Pix) {
+		t.Fatalf("back side shaded differently")
+	}
+}
+
+func TestPlaneJSON(t *testing.T) {
+	var l ObjectList
+	data := `[{ "Type": "Plane",
+		"Plane": { "Point": {"X":0, "Y":-1, "Z":0}, "Normal": {"X":0, "Y":1, "Z":0} },
+		"Color": {"R":0, "G":1, "B":0} }]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	pl, ok := l[0].(*Plane)
+	if !ok || pl.Plane.Normal != (geom.Vector{0, 1, 0}) {
+		t.Fatalf("bad plane: %#v", l[0])
+	}
+	if err := pl.Validate(); err != nil {
+		t.Fatalf("valid plane rejected: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"math/rand"
+)
+
+// Quad objects are parallelograms.  Like planes, both sides are visible and
+// the half-space behind the quad is considered its inside.  Hits record in U
+// and V the coordinates of the intersection along the edges, which textures
+// use as texture coordinates.
+type Quad struct {
+	Quad     geom.Quad
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Quad", func() Object { return new(Quad) })
+}
+
+func (q *Quad) prepare(protos ObjectMap) error {
+	if q.Material != nil {
+		if err := q.Material.prepare(); err != nil {
+			return fmt.Errorf("invalid quad: %v", err)
+		}
+	}
+	return nil
+}
+
+func (q *Quad) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	p, t, a, b, ok := geom.RayQuadIntersection(q.Quad, ray)
+	if !ok {
+		return Hit{}, false
+	}
+	return Hit{T: t, Point: p, Object: q, U: a, V: b}, true
+}
+
+func (q *Quad) NormalAt(h *Hit, time float64) geom.Vector {
+	n := q.Quad.Normal()
+	if q.Material == nil || q.Material.NormalMap == nil {
+		return n
+	}
+	return q.Material.normalAt(h, n, q.Quad.U, q.uvSize())
+}
+
+func (q *Quad) Bounds() geom.AABB {
+	return q.Quad.Bounds()
+}
+
+func (q *Quad) Inside(p geom.Point, time float64) bool {
+	v := geom.MakeVector(p, q.Quad.Corner)
+	n := q.Quad.Normal()
+	return geom.DotProduct(&v, &n) < 0
+}
+
+func (q *Quad) ColorAt(h *Hit) Color {
+	return q.Material.colorAt(h, q.Color, q.uvSize())
+}
+
+// uvSize returns the length spanned on q by a unit of texture coordinates.
+func (q *Quad) uvSize() float64 {
+	return math.Max(q.Quad.U.Module(), q.Quad.V.Module())
+}
+
+func (q *Quad) MaterialAt(h *Hit) *Material {
+	return q.Material
+}
+
+func (q *Quad) surfaceArea() float64 {
+	n := geom.CrossProduct(&q.Quad.U, &q.Quad.V)
+	return n.Module()
+}
+
+func (q *Quad) sampleSurface(time float64, rng *rand.Rand) (geom.Point, geom.Vector) {
+	p := q.Quad.Corner.Translate(q.Quad.U.Scale(rng.Float64()))
+	return p.Translate(q.Quad.V.Scale(rng.Float64())), q.Quad.Normal()
+}
+
+func (q *Quad) Clone() Object {
+	c := *q
+	c.Material = q.Material.clone()
+	return &c
+}
+
+func (q *Quad) Validate() error {
+	if err := q.Quad.Validate(); err != nil {
+		return err
+	}
+	if err := q.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid quad: %v", err)
+	}
+	if q.Material != nil {
+		if err := q.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid quad: %v", err)
+		}
+	}
+	return nil
+}
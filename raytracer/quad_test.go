@@ -0,0 +1,69 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+func TestQuadIntersect(t *testing.T) {
+	q := &Quad{Quad: geom.Quad{geom.Point{0, 0, 10}, geom.Vector{4, 0, 0}, geom.Vector{0, 2, 0}}}
+	h, ok := q.Intersect(geom.MakeRay(geom.Point{1, 1.5, 0}, geom.Vector{0, 0, 1}), 0)
+	if !ok {
+		t.Fatalf("quad missed")
+	}
+	if h.U != 0.25 || h.V != 0.75 {
+		t.Errorf("exp: 0.25 0.75 act: %v %v", h.U, h.V)
+	}
+	if n, exp := q.NormalAt(&h, 0), (geom.Vector{0, 0, 1}); !geom.VectorsEqual(n, exp, 1e-9) {
+		t.Errorf("exp: %v act: %v", exp, n)
+	}
+	if !q.Inside(geom.Point{0, 0, 0}, 0) || q.Inside(geom.Point{0, 0, 20}, 0) {
+		t.Errorf("bad inside")
+	}
+}
+
+func TestQuadTexture(t *testing.T) {
+	green, blue := Color{0, 1, 0}, Color{0, 0, 1}
+	q := &Quad{
+		Quad:     geom.Quad{geom.Point{0, 0, 10}, geom.Vector{4, 0, 0}, geom.Vector{0, 2, 0}},
+		Material: &Material{Texture: &Texture{Type: CheckerTexture, Scale: 2, Colors: [2]Color{green, blue}}},
+	}
+	data := []struct {
+		x   float64
+		exp Color
+	}{
+		{1, green},
+		{3, blue},
+	}
+	for i, d := range data {
+		h, ok := q.Intersect(geom.MakeRay(geom.Point{d.x, 0.5, 0}, geom.Vector{0, 0, 1}), 0)
+		if !ok {
+			t.Fatalf("#%d: quad missed", i)
+		}
+		if act := q.ColorAt(&h); act != d.exp {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// Quadric objects are quadric surfaces, optionally clipped by a box.  Most
+// quadrics are unbounded and need clipping to be used as finite shapes.
+type Quadric struct {
+	Quadric  geom.Quadric
+	Clip     *geom.AABB // only the part of the surface inside this box is visible
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Quadric", func() Object { return new(Quadric) })
+}
+
+// Intersect returns the nearest intersection between ray and the visible part
+// of q.
+func (q *Quadric) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	t0, t1, ok := geom.RayQuadricRoots(q.Quadric, ray)
+	if !ok {
+		return Hit{}, false
+	}
+	for _, t := range [...]float64{t0, t1} {
+		if t < ray.TMin || t > ray.TMax {
+			continue
+		}
+		p := ray.At(t)
+		if q.Clip != nil && !q.Clip.Contains(&p) {
+			continue
+		}
+		return Hit{T: t, Point: p, Object: q}, true
+	}
+	return Hit{}, false
+}
+
+func (q *Quadric) NormalAt(h *Hit, time float64) geom.Vector {
+	return q.Quadric.NormalVectorAt(&h.Point)
+}
+
+func (q *Quadric) Bounds() geom.AABB {
+	if q.Clip != nil {
+		return *q.Clip
+	}
+	inf := math.Inf(1)
+	return geom.AABB{geom.Point{-inf, -inf, -inf}, geom.Point{inf, inf, inf}}
+}
+
+func (q *Quadric) Inside(p geom.Point, time float64) bool {
+	if q.Clip != nil && !q.Clip.Contains(&p) {
+		return false
+	}
+	return q.Quadric.Contains(&p)
+}
+
+func (q *Quadric) ColorAt(h *Hit) Color {
+	return q.Color
+}
+
+func (q *Quadric) MaterialAt(h *Hit) *Material {
+	return q.Material
+}
+
+func (q *Quadric) Clone() Object {
+	c := *q
+	if q.Clip != nil {
+		clip := *q.Clip
+		c.Clip = &clip
+	}
+	c.Material = q.Material.clone()
+	return &c
+}
+
+func (q *Quadric) Validate() error {
+	if err := q.Quadric.Validate(); err != nil {
+		return err
+	}
+	if q.Clip != nil && q.Clip.Empty() {
+		return fmt.Errorf("invalid quadric: empty clipping box")
+	}
+	if err := q.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid quadric: %v", err)
+	}
+	if q.Material != nil {
+		if err := q.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid quadric: %v", err)
+		}
+		if err := q.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid quadric: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+func TestQuadricIntersect(t *testing.T) {
+	// Infinite cylinder of radius 1 around the z-axis, clipped to z in [0..1].
+	q := &Quadric{
+		Quadric: geom.Quadric{A: 1, B: 1, J: -1},
+		Clip:    &geom.AABB{geom.Point{-2, -2, 0}, geom.Point{2, 2, 1}},
+	}
+	data := []struct {
+		r  geom.Ray
+		ok bool
+		p  geom.Point
+		n  geom.Vector
+	}{
+		{geom.MakeRay(geom.Point{5, 0, 0.5}, geom.Vector{-1, 0, 0}), true, geom.Point{1, 0, 0.5}, geom.Vector{1, 0, 0}},
+		{geom.MakeRay(geom.Point{0, 0, 0.5}, geom.Vector{0, 1, 0}), true, geom.Point{0, 1, 0.5}, geom.Vector{0, 1, 0}},
+		{geom.MakeRay(geom.Point{5, 0, 2}, geom.Vector{-1, 0, 0}), false, geom.Point{}, geom.Vector{}},
+		// Enters the box past the first crossing.
+		{geom.MakeRay(geom.Point{-1, 0, 2}, geom.Vector{2, 0, -1}), true, geom.Point{1, 0, 1}, geom.Vector{1, 0, 0}},
+	}
+	for i, d := range data {
+		h, ok := q.Intersect(d.r, 0)
+		if ok != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !geom.PointsEqual(h.Point, d.p, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.p, h.Point)
+		}
+		if n := q.NormalAt(&h, 0); !geom.VectorsEqual(n, d.n, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.n, n)
+		}
+	}
+}
@@ -28,11 +28,18 @@ Package raytracer implements a very simple ray tracer based the algorithms descr
 package raytracer
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/nthery/goraytracer/geom"
 	"image"
 	"image/color"
+	"log"
 	"math"
+	"math/rand"
+	"sort"
+	"sync"
 )
 
 // A Color is a red/green/blue triplet of color channels in [0..1] range
@@ -49,8 +56,34 @@ func (c *Color) Validate() error {
 
 // toRGBA converts to standard 32bpp.
 // The color.Color interface is not used for performance.
+// Channels are clamped to [0..1].
 func (c *Color) toRGBA() color.RGBA {
-	return color.RGBA{uint8(c.R * 255), uint8(c.G * 255), uint8(c.B * 255), 255}
+	return color.RGBA{toUint8(c.R), toUint8(c.G), toUint8(c.B), 255}
+}
+
+// toPremultipliedRGBA converts c, premultiplied by alpha a, to standard 32bpp
+// with an alpha channel.  Channels are clamped to [0..a].
+func (c *Color) toPremultipliedRGBA(a float64) color.RGBA {
+	a = math.Min(a, 1)
+	return color.RGBA{
+		toUint8(math.Min(c.R, a)),
+		toUint8(math.Min(c.G, a)),
+		toUint8(math.Min(c.B, a)),
+		toUint8(a),
+	}
+}
+
+// toUint8 converts color channel c to 8 bits, clamped to [0..1].  NaN maps
+// to 0 as float to integer conversions of negative and NaN values are
+// implementation-defined.
+func toUint8(c float64) uint8 {
+	if !(c > 0) {
+		return 0
+	}
+	if c > 1 {
+		c = 1
+	}
+	return uint8(c * 255)
 }
 
 func isColorChannelValid(c float64) bool {
@@ -60,8 +93,197 @@ func isColorChannelValid(c float64) bool {
 // Sphere objects are part of the scene to render.
 type Sphere struct {
 	// No embedding here for compatibility with json package
-	Sphere geom.Sphere
-	Color  Color
+	Sphere   geom.Sphere
+	Color    Color
+	Material *Material
+	Visibility
+
+	// By default, a ray originating inside a sphere hits its inner side
+	// which is shaded with a flipped normal.  CullBackface makes the inner
+	// side invisible instead.
+	CullBackface bool
+
+	// Motion is the displacement of the sphere center during the camera
+	// shutter interval.  Moving spheres are blurred when rendering several
+	// time samples per pixel (see Options.TimeSamples).
+	Motion geom.Vector
+
+	// Transform, when set, is applied to the sphere before Motion.  Non
+	// uniform scaling turns the sphere into an ellipsoid.
+	Transform *Transform
+
+	// Matrix of Transform and its inverse, cached by prepare.
+	xform, inv geom.Mat4
+	prepared   bool
+}
+
+// at returns the geometry of s at time t in [0..1] of the shutter interval,
+// ignoring s.Transform.
+func (s *Sphere) at(t float64) geom.Sphere {
+	g := s.Sphere
+	g.Center = g.Center.Translate(s.Motion.Scale(t))
+	return g
+}
+
+func (s *Sphere) prepare(protos ObjectMap) error {
+	if s.Material != nil {
+		if err := s.Material.prepare(); err != nil {
+			return fmt.Errorf("invalid sphere: %v", err)
+		}
+	}
+	if s.Transform != nil {
+		s.xform, s.inv = s.matrices()
+		s.prepared = true
+	}
+	return nil
+}
+
+// matrices returns the matrix of s.Transform and its inverse.
+func (s *Sphere) matrices() (m, inv geom.Mat4) {
+	if s.prepared {
+		return s.xform, s.inv
+	}
+	m = s.Transform.Matrix()
+	inv, _ = m.Inverse()
+	return m, inv
+}
+
+// toLocal returns the point of s.Sphere mapped to world point p at the given
+// time by s.Transform and s.Motion.
+func (s *Sphere) toLocal(p geom.Point, time float64) geom.Point {
+	_, inv := s.matrices()
+	return inv.TransformPoint(p.Translate(s.Motion.Scale(-time)))
+}
+
+// Intersect returns the nearest intersection between ray and s at the given
+// time, honoring backface culling.
+func (s *Sphere) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	if s.Transform != nil {
+		// Intersect the untransformed sphere with the ray expressed in
+		// its frame.  Parameters along both rays match.
+		_, inv := s.matrices()
+		local := ray
+		local.Origin = local.Origin.Translate(s.Motion.Scale(-time))
+		local = inv.TransformRay(local)
+		if s.CullBackface && s.Sphere.Contains(&local.Origin) {
+			return Hit{}, false
+		}
+		p, t, ok := geom.RaySphereIntersection(s.Sphere, local)
+		if !ok {
+			return Hit{}, false
+		}
+		h := Hit{T: t, Point: ray.At(t), Object: s}
+		if s.Material.textured() {
+			uv := s.Sphere.UVAt(&p)
+			h.U, h.V = uv.X, uv.Y
+		}
+		return h, true
+	}
+
+	g := s.at(time)
+	if s.CullBackface && g.Contains(&ray.Origin) {
+		return Hit{}, false
+	}
+	p, t, ok := geom.RaySphereIntersection(g, ray)
+	if !ok {
+		return Hit{}, false
+	}
+	h := Hit{T: t, Point: p, Object: s}
+	if s.Material.textured() {
+		uv := g.UVAt(&p)
+		h.U, h.V = uv.X, uv.Y
+	}
+	return h, true
+}
+
+// NormalAt returns the normal at h.  Normals of transformed spheres are
+// transformed by the inverse transpose of the transform matrix to remain
+// orthogonal to the surface.
+func (s *Sphere) NormalAt(h *Hit, time float64) geom.Vector {
+	if s.Transform != nil {
+		m, inv := s.matrices()
+		p := s.toLocal(h.Point, time)
+		n := s.Sphere.NormalVectorAt(&p)
+		invT := inv.Transpose()
+		n = invT.TransformVector(n)
+		n = n.UnitVector()
+		if s.Material == nil || s.Material.NormalMap == nil {
+			return n
+		}
+		return s.Material.normalAt(h, n, m.TransformVector(s.Sphere.TangentAt(&p)), s.uvSize())
+	}
+	g := s.at(time)
+	n := g.NormalVectorAt(&h.Point)
+	if s.Material == nil || s.Material.NormalMap == nil {
+		return n
+	}
+	return s.Material.normalAt(h, n, g.TangentAt(&h.Point), s.uvSize())
+}
+
+func (s *Sphere) Bounds() geom.AABB {
+	if s.Transform != nil {
+		m, _ := s.matrices()
+		b := transformBounds(&m, sphereBounds(s.Sphere))
+		return b.Union(geom.AABB{b.Min.Translate(s.Motion), b.Max.Translate(s.Motion)})
+	}
+	b := sphereBounds(s.at(0))
+	return b.Union(sphereBounds(s.at(1)))
+}
+
+func sphereBounds(g geom.Sphere) geom.AABB {
+	r := geom.Vector{g.Radius, g.Radius, g.Radius}
+	return geom.AABB{g.Center.Translate(r.Neg()), g.Center.Translate(r)}
+}
+
+func (s *Sphere) Inside(p geom.Point, time float64) bool {
+	if s.CullBackface {
+		return false
+	}
+	if s.Transform != nil {
+		p = s.toLocal(p, time)
+		return s.Sphere.Contains(&p)
+	}
+	g := s.at(time)
+	return g.Contains(&p)
+}
+
+func (s *Sphere) ColorAt(h *Hit) Color {
+	return s.Material.colorAt(h, s.Color, s.uvSize())
+}
+
+// uvSize returns the length spanned on s by a unit of texture coordinates,
+// ignoring s.Transform.
+func (s *Sphere) uvSize() float64 {
+	return 2 * math.Pi * s.Sphere.Radius
+}
+
+func (s *Sphere) MaterialAt(h *Hit) *Material {
+	return s.Material
+}
+
+// surfaceArea returns the area of s, zero for transformed spheres that can not
+// be sampled.
+func (s *Sphere) surfaceArea() float64 {
+	if s.Transform != nil {
+		return 0
+	}
+	return 4 * math.Pi * s.Sphere.Radius * s.Sphere.Radius
+}
+
+func (s *Sphere) sampleSurface(time float64, rng *rand.Rand) (geom.Point, geom.Vector) {
+	g := s.at(time)
+	n := geom.UniformSampleSphere(rng)
+	return g.Center.Translate(n.Scale(g.Radius)), n
+}
+
+func (s *Sphere) Clone() Object {
+	c := *s
+	if s.Transform != nil {
+		tr := *s.Transform
+		c.Transform = &tr
+	}
+	c.Material = s.Material.clone()
+	return &c
 }
 
 func (s *Sphere) Validate() error {
@@ -71,53 +293,480 @@ func (s *Sphere) Validate() error {
 	if err := s.Color.Validate(); err != nil {
 		return fmt.Errorf("invalid sphere: %v", err)
 	}
+	if s.Material != nil {
+		if err := s.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid sphere: %v", err)
+		}
+	}
+	if s.Transform != nil {
+		if err := s.Transform.Validate(); err != nil {
+			return fmt.Errorf("invalid sphere: %v", err)
+		}
+	}
 	return nil
 }
 
 // A Frustum is a pyramidal viewing frustum orthogonal to the z-axis.  The
 // rendered scene is projected onto the near plane.  The size ratio between the
 // near and far planes determines the field of view.
+//
+// Alternatively, when FOV is set, the planes are derived from the vertical
+// field of view FOV in degrees of an eye at the origin looking along +z, the
+// aspect ratio Aspect of images, width over height, zero meaning 1, and the
+// distance NearDist of the near plane.  Pixels being one unit wide on the near
+// plane, the further the plane, the larger images.  The far plane lies at
+// distance FarDist, zero meaning twice NearDist.
 type Frustum struct {
 	Near, Far geom.Plane2d // near and far viewing planes
+
+	FOV, Aspect       float64
+	NearDist, FarDist float64
+
+	// ChromaticAberration simulates a lens refracting color channels
+	// differently.  The red and blue channels are magnified by respectively
+	// 1+ChromaticAberration and 1-ChromaticAberration relative to the green
+	// one.  Zero disables the effect.
+	ChromaticAberration float64
 }
 
 func (f *Frustum) Validate() error {
-	if err := f.Near.Validate(); err != nil {
+	if f.FOV != 0 {
+		if f.FOV < 0 || f.FOV >= 180 {
+			return fmt.Errorf("invalid frustum field of view: %v", f.FOV)
+		}
+		if f.Aspect < 0 {
+			return fmt.Errorf("invalid frustum aspect ratio: %v", f.Aspect)
+		}
+		if f.NearDist <= 0 {
+			return fmt.Errorf("invalid frustum near distance: %v", f.NearDist)
+		}
+		if f.FarDist != 0 && f.FarDist <= f.NearDist {
+			return fmt.Errorf("invalid frustum far distance: %v", f.FarDist)
+		}
+	}
+	near, far := f.planes()
+	if err := near.Validate(); err != nil {
 		return fmt.Errorf("invalid near frustum plane: %v", err)
 	}
-	if err := f.Far.Validate(); err != nil {
+	if err := far.Validate(); err != nil {
 		return fmt.Errorf("invalid far frustum plane: %v", err)
 	}
+	if math.Abs(f.ChromaticAberration) >= 1 {
+		return fmt.Errorf("invalid chromatic aberration: %v", f.ChromaticAberration)
+	}
 	return nil
 }
 
+// planes returns the near and far planes of f, derived from its field of view
+// if set.
+func (f *Frustum) planes() (near, far geom.Plane2d) {
+	if f.FOV == 0 {
+		return f.Near, f.Far
+	}
+	aspect := f.Aspect
+	if aspect == 0 {
+		aspect = 1
+	}
+	fd := f.FarDist
+	if fd == 0 {
+		fd = 2 * f.NearDist
+	}
+	plane := func(z float64) geom.Plane2d {
+		h := z * math.Tan(f.FOV*math.Pi/360)
+		w := h * aspect
+		return geom.Plane2d{geom.Point2d{-w, h}, geom.Point2d{w, -h}, z}
+	}
+	return plane(f.NearDist), plane(fd)
+}
+
+// prepare derives the planes of f from its field of view if set.
+func (f *Frustum) prepare() {
+	f.Near, f.Far = f.planes()
+}
+
+func (f *Frustum) size() (w, h int) {
+	return int(math.Round(f.Near.Dx())), int(math.Round(f.Near.Dy()))
+}
+
+// pixel samples the top-left corners of pixels.
+func (f *Frustum) pixel(px, py int) (x, y float64) {
+	return float64(px) + f.Near.Tl.X, -f.Near.Br.Y - float64(py)
+}
+
+// ray returns the ray from point (x, y) of the near plane to the matching
+// point of the far one.
+func (f *Frustum) ray(x, y, time float64) viewRay {
+	xfar := x * f.Far.Dx() / f.Near.Dx()
+	yfar := y * f.Far.Dy() / f.Near.Dy()
+	near := geom.Point{x, y, f.Near.Z}
+	far := geom.Point{xfar, yfar, f.Far.Z}
+	// Pixels are one unit wide on the near plane and widen linearly up to
+	// the far one.
+	return viewRay{
+		Ray:       geom.MakeRay(near, geom.MakeVector(far, near)),
+		footprint: [2]float64{1, f.Far.Dx()/f.Near.Dx() - 1},
+		backdrop:  1,
+	}
+}
+
+func (f *Frustum) chromaticAberration() float64 {
+	return f.ChromaticAberration
+}
+
+func (f *Frustum) magnify(x, y, k float64) (mx, my float64) {
+	return x * k, y * k
+}
+
 // The Scene to render.
 type Scene struct {
 	ViewFrustum Frustum
-	Light       geom.Point // coordinate of light source
-	Objects     []Sphere   // objects to render
+	Camera      *Camera    // views the scene instead of ViewFrustum when set
+	Lights      []Light    // light sources
+	Objects     ObjectList // objects to render
+	Prototypes  ObjectMap  // shared objects referenced by Instance objects
 	Bg          Color      // background color
-	Kd          float64    // diffuse coefficient
+	Kd          float64    // diffuse coefficient of objects without material
+
+	// Ambient is the color of the light reaching objects from all
+	// directions, scaled by the ambient coefficient of their material.  When
+	// nil, objects without material or with a PBR model receive gray ambient
+	// light of intensity 1 - Kd and others white light.  Setting it decouples
+	// ambient light from Kd, which then only scales diffuse light.
+	Ambient *Color
+
+	// LightFlux is the luminous flux of light sources in lumens.  When
+	// set, lights are isotropic point lights whose illuminance falls off
+	// with the square of the distance, scene units being meters.  When zero,
+	// the legacy unit-less model is used.  Only lumens are supported: the
+	// flux of a bulb rated in watts depends on its efficacy and must be
+	// converted first.
+	LightFlux float64
+
+	// Exposure is the camera exposure value at ISO 100 (EV100) used to map
+	// luminances to pixel values when LightFlux is set.  Each EV step halves
+	// the pixel values.  Ambient light, being unit-less, is that seen at EV
+	// 0 and is scaled alike so that its ratio to direct light does not
+	// depend on exposure.
+	Exposure float64
+
+	// MaxDepth is the maximum number of bounces of rays reflected or
+	// refracted by objects.  Zero selects defaultMaxDepth.
+	MaxDepth int
+
+	// Materials holds named materials that objects of JSON scenes may use
+	// instead of defining their own (see UnmarshalJSON).
+	Materials map[string]*Material
+
+	// Environment, when set, is seen by rays missing all objects instead of
+	// Bg.  Unlike Bg, it is not darkened by shadows.
+	Environment *Environment
+
+	// Shader computes the color of objects, nil selecting DefaultShader.
+	Shader Shader `json:"-"`
+
+	// ShadowBias is the distance off surfaces at which shadow rays start,
+	// zero selecting a tiny distance relative to the magnitude of
+	// coordinates.  Increase it if surfaces shadow themselves in speckles
+	// ("shadow acne") and decrease it if shadows detach from the objects
+	// casting them ("peter-panning").
+	ShadowBias float64
+
+	// EmitterSamples is the number of points sampled on each emissive object
+	// to compute the light it casts on a surface point, zero selecting
+	// defaultLightSamples.
+	EmitterSamples int
+
+	bounds   []geom.AABB // bounds of Objects, cached by prepare
+	emitters []Object    // emissive surface samplers, cached by prepare
+	view     view        // Camera or ViewFrustum, set by prepare
+}
+
+// UnmarshalJSON decodes s from JSON.  The material of objects can be the name
+// of an entry of Materials which stands for its definition, so that objects
+// share materials without repeating them.  Shader is decoded like objects,
+// its "Type" field naming a type registered with RegisterShaderType.  The
+// "Light" point of scenes predating Lights is decoded as their single light.
+func (s *Scene) UnmarshalJSON(data []byte) error {
+	type plainScene Scene // without this method to avoid recursion
+
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	var shader Shader
+	if v, ok := raw["Shader"]; ok {
+		delete(raw, "Shader")
+		var err error
+		if shader, err = decodeShader(v); err != nil {
+			return fmt.Errorf("invalid scene shader: %v", err)
+		}
+	}
+	if v, ok := raw["Light"]; ok {
+		if _, ok := raw["Lights"]; ok {
+			return fmt.Errorf("invalid scene: both Light and Lights set")
+		}
+		delete(raw, "Light")
+		raw["Lights"] = []interface{}{map[string]interface{}{"Position": v}}
+	}
+	if lib, ok := raw["Materials"].(map[string]interface{}); ok {
+		for _, k := range []string{"Objects", "Prototypes"} {
+			if err := resolveMaterials(raw[k], lib); err != nil {
+				return fmt.Errorf("invalid scene: %v", err)
+			}
+		}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*plainScene)(s)); err != nil {
+		return err
+	}
+	if shader != nil {
+		s.Shader = shader
+	}
+	return nil
+}
+
+// Clone returns a deep copy of s.
+func (s *Scene) Clone() *Scene {
+	c := *s
+	c.Lights = append([]Light(nil), s.Lights...)
+	c.Objects = make(ObjectList, len(s.Objects))
+	for i, o := range s.Objects {
+		c.Objects[i] = o.Clone()
+	}
+	if s.Prototypes != nil {
+		c.Prototypes = make(ObjectMap, len(s.Prototypes))
+		for name, o := range s.Prototypes {
+			c.Prototypes[name] = o.Clone()
+		}
+	}
+	if s.Materials != nil {
+		c.Materials = make(map[string]*Material, len(s.Materials))
+		for name, m := range s.Materials {
+			c.Materials[name] = m.clone()
+		}
+	}
+	if s.Ambient != nil {
+		a := *s.Ambient
+		c.Ambient = &a
+	}
+	c.Environment = s.Environment.clone()
+	c.Camera = s.Camera.clone()
+	return &c
 }
 
 func (s *Scene) Validate() error {
+	for i := range s.Lights {
+		if err := s.Lights[i].Validate(); err != nil {
+			return fmt.Errorf("invalid scene light: %v", err)
+		}
+	}
 	for _, o := range s.Objects {
 		if err := o.Validate(); err != nil {
 			return fmt.Errorf("invalid scene object: %v", err)
 		}
 	}
+	for name, o := range s.Prototypes {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("invalid scene prototype %q: %v", name, err)
+		}
+	}
+	for name, m := range s.Materials {
+		if m == nil {
+			return fmt.Errorf("invalid scene material %q: null material", name)
+		}
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("invalid scene material %q: %v", name, err)
+		}
+	}
+	if v, ok := s.Shader.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid scene shader: %v", err)
+		}
+	}
 	if err := s.Bg.Validate(); err != nil {
 		return fmt.Errorf("invalid scene background: %v", err)
 	}
+	if s.Ambient != nil {
+		if err := s.Ambient.Validate(); err != nil {
+			return fmt.Errorf("invalid scene ambient: %v", err)
+		}
+	}
+	if s.Environment != nil {
+		if err := s.Environment.Validate(); err != nil {
+			return fmt.Errorf("invalid scene environment: %v", err)
+		}
+	}
 	if s.Kd < 0 || s.Kd > 1 {
 		return fmt.Errorf("invalid scene diffuse coefficient: %v", s.Kd)
 	}
-	if err := s.ViewFrustum.Validate(); err != nil {
+	if s.Camera != nil {
+		if err := s.Camera.Validate(); err != nil {
+			return err
+		}
+	} else if err := s.ViewFrustum.Validate(); err != nil {
 		return fmt.Errorf("invalid scene frustum: %v", err)
 	}
+	if s.LightFlux < 0 {
+		return fmt.Errorf("invalid scene light flux: %v", s.LightFlux)
+	}
+	if s.MaxDepth < 0 {
+		return fmt.Errorf("invalid scene maximum depth: %v", s.MaxDepth)
+	}
+	if s.ShadowBias < 0 {
+		return fmt.Errorf("invalid scene shadow bias: %v", s.ShadowBias)
+	}
+	if s.EmitterSamples < 0 {
+		return fmt.Errorf("invalid scene emitter samples: %v", s.EmitterSamples)
+	}
 	return nil
 }
 
+// defaultMaxDepth is the maximum number of ray bounces when Scene.MaxDepth is
+// zero.
+const defaultMaxDepth = 5
+
+func (s *Scene) maxDepth() int {
+	if s.MaxDepth == 0 {
+		return defaultMaxDepth
+	}
+	return s.MaxDepth
+}
+
+// prepare lets objects precompute data once validated and links instances to
+// their prototypes.  Objects are prepared concurrently by nworkers goroutines
+// reporting to progress, possibly nil, as described in
+// Options.PrepareProgress.  It must only be called on private copies of the
+// scene as it modifies objects.
+func (s *Scene) prepare(nworkers int, progress func(done, total int)) error {
+	names := make([]string, 0, len(s.Prototypes))
+	for name := range s.Prototypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	protos := make([]Object, len(names))
+	for i, name := range names {
+		protos[i] = s.Prototypes[name]
+	}
+
+	var mu sync.Mutex
+	ndone, total := 0, len(protos)+len(s.Objects)
+	step := func() {
+		if progress != nil {
+			mu.Lock()
+			ndone++
+			progress(ndone, total)
+			mu.Unlock()
+		}
+	}
+
+	// Prototypes can not refer to other prototypes, which rules out cycles.
+	errs := prepareObjects(protos, nil, nworkers, func(int) { step() })
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("invalid scene prototype %q: %v", names[i], err)
+		}
+	}
+	s.bounds = make([]geom.AABB, len(s.Objects))
+	errs = prepareObjects(s.Objects, s.Prototypes, nworkers, func(i int) {
+		s.bounds[i] = padBounds(s.Objects[i].Bounds())
+		step()
+	})
+	for _, err := range errs {
+		if err != nil {
+			return fmt.Errorf("invalid scene object: %v", err)
+		}
+	}
+	if s.Environment != nil {
+		if err := s.Environment.prepare(); err != nil {
+			return fmt.Errorf("invalid scene environment: %v", err)
+		}
+		if l, ok := s.Environment.sunLight(); ok {
+			s.Lights = append(s.Lights, l)
+		}
+	}
+	for i := range s.Lights {
+		if err := s.Lights[i].prepare(); err != nil {
+			return fmt.Errorf("invalid scene light: %v", err)
+		}
+	}
+	if s.Camera != nil {
+		s.Camera.prepare()
+		s.view = s.Camera
+	} else {
+		s.ViewFrustum.prepare()
+		s.view = &s.ViewFrustum
+	}
+	s.emitters = nil
+	for _, o := range s.Objects {
+		if isEmitter(o) {
+			s.emitters = append(s.emitters, o)
+		}
+	}
+	return nil
+}
+
+// prepareObjects prepares objs with nworkers goroutines as decoding textures
+// and computing normals of large meshes is slow, and returns the error of
+// each object.  done is called from the preparing goroutine with the index of
+// each successfully prepared object.
+func prepareObjects(objs []Object, protos ObjectMap, nworkers int, done func(i int)) []error {
+	errs := make([]error, len(objs))
+	work := make(chan int, len(objs))
+	for i := range objs {
+		work <- i
+	}
+	close(work)
+	var wg sync.WaitGroup
+	for n := 0; n < nworkers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if p, ok := objs[i].(preparer); ok {
+					if errs[i] = p.prepare(protos); errs[i] != nil {
+						continue
+					}
+				}
+				done(i)
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// padBounds returns b slightly enlarged so that rounding errors do not make
+// rays grazing an object miss its bounds.
+func padBounds(b geom.AABB) geom.AABB {
+	if b.Empty() {
+		return b
+	}
+	pad := func(x float64) float64 {
+		return 1e-9 * math.Max(1, math.Abs(x))
+	}
+	return geom.AABB{
+		geom.Point{b.Min.X - pad(b.Min.X), b.Min.Y - pad(b.Min.Y), b.Min.Z - pad(b.Min.Z)},
+		geom.Point{b.Max.X + pad(b.Max.X), b.Max.Y + pad(b.Max.Y), b.Max.Z + pad(b.Max.Z)},
+	}
+}
+
+// mayHit returns whether ray may intersect the i-th scene object according to
+// its cached bounds.  This cheap test spares intersecting most objects missed
+// by the ray.
+func (s *Scene) mayHit(i int, ray *geom.Ray) bool {
+	if i >= len(s.bounds) {
+		// Scene not prepared.
+		return true
+	}
+	_, _, ok := geom.RayAABBIntersection(s.bounds[i], *ray)
+	return ok
+}
+
 // diffuseShading computes a color channel value taking into account the
 // diffuse and ambiant coefficients and the angle between the pixel and light
 // source.
@@ -126,103 +775,830 @@ func diffuseShading(factor, kd float64, channel float64) float64 {
 	return factor*kd*channel + factor*ka
 }
 
-// rayHitsObject returns whether the ray intersects one object in the scene.
-func (s *Scene) rayHitsObject(ray geom.Line) bool {
-	for i := range s.Objects {
-		_, _, ok := geom.SphereLineIntersection(s.Objects[i].Sphere, ray)
-		if ok {
-			return true
+// transmittance returns the fraction of light of each color channel crossing
+// the objects casting shadows along ray at the given time, skip excepted.
+// Each object lets through the fraction of light its opacity does not stop
+// where the ray first hits it, all of it for shadow catchers.  Transparent
+// materials also let through their transparency, filtered by their
+// transmission color, which tints shadows.  Refraction is ignored.
+func (s *Scene) transmittance(ray geom.Ray, time float64, skip Object) Color {
+	f := Color{1, 1, 1}
+	for i, o := range s.Objects {
+		if o == skip || !hitBy(o, shadowRay) || !s.mayHit(i, &ray) {
+			continue
 		}
-	}
-	return false
-}
-
-// castRay finds the nearest intersection point between the ray and the scene
-// objects.  On return, obj is nil if there is no intersection.
-func (s *Scene) castRay(ray geom.Line) (obj *Sphere, intersection geom.Point) {
-	var pmin geom.Point
-	tmin := math.MaxFloat64
-	imin := -1
-	for i := range s.Objects {
-		p, t, ok := geom.SphereLineIntersection(s.Objects[i].Sphere, ray)
-		if ok {
-			if t < tmin {
-				tmin = t
-				pmin = p
-				imin = i
+		h, ok := intersect(o, ray, time, shadowRay)
+		if !ok {
+			continue
+		}
+		m := o.MaterialAt(&h)
+		if m.shadowCatcher() {
+			continue
+		}
+		a := m.opacity()
+		var kt float64
+		tc := Color{1, 1, 1}
+		if m != nil {
+			kt = m.Transparency
+			if m.Transmission != nil {
+				tc = *m.Transmission
 			}
 		}
+		f.R *= 1 - a + a*kt*tc.R
+		f.G *= 1 - a + a*kt*tc.G
+		f.B *= 1 - a + a*kt*tc.B
+		if f == (Color{}) {
+			break
+		}
 	}
+	return f
+}
 
-	if imin == -1 {
-		return nil, geom.Origin
+// castRay finds the nearest intersection between the ray and the scene objects
+// hit by rays of the given kind at the given time.  ok is false if there is no
+// intersection.
+func (s *Scene) castRay(ray geom.Ray, time float64, kind rayKind) (h Hit, ok bool) {
+	h.T = math.MaxFloat64
+	for i, o := range s.Objects {
+		if !hitBy(o, kind) || !s.mayHit(i, &ray) {
+			continue
+		}
+		if oh, hit := intersect(o, ray, time, kind); hit && oh.T < h.T {
+			h = oh
+			ok = true
+		}
 	}
-
-	return &s.Objects[imin], pmin
+	return h, ok
 }
 
-func (s *Scene) computeObjectColorAt(obj *Sphere, p geom.Point) Color {
-	normal := obj.Sphere.NormalVectorAt(&p)
-	light := geom.MakeVector(s.Light, p)
-	light = light.UnitVector()
+// computeObjectColorAt shades intersection h of ray at the given time as lit
+// by light l.  When ray hits the back side of the surface, such as the inner
+// side of an object seen from inside, that side is shaded.
+func (s *Scene) computeObjectColorAt(h *Hit, ray *geom.Ray, time float64, l *Light) Color {
+	obj, p := h.Object, h.Point
+	normal, _ := facingNormal(h, ray, time)
+	light, d2 := l.toward(p)
 	dot := geom.DotProduct(&light, &normal)
+	if dot < 0 && obj.MaterialAt(h).twoSided() {
+		// The light is behind the side seen, which is lit nonetheless.
+		normal = normal.Neg()
+		dot = -dot
+	}
+	cos := dot
 	if dot < 0 {
 		dot = 0
 	}
-	r := diffuseShading(dot, s.Kd, obj.Color.R)
-	g := diffuseShading(dot, s.Kd, obj.Color.G)
-	b := diffuseShading(dot, s.Kd, obj.Color.B)
+	col := obj.ColorAt(h)
+	if m := obj.MaterialAt(h); m != nil {
+		view := ray.Dir.Neg()
+		view = view.UnitVector()
+		half := light.Add(view)
+		half = half.UnitVector()
+		k := l.emission(p)
+		if s.LightFlux > 0 {
+			k *= s.luminance(1, d2)
+		}
+		if p := m.PBR; p != nil {
+			var tdoth, bdoth float64
+			if p.RoughnessV != 0 {
+				t, b := p.tangentFrame(normal)
+				tdoth, bdoth = geom.DotProduct(&half, &t), geom.DotProduct(&half, &b)
+			}
+			return p.shade(col, s.ambientLight(m), dot, geom.DotProduct(&view, &normal),
+				geom.DotProduct(&half, &normal), geom.DotProduct(&half, &view), tdoth, bdoth, k)
+		}
+		c := m.shade(col, s.ambientLight(m), dot, geom.DotProduct(&half, &normal), k)
+		if m.Subsurface > 0 {
+			sc := m.scatter(col, cos, k)
+			c = Color{c.R + sc.R, c.G + sc.G, c.B + sc.B}
+		}
+		return c
+	}
+	dot *= l.emission(p)
+	if s.LightFlux > 0 {
+		return s.physicalShading(col, dot, d2)
+	}
+	if a := s.Ambient; a != nil {
+		return Color{
+			(a.R + s.Kd*dot) * col.R,
+			(a.G + s.Kd*dot) * col.G,
+			(a.B + s.Kd*dot) * col.B,
+		}
+	}
+	r := diffuseShading(dot, s.Kd, col.R)
+	g := diffuseShading(dot, s.Kd, col.G)
+	b := diffuseShading(dot, s.Kd, col.B)
 
 	return Color{r, g, b}
 }
 
+// environmentLight returns the light of the scene environment diffusely
+// reflected at intersection h of ray.
+func (s *Scene) environmentLight(h *Hit, ray *geom.Ray, time float64) Color {
+	normal, _ := facingNormal(h, ray, time)
+	e := s.Environment.irradianceAt(normal)
+	r := s.diffuseReflectance(h)
+	return Color{e.R * r.R, e.G * r.G, e.B * r.B}
+}
+
+// diffuseReflectance returns the fraction of light diffusely reflected at
+// intersection h.
+func (s *Scene) diffuseReflectance(h *Hit) Color {
+	col := h.Object.ColorAt(h)
+	kd := s.Kd
+	if m := h.Object.MaterialAt(h); m != nil {
+		if m.PBR != nil {
+			col, kd = m.PBR.baseColor(col), 1-m.PBR.Metallic
+		} else {
+			kd = m.Diffuse
+		}
+	}
+	return Color{kd * col.R, kd * col.G, kd * col.B}
+}
+
+// physicalShading computes the color of a lambertian surface of color col lit
+// by a point light of flux s.LightFlux at squared distance d2.  dot is the
+// cosine of the angle of incidence.
+func (s *Scene) physicalShading(col Color, dot, d2 float64) Color {
+	l := s.luminance(dot, d2)
+	ka := s.ambientLight(nil)
+	return Color{
+		s.Kd*l*col.R + ka.R*col.R,
+		s.Kd*l*col.G + ka.G*col.G,
+		s.Kd*l*col.B + ka.B*col.B,
+	}
+}
+
+// luminance returns the luminance of a perfect diffuser lit by a point light
+// of flux s.LightFlux at squared distance d2, scaled by the standard exposure
+// formula for a camera with ISO 100 film/sensor.  dot is the cosine of the
+// angle of incidence.
+func (s *Scene) luminance(dot, d2 float64) float64 {
+	intensity := s.LightFlux / (4 * math.Pi) // candelas
+	illuminance := intensity * dot / d2      // luxes
+	return illuminance / math.Pi / 1.2 * s.exposure()
+}
+
+// exposure returns the factor by which the exposure of the camera scales
+// pixel values relative to EV 0, 1 when lights are unit-less.
+func (s *Scene) exposure() float64 {
+	if s.LightFlux == 0 {
+		return 1
+	}
+	return math.Exp2(-s.Exposure)
+}
+
+// ambientColor returns the color of intersection h when no scene light
+// reaches it.
+func (s *Scene) ambientColor(h *Hit) Color {
+	col := h.Object.ColorAt(h)
+	m := h.Object.MaterialAt(h)
+	if m != nil && m.PBR != nil {
+		return m.PBR.ambient(m.PBR.baseColor(col), s.ambientLight(m))
+	}
+	ka := s.ambientFactor(m)
+	return Color{ka.R * col.R, ka.G * col.G, ka.B * col.B}
+}
+
+// ambientLight returns the ambient light reaching objects of material m,
+// possibly nil, before their ambient coefficient applies, as seen with the
+// exposure of the camera.
+func (s *Scene) ambientLight(m *Material) Color {
+	var a Color
+	switch {
+	case s.Ambient != nil:
+		a = *s.Ambient
+	case m != nil && m.PBR == nil:
+		a = Color{1, 1, 1}
+	default:
+		ka := 1 - s.Kd
+		a = Color{ka, ka, ka}
+	}
+	e := s.exposure()
+	return Color{e * a.R, e * a.G, e * a.B}
+}
+
+// ambientFactor returns the fraction of the color of objects of material m,
+// possibly nil, seen under ambient light.
+func (s *Scene) ambientFactor(m *Material) Color {
+	a := s.ambientLight(m)
+	if m != nil && m.PBR == nil {
+		return Color{m.Ambient * a.R, m.Ambient * a.G, m.Ambient * a.B}
+	}
+	return a
+}
+
 func bgShadowPixel(c Color) Color {
 	return Color{c.R / 2, c.G / 2, c.B / 2}
 }
 
-func (s *Scene) renderPixel(x, y float64) color.RGBA {
-	xfar := x * s.ViewFrustum.Far.Dx() / s.ViewFrustum.Near.Dx()
-	yfar := y * s.ViewFrustum.Far.Dy() / s.ViewFrustum.Near.Dx()
-	ray := geom.Line{
-		geom.Point{x, y, s.ViewFrustum.Near.Z},
-		geom.Point{xfar, yfar, s.ViewFrustum.Far.Z},
+// DebugColor is the color of pixels whose computation produced NaN or
+// infinite values when Options.CheckNaN is set.
+var DebugColor = Color{1, 0, 1}
+
+// mean returns the average of the channels of c.
+func (c *Color) mean() float64 {
+	return (c.R + c.G + c.B) / 3
+}
+
+// isFinite returns whether all channels are neither NaN nor infinite.
+func (c *Color) isFinite() bool {
+	return isFinite(c.R) && isFinite(c.G) && isFinite(c.B)
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+func isFinitePoint(p *geom.Point) bool {
+	return isFinite(p.X) && isFinite(p.Y) && isFinite(p.Z)
+}
+
+// Options controls how a scene is rendered.
+type Options struct {
+	Stripes int // # of horizontal stripes or goroutines processing tiles
+
+	// TileSize is the width and height in pixels of the square tiles the
+	// image is split into.  Tiles are dispatched to Stripes goroutines.  When
+	// zero, the image is split into Stripes horizontal stripes.
+	TileSize int
+
+	TileOrder TileOrder // order in which tiles are rendered
+
+	// TimeSamples is the # of rays traced per pixel at random times over
+	// the shutter interval, one in each of TimeSamples equal parts of it.
+	// Values above one blur moving objects, and the camera if it moves.  A
+	// single sample sees the scene when the shutter opens.
+	TimeSamples int
+
+	// MaxSamples, when above TimeSamples, enables adaptive sampling: pixels
+	// whose samples disagree, such as those seeing soft shadows of area
+	// lights and emissive objects, glossy reflections or motion blur, are
+	// refined with extra samples until their noise falls below
+	// NoiseThreshold or MaxSamples samples are traced.  All samples of a
+	// pixel go through the same point so edges are not antialiased.  Converged pixels stop after TimeSamples samples,
+	// at least two.  Extra samples are taken at random times over the
+	// shutter interval when TimeSamples is above one, when it opens
+	// otherwise.
+	MaxSamples int
+
+	// NoiseThreshold is the standard error of the mean of the samples of a
+	// pixel, in color channel units, below which adaptive sampling stops,
+	// zero meaning defaultNoiseThreshold.
+	NoiseThreshold float64
+
+	// Frame is the index of the animation frame to render, which positions
+	// cameras with keys (see Camera.Keys).
+	Frame int
+
+	// Focus is an image region rendered before the rest of the image.  It is
+	// most useful with small tiles and TileDone to preview the most
+	// interesting part of the image early.
+	Focus image.Rectangle
+
+	// TileDone, when set, is called with the image being rendered and the
+	// bounds of each tile as soon as it is rendered.  Calls are serialized
+	// but happen while other tiles are being rendered so only pixels inside
+	// the passed bounds should be accessed.
+	TileDone func(img *image.RGBA, r image.Rectangle)
+
+	// PrepareProgress, when set, is called as each prototype and object is
+	// prepared for rendering, before the first tile, with the number of
+	// them prepared so far and their total.  Objects are prepared by
+	// Stripes goroutines and calls are serialized.
+	PrepareProgress func(done, total int)
+
+	// CheckNaN enables checking of intersections and shading results for
+	// NaN and infinite values.  Offending pixels are painted with DebugColor
+	// and reported to Log.
+	CheckNaN bool
+
+	// Alpha renders pixels seeing the background transparent in the alpha
+	// channel of the image, and those seeing shadow catchers as black with
+	// the opacity of the shadows they receive, for compositing onto other
+	// images.  Colors are premultiplied by alpha.
+	Alpha bool
+
+	Log *log.Logger // destination of diagnostics, standard logger if nil
+
+	// Cancel, when closed, stops rendering early: goroutines finish the
+	// row of pixels they are rendering and RenderWithOptions returns
+	// ErrCancelled.
+	Cancel <-chan struct{}
+}
+
+// ErrCancelled is returned by RenderWithOptions when Options.Cancel is
+// closed during rendering.
+var ErrCancelled = errors.New("rendering cancelled")
+
+// cancelled returns whether o.Cancel is closed.
+func (o *Options) cancelled() bool {
+	select {
+	case <-o.Cancel:
+		return true
+	default:
+		return false
 	}
+}
+
+func (o *Options) logf(format string, v ...interface{}) {
+	if o.Log != nil {
+		o.Log.Printf(format, v...)
+	} else {
+		log.Printf(format, v...)
+	}
+}
 
-	obj, intersection := s.castRay(ray)
+// renderPixel computes the color of image pixel (px, py).
+func (s *Scene) renderPixel(px, py int, o *Options) color.RGBA {
+	x, y := s.view.pixel(px, py)
 
-	var c Color
-	if obj != nil {
-		// Is intersection shadowed by another object?
-		sray := geom.Line{s.Light, intersection}
-		other, _ := s.castRay(sray)
-		if other != nil && other != obj {
-			c = Color{
-				(1 - s.Kd) * obj.Color.R,
-				(1 - s.Kd) * obj.Color.G,
-				(1 - s.Kd) * obj.Color.B,
+	nsamples := o.TimeSamples
+	if nsamples < 1 {
+		nsamples = 1
+	}
+	adaptive := o.MaxSamples > nsamples
+	ninitial := nsamples
+	if adaptive && ninitial < 2 {
+		ninitial = 2
+	}
+	rng := pixelRand(px, py)
+	var sum, sumSq Color
+	var sumAlpha float64
+	n := 0
+	for ; n < ninitial || adaptive && n < o.MaxSamples && sampleNoise(sum, sumSq, n) > o.noiseThreshold(); n++ {
+		time := 0.0
+		if nsamples > 1 {
+			if n < nsamples {
+				time = (float64(n) + rng.Float64()) / float64(nsamples)
+			} else {
+				time = rng.Float64()
 			}
-		} else {
-			c = s.computeObjectColorAt(obj, intersection)
 		}
-	} else {
-		sray := geom.Line{
-			geom.Point{xfar, yfar, s.ViewFrustum.Far.Z},
-			s.Light,
+		c, alpha, ok := s.samplePixel(x, y, time, rng, px, py, o)
+		if !ok {
+			return DebugColor.toRGBA()
+		}
+		sum.R += c.R
+		sum.G += c.G
+		sum.B += c.B
+		sumSq.R += c.R * c.R
+		sumSq.G += c.G * c.G
+		sumSq.B += c.B * c.B
+		sumAlpha += alpha
+	}
+	k := float64(n)
+	c := Color{sum.R / k, sum.G / k, sum.B / k}
+
+	if o.Alpha {
+		return c.toPremultipliedRGBA(sumAlpha / k)
+	}
+	return c.toRGBA()
+}
+
+// defaultNoiseThreshold is the noise below which adaptive sampling stops
+// when Options.NoiseThreshold is zero, about half a level of 8-bit color
+// channels.
+const defaultNoiseThreshold = 0.002
+
+func (o *Options) noiseThreshold() float64 {
+	if o.NoiseThreshold == 0 {
+		return defaultNoiseThreshold
+	}
+	return o.NoiseThreshold
+}
+
+// sampleNoise returns the largest standard error of the mean of n samples
+// over color channels, given the sums of the samples and of their squares.
+func sampleNoise(sum, sumSq Color, n int) float64 {
+	k := float64(n)
+	noise := 0.0
+	for _, ch := range [][2]float64{{sum.R, sumSq.R}, {sum.G, sumSq.G}, {sum.B, sumSq.B}} {
+		// Unbiased variance of the samples, clamped against rounding.
+		v := math.Max(0, (ch[1]-ch[0]*ch[0]/k)/(k-1))
+		noise = math.Max(noise, math.Sqrt(v/k))
+	}
+	return noise
+}
+
+// A pixelSource is a SplitMix64 pseudo-random number generator.  It is small
+// and fast to seed so that each pixel can get its own and be rendered the
+// same whatever the order and concurrency of rendering.
+//
+// Algorithm taken from:
+// 	Steele et al., "Fast Splittable Pseudorandom Number Generators",
+// 	OOPSLA 2014.
+type pixelSource uint64
+
+func (s *pixelSource) Uint64() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *pixelSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *pixelSource) Seed(seed int64) {
+	*s = pixelSource(seed)
+}
+
+// pixelRand returns the random number generator of pixel (px, py).
+func pixelRand(px, py int) *rand.Rand {
+	src := pixelSource(uint64(uint32(py))<<32 | uint64(uint32(px)))
+	return rand.New(&src)
+}
+
+// samplePixel computes the color seen through point (x, y) of the image plane
+// at the given time, taking chromatic aberration into account.
+func (s *Scene) samplePixel(x, y, time float64, rng *rand.Rand, px, py int, o *Options) (c Color, alpha float64, ok bool) {
+	if k := s.view.chromaticAberration(); k != 0 {
+		rx, ry := s.view.magnify(x, y, 1+k)
+		bx, by := s.view.magnify(x, y, 1-k)
+		r, _, rok := s.traceRay(rx, ry, time, rng, px, py, o)
+		g, alpha, gok := s.traceRay(x, y, time, rng, px, py, o)
+		b, _, bok := s.traceRay(bx, by, time, rng, px, py, o)
+		return Color{r.R, g.G, b.B}, alpha, rok && gok && bok
+	}
+	return s.traceRay(x, y, time, rng, px, py, o)
+}
+
+// lightFraction returns the fraction of the light of l reaching intersection
+// h seen from eye at the given time, averaged over color channels.  rng
+// samples area lights.
+func (s *Scene) lightFraction(h *Hit, eye geom.Point, time float64, l *Light, rng *rand.Rand) float64 {
+	f := s.lightFilter(h, eye, time, l, rng)
+	return f.mean()
+}
+
+// lightFilter returns the fraction of the light of l of each color channel
+// reaching intersection h seen from eye at the given time, which transparent
+// objects in between reduce.  rng samples area lights.
+func (s *Scene) lightFilter(h *Hit, eye geom.Point, time float64, l *Light, rng *rand.Rand) Color {
+	obj := h.Object
+	m := obj.MaterialAt(h)
+	if !m.litBy(l) {
+		return Color{}
+	}
+	// Light can not reach the inner side of a surface from outside and
+	// vice-versa, unless both sides are lit.
+	if !m.twoSided() && obj.Inside(eye, time) != l.inside(obj, time) {
+		return Color{}
+	}
+	// Is intersection shadowed by objects, including its own?  Shadow
+	// rays start off the surface on the side of the light so that it does
+	// not shadow itself due to rounding errors.
+	n := obj.NormalAt(h, time)
+	if dir, _ := l.toward(h.Point); geom.DotProduct(&n, &dir) < 0 {
+		n = n.Neg()
+	}
+	p := h.Point.Translate(n.Scale(s.shadowBias(&h.Point)))
+	var skip Object
+	if m != nil && m.Subsurface > 0 {
+		// Light wraps past the terminator of translucent objects.
+		skip = obj
+	}
+	return s.visibility(l, p, time, skip, rng)
+}
+
+// shadowBias returns the distance from surface point p at which shadow rays
+// start.
+func (s *Scene) shadowBias(p *geom.Point) float64 {
+	if s.ShadowBias > 0 {
+		return s.ShadowBias
+	}
+	return surfaceEpsilon(p)
+}
+
+// directColor returns the color of intersection h of ray at the given time
+// lit by the scene lights.  Each light adds to the ambient color the light it
+// casts, scaled by the fraction of it reaching h.  rng samples area lights.
+func (s *Scene) directColor(h *Hit, ray *geom.Ray, time float64, rng *rand.Rand) Color {
+	a := s.ambientColor(h)
+	c := a
+	for i := range s.Lights {
+		l := &s.Lights[i]
+		if f := s.lightFilter(h, ray.Origin, time, l, rng); f != (Color{}) {
+			lc := s.computeObjectColorAt(h, ray, time, l)
+			c.R += f.R * (lc.R - a.R)
+			c.G += f.G * (lc.G - a.G)
+			c.B += f.B * (lc.B - a.B)
+		}
+	}
+	// The legacy model of objects without material shades surfaces lit at
+	// grazing angles darker than their ambient color.
+	return Color{math.Max(c.R, 0), math.Max(c.G, 0), math.Max(c.B, 0)}
+}
+
+// shadeHit computes the color of intersection h between ray and the scene at
+// the given time, following at most depth bounces of reflected and refracted
+// rays.  path is what ray inherits from the rays it was spawned by.
+func (s *Scene) shadeHit(h *Hit, ray geom.Ray, time float64, depth int, rng *rand.Rand, path rayPath) Color {
+	obj := h.Object
+	if obj.MaterialAt(h).shadowCatcher() {
+		d := s.catcherShadow(h, ray.Origin, time, rng)
+		ray.TMin = pastHit(h, &ray)
+		c := s.traceSecondaryRay(ray, time, cameraRay, depth, rng, path)
+		return Color{(1 - d) * c.R, (1 - d) * c.G, (1 - d) * c.B}
+	}
+	c := s.directColor(h, &ray, time, rng)
+
+	if e := s.Environment; e != nil && e.Lighting > 0 {
+		l := s.environmentLight(h, &ray, time)
+		c = Color{c.R + l.R, c.G + l.G, c.B + l.B}
+	}
+	if len(s.emitters) > 0 {
+		l := s.emitterLight(h, &ray, time, rng)
+		c = Color{c.R + l.R, c.G + l.G, c.B + l.B}
+	}
+
+	m := obj.MaterialAt(h)
+	if m == nil || depth == 0 {
+		return c
+	}
+	c = s.bounceColor(h, ray, time, m, depth, rng, path, c)
+	if a := m.opacity(); a < 1 {
+		// Blend with what lies behind along the same ray.
+		d := ray.Dir.UnitVector()
+		origin := h.Point.Translate(d.Scale(surfaceEpsilon(&h.Point)))
+		bc := s.traceSecondaryRay(geom.MakeRay(origin, ray.Dir), time, cameraRay, depth-1, rng, path)
+		return Color{
+			a*c.R + (1-a)*bc.R,
+			a*c.G + (1-a)*bc.G,
+			a*c.B + (1-a)*bc.B,
+		}
+	}
+	return c
+}
+
+// bounceColor mixes color c of intersection h of ray, as lit by the scene
+// lights, with the colors reflected and refracted by material m, following at
+// most depth bounces.  ray follows path.
+func (s *Scene) bounceColor(h *Hit, ray geom.Ray, time float64, m *Material, depth int, rng *rand.Rand, path rayPath, c Color) Color {
+	kr, kt := m.Reflectivity, m.Transparency
+	if kr > 0 && kt > 0 {
+		// Part of the light transmitted through the surface is reflected,
+		// all of it at grazing angles.
+		normal, inside := facingNormal(h, &ray, time)
+		d := ray.Dir.UnitVector()
+		eta, _ := path.media.cross(h.Object, m, inside, path.channel)
+		f := schlick(-geom.DotProduct(&d, &normal), eta)
+		kr, kt = kr+kt*f, kt*(1-f)
+	}
+	var rc, tc Color
+	if kr > 0 {
+		rc = s.reflectedColor(h, ray, time, m, depth-1, rng, path)
+	}
+	if kt > 0 {
+		tc = s.refractedColor(h, ray, time, m, depth-1, rng, path)
+	}
+	kl := 1 - kr - kt
+	return Color{
+		kl*c.R + kr*rc.R + kt*tc.R,
+		kl*c.G + kr*rc.G + kt*tc.G,
+		kl*c.B + kr*rc.B + kt*tc.B,
+	}
+}
+
+// facingNormal returns the unit normal at intersection h pointing to the side
+// of the surface ray comes from and whether ray comes from inside the object.
+// Rays hitting the back of a surface are considered coming from inside even
+// when the object encloses no volume, or when their origin lies so close to
+// the surface that Inside is unreliable.
+func facingNormal(h *Hit, ray *geom.Ray, time float64) (n geom.Vector, inside bool) {
+	n = h.Object.NormalAt(h, time)
+	if h.Object.Inside(ray.Origin, time) {
+		n, inside = n.Neg(), true
+	}
+	if geom.DotProduct(&n, &ray.Dir) > 0 {
+		n, inside = n.Neg(), !inside
+	}
+	return n, inside
+}
+
+// reflectedColor computes the color seen in the mirror direction of ray at
+// intersection h, following at most depth further bounces.  Reflected rays
+// missing all objects see the background.  Reflected rays follow path, that
+// of ray, staying inside the same media.
+func (s *Scene) reflectedColor(h *Hit, ray geom.Ray, time float64, m *Material, depth int, rng *rand.Rand, path rayPath) Color {
+	normal, _ := facingNormal(h, &ray, time)
+	d := ray.Dir.UnitVector()
+	r := geom.Reflect(d, normal)
+	// Start slightly off the surface so that the reflected ray does not hit
+	// it again due to rounding errors.
+	origin := h.Point.Translate(normal.Scale(surfaceEpsilon(&h.Point)))
+	if m.Roughness == 0 {
+		return s.traceSecondaryRay(geom.MakeRay(origin, r), time, reflectionRay, depth, rng, path)
+	}
+
+	// Rough surfaces scatter rays in a cone around the mirror direction.
+	// Only rays reflected by the first surface hit are sampled more than
+	// once to bound the number of rays traced per pixel.
+	n := 1
+	if depth == s.maxDepth()-1 {
+		n = m.glossySamples()
+	}
+	onb := geom.MakeONB(r)
+	cosMax := math.Cos(m.Roughness * math.Pi / 2)
+	var sum Color
+	for i := 0; i < n; i++ {
+		g := onb.ToWorld(geom.UniformSampleCone(rng, cosMax))
+		if geom.DotProduct(&g, &normal) < 0 {
+			// Mirror samples pointing below the surface.
+			g = geom.Reflect(g, normal)
+		}
+		c := s.traceSecondaryRay(geom.MakeRay(origin, g), time, reflectionRay, depth, rng, path)
+		sum.R += c.R
+		sum.G += c.G
+		sum.B += c.B
+	}
+	k := float64(n)
+	return Color{sum.R / k, sum.G / k, sum.B / k}
+}
+
+// refractedColor computes the color seen through the surface of material m at
+// intersection h of ray, following at most depth further bounces.  The
+// direction of the refracted ray follows Snell's law, relative to the media
+// ray travels inside and enters along path.  When total internal reflection
+// occurs, the reflected color is returned instead.
+//
+// Dispersive materials refract each color channel in its own direction.  Rays
+// of a single channel stay so past further surfaces.
+func (s *Scene) refractedColor(h *Hit, ray geom.Ray, time float64, m *Material, depth int, rng *rand.Rand, path rayPath) Color {
+	if m.Dispersion > 0 && path.channel == allChannels {
+		r := s.refractedColor(h, ray, time, m, depth, rng, rayPath{path.media, redChannel})
+		g := s.refractedColor(h, ray, time, m, depth, rng, rayPath{path.media, greenChannel})
+		b := s.refractedColor(h, ray, time, m, depth, rng, rayPath{path.media, blueChannel})
+		return Color{r.R, g.G, b.B}
+	}
+	normal, inside := facingNormal(h, &ray, time)
+	eta, next := path.media.cross(h.Object, m, inside, path.channel)
+	dt, ok := geom.Refract(ray.Dir.UnitVector(), normal, eta)
+	if !ok {
+		return s.reflectedColor(h, ray, time, m, depth, rng, path)
+	}
+	// Start slightly past the surface.
+	origin := h.Point.Translate(normal.Scale(-surfaceEpsilon(&h.Point)))
+	c := s.traceSecondaryRay(geom.MakeRay(origin, dt), time, cameraRay, depth, rng, rayPath{next, path.channel})
+	if f := m.Transmission; f != nil {
+		c = Color{c.R * f.R, c.G * f.G, c.B * f.B}
+	}
+	return c
+}
+
+// schlick returns the fraction of light reflected by a surface separating two
+// media according to Schlick's approximation of Fresnel equations.  cosi is
+// the cosine of the angle of incidence and eta the ratio of the indices of
+// refraction of the media light leaves and enters.
+//
+// Formula taken from:
+// 	Schlick, "An Inexpensive BRDF Model for Physically-based Rendering",
+// 	Computer Graphics Forum 13 (3), 1994.
+func schlick(cosi, eta float64) float64 {
+	r0 := (eta - 1) / (eta + 1)
+	r0 *= r0
+	c := cosi
+	if eta > 1 {
+		// Use the angle in the less dense medium.
+		sin2t := eta * eta * (1 - cosi*cosi)
+		if sin2t > 1 {
+			// Total internal reflection.
+			return 1
 		}
-		if s.rayHitsObject(sray) {
-			c = Color{s.Bg.R / 2, s.Bg.G / 2, s.Bg.B / 2}
+		c = math.Sqrt(1 - sin2t)
+	}
+	x := 1 - c
+	return r0 + (1-r0)*x*x*x*x*x
+}
+
+// traceSecondaryRay computes the color seen along a ray spawned by a bounce on
+// an object along path, following at most depth further bounces.  Rays
+// missing all objects see the background.
+func (s *Scene) traceSecondaryRay(ray geom.Ray, time float64, kind rayKind, depth int, rng *rand.Rand, path rayPath) Color {
+	h, hit := s.castRay(ray, time, kind)
+	if !hit {
+		return s.background(ray.Dir)
+	}
+	return s.shader().Shade(&h, s, &TracedRay{ray, time, kind, rng, path}, depth)
+}
+
+// background returns the color seen along direction d by rays missing all
+// objects, shadows aside.
+func (s *Scene) background(d geom.Vector) Color {
+	if s.Environment != nil {
+		return s.Environment.colorAt(d)
+	}
+	return s.Bg
+}
+
+// surfaceEpsilon returns the distance from p under which points are
+// considered on the same surface as p, relative to the magnitude of its
+// coordinates.
+func surfaceEpsilon(p *geom.Point) float64 {
+	m := math.Max(math.Abs(p.X), math.Max(math.Abs(p.Y), math.Abs(p.Z)))
+	return 1e-6 * math.Max(1, m)
+}
+
+// traceRay computes the color seen through point (x, y) of the image plane at
+// the given time.  (px, py) is the image pixel being rendered, for diagnostics
+// only.  ok is false if Options.CheckNaN is set and a non-finite value was
+// detected.
+func (s *Scene) traceRay(x, y, time float64, rng *rand.Rand, px, py int, o *Options) (c Color, alpha float64, ok bool) {
+	vr := s.view.ray(x, y, time)
+	if vr.blank {
+		return Color{}, 0, true
+	}
+	ray := vr.Ray
+
+	h, hit := s.castRay(ray, time, cameraRay)
+	// Shadow catchers show what lies behind them darkened by the shadows
+	// they receive.  k is the fraction of light left and alpha accumulates
+	// the opacity of shadows.
+	k := 1.0
+	for hit && h.Object.MaterialAt(&h).shadowCatcher() && isFinitePoint(&h.Point) {
+		d := s.catcherShadow(&h, ray.Origin, time, rng)
+		alpha += k * d
+		k *= 1 - d
+		ray.TMin = pastHit(&h, &ray)
+		h, hit = s.castRay(ray, time, cameraRay)
+	}
+	if o.CheckNaN && hit && !isFinitePoint(&h.Point) {
+		o.logf("pixel (%d, %d): non-finite intersection %v with %v",
+			px, py, h.Point, h.Object)
+		return DebugColor, 1, false
+	}
+
+	if hit {
+		h.setFootprint(vr.footprint[0] + vr.footprint[1]*h.T)
+		c = s.shader().Shade(&h, s, &TracedRay{ray, time, cameraRay, rng, rayPath{}}, s.maxDepth())
+		alpha += k
+	} else if o.Alpha {
+		// Transparent background.
+	} else if s.Environment != nil {
+		c = s.background(ray.Dir)
+	} else if vr.backdrop > 0 {
+		far := vr.At(vr.backdrop)
+		k := 1 - s.shadow(func(l *Light) float64 {
+			v := s.visibility(l, far, time, nil, rng)
+			return v.mean()
+		})
+		c = Color{k * s.Bg.R, k * s.Bg.G, k * s.Bg.B}
+	} else {
+		c = s.Bg
+	}
+
+	if o.CheckNaN && !c.isFinite() {
+		if hit {
+			o.logf("pixel (%d, %d): non-finite color %v on %v", px, py, c, h.Object)
 		} else {
-			c = s.Bg
+			o.logf("pixel (%d, %d): non-finite background color %v", px, py, c)
 		}
+		return DebugColor, 1, false
 	}
 
-	return c.toRGBA()
+	return Color{k * c.R, k * c.G, k * c.B}, alpha, true
+}
+
+// pastHit returns the parameter along ray slightly past intersection h so
+// that rays resumed from it do not hit the same surface again due to rounding
+// errors.
+func pastHit(h *Hit, ray *geom.Ray) float64 {
+	return h.T + surfaceEpsilon(&h.Point)/ray.Dir.Module()
+}
+
+// catcherShadow returns the fraction by which shadows darken what lies behind
+// intersection h with a shadow catcher seen from eye at the given time, like
+// shadows on the background.  rng samples area lights.
+func (s *Scene) catcherShadow(h *Hit, eye geom.Point, time float64, rng *rand.Rand) float64 {
+	return s.shadow(func(l *Light) float64 {
+		return s.lightFraction(h, eye, time, l, rng)
+	})
 }
 
 // Render validates the scene and runs the ray-tracing algorithm over it.  It
 // generates an in-memory image containing the result.  The scene is divided in
 // nstripes horizontal stripes that are processed concurrently.
+//
+// Render works on a private copy of the scene taken before any rendering
+// goroutine starts.  Several goroutines may render the same scene
+// concurrently, possibly with different options, provided none of them
+// modifies it.
 func (s *Scene) Render(nstripes int) (*image.RGBA, error) {
+	return s.RenderWithOptions(&Options{Stripes: nstripes})
+}
+
+// RenderWithOptions is like Render but provides finer control over rendering.
+func (s *Scene) RenderWithOptions(opts *Options) (*image.RGBA, error) {
+	o := *opts
+	s = s.Clone()
+	if s.Camera != nil {
+		s.Camera.animate(o.Frame)
+	}
+
+	nstripes := o.Stripes
 	if nstripes < 1 {
 		nstripes = 1
 	}
@@ -230,31 +1606,57 @@ func (s *Scene) Render(nstripes int) (*image.RGBA, error) {
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.prepare(nstripes, o.PrepareProgress); err != nil {
+		return nil, err
+	}
 
-	vp := &s.ViewFrustum.Near
-	w := int(vp.Dx())
-	h := int(vp.Dy())
+	w, h := s.view.size()
 	img := image.NewRGBA(image.Rect(0, 0, w, h))
 
-	slice := h / nstripes
+	tiles := makeTiles(img.Bounds(), nstripes, o.TileSize)
+	orderTiles(tiles, img.Bounds(), o.TileSize, o.TileOrder)
+	if !o.Focus.Empty() {
+		tiles = prioritize(tiles, o.Focus)
+	}
+	work := make(chan image.Rectangle, len(tiles))
+	for _, t := range tiles {
+		work <- t
+	}
+	close(work)
+
+	var mu sync.Mutex // serializes TileDone calls
 	ch := make(chan bool)
 	for n := 0; n < nstripes; n++ {
-		ystart := slice * n
-		yend := ystart + slice
 		go func() {
-			for y := ystart; y < yend; y++ {
-				for x := 0; x < w; x++ {
-					c := s.renderPixel(float64(x)+vp.Tl.X, -vp.Br.Y-float64(y))
-					img.SetRGBA(x, y, c)
+		tiles:
+			for t := range work {
+				for y := t.Min.Y; y < t.Max.Y; y++ {
+					if o.cancelled() {
+						// Drain remaining tiles without reporting
+						// this partial one.
+						continue tiles
+					}
+					for x := t.Min.X; x < t.Max.X; x++ {
+						c := s.renderPixel(x, y, &o)
+						img.SetRGBA(x, y, c)
+					}
+				}
+				if o.TileDone != nil {
+					mu.Lock()
+					o.TileDone(img, t)
+					mu.Unlock()
 				}
 			}
 			ch <- true
 		}()
 	}
 
-	// block until all stripes processed
+	// block until all tiles processed
 	for n := 0; n < nstripes; n++ {
 		<-ch
 	}
+	if o.cancelled() {
+		return nil, ErrCancelled
+	}
 	return img, nil
 }
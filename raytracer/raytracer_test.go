@@ -0,0 +1,648 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"image"
+	"log"
+	"math"
+	"sync/atomic"
+	"testing"
+)
+
+func testScene() *Scene {
+	return &Scene{
+		ViewFrustum: Frustum{
+			Near: geom.Plane2d{geom.Point2d{-10, 10}, geom.Point2d{10, -10}, 0},
+			Far:  geom.Plane2d{geom.Point2d{-20, 20}, geom.Point2d{20, -20}, 100},
+		},
+		Lights: []Light{{Position: geom.Point{-100, 30, 0}}},
+		Objects: ObjectList{
+			&Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 80}, 10}, Color: Color{1, 0, 0}},
+		},
+		Bg: Color{0.5, 0.5, 0.5},
+		Kd: 0.9,
+	}
+}
+
+func TestCheckNaN(t *testing.T) {
+	s := testScene()
+	s.Kd = math.NaN()
+	var buf bytes.Buffer
+	img, err := s.RenderWithOptions(&Options{CheckNaN: true, Log: log.New(&buf, "", 0)})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act, exp := img.RGBAAt(10, 10), DebugColor.toRGBA(); act != exp {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+	if act, exp := img.RGBAAt(0, 0), s.Bg.toRGBA(); act != exp {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+	if buf.Len() == 0 {
+		t.Fatalf("no diagnostic logged")
+	}
+}
+
+func TestFrustumFOV(t *testing.T) {
+	data := []struct {
+		f  Frustum
+		ok bool
+	}{
+		{Frustum{FOV: 60, NearDist: 10}, true},
+		{Frustum{FOV: 60, Aspect: 1.5, NearDist: 10, FarDist: 100}, true},
+		{Frustum{FOV: 180, NearDist: 10}, false},
+		{Frustum{FOV: -10, NearDist: 10}, false},
+		{Frustum{FOV: 60, Aspect: -1, NearDist: 10}, false},
+		{Frustum{FOV: 60}, false},
+		{Frustum{FOV: 60, NearDist: 10, FarDist: 5}, false},
+	}
+	for i, d := range data {
+		if err := d.f.Validate(); (err == nil) != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, err)
+		}
+	}
+
+	f := Frustum{FOV: 90, Aspect: 2, NearDist: 10}
+	near, far := f.planes()
+	expNear := geom.Plane2d{geom.Point2d{-20, 10}, geom.Point2d{20, -10}, 10}
+	expFar := geom.Plane2d{geom.Point2d{-40, 20}, geom.Point2d{40, -20}, 20}
+	for _, p := range []struct{ exp, act geom.Plane2d }{{expNear, near}, {expFar, far}} {
+		if !geom.FloatsEqual(p.act.Tl.X, p.exp.Tl.X, 1e-9) || !geom.FloatsEqual(p.act.Tl.Y, p.exp.Tl.Y, 1e-9) ||
+			!geom.FloatsEqual(p.act.Br.X, p.exp.Br.X, 1e-9) || !geom.FloatsEqual(p.act.Br.Y, p.exp.Br.Y, 1e-9) ||
+			p.act.Z != p.exp.Z {
+			t.Errorf("exp: %v act: %v", p.exp, p.act)
+		}
+	}
+
+	// The frustum of the test scene has its eye 100 units behind the near
+	// plane, and renders like a derived one once the scene is moved away.
+	s := testScene()
+	exp, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	s.ViewFrustum = Frustum{FOV: 2 * math.Atan(0.1) * 180 / math.Pi, NearDist: 100}
+	s.Lights[0].Position.Z += 100
+	s.Objects[0].(*Sphere).Sphere.Center.Z += 100
+	act, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act.Bounds() != exp.Bounds() {
+		t.Fatalf("size: exp: %v act: %v", exp.Bounds(), act.Bounds())
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			e, a := exp.RGBAAt(x, y), act.RGBAAt(x, y)
+			if absDiff(e.R, a.R) > 1 || absDiff(e.G, a.G) > 1 || absDiff(e.B, a.B) > 1 {
+				t.Errorf("(%d, %d): exp: %v act: %v", x, y, e, a)
+			}
+		}
+	}
+}
+
+// Regression test: the height of the far plane used to be scaled by the
+// width of the near one, skewing rays of non-square frustums vertically.
+func TestFrustumRay(t *testing.T) {
+	f := Frustum{
+		Near: geom.Plane2d{geom.Point2d{-20, 10}, geom.Point2d{20, -10}, 0},
+		Far:  geom.Plane2d{geom.Point2d{-40, 20}, geom.Point2d{40, -20}, 100},
+	}
+	data := []struct {
+		x, y float64
+		exp  geom.Point
+	}{
+		{0, 0, geom.Point{0, 0, 100}},
+		{20, 10, geom.Point{40, 20, 100}},
+		{-10, -5, geom.Point{-20, -10, 100}},
+	}
+	for i, d := range data {
+		vr := f.ray(d.x, d.y, 0)
+		if act := vr.Ray.At(1); !geom.PointsEqual(act, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+}
+
+func TestToUint8(t *testing.T) {
+	data := []struct {
+		c   float64
+		exp uint8
+	}{
+		{0, 0},
+		{0.5, 127},
+		{1, 255},
+		{2, 255},
+		{math.Inf(1), 255},
+		{-0.5, 0},
+		{math.Inf(-1), 0},
+		{math.NaN(), 0},
+	}
+	for _, d := range data {
+		if act := toUint8(d.c); act != d.exp {
+			t.Errorf("%v: exp: %v act: %v", d.c, d.exp, act)
+		}
+	}
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func TestCullBackface(t *testing.T) {
+	s := testScene()
+	s.Objects = append(s.Objects,
+		&Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 0}, 500}, Color: Color{0, 1, 0}})
+
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act, bg := img.RGBAAt(0, 0), s.Bg.toRGBA(); act == bg {
+		t.Fatalf("inner side not rendered")
+	}
+
+	s.Objects[1].(*Sphere).CullBackface = true
+	img, err = s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act, exp := img.RGBAAt(0, 0), s.Bg.toRGBA(); act != exp {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	s := testScene()
+	ref, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	// Green sphere between the light and the red one.
+	green := &Sphere{Sphere: geom.Sphere{geom.Point{-30, 10, 60}, 8}, Color: Color{0, 1, 0}}
+	green.Invisible = true
+	s.Objects = append(s.Objects, green)
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if bytes.Equal(img.Pix, ref.Pix) {
+		t.Fatalf("invisible sphere casts no shadow")
+	}
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			if c := img.RGBAAt(x, y); c.G > c.R && c.G > c.B {
+				t.Fatalf("invisible sphere rendered at (%d, %d): %v", x, y, c)
+			}
+		}
+	}
+
+	green.NoShadow = true
+	img, err = s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !bytes.Equal(img.Pix, ref.Pix) {
+		t.Fatalf("invisible sphere without shadow changes image")
+	}
+}
+
+// countingObject counts the intersection tests of the object it wraps.
+type countingObject struct {
+	Object
+	n int64
+}
+
+func (c *countingObject) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	atomic.AddInt64(&c.n, 1)
+	return c.Object.Intersect(ray, time)
+}
+
+// Clone shares c between the scene and its copies to count all tests.
+func (c *countingObject) Clone() Object {
+	return c
+}
+
+func TestBoundsRejectRays(t *testing.T) {
+	s := testScene()
+	// Small sphere far below the field of view and the light.
+	c := &countingObject{Object: &Sphere{Sphere: geom.Sphere{geom.Point{0, -500, 50}, 1}}}
+	s.Objects = append(s.Objects, c)
+	if _, err := s.Render(1); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if c.n != 0 {
+		t.Fatalf("exp: 0 intersection tests act: %d", c.n)
+	}
+}
+
+func TestCancelRender(t *testing.T) {
+	s := testScene()
+	cancel := make(chan struct{})
+	close(cancel)
+	if _, err := s.RenderWithOptions(&Options{Cancel: cancel}); err != ErrCancelled {
+		t.Fatalf("exp: %v act: %v", ErrCancelled, err)
+	}
+
+	// Rendering stops between tiles.
+	cancel = make(chan struct{})
+	n := 0
+	opts := Options{Stripes: 1, TileSize: 4, Cancel: cancel, TileDone: func(img *image.RGBA, r image.Rectangle) {
+		if n++; n == 1 {
+			close(cancel)
+		}
+	}}
+	if _, err := s.RenderWithOptions(&opts); err != ErrCancelled {
+		t.Fatalf("exp: %v act: %v", ErrCancelled, err)
+	}
+	if n != 1 {
+		t.Errorf("exp: 1 tile rendered act: %d", n)
+	}
+}
+
+// Run with -race to check that concurrent renders of a shared scene do not
+// interfere.
+func TestConcurrentRenders(t *testing.T) {
+	s := testScene()
+	ref, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	const n = 8
+	ch := make(chan error)
+	for i := 0; i < n; i++ {
+		opts := Options{Stripes: i%4 + 1, CheckNaN: i%2 == 0}
+		go func() {
+			img, err := s.RenderWithOptions(&opts)
+			if err == nil && !bytes.Equal(img.Pix, ref.Pix) {
+				err = fmt.Errorf("stripes=%d: image differs", opts.Stripes)
+			}
+			ch <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-ch; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Run with -race to check that objects are prepared concurrently without
+// interfering.
+func TestPrepareProgress(t *testing.T) {
+	s := testScene()
+	s.Prototypes = ObjectMap{"cube": cubeMesh(geom.Point{0, 0, 0}, 1, Color{0, 1, 0})}
+	for i := 0; i < 8; i++ {
+		m := cubeMesh(geom.Point{float64(4*i - 14), -8, 60}, 1, Color{0, 0, 1})
+		m.Smooth = true
+		s.Objects = append(s.Objects, m)
+	}
+	s.Objects = append(s.Objects, &Instance{Prototype: "cube"})
+	var calls []int
+	total := 0
+	opts := Options{Stripes: 4, PrepareProgress: func(done, n int) {
+		calls = append(calls, done)
+		total = n
+	}}
+	if _, err := s.RenderWithOptions(&opts); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	exp := 1 + len(s.Objects)
+	if total != exp || len(calls) != exp {
+		t.Fatalf("exp: %d calls act: %d of %d", exp, len(calls), total)
+	}
+	for i, done := range calls {
+		if done != i+1 {
+			t.Fatalf("#%d: exp: %d act: %d", i, i+1, done)
+		}
+	}
+
+	// Errors are reported whichever goroutine prepares the faulty object.
+	s.Objects = append(s.Objects, &Instance{Prototype: "none"})
+	if _, err := s.RenderWithOptions(&opts); err == nil {
+		t.Fatalf("unknown prototype accepted")
+	}
+}
+
+func TestPhysicalLightFalloff(t *testing.T) {
+	s := testScene()
+	s.Kd = 1
+	s.LightFlux = 1000
+	h := &Hit{Point: geom.Point{0, 0, 70}, Object: s.Objects[0]}
+	ray := geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1})
+	near := s.computeObjectColorAt(h, &ray, 0, &Light{Position: geom.Point{0, 0, 60}})
+	l := &Light{Position: geom.Point{0, 0, 50}}
+	far := s.computeObjectColorAt(h, &ray, 0, l)
+	if !geom.FloatsEqual(near.R, 4*far.R, 1e-9) {
+		t.Fatalf("not inverse-square: near: %v far: %v", near.R, far.R)
+	}
+
+	s.Exposure = 1
+	darker := s.computeObjectColorAt(h, &ray, 0, l)
+	if !geom.FloatsEqual(far.R, 2*darker.R, 1e-9) {
+		t.Fatalf("bad exposure: EV0: %v EV1: %v", far.R, darker.R)
+	}
+
+	// Exposure scales ambient light too, keeping its ratio to direct light,
+	// with or without material.
+	s.Kd = 0.5
+	for _, m := range []*Material{nil, {Ambient: 0.2, Diffuse: 0.8}} {
+		s.Objects[0].(*Sphere).Material = m
+		s.Exposure = 0
+		ev0 := s.computeObjectColorAt(h, &ray, 0, l)
+		amb0 := s.ambientColor(h)
+		s.Exposure = 2
+		ev2 := s.computeObjectColorAt(h, &ray, 0, l)
+		amb2 := s.ambientColor(h)
+		if amb0.R == 0 || !geom.FloatsEqual(ev0.R, 4*ev2.R, 1e-9) || !geom.FloatsEqual(amb0.R, 4*amb2.R, 1e-9) {
+			t.Errorf("material %v: EV0: %v %v EV2: %v %v", m, ev0, amb0, ev2, amb2)
+		}
+	}
+}
+
+func TestMultipleLights(t *testing.T) {
+	s := testScene()
+	m := &Material{Ambient: 0.1, Diffuse: 0.9}
+	s.Objects[0].(*Sphere).Material = m
+	key, fill := Light{Position: geom.Point{-100, 30, 0}}, Light{Position: geom.Point{100, 0, 0}, Intensity: 0.5}
+	s.Lights = []Light{key, fill}
+	ray := geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1})
+	h, _ := s.Objects[0].Intersect(ray, 0)
+
+	a := s.ambientColor(&h)
+	k, f := s.computeObjectColorAt(&h, &ray, 0, &key), s.computeObjectColorAt(&h, &ray, 0, &fill)
+	exp := Color{k.R + f.R - a.R, k.G + f.G - a.G, k.B + f.B - a.B}
+	if act := s.shadeHit(&h, ray, 0, 0, nil, rayPath{}); !colorsEqual(act, exp) {
+		t.Errorf("both lights: exp: %v act: %v", exp, act)
+	}
+
+	// Hide the fill light behind a sphere out of view.
+	s.Objects = append(s.Objects, &Sphere{Sphere: geom.Sphere{geom.Point{90, 0, 7}, 3}})
+	if act := s.shadeHit(&h, ray, 0, 0, nil, rayPath{}); !colorsEqual(act, k) {
+		t.Errorf("key light only: exp: %v act: %v", k, act)
+	}
+	// The fill light weighs a third of the light reaching the background.
+	if exp, act := 1.0/6, s.shadow(func(l *Light) float64 {
+		if l.Position == fill.Position {
+			return 0
+		}
+		return 1
+	}); !geom.FloatsEqual(act, exp, 1e-9) {
+		t.Errorf("background shadow: exp: %v act: %v", exp, act)
+	}
+
+	s.Lights[1].Intensity = -1
+	if err := s.Validate(); err == nil {
+		t.Errorf("negative light intensity accepted")
+	}
+}
+
+func TestAmbientLight(t *testing.T) {
+	s := testScene()
+	s.Kd = 1
+	ray := geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1})
+	h, _ := s.Objects[0].Intersect(ray, 0)
+	// Fully diffuse objects get no ambient light from Kd alone.
+	if act := s.ambientColor(&h); act != (Color{}) {
+		t.Errorf("coupled: exp: black act: %v", act)
+	}
+
+	s.Ambient = &Color{0.2, 0.2, 0.4}
+	data := []struct {
+		m   *Material
+		exp Color
+	}{
+		{nil, Color{0.2, 0, 0}},
+		{&Material{Ambient: 0.5}, Color{0.1, 0, 0}},
+		{&Material{PBR: &PBR{BaseColor: &Color{0, 0, 1}}}, Color{0, 0, 0.4}},
+	}
+	for i, d := range data {
+		s.Objects[0].(*Sphere).Material = d.m
+		if act := s.ambientColor(&h); !colorsEqual(act, d.exp) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+		// Lights add to ambient light.
+		lit := s.computeObjectColorAt(&h, &ray, 0, &s.Lights[0])
+		if lit.R < d.exp.R || lit.G < d.exp.G || lit.B < d.exp.B {
+			t.Errorf("#%d: lit darker than ambient: %v", i, lit)
+		}
+	}
+
+	s.Ambient = &Color{2, 0, 0}
+	if err := s.Validate(); err == nil {
+		t.Errorf("invalid ambient light accepted")
+	}
+}
+
+func TestShadowBias(t *testing.T) {
+	to := &Torus{Torus: geom.Torus{Center: geom.Origin, Axis: geom.Vector{0, 1, 0}, Major: 10, Minor: 2}}
+	l := &Light{Position: geom.Point{20, 0, 0}}
+	s := &Scene{Objects: ObjectList{to}, Lights: []Light{*l}}
+	data := []struct {
+		ray geom.Ray
+		exp float64
+	}{
+		// Outer side of the tube facing the light.
+		{geom.MakeRay(geom.Point{30, 0, 0}, geom.Vector{-1, 0, 0}), 1},
+		// Inner side of the tube shadowed by the opposite side.
+		{geom.MakeRay(geom.Origin, geom.Vector{-1, 0, 0}), 0},
+	}
+	for i, d := range data {
+		h, ok := to.Intersect(d.ray, 0)
+		if !ok {
+			t.Fatalf("#%d: no intersection", i)
+		}
+		if f := s.lightFraction(&h, d.ray.Origin, 0, l, nil); f != d.exp {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, f)
+		}
+	}
+
+	// Large biases detach shadows from the objects casting them.
+	w := &wall{Z: 100}
+	s.Objects = ObjectList{w, &Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 99.5}, 0.4}}}
+	l = &Light{Position: geom.Origin}
+	h := &Hit{T: 100, Point: geom.Point{0, 0, 100}, Object: w}
+	if f := s.lightFraction(h, geom.Origin, 0, l, nil); f != 0 {
+		t.Errorf("small bias: exp: 0 act: %v", f)
+	}
+	s.ShadowBias = 1
+	if f := s.lightFraction(h, geom.Origin, 0, l, nil); f != 1 {
+		t.Errorf("large bias: exp: 1 act: %v", f)
+	}
+
+	s.ShadowBias = -1
+	if err := s.Validate(); err == nil {
+		t.Errorf("negative shadow bias accepted")
+	}
+}
+
+func TestMotionBlur(t *testing.T) {
+	s := testScene()
+	still, err := s.RenderWithOptions(&Options{TimeSamples: 4})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	s.Objects[0].(*Sphere).Motion = geom.Vector{10, 0, 0}
+	start, err := s.RenderWithOptions(&Options{TimeSamples: 1})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !bytes.Equal(start.Pix, still.Pix) {
+		t.Fatalf("single time sample not at shutter opening")
+	}
+
+	blurred, err := s.RenderWithOptions(&Options{TimeSamples: 4})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if bytes.Equal(blurred.Pix, still.Pix) {
+		t.Fatalf("moving sphere not blurred")
+	}
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	// render returns the image of s rendered with opts and the # of
+	// intersection tests with its sphere.
+	render := func(s *Scene, opts Options) ([]uint8, int64) {
+		c := &countingObject{Object: s.Objects[0]}
+		s.Objects[0] = c
+		defer func() { s.Objects[0] = c.Object }()
+		img, err := s.RenderWithOptions(&opts)
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		return img.Pix, c.n
+	}
+	// diff returns the sum of the differences between two images.
+	diff := func(a, b []uint8) int {
+		d := 0
+		for i := range a {
+			if a[i] > b[i] {
+				d += int(a[i] - b[i])
+			} else {
+				d += int(b[i] - a[i])
+			}
+		}
+		return d
+	}
+
+	// Converged pixels stop after two samples.
+	s := testScene()
+	ref, nref := render(s, Options{})
+	img, n := render(s, Options{MaxSamples: 64})
+	if !bytes.Equal(img, ref) {
+		t.Errorf("adaptive sampling changed still image")
+	}
+	if n != 2*nref {
+		t.Errorf("exp: %d intersection tests act: %d", 2*nref, n)
+	}
+
+	// Pixels blurred by motion get more samples and are closer to a
+	// reference image than with uniform sampling.
+	s.Objects[0].(*Sphere).Motion = geom.Vector{10, 0, 0}
+	ref, nref = render(s, Options{TimeSamples: 256})
+	uniform, _ := render(s, Options{TimeSamples: 2})
+	adaptive, n := render(s, Options{TimeSamples: 2, MaxSamples: 256})
+	if du, da := diff(uniform, ref), diff(adaptive, ref); da >= du {
+		t.Errorf("adaptive error %d not below uniform error %d", da, du)
+	}
+	if n >= nref {
+		t.Errorf("exp: less than %d intersection tests act: %d", nref, n)
+	}
+}
+
+// Regression test: spheres behind the light used to shadow the background.
+func TestNoShadowFromBehindLight(t *testing.T) {
+	s := testScene()
+	ref, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	// Pixel (10, 0) maps to far plane point (0, 20, 100).  This sphere is
+	// centered on the half-line from this point through the light, past the
+	// light.
+	s.Objects = append(s.Objects,
+		&Sphere{Sphere: geom.Sphere{geom.Point{-200, 40, -100}, 50}, Color: Color{0, 1, 0}})
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act, exp := img.RGBAAt(10, 0), ref.RGBAAt(10, 0); act != exp {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestFacingNormal(t *testing.T) {
+	w := &wall{Z: 10}
+	s := &Sphere{Sphere: geom.Sphere{geom.Point{0, 0, 0}, 20}}
+	data := []struct {
+		o      Object
+		ray    geom.Ray
+		exp    geom.Vector
+		inside bool
+	}{
+		{w, geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1}), geom.Vector{0, 0, -1}, false},
+		// Back of a surface enclosing no volume.
+		{w, geom.MakeRay(geom.Point{0, 0, 15}, geom.Vector{0, 0, -1}), geom.Vector{0, 0, 1}, true},
+		{s, geom.MakeRay(geom.Point{0, 0, -30}, geom.Vector{0, 0, 1}), geom.Vector{0, 0, -1}, false},
+		{s, geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1}), geom.Vector{0, 0, -1}, true},
+	}
+	for i, d := range data {
+		h, ok := d.o.Intersect(d.ray, 0)
+		if !ok {
+			t.Fatalf("#%d: no intersection", i)
+		}
+		n, inside := facingNormal(&h, &d.ray, 0)
+		if !geom.VectorsEqual(n, d.exp, 1e-9) || inside != d.inside {
+			t.Errorf("#%d: exp: %v %v act: %v %v", i, d.exp, d.inside, n, inside)
+		}
+	}
+}
+
+func TestTwoSided(t *testing.T) {
+	s := testScene()
+	// Eye inside a sphere lit from outside.
+	s.Lights[0].Position = geom.Point{0, 0, 1000}
+	m := &Material{Ambient: 0.1, Diffuse: 0.9}
+	s.Objects = ObjectList{&Sphere{Sphere: geom.Sphere{geom.Origin, 500}, Color: Color{1, 0, 0}, Material: m}}
+	ray := geom.MakeRay(geom.Origin, geom.Vector{0, 0, 1})
+	h, _ := s.Objects[0].Intersect(ray, 0)
+	if exp, act := (Color{0.1, 0, 0}), s.shadeHit(&h, ray, 0, 0, nil, rayPath{}); !colorsEqual(act, exp) {
+		t.Errorf("one-sided: exp: %v act: %v", exp, act)
+	}
+	m.TwoSided = true
+	if exp, act := (Color{1, 0, 0}), s.shadeHit(&h, ray, 0, 0, nil, rayPath{}); !colorsEqual(act, exp) {
+		t.Errorf("two-sided: exp: %v act: %v", exp, act)
+	}
+}
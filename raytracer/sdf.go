@@ -0,0 +1,291 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// An SDFShape is a node of a tree of shapes defined by their signed distance
+// function, negative inside the shape.  Type selects the shape and the fields
+// it uses:
+//	"sphere"       Center, Radius
+//	"box"          Center, Size (half-edges)
+//	"capsule"      A, B, Radius
+//	"torus"        Center, Radius (major), Minor, around the y-axis
+//	"union"        Children, Smoothness
+//	"intersection" Children
+//	"difference"   Children, the first minus the others
+// A positive Smoothness blends the children of a union over this distance.
+type SDFShape struct {
+	Type       string
+	Center     geom.Point
+	Radius     float64
+	Minor      float64
+	Size       geom.Vector
+	A, B       geom.Point
+	Smoothness float64
+	Children   []SDFShape
+}
+
+// Distance returns the signed distance between p and the surface of sh.  The
+// result may underestimate the distance for smooth unions.
+func (sh *SDFShape) Distance(p geom.Point) float64 {
+	switch sh.Type {
+	case "sphere":
+		return geom.Distance(p, sh.Center) - sh.Radius
+	case "box":
+		q := geom.MakeVector(p, sh.Center)
+		q = geom.Vector{
+			math.Abs(q.X) - sh.Size.X,
+			math.Abs(q.Y) - sh.Size.Y,
+			math.Abs(q.Z) - sh.Size.Z,
+		}
+		out := geom.Vector{math.Max(q.X, 0), math.Max(q.Y, 0), math.Max(q.Z, 0)}
+		return out.Module() + math.Min(math.Max(q.X, math.Max(q.Y, q.Z)), 0)
+	case "capsule":
+		// Distance to the nearest point of segment [A, B].
+		ab := geom.MakeVector(sh.B, sh.A)
+		ap := geom.MakeVector(p, sh.A)
+		k := 0.0
+		if l2 := geom.DotProduct(&ab, &ab); l2 > 0 {
+			k = math.Max(0, math.Min(1, geom.DotProduct(&ap, &ab)/l2))
+		}
+		return geom.Distance(p, sh.A.Translate(ab.Scale(k))) - sh.Radius
+	case "torus":
+		q := geom.MakeVector(p, sh.Center)
+		dxz := math.Hypot(q.X, q.Z) - sh.Radius
+		return math.Hypot(dxz, q.Y) - sh.Minor
+	case "union":
+		d := sh.Children[0].Distance(p)
+		for i := 1; i < len(sh.Children); i++ {
+			d = smoothMin(d, sh.Children[i].Distance(p), sh.Smoothness)
+		}
+		return d
+	case "intersection":
+		d := sh.Children[0].Distance(p)
+		for i := 1; i < len(sh.Children); i++ {
+			d = math.Max(d, sh.Children[i].Distance(p))
+		}
+		return d
+	case "difference":
+		d := sh.Children[0].Distance(p)
+		for i := 1; i < len(sh.Children); i++ {
+			d = math.Max(d, -sh.Children[i].Distance(p))
+		}
+		return d
+	}
+	panic("raytracer: unknown SDF shape: " + sh.Type)
+}
+
+// smoothMin returns the minimum of a and b blended over distance k.
+//
+// Polynomial smooth minimum taken from:
+// 	http://iquilezles.org/www/articles/smin/smin.htm
+func smoothMin(a, b, k float64) float64 {
+	if k <= 0 {
+		return math.Min(a, b)
+	}
+	h := math.Max(0, math.Min(1, 0.5+0.5*(b-a)/k))
+	return b + (a-b)*h - k*h*(1-h)
+}
+
+// Bounds returns a box enclosing sh.
+func (sh *SDFShape) Bounds() geom.AABB {
+	switch sh.Type {
+	case "sphere":
+		return sphereBounds(geom.Sphere{sh.Center, sh.Radius})
+	case "box":
+		return geom.AABB{sh.Center.Translate(sh.Size.Neg()), sh.Center.Translate(sh.Size)}
+	case "capsule":
+		b := sphereBounds(geom.Sphere{sh.A, sh.Radius})
+		return b.Union(sphereBounds(geom.Sphere{sh.B, sh.Radius}))
+	case "torus":
+		r := sh.Radius + sh.Minor
+		e := geom.Vector{r, sh.Minor, r}
+		return geom.AABB{sh.Center.Translate(e.Neg()), sh.Center.Translate(e)}
+	case "union":
+		b := geom.EmptyAABB()
+		for i := range sh.Children {
+			b = b.Union(sh.Children[i].Bounds())
+		}
+		// Blending grows the shape by at most Smoothness/4.
+		k := sh.Smoothness / 4
+		e := geom.Vector{k, k, k}
+		return geom.AABB{b.Min.Translate(e.Neg()), b.Max.Translate(e)}
+	case "intersection":
+		b := sh.Children[0].Bounds()
+		for i := 1; i < len(sh.Children); i++ {
+			c := sh.Children[i].Bounds()
+			b = geom.AABB{
+				geom.Point{math.Max(b.Min.X, c.Min.X), math.Max(b.Min.Y, c.Min.Y), math.Max(b.Min.Z, c.Min.Z)},
+				geom.Point{math.Min(b.Max.X, c.Max.X), math.Min(b.Max.Y, c.Max.Y), math.Min(b.Max.Z, c.Max.Z)},
+			}
+		}
+		return b
+	case "difference":
+		return sh.Children[0].Bounds()
+	}
+	panic("raytracer: unknown SDF shape: " + sh.Type)
+}
+
+func (sh *SDFShape) Validate() error {
+	switch sh.Type {
+	case "sphere", "capsule":
+		if sh.Radius <= 0 {
+			return fmt.Errorf("invalid SDF %s: negative or null radius", sh.Type)
+		}
+	case "box":
+		if sh.Size.X <= 0 || sh.Size.Y <= 0 || sh.Size.Z <= 0 {
+			return fmt.Errorf("invalid SDF box: negative or null size")
+		}
+	case "torus":
+		if sh.Radius <= 0 || sh.Minor <= 0 {
+			return fmt.Errorf("invalid SDF torus: negative or null radius")
+		}
+	case "union", "intersection", "difference":
+		if len(sh.Children) == 0 {
+			return fmt.Errorf("invalid SDF %s: no children", sh.Type)
+		}
+		if sh.Smoothness < 0 {
+			return fmt.Errorf("invalid SDF %s: negative smoothness", sh.Type)
+		}
+		for i := range sh.Children {
+			if err := sh.Children[i].Validate(); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("invalid SDF shape type: %q", sh.Type)
+	}
+	return nil
+}
+
+func (sh *SDFShape) clone() SDFShape {
+	c := *sh
+	c.Children = make([]SDFShape, len(sh.Children))
+	for i := range sh.Children {
+		c.Children[i] = sh.Children[i].clone()
+	}
+	return c
+}
+
+// SDF objects are shapes defined by signed distance functions and intersected
+// by sphere tracing: rays advance by the distance to the surface until they
+// get close enough.
+type SDF struct {
+	Shape    SDFShape
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("SDF", func() Object { return new(SDF) })
+}
+
+// sdfMaxSteps bounds the # of sphere tracing steps per ray.
+const sdfMaxSteps = 256
+
+// tolerance returns the distance under which points are considered on the
+// surface of s, relative to its size.
+func (s *SDF) tolerance() float64 {
+	b := s.Shape.Bounds()
+	return 1e-6 * math.Max(1, geom.Distance(b.Min, b.Max))
+}
+
+func (s *SDF) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	t, tmax, ok := geom.RayAABBIntersection(s.Shape.Bounds(), ray)
+	if !ok {
+		return Hit{}, false
+	}
+	eps := s.tolerance()
+	dlen := ray.Dir.Module()
+	for i := 0; i < sdfMaxSteps && t <= tmax; i++ {
+		d := math.Abs(s.Shape.Distance(ray.At(t)))
+		if d < eps {
+			return Hit{T: t, Point: ray.At(t), Object: s}, true
+		}
+		t += d / dlen
+	}
+	return Hit{}, false
+}
+
+// NormalAt estimates the gradient of the distance function with central
+// differences.
+func (s *SDF) NormalAt(h *Hit, time float64) geom.Vector {
+	e := 10 * s.tolerance()
+	p := h.Point
+	d := func(dx, dy, dz float64) float64 {
+		return s.Shape.Distance(geom.Point{p.X + dx, p.Y + dy, p.Z + dz})
+	}
+	n := geom.Vector{
+		d(e, 0, 0) - d(-e, 0, 0),
+		d(0, e, 0) - d(0, -e, 0),
+		d(0, 0, e) - d(0, 0, -e),
+	}
+	return n.UnitVector()
+}
+
+func (s *SDF) Bounds() geom.AABB {
+	return s.Shape.Bounds()
+}
+
+func (s *SDF) Inside(p geom.Point, time float64) bool {
+	return s.Shape.Distance(p) < 0
+}
+
+func (s *SDF) ColorAt(h *Hit) Color {
+	return s.Color
+}
+
+func (s *SDF) MaterialAt(h *Hit) *Material {
+	return s.Material
+}
+
+func (s *SDF) Clone() Object {
+	c := *s
+	c.Shape = s.Shape.clone()
+	c.Material = s.Material.clone()
+	return &c
+}
+
+func (s *SDF) Validate() error {
+	if err := s.Shape.Validate(); err != nil {
+		return err
+	}
+	if err := s.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid SDF: %v", err)
+	}
+	if s.Material != nil {
+		if err := s.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid SDF: %v", err)
+		}
+		if err := s.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid SDF: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+func TestSDFDistance(t *testing.T) {
+	sphere := SDFShape{Type: "sphere", Center: geom.Point{0, 0, 0}, Radius: 1}
+	box := SDFShape{Type: "box", Center: geom.Point{3, 0, 0}, Size: geom.Vector{1, 1, 1}}
+	data := []struct {
+		sh  SDFShape
+		p   geom.Point
+		exp float64
+	}{
+		{sphere, geom.Point{0, 2, 0}, 1},
+		{sphere, geom.Point{0, 0, 0}, -1},
+		{box, geom.Point{3, 0, 3}, 2},
+		{box, geom.Point{5, 2, 0}, 1.4142135623730951},
+		{box, geom.Point{3, 0.5, 0}, -0.5},
+		{SDFShape{Type: "capsule", A: geom.Point{0, 0, 0}, B: geom.Point{0, 2, 0}, Radius: 1}, geom.Point{0, 4, 0}, 1},
+		{SDFShape{Type: "capsule", A: geom.Point{0, 0, 0}, B: geom.Point{0, 2, 0}, Radius: 1}, geom.Point{2, 1, 0}, 1},
+		{SDFShape{Type: "torus", Center: geom.Point{0, 0, 0}, Radius: 3, Minor: 1}, geom.Point{0, 0, 0}, 2},
+		{SDFShape{Type: "torus", Center: geom.Point{0, 0, 0}, Radius: 3, Minor: 1}, geom.Point{0, 2, 3}, 1},
+		{SDFShape{Type: "union", Children: []SDFShape{sphere, box}}, geom.Point{1.5, 0, 0}, 0.5},
+		{SDFShape{Type: "intersection", Children: []SDFShape{sphere, box}}, geom.Point{0, 0, 0}, 2},
+		{SDFShape{Type: "difference", Children: []SDFShape{sphere, box}}, geom.Point{0, 0, 0}, -1},
+	}
+	for i, d := range data {
+		if err := d.sh.Validate(); err != nil {
+			t.Errorf("#%d: valid shape rejected: %v", i, err)
+		}
+		if act := d.sh.Distance(d.p); !geom.FloatsEqual(act, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+}
+
+func TestSDFSmoothUnion(t *testing.T) {
+	a := SDFShape{Type: "sphere", Center: geom.Point{-1.5, 0, 0}, Radius: 1}
+	b := SDFShape{Type: "sphere", Center: geom.Point{1.5, 0, 0}, Radius: 1}
+	hard := SDFShape{Type: "union", Children: []SDFShape{a, b}}
+	smooth := SDFShape{Type: "union", Children: []SDFShape{a, b}, Smoothness: 3}
+	// The gap between the spheres is filled.
+	p := geom.Point{0, 0, 0}
+	if hard.Distance(p) <= 0 || smooth.Distance(p) >= 0 {
+		t.Fatalf("bad smooth union: hard: %v smooth: %v", hard.Distance(p), smooth.Distance(p))
+	}
+	// Far from the junction, blending has no effect.
+	p = geom.Point{-3, 0, 0}
+	if act, exp := smooth.Distance(p), hard.Distance(p); act != exp {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestSDFIntersect(t *testing.T) {
+	s := &SDF{Shape: SDFShape{Type: "box", Center: geom.Point{0, 0, 10}, Size: geom.Vector{1, 2, 3}}}
+	for _, d := range []struct {
+		r geom.Ray
+		p geom.Point
+		n geom.Vector
+	}{
+		{geom.MakeRay(geom.Point{0, 0, 0}, geom.Vector{0, 0, 1}), geom.Point{0, 0, 7}, geom.Vector{0, 0, -1}},
+		{geom.MakeRay(geom.Point{0.5, 10, 10}, geom.Vector{0, -1, 0}), geom.Point{0.5, 2, 10}, geom.Vector{0, 1, 0}},
+		{geom.MakeRay(geom.Point{0, 0, 10}, geom.Vector{1, 0, 0}), geom.Point{1, 0, 10}, geom.Vector{1, 0, 0}},
+	} {
+		h, ok := s.Intersect(d.r, 0)
+		if !ok {
+			t.Errorf("%v: SDF missed", d.r)
+			continue
+		}
+		if !geom.PointsEqual(h.Point, d.p, 1e-4) {
+			t.Errorf("%v: exp: %v act: %v", d.r, d.p, h.Point)
+		}
+		if n := s.NormalAt(&h, 0); !geom.VectorsEqual(n, d.n, 1e-4) {
+			t.Errorf("%v: exp: %v act: %v", d.r, d.n, n)
+		}
+	}
+	if _, ok := s.Intersect(geom.MakeRay(geom.Point{1.1, 0, 0}, geom.Vector{0, 0, 1}), 0); ok {
+		t.Errorf("SDF hit")
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math/rand"
+)
+
+// A Shader computes the color of the surface of objects where rays hit them.
+// Setting Scene.Shader replaces the default shading model to experiment with
+// others.
+type Shader interface {
+	// Shade returns the color at intersection h between ray r and an
+	// object of scene s.  depth is the number of bounces that secondary
+	// rays traced with Scene.Trace may still make.
+	Shade(h *Hit, s *Scene, r *TracedRay, depth int) Color
+}
+
+var shaderTypes = map[string]func() Shader{}
+
+// RegisterShaderType makes shaders created by newShader loadable from JSON
+// scenes under the given type name (see Scene.UnmarshalJSON).  It panics if
+// name is already registered.
+func RegisterShaderType(name string, newShader func() Shader) {
+	if _, dup := shaderTypes[name]; dup {
+		panic("raytracer: shader type registered twice: " + name)
+	}
+	shaderTypes[name] = newShader
+}
+
+func init() {
+	RegisterShaderType("Default", func() Shader { return new(DefaultShader) })
+}
+
+// decodeShader creates a shader from decoded JSON value v.
+func decodeShader(v interface{}) (Shader, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tag struct{ Type string }
+	if err := json.Unmarshal(raw, &tag); err != nil {
+		return nil, err
+	}
+	newShader, ok := shaderTypes[tag.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", tag.Type)
+	}
+	sh := newShader()
+	if err := json.Unmarshal(raw, sh); err != nil {
+		return nil, err
+	}
+	return sh, nil
+}
+
+// A TracedRay is a ray traced through a scene at a given time of the camera
+// shutter interval.
+type TracedRay struct {
+	geom.Ray
+	Time float64
+
+	kind rayKind
+	rng  *rand.Rand // nil for rays built by shaders
+	path rayPath
+}
+
+// DefaultShader is the shading model of scenes without shader.  Objects are
+// shaded with their material, or the diffuse coefficient of the scene, and
+// reflect and refract secondary rays.
+type DefaultShader struct{}
+
+func (DefaultShader) Shade(h *Hit, s *Scene, r *TracedRay, depth int) Color {
+	return s.shadeHit(h, r.Ray, r.Time, depth, r.random(), r.path)
+}
+
+// shader returns the shader of s.
+func (s *Scene) shader() Shader {
+	if s.Shader == nil {
+		return DefaultShader{}
+	}
+	return s.Shader
+}
+
+// random returns the random number generator used by shaders to sample
+// secondary rays of r.
+func (r *TracedRay) random() *rand.Rand {
+	if r.rng == nil {
+		r.rng = pixelRand(0, 0)
+	}
+	return r.rng
+}
+
+// Trace returns the color seen along r, the background color if it hits
+// nothing, following at most depth bounces.  Shaders use it to trace
+// secondary rays.
+func (s *Scene) Trace(r *TracedRay, depth int) Color {
+	return s.traceSecondaryRay(r.Ray, r.Time, r.kind, depth, r.random(), r.path)
+}
+
+// Lit returns whether light l reaches intersection h of ray r, that is l
+// lights the material of h, which is neither in shadow nor on the side of its
+// surface opposite to the light.
+func (s *Scene) Lit(h *Hit, r *TracedRay, l *Light) bool {
+	return s.lightFraction(h, r.Origin, r.Time, l, r.random()) > 0
+}
+
+// Spawn returns the secondary ray of r following ray, traced at the same
+// time along the same path.  Objects invisible in reflections are invisible
+// to it.
+func (r *TracedRay) Spawn(ray geom.Ray) *TracedRay {
+	return &TracedRay{ray, r.Time, reflectionRay, r.random(), r.path}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"github.com/nthery/goraytracer/geom"
+	"image/color"
+	"testing"
+)
+
+// flatShader shades points lit by any light with a color and others in black.
+type flatShader Color
+
+func (f flatShader) Shade(h *Hit, s *Scene, r *TracedRay, depth int) Color {
+	for i := range s.Lights {
+		if s.Lit(h, r, &s.Lights[i]) {
+			return Color(f)
+		}
+	}
+	return Color{}
+}
+
+// eyeShader shades points with the color seen from them toward the eye.
+type eyeShader struct{}
+
+func (eyeShader) Shade(h *Hit, s *Scene, r *TracedRay, depth int) Color {
+	d := r.Dir.Neg()
+	origin := h.Point.Translate(d.Scale(1e-6))
+	return s.Trace(r.Spawn(geom.MakeRay(origin, d)), depth-1)
+}
+
+func TestShader(t *testing.T) {
+	s := testScene()
+	def, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	s.Shader = DefaultShader{}
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !bytes.Equal(def.Pix, img.Pix) {
+		t.Errorf("default shader differs from scene without shader")
+	}
+
+	s.Shader = flatShader{0, 1, 0}
+	img, err = s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if exp, act := (color.RGBA{0, 255, 0, 255}), img.RGBAAt(10, 10); act != exp {
+		t.Errorf("lit sphere: exp: %v act: %v", exp, act)
+	}
+	if exp, act := def.RGBAAt(0, 0), img.RGBAAt(0, 0); act != exp {
+		t.Errorf("background: exp: %v act: %v", exp, act)
+	}
+	// Hide the light behind a sphere out of view.
+	s.Objects = append(s.Objects, &Sphere{Sphere: geom.Sphere{geom.Point{-88, 27, 9}, 8}})
+	img, err = s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if exp, act := (color.RGBA{0, 0, 0, 255}), img.RGBAAt(10, 10); act != exp {
+		t.Errorf("shadowed sphere: exp: %v act: %v", exp, act)
+	}
+	s.Objects = s.Objects[:1]
+
+	s.Shader = eyeShader{}
+	img, err = s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if exp, act := s.Bg.toRGBA(), img.RGBAAt(10, 10); act != exp {
+		t.Errorf("traced ray: exp: %v act: %v", exp, act)
+	}
+}
@@ -0,0 +1,550 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"image"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"math"
+)
+
+// A ColorStop is a color at a given position in [0..1] of a color ramp.
+// Colors between stops are interpolated.
+type ColorStop struct {
+	At    float64
+	Color Color
+}
+
+// A TextureType selects how a texture computes colors.
+type TextureType string
+
+const (
+	ImageTexture   TextureType = "image"   // colors of an image file
+	CheckerTexture TextureType = "checker" // squares alternating two colors
+	NoiseTexture   TextureType = "noise"   // Perlin noise blending two colors
+	MarbleTexture  TextureType = "marble"  // turbulent veins
+	WoodTexture    TextureType = "wood"    // noisy concentric rings
+)
+
+// A Texture varies the color of the surface of an object, replacing its
+// Color.  Textures are looked up with the (u, v) texture coordinates of
+// surface points, usually in [0..1].  Spheres are mapped with spherical
+// coordinates (see geom.Sphere.UVAt) and planes with planar ones (see
+// geom.Plane.UVAt).
+//
+// Image textures are stretched over the whole [0..1] range, u increasing
+// from left to right and v from top to bottom, and extended beyond as
+// selected by Wrap.  File is
+// a PNG, JPEG or Radiance HDR image loaded when rendering starts, relative
+// paths being relative to the current directory.  Filter selects how colors
+// are interpolated between pixels.
+//
+// Checker textures alternate squares of Colors[0] and Colors[1].  Scale is
+// the number of squares per unit of texture coordinates.
+//
+// Noise textures are solid textures whose colors vary with fractal Perlin
+// noise (see geom.FractalNoise) evaluated at surface points rather than
+// texture coordinates, avoiding seams.  Scale is the number of
+// noise features per unit of length, Octaves the number of octaves summed,
+// zero meaning 1, and Turbulence sums absolute noise values instead (see
+// geom.Turbulence) for cloudy or fiery looks.
+//
+// Marble and wood textures are solid textures too.  Marble veins run across
+// the x-axis, Scale veins per unit of length, and are bent by turbulence.
+// Wood rings are centered on the y-axis, Scale rings per unit of length, and
+// are distorted by fractal noise.  Distortion is the amplitude of the
+// perturbation of veins and rings and Octaves the number of octaves of
+// noise.
+//
+// Solid textures blend Colors[0] and Colors[1] unless Ramp is set.
+//
+// Tiling, Offset and Rotation transform the texture coordinates of image and
+// checker textures so that objects of different sizes can share a texture:
+// (u, v) is scaled by Tiling, zero components meaning 1, rotated
+// counterclockwise by Rotation degrees and shifted by Offset.
+//
+// Wrap selects how image and checker textures extend beyond [0..1] texture
+// coordinates, BorderColor being the color outside this range for
+// BorderWrap.
+type Texture struct {
+	Type        TextureType
+	File        string
+	Colors      [2]Color
+	Ramp        []ColorStop
+	Scale       float64
+	Octaves     int
+	Turbulence  bool
+	Distortion  float64
+	Filter      TextureFilter
+	Tiling      [2]float64
+	Offset      [2]float64
+	Rotation    float64
+	Wrap        TextureWrap
+	BorderColor Color
+
+	// Image of image textures followed by its mipmaps if any, set by
+	// prepare.
+	levels []texelMap
+}
+
+// A TextureFilter selects how image textures are sampled.
+type TextureFilter string
+
+const (
+	// NearestFilter picks the color of the nearest pixel, which renders
+	// magnified textures as blocks and aliases minified ones.
+	NearestFilter TextureFilter = "nearest"
+
+	// BilinearFilter, the default, interpolates the colors of the four
+	// nearest pixels, which smoothes magnified textures.
+	BilinearFilter TextureFilter = "bilinear"
+
+	// MipmapFilter also interpolates between downsampled copies of the
+	// image selected according to the footprint of pixels on the surface
+	// (see Hit.Footprint), which removes aliasing of minified textures
+	// such as floors receding toward the horizon.
+	MipmapFilter TextureFilter = "mipmap"
+)
+
+// A TextureWrap selects how textures are extended beyond [0..1] texture
+// coordinates.
+type TextureWrap string
+
+const (
+	// RepeatWrap, the default, tiles the texture.
+	RepeatWrap TextureWrap = "repeat"
+
+	// MirrorWrap tiles the texture flipping every other copy so that
+	// edges match.
+	MirrorWrap TextureWrap = "mirror"
+
+	// ClampWrap stretches the edges of the texture.
+	ClampWrap TextureWrap = "clamp"
+
+	// BorderWrap colors surfaces outside the texture with BorderColor, as
+	// for decals.
+	BorderWrap TextureWrap = "border"
+)
+
+func (t *Texture) Validate() error {
+	switch t.Wrap {
+	case "", RepeatWrap, MirrorWrap, ClampWrap, BorderWrap:
+	default:
+		return fmt.Errorf("invalid texture wrap: %q", t.Wrap)
+	}
+	if err := t.BorderColor.Validate(); err != nil {
+		return fmt.Errorf("invalid texture border: %v", err)
+	}
+	switch t.Type {
+	case ImageTexture:
+		if t.File == "" {
+			return fmt.Errorf("invalid texture: no image file")
+		}
+		switch t.Filter {
+		case "", NearestFilter, BilinearFilter, MipmapFilter:
+		default:
+			return fmt.Errorf("invalid texture filter: %q", t.Filter)
+		}
+	case CheckerTexture, NoiseTexture, MarbleTexture, WoodTexture:
+		for _, c := range t.Colors {
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("invalid texture: %v", err)
+			}
+		}
+		if t.Scale <= 0 {
+			return fmt.Errorf("invalid texture: non-positive scale: %v", t.Scale)
+		}
+		if t.Octaves < 0 {
+			return fmt.Errorf("invalid texture: negative octaves: %v", t.Octaves)
+		}
+		if t.Distortion < 0 {
+			return fmt.Errorf("invalid texture: negative distortion: %v", t.Distortion)
+		}
+		for i, st := range t.Ramp {
+			if st.At < 0 || st.At > 1 || (i > 0 && st.At < t.Ramp[i-1].At) {
+				return fmt.Errorf("invalid texture: ramp stops not increasing in [0..1]")
+			}
+			if err := st.Color.Validate(); err != nil {
+				return fmt.Errorf("invalid texture: ramp %v", err)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid texture type: %q", t.Type)
+	}
+	return nil
+}
+
+// clone returns a copy of t, nil if t is nil.
+func (t *Texture) clone() *Texture {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	c.Ramp = append([]ColorStop(nil), t.Ramp...)
+	return &c
+}
+
+// prepare loads the image of image textures and builds its mipmaps if
+// needed.
+func (t *Texture) prepare() error {
+	if t.Type != ImageTexture || t.levels != nil {
+		return nil
+	}
+	levels, err := loadTexelMaps(t.File, t.Filter == MipmapFilter)
+	if err != nil {
+		return fmt.Errorf("invalid texture: %v", err)
+	}
+	t.levels = levels
+	return nil
+}
+
+// colorAt returns the color of t at surface point h.  size is the length in
+// world units spanned by a unit of texture coordinates on the surface, zero
+// if unknown, and selects mipmaps.
+func (t *Texture) colorAt(h *Hit, size float64) Color {
+	switch t.Type {
+	case NoiseTexture:
+		return t.noiseColorAt(h.Point)
+	case MarbleTexture:
+		return t.marbleColorAt(h.Point)
+	case WoodTexture:
+		return t.woodColorAt(h.Point)
+	}
+	u, v := t.transformUV(h.U, h.V)
+	if t.Wrap == BorderWrap && (u < 0 || u > 1 || v < 0 || v > 1) {
+		return t.BorderColor
+	}
+	if t.Type == CheckerTexture {
+		u, v = wrapCoord(u, t.Wrap), wrapCoord(v, t.Wrap)
+		i := int64(math.Floor(u*t.Scale)) + int64(math.Floor(v*t.Scale))
+		return t.Colors[i&1]
+	}
+	su, sv := t.tiling()
+	return t.imageColorAt(u, v, h.Footprint, size/math.Sqrt(math.Abs(su*sv)))
+}
+
+// solid returns whether t computes colors from surface points rather than
+// texture coordinates.
+func (t *Texture) solid() bool {
+	switch t.Type {
+	case NoiseTexture, MarbleTexture, WoodTexture:
+		return true
+	}
+	return false
+}
+
+// tiling returns the scale factors applied by t to texture coordinates.
+func (t *Texture) tiling() (su, sv float64) {
+	su, sv = t.Tiling[0], t.Tiling[1]
+	if su == 0 {
+		su = 1
+	}
+	if sv == 0 {
+		sv = 1
+	}
+	return su, sv
+}
+
+// transformUV returns texture coordinates (u, v) scaled, rotated and shifted
+// as set by the Tiling, Rotation and Offset fields of t.
+func (t *Texture) transformUV(u, v float64) (float64, float64) {
+	su, sv := t.tiling()
+	u, v = u*su, v*sv
+	if t.Rotation != 0 {
+		sin, cos := math.Sincos(t.Rotation * math.Pi / 180)
+		u, v = u*cos-v*sin, u*sin+v*cos
+	}
+	return u + t.Offset[0], v + t.Offset[1]
+}
+
+// octaves returns the number of octaves of noise of t.
+func (t *Texture) octaves() int {
+	if t.Octaves == 0 {
+		return 1
+	}
+	return t.Octaves
+}
+
+// noiseColorAt returns the color of noise texture t at point p.
+func (t *Texture) noiseColorAt(p geom.Point) Color {
+	p = geom.Point{p.X * t.Scale, p.Y * t.Scale, p.Z * t.Scale}
+	var k float64
+	if t.Turbulence {
+		k = geom.Turbulence(p, t.octaves())
+	} else {
+		k = (geom.FractalNoise(p, t.octaves()) + 1) / 2
+	}
+	return t.rampColor(k)
+}
+
+// marbleColorAt returns the color of marble texture t at point p.
+//
+// Algorithm taken from:
+// 	Perlin, "An Image Synthesizer", SIGGRAPH 1985.
+func (t *Texture) marbleColorAt(p geom.Point) Color {
+	p = geom.Point{p.X * t.Scale, p.Y * t.Scale, p.Z * t.Scale}
+	x := p.X + t.Distortion*geom.Turbulence(p, t.octaves())
+	return t.rampColor((1 + math.Sin(2*math.Pi*x)) / 2)
+}
+
+// woodColorAt returns the color of wood texture t at point p.
+func (t *Texture) woodColorAt(p geom.Point) Color {
+	p = geom.Point{p.X * t.Scale, p.Y * t.Scale, p.Z * t.Scale}
+	r := math.Hypot(p.X, p.Z) + t.Distortion*geom.FractalNoise(p, t.octaves())
+	return t.rampColor(r - math.Floor(r))
+}
+
+// rampColor returns the color at k in [0..1] of the color ramp of solid
+// texture t, clamping k.
+func (t *Texture) rampColor(k float64) Color {
+	k = math.Max(0, math.Min(1, k))
+	if len(t.Ramp) == 0 {
+		return blendColors(t.Colors[0], t.Colors[1], k)
+	}
+	if k <= t.Ramp[0].At {
+		return t.Ramp[0].Color
+	}
+	for i := 1; i < len(t.Ramp); i++ {
+		a, b := &t.Ramp[i-1], &t.Ramp[i]
+		if k <= b.At {
+			if b.At == a.At {
+				return b.Color
+			}
+			return blendColors(a.Color, b.Color, (k-a.At)/(b.At-a.At))
+		}
+	}
+	return t.Ramp[len(t.Ramp)-1].Color
+}
+
+// blendColors returns the linear interpolation between a and b at k in
+// [0..1].
+func blendColors(a, b Color, k float64) Color {
+	return Color{
+		a.R + k*(b.R-a.R),
+		a.G + k*(b.G-a.G),
+		a.B + k*(b.B-a.B),
+	}
+}
+
+// imageColorAt returns the color of image texture t at texture coordinates
+// (u, v) for a pixel footprint and texture size as described in colorAt and
+// Hit.Footprint.
+func (t *Texture) imageColorAt(u, v, footprint, size float64) Color {
+	switch t.Filter {
+	case NearestFilter:
+		return t.levels[0].nearest(u, v, t.Wrap)
+	case MipmapFilter:
+		if footprint > 0 && size > 0 {
+			// Level of detail where a pixel covers about one texel.
+			lod := math.Log2(footprint / size * float64(t.levels[0].w))
+			if lod > 0 {
+				last := len(t.levels) - 1
+				l := int(lod)
+				if l >= last {
+					return t.levels[last].bilinear(u, v, t.Wrap)
+				}
+				k := lod - float64(l)
+				return blendColors(t.levels[l].bilinear(u, v, t.Wrap), t.levels[l+1].bilinear(u, v, t.Wrap), k)
+			}
+		}
+	}
+	return t.levels[0].bilinear(u, v, t.Wrap)
+}
+
+// A texelMap is an image converted to colors, row by row.
+type texelMap struct {
+	w, h int
+	pix  []Color
+}
+
+// loadTexelMap decodes the PNG, JPEG or Radiance HDR image stored in file.
+func loadTexelMap(file string) (texelMap, error) {
+	levels, err := loadTexelMaps(file, false)
+	if err != nil {
+		return texelMap{}, err
+	}
+	return levels[0], nil
+}
+
+// loadTexelMaps returns the image stored in file followed by its mipmaps if
+// mipmaps is set.  Both are cached in assets.
+func loadTexelMaps(file string, mipmaps bool) ([]texelMap, error) {
+	data, key, err := readAsset(file, "image")
+	if err != nil {
+		return nil, err
+	}
+	var levels []texelMap
+	if v, ok := assets.get(key); ok {
+		levels = v.([]texelMap)
+	} else {
+		m, err := decodeTexelMap(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		levels = []texelMap{m}
+		assets.put(key, levels)
+	}
+	if !mipmaps {
+		return levels[:1:1], nil
+	}
+	if len(levels) == 1 {
+		for m := &levels[0]; m.w > 1 || m.h > 1; m = &levels[len(levels)-1] {
+			levels = append(levels, m.downsample())
+		}
+		assets.put(key, levels)
+	}
+	return levels, nil
+}
+
+// decodeTexelMap decodes a PNG, JPEG or Radiance HDR image.
+func decodeTexelMap(data []byte) (texelMap, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	if isHDR(r) {
+		return decodeHDR(r)
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return texelMap{}, err
+	}
+	return makeTexelMap(img), nil
+}
+
+func makeTexelMap(img image.Image) texelMap {
+	b := img.Bounds()
+	m := texelMap{b.Dx(), b.Dy(), make([]Color, b.Dx()*b.Dy())}
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			m.pix[y*m.w+x] = Color{float64(r) / 0xffff, float64(g) / 0xffff, float64(bl) / 0xffff}
+		}
+	}
+	return m
+}
+
+// at returns the color of texel (x, y), extending m beyond its bounds as
+// selected by wrap.
+func (m *texelMap) at(x, y int, wrap TextureWrap) Color {
+	return m.pix[wrapTexel(y, m.h, wrap)*m.w+wrapTexel(x, m.w, wrap)]
+}
+
+// nearest returns the color of the texel covering texture coordinates (u, v).
+func (m *texelMap) nearest(u, v float64, wrap TextureWrap) Color {
+	return m.pix[wrapPixel(v, m.h, wrap)*m.w+wrapPixel(u, m.w, wrap)]
+}
+
+// bilinear returns the color at texture coordinates (u, v) interpolated
+// between the centers of the four nearest texels.
+func (m *texelMap) bilinear(u, v float64, wrap TextureWrap) Color {
+	x := u*float64(m.w) - 0.5
+	y := v*float64(m.h) - 0.5
+	fx, fy := math.Floor(x), math.Floor(y)
+	kx, ky := x-fx, y-fy
+	x0, y0 := int(fx), int(fy)
+	top := blendColors(m.at(x0, y0, wrap), m.at(x0+1, y0, wrap), kx)
+	bottom := blendColors(m.at(x0, y0+1, wrap), m.at(x0+1, y0+1, wrap), kx)
+	return blendColors(top, bottom, ky)
+}
+
+// downsample returns the next level of the mipmap chain of m, half its size
+// rounded up, each texel averaging up to four texels of m.
+func (m *texelMap) downsample() texelMap {
+	w, h := (m.w+1)/2, (m.h+1)/2
+	d := texelMap{w, h, make([]Color, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum Color
+			n := 0.0
+			for _, sy := range []int{2 * y, 2*y + 1} {
+				for _, sx := range []int{2 * x, 2*x + 1} {
+					if sx < m.w && sy < m.h {
+						c := m.pix[sy*m.w+sx]
+						sum.R += c.R
+						sum.G += c.G
+						sum.B += c.B
+						n++
+					}
+				}
+			}
+			d.pix[y*w+x] = Color{sum.R / n, sum.G / n, sum.B / n}
+		}
+	}
+	return d
+}
+
+// wrapPixel returns the index of the pixel covering coordinate c in [0..1]
+// of an image row or column of n pixels.  Coordinates outside this range
+// extend the image as selected by wrap.
+func wrapPixel(c float64, n int, wrap TextureWrap) int {
+	return wrapTexel(int(math.Floor(c*float64(n))), n, wrap)
+}
+
+// wrapTexel returns the index in [0..n) of the texel of an image row or
+// column of n texels extended as selected by wrap to index i.  Bordered
+// images are clamped, the border being handled on texture coordinates.
+func wrapTexel(i, n int, wrap TextureWrap) int {
+	switch wrap {
+	case MirrorWrap:
+		i %= 2 * n
+		if i < 0 {
+			i += 2 * n
+		}
+		if i >= n {
+			i = 2*n - 1 - i
+		}
+	case ClampWrap, BorderWrap:
+		if i < 0 {
+			i = 0
+		} else if i >= n {
+			i = n - 1
+		}
+	default:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+	}
+	return i
+}
+
+// wrapCoord returns texture coordinate c folded into [0..1] as selected by
+// wrap.  Repeated coordinates are returned unchanged as lookups are periodic.
+func wrapCoord(c float64, wrap TextureWrap) float64 {
+	switch wrap {
+	case MirrorWrap:
+		c = math.Mod(c, 2)
+		if c < 0 {
+			c += 2
+		}
+		if c > 1 {
+			c = 2 - c
+		}
+	case ClampWrap, BorderWrap:
+		c = math.Max(0, math.Min(c, math.Nextafter(1, 0)))
+	}
+	return c
+}
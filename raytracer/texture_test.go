@@ -0,0 +1,510 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestImage saves a PNG image with a red left half and a green right
+// half in a temporary directory and returns its path.
+func writeTestImage(t *testing.T) string {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	img.SetRGBA(1, 0, color.RGBA{0, 255, 0, 255})
+	path := filepath.Join(t.TempDir(), "texture.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTextureValidate(t *testing.T) {
+	data := []struct {
+		tex Texture
+		ok  bool
+	}{
+		{Texture{Type: ImageTexture, File: "earth.png"}, true},
+		{Texture{Type: ImageTexture}, false},
+		{Texture{Type: ImageTexture, File: "earth.png", Filter: MipmapFilter}, true},
+		{Texture{Type: ImageTexture, File: "earth.png", Filter: "cubic"}, false},
+		{Texture{Type: CheckerTexture, Colors: [2]Color{{0, 0, 0}, {1, 1, 1}}, Scale: 8}, true},
+		{Texture{Type: CheckerTexture, Colors: [2]Color{{0, 0, 0}, {2, 1, 1}}, Scale: 8}, false},
+		{Texture{Type: CheckerTexture}, false},
+		{Texture{Type: NoiseTexture, Colors: [2]Color{{0, 0, 0}, {1, 1, 1}}, Scale: 0.1, Octaves: 4}, true},
+		{Texture{Type: NoiseTexture, Colors: [2]Color{{0, 0, 0}, {1, 1, 1}}, Scale: 0.1, Octaves: -1}, false},
+		{Texture{Type: MarbleTexture, Scale: 0.1, Distortion: 5,
+			Ramp: []ColorStop{{0, Color{1, 1, 1}}, {0.8, Color{0.5, 0.5, 0.5}}, {1, Color{0, 0, 0}}}}, true},
+		{Texture{Type: WoodTexture, Scale: 0.1, Distortion: -1}, false},
+		{Texture{Type: WoodTexture, Scale: 0.1,
+			Ramp: []ColorStop{{0.5, Color{1, 1, 1}}, {0.2, Color{0, 0, 0}}}}, false},
+		{Texture{Type: WoodTexture, Scale: 0.1, Ramp: []ColorStop{{1.5, Color{1, 1, 1}}}}, false},
+		{Texture{Type: CheckerTexture, Colors: [2]Color{{0, 0, 0}, {1, 1, 1}}, Scale: 8, Wrap: MirrorWrap}, true},
+		{Texture{Type: CheckerTexture, Colors: [2]Color{{0, 0, 0}, {1, 1, 1}}, Scale: 8, Wrap: "wrap"}, false},
+		{Texture{Type: ImageTexture, File: "earth.png", Wrap: BorderWrap, BorderColor: Color{0, 0, 2}}, false},
+		{Texture{Type: "bogus"}, false},
+	}
+	for _, d := range data {
+		if err := d.tex.Validate(); (err == nil) != d.ok {
+			t.Errorf("%+v: exp: %v act: %v", d.tex, d.ok, err)
+		}
+	}
+}
+
+func TestImageTexture(t *testing.T) {
+	tex := Texture{Type: ImageTexture, File: writeTestImage(t), Filter: NearestFilter}
+	if err := tex.prepare(); err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	red, green := Color{1, 0, 0}, Color{0, 1, 0}
+	data := []struct {
+		u, v float64
+		exp  Color
+	}{
+		{0, 0, red},
+		{0.25, 0.5, red},
+		{0.75, 0.5, green},
+		{0.99, 0.99, green},
+		// Coordinates out of range wrap around.
+		{1.25, 0.5, red},
+		{-0.25, 0.5, green},
+	}
+	for _, d := range data {
+		if act := tex.colorAt(&Hit{U: d.u, V: d.v}, 0); act != d.exp {
+			t.Errorf("(%v, %v): exp: %v act: %v", d.u, d.v, d.exp, act)
+		}
+	}
+
+	tex = Texture{Type: ImageTexture, File: filepath.Join(t.TempDir(), "missing.png")}
+	if err := tex.prepare(); err == nil {
+		t.Errorf("missing image loaded")
+	}
+}
+
+func TestTextureFilters(t *testing.T) {
+	file := writeTestImage(t)
+	red, green := Color{1, 0, 0}, Color{0, 1, 0}
+	yellow := Color{0.5, 0.5, 0}
+
+	tex := Texture{Type: ImageTexture, File: file}
+	if err := tex.prepare(); err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	data := []struct {
+		u, v float64
+		exp  Color
+	}{
+		// Pixel centers.
+		{0.25, 0.5, red},
+		{0.75, 0.5, green},
+		// Halfway between pixels, wrapping around.
+		{0.5, 0.5, yellow},
+		{0, 0.5, yellow},
+		{0.375, 0.5, Color{0.75, 0.25, 0}},
+	}
+	for _, d := range data {
+		if act := tex.colorAt(&Hit{U: d.u, V: d.v}, 0); !colorsEqual(act, d.exp) {
+			t.Errorf("bilinear: (%v, %v): exp: %v act: %v", d.u, d.v, d.exp, act)
+		}
+	}
+
+	tex = Texture{Type: ImageTexture, File: file, Filter: MipmapFilter}
+	if err := tex.prepare(); err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	if n := len(tex.levels); n != 2 {
+		t.Fatalf("bad mipmap chain length: %v", n)
+	}
+	// Small footprints select the image and large ones the average of its
+	// pixels.
+	data = []struct {
+		u, v float64
+		exp  Color
+	}{
+		{0.25, 0.5, red},
+		{0.25, 0.5, yellow},
+		{0.25, 0.5, Color{0.75, 0.25, 0}},
+	}
+	for i, footprint := range []float64{0.1, 10, math.Sqrt2 / 2} {
+		d := data[i]
+		h := Hit{U: d.u, V: d.v, Footprint: footprint}
+		if act := tex.colorAt(&h, 1); !colorsEqual(act, d.exp) {
+			t.Errorf("mipmap: footprint %v: exp: %v act: %v", footprint, d.exp, act)
+		}
+	}
+}
+
+func TestTransformUV(t *testing.T) {
+	data := []struct {
+		tex  Texture
+		u, v float64
+		expU float64
+		expV float64
+	}{
+		{Texture{}, 0.25, 0.5, 0.25, 0.5},
+		{Texture{Tiling: [2]float64{2, 0}}, 0.25, 0.5, 0.5, 0.5},
+		{Texture{Tiling: [2]float64{2, 3}}, 0.25, 0.5, 0.5, 1.5},
+		{Texture{Offset: [2]float64{0.5, -0.25}}, 0.25, 0.5, 0.75, 0.25},
+		{Texture{Rotation: 90}, 0.25, 0.5, -0.5, 0.25},
+		// Scaling happens first, then rotation and finally offset.
+		{Texture{Tiling: [2]float64{2, 2}, Rotation: 180, Offset: [2]float64{1, 1}}, 0.25, 0.5, 0.5, 0},
+	}
+	for i, d := range data {
+		u, v := d.tex.transformUV(d.u, d.v)
+		if !geom.FloatsEqual(u, d.expU, 1e-9) || !geom.FloatsEqual(v, d.expV, 1e-9) {
+			t.Errorf("#%d: exp: (%v, %v) act: (%v, %v)", i, d.expU, d.expV, u, v)
+		}
+	}
+
+	// Tiling repeats image textures.
+	file := writeTestImage(t)
+	tex := Texture{Type: ImageTexture, File: file, Filter: NearestFilter, Tiling: [2]float64{2, 1}}
+	if err := tex.prepare(); err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	red, green := Color{1, 0, 0}, Color{0, 1, 0}
+	for i, exp := range []Color{red, green, red, green} {
+		u := (float64(i) + 0.5) / 4
+		if act := tex.colorAt(&Hit{U: u, V: 0.5}, 0); act != exp {
+			t.Errorf("tiled image: u=%v: exp: %v act: %v", u, exp, act)
+		}
+	}
+}
+
+func TestTextureWrap(t *testing.T) {
+	file := writeTestImage(t)
+	red, green, blue := Color{1, 0, 0}, Color{0, 1, 0}, Color{0, 0, 1}
+	black, white := Color{0, 0, 0}, Color{1, 1, 1}
+	data := []struct {
+		wrap TextureWrap
+		u, v float64
+		img  Color // nearest image color
+		chk  Color // checker color
+	}{
+		{"", 1.25, 0.25, red, black},
+		{"", -0.25, 0.25, green, white},
+		{RepeatWrap, 1.25, 0.25, red, black},
+		{MirrorWrap, 1.25, 0.25, green, white},
+		{MirrorWrap, -0.25, 0.25, red, black},
+		{MirrorWrap, 0.25, -1.25, red, white},
+		{ClampWrap, 1.25, 0.25, green, white},
+		{ClampWrap, -0.25, 0.25, red, black},
+		{ClampWrap, 0.25, 3, red, white},
+		{BorderWrap, 1.25, 0.25, blue, blue},
+		{BorderWrap, 0.25, -0.5, blue, blue},
+		{BorderWrap, 0.75, 0.25, green, white},
+	}
+	for i, d := range data {
+		img := Texture{Type: ImageTexture, File: file, Filter: NearestFilter, Wrap: d.wrap, BorderColor: blue}
+		if err := img.prepare(); err != nil {
+			t.Fatalf("loading failed: %v", err)
+		}
+		if act := img.colorAt(&Hit{U: d.u, V: d.v}, 0); act != d.img {
+			t.Errorf("#%d: image: exp: %v act: %v", i, d.img, act)
+		}
+		chk := Texture{Type: CheckerTexture, Colors: [2]Color{black, white}, Scale: 2, Wrap: d.wrap, BorderColor: blue}
+		if act := chk.colorAt(&Hit{U: d.u, V: d.v}, 0); act != d.chk {
+			t.Errorf("#%d: checker: exp: %v act: %v", i, d.chk, act)
+		}
+	}
+
+	// Bilinear filtering blends texels across edges of repeated textures
+	// only.
+	yellow := Color{0.5, 0.5, 0}
+	for _, d := range []struct {
+		wrap TextureWrap
+		exp  Color
+	}{
+		{RepeatWrap, yellow},
+		{MirrorWrap, red},
+		{ClampWrap, red},
+	} {
+		tex := Texture{Type: ImageTexture, File: file, Wrap: d.wrap}
+		if err := tex.prepare(); err != nil {
+			t.Fatalf("loading failed: %v", err)
+		}
+		if act := tex.colorAt(&Hit{U: 0, V: 0.5}, 0); !colorsEqual(act, d.exp) {
+			t.Errorf("%v: exp: %v act: %v", d.wrap, d.exp, act)
+		}
+	}
+}
+
+func TestTextureCache(t *testing.T) {
+	file := writeTestImage(t)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := filepath.Join(t.TempDir(), "copy.png")
+	if err := os.WriteFile(copied, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	a := Texture{Type: ImageTexture, File: file}
+	b := Texture{Type: ImageTexture, File: copied}
+	for _, tex := range []*Texture{&a, &b} {
+		if err := tex.prepare(); err != nil {
+			t.Fatalf("loading failed: %v", err)
+		}
+	}
+	if &a.levels[0].pix[0] != &b.levels[0].pix[0] {
+		t.Errorf("identical images decoded twice")
+	}
+
+	// Editing a file reloads it.
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, color.RGBA{0, 0, 255, 255})
+	f, err := os.Create(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	c := Texture{Type: ImageTexture, File: copied}
+	if err := c.prepare(); err != nil {
+		t.Fatalf("loading failed: %v", err)
+	}
+	if act, exp := c.colorAt(&Hit{U: 0.5, V: 0.5}, 0), (Color{0, 0, 1}); act != exp {
+		t.Errorf("stale image: exp: %v act: %v", exp, act)
+	}
+
+	// Mipmaps are cached with images.
+	a = Texture{Type: ImageTexture, File: file, Filter: MipmapFilter}
+	b = Texture{Type: ImageTexture, File: file, Filter: MipmapFilter}
+	for _, tex := range []*Texture{&a, &b} {
+		if err := tex.prepare(); err != nil {
+			t.Fatalf("loading failed: %v", err)
+		}
+	}
+	if len(a.levels) != 2 || &a.levels[1].pix[0] != &b.levels[1].pix[0] {
+		t.Errorf("mipmaps built twice")
+	}
+
+	// The oldest entries are evicted first.
+	cache := assetCache{entries: make(map[assetKey]interface{})}
+	key := func(i int) assetKey { return assetKey{"image", sha256.Sum256([]byte{byte(i)})} }
+	for i := 0; i <= maxCachedAssets; i++ {
+		cache.put(key(i), i)
+	}
+	if _, ok := cache.get(key(0)); ok {
+		t.Errorf("oldest entry not evicted")
+	}
+	if _, ok := cache.get(key(maxCachedAssets)); !ok {
+		t.Errorf("newest entry evicted")
+	}
+}
+
+func TestTexelMapDownsample(t *testing.T) {
+	m := texelMap{3, 1, []Color{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}
+	d := m.downsample()
+	exp := texelMap{2, 1, []Color{{0.5, 0.5, 0}, {0, 0, 1}}}
+	if d.w != exp.w || d.h != exp.h || d.pix[0] != exp.pix[0] || d.pix[1] != exp.pix[1] {
+		t.Errorf("exp: %v act: %v", exp, d)
+	}
+}
+
+func TestCheckerTexture(t *testing.T) {
+	black, white := Color{0, 0, 0}, Color{1, 1, 1}
+	tex := Texture{Type: CheckerTexture, Colors: [2]Color{black, white}, Scale: 2}
+	data := []struct {
+		u, v float64
+		exp  Color
+	}{
+		{0.25, 0.25, black},
+		{0.75, 0.25, white},
+		{0.25, 0.75, white},
+		{0.75, 0.75, black},
+		{-0.25, 0.25, white},
+		{-0.25, -0.25, black},
+	}
+	for _, d := range data {
+		if act := tex.colorAt(&Hit{U: d.u, V: d.v}, 0); act != d.exp {
+			t.Errorf("(%v, %v): exp: %v act: %v", d.u, d.v, d.exp, act)
+		}
+	}
+}
+
+func TestNoiseTexture(t *testing.T) {
+	black, white := Color{0, 0, 0}, Color{1, 1, 1}
+	for _, turbulence := range []bool{false, true} {
+		tex := Texture{Type: NoiseTexture, Colors: [2]Color{black, white}, Scale: 0.5,
+			Octaves: 3, Turbulence: turbulence}
+		var min, max float64 = 1, 0
+		for i := 0; i < 100; i++ {
+			h := Hit{Point: geom.Point{float64(i) * 0.37, float64(i) * 0.11, 1.3}}
+			c := tex.colorAt(&h, 0)
+			if c.R != c.G || c.G != c.B || c.R < 0 || c.R > 1 {
+				t.Fatalf("turbulence %v: %v: color out of range: %v", turbulence, h.Point, c)
+			}
+			min, max = math.Min(min, c.R), math.Max(max, c.R)
+		}
+		if max-min < 0.2 {
+			t.Errorf("turbulence %v: flat noise: [%v..%v]", turbulence, min, max)
+		}
+	}
+}
+
+func TestRampColor(t *testing.T) {
+	tex := Texture{Ramp: []ColorStop{
+		{0.2, Color{1, 0, 0}},
+		{0.6, Color{0, 1, 0}},
+		{0.6, Color{0, 0, 1}},
+		{1, Color{0, 0, 0}},
+	}}
+	data := []struct {
+		k   float64
+		exp Color
+	}{
+		{-1, Color{1, 0, 0}},
+		{0.1, Color{1, 0, 0}},
+		{0.4, Color{0.5, 0.5, 0}},
+		{0.6, Color{0, 1, 0}},
+		{0.8, Color{0, 0, 0.5}},
+		{2, Color{0, 0, 0}},
+	}
+	for _, d := range data {
+		if act := tex.rampColor(d.k); !colorsEqual(act, d.exp) {
+			t.Errorf("%v: exp: %v act: %v", d.k, d.exp, act)
+		}
+	}
+
+	// Without ramp, the two colors are blended.
+	tex = Texture{Colors: [2]Color{{0, 0, 0}, {1, 1, 1}}}
+	if act, exp := tex.rampColor(0.25), (Color{0.25, 0.25, 0.25}); act != exp {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+}
+
+func TestMarbleAndWoodTextures(t *testing.T) {
+	black, white := Color{0, 0, 0}, Color{1, 1, 1}
+	data := []struct {
+		typ TextureType
+		exp Color // undistorted color at x == 0.5 on the xy-plane
+	}{
+		{MarbleTexture, white},
+		{WoodTexture, Color{0.25, 0.25, 0.25}},
+	}
+	for _, d := range data {
+		tex := Texture{Type: d.typ, Colors: [2]Color{black, white}, Scale: 0.5, Octaves: 3}
+		// Undistorted veins and rings are regular.
+		for _, y := range []float64{0, 3} {
+			h := Hit{Point: geom.Point{0.5, y, 0}}
+			if act := tex.colorAt(&h, 0); !colorsEqual(act, d.exp) {
+				t.Errorf("%v: %v: exp: %v act: %v", d.typ, h.Point, d.exp, act)
+			}
+		}
+		// Distortion bends them.
+		tex.Distortion = 2
+		var min, max float64 = 1, 0
+		for i := 0; i < 100; i++ {
+			h := Hit{Point: geom.Point{0.5, float64(i) * 0.13, 0}}
+			c := tex.colorAt(&h, 0)
+			min, max = math.Min(min, c.R), math.Max(max, c.R)
+		}
+		if max-min < 0.2 {
+			t.Errorf("%v: distortion ignored: [%v..%v]", d.typ, min, max)
+		}
+	}
+}
+
+// colorsEqual returns whether a and b are equal within rounding errors.
+func colorsEqual(a, b Color) bool {
+	return geom.FloatsEqual(a.R, b.R, 1e-9) && geom.FloatsEqual(a.G, b.G, 1e-9) &&
+		geom.FloatsEqual(a.B, b.B, 1e-9)
+}
+
+func TestTextureRender(t *testing.T) {
+	file, err := json.Marshal(writeTestImage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := testScene()
+	var l ObjectList
+	// The right half of the image covers the side of the sphere facing the
+	// eye.
+	data := fmt.Sprintf(`[{ "Sphere": { "Center": {"X":0,"Y":0,"Z":60}, "Radius":5 },
+		"Color": {"R":1, "G":0, "B":0},
+		"Material": { "Ambient": 0.1, "Diffuse": 0.9,
+			"Texture": { "Type": "image", "File": %s, "Filter": "nearest" } } }]`, file)
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l...)
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(11, 10); act.G == 0 || act.R != act.B {
+		t.Fatalf("texture not rendered: %v", act)
+	}
+	if act := img.RGBAAt(8, 10); act.R == 0 || act.G != act.B {
+		t.Fatalf("texture not rendered: %v", act)
+	}
+
+	s.Objects[1].(*Sphere).Material.Texture.File += ".missing"
+	if _, err := s.Render(1); err == nil {
+		t.Fatalf("missing texture image accepted")
+	}
+}
+
+func TestCheckerRender(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	// Checkerboard wall behind the red sphere with squares 10 units wide.
+	data := `[{ "Type": "Plane",
+		"Plane": { "Point": {"X":0,"Y":0,"Z":95}, "Normal": {"X":0,"Y":0,"Z":-1} },
+		"Color": {"R":1, "G":1, "B":1},
+		"Material": { "Ambient": 0.1, "Diffuse": 0.9,
+			"Texture": { "Type": "checker", "Scale": 0.1,
+				"Colors": [ {"R":0, "G":1, "B":0}, {"R":0, "G":0, "B":1} ] } } }]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	s.Objects = append(s.Objects, l...)
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if act := img.RGBAAt(0, 0); act.G == 0 || act.R != 0 || act.B != 0 {
+		t.Fatalf("green square not rendered: %v", act)
+	}
+	if act := img.RGBAAt(19, 0); act.B == 0 || act.R != 0 || act.G != 0 {
+		t.Fatalf("blue square not rendered: %v", act)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// A TileOrder is an order in which tiles are dispatched to rendering
+// goroutines.
+type TileOrder int
+
+const (
+	// ScanlineOrder renders tiles left to right, top to bottom.
+	ScanlineOrder TileOrder = iota
+
+	// SpiralOrder renders tiles spiraling out from the image center, which
+	// is better for previewing.
+	SpiralOrder
+
+	// HilbertOrder renders tiles along a Hilbert curve so that consecutive
+	// tiles are neighbors, which improves cache coherence.
+	HilbertOrder
+)
+
+var tileOrderNames = [...]string{"scanline", "spiral", "hilbert"}
+
+func (o TileOrder) String() string {
+	if o < 0 || int(o) >= len(tileOrderNames) {
+		return fmt.Sprintf("TileOrder(%d)", int(o))
+	}
+	return tileOrderNames[o]
+}
+
+// ParseTileOrder converts the name of a tile order as returned by String back
+// to a TileOrder.
+func ParseTileOrder(name string) (TileOrder, error) {
+	for i, n := range tileOrderNames {
+		if n == name {
+			return TileOrder(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tile order: %q", name)
+}
+
+// makeTiles splits r into the rectangles processed by rendering goroutines.
+// When size is positive, r is split into square tiles of size pixels (smaller
+// along the right and bottom edges) in scanline order.  Otherwise, r is split
+// into nstripes horizontal stripes.
+func makeTiles(r image.Rectangle, nstripes, size int) []image.Rectangle {
+	var tiles []image.Rectangle
+	if size <= 0 {
+		slice := r.Dy() / nstripes
+		for n := 0; n < nstripes; n++ {
+			ystart := r.Min.Y + slice*n
+			yend := ystart + slice
+			if n == nstripes-1 {
+				yend = r.Max.Y
+			}
+			tiles = append(tiles, image.Rect(r.Min.X, ystart, r.Max.X, yend))
+		}
+		return tiles
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y += size {
+		for x := r.Min.X; x < r.Max.X; x += size {
+			tiles = append(tiles, image.Rect(x, y, x+size, y+size).Intersect(r))
+		}
+	}
+	return tiles
+}
+
+// orderTiles sorts tiles produced by makeTiles with the given size in place
+// according to order.
+func orderTiles(tiles []image.Rectangle, r image.Rectangle, size int, order TileOrder) {
+	if size <= 0 || order == ScanlineOrder {
+		return
+	}
+
+	cols := (r.Dx() + size - 1) / size
+	rows := (r.Dy() + size - 1) / size
+	keys := make(map[image.Point]float64, len(tiles))
+	for _, t := range tiles {
+		col := (t.Min.X - r.Min.X) / size
+		row := (t.Min.Y - r.Min.Y) / size
+		switch order {
+		case SpiralOrder:
+			keys[t.Min] = spiralKey(col, row, cols, rows)
+		case HilbertOrder:
+			keys[t.Min] = float64(hilbertIndex(col, row, cols, rows))
+		}
+	}
+	sort.SliceStable(tiles, func(i, j int) bool {
+		return keys[tiles[i].Min] < keys[tiles[j].Min]
+	})
+}
+
+// spiralKey returns a sort key ordering cell (col, row) of a cols x rows grid
+// by increasing square ring around the grid center then by angle in each
+// ring.
+func spiralKey(col, row, cols, rows int) float64 {
+	dx := float64(col) - float64(cols-1)/2
+	dy := float64(row) - float64(rows-1)/2
+	ring := math.Floor(math.Max(math.Abs(dx), math.Abs(dy)))
+	angle := math.Atan2(dy, dx) + math.Pi // in [0..2*Pi]
+	return ring*2*math.Pi*2 + angle
+}
+
+// hilbertIndex returns the distance of cell (col, row) along a Hilbert curve
+// covering a cols x rows grid.
+//
+// Algorithm taken from:
+//
+//	https://en.wikipedia.org/wiki/Hilbert_curve
+func hilbertIndex(col, row, cols, rows int) int {
+	n := 1
+	for n < cols || n < rows {
+		n *= 2
+	}
+	x, y, d := col, row, 0
+	for s := n / 2; s > 0; s /= 2 {
+		rx, ry := 0, 0
+		if x&s != 0 {
+			rx = 1
+		}
+		if y&s != 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+		// rotate quadrant
+		if ry == 0 {
+			if rx == 1 {
+				x = s - 1 - x
+				y = s - 1 - y
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
+
+// prioritize moves tiles overlapping focus before the others, preserving the
+// relative order inside each group.
+func prioritize(tiles []image.Rectangle, focus image.Rectangle) []image.Rectangle {
+	sorted := make([]image.Rectangle, 0, len(tiles))
+	for _, t := range tiles {
+		if t.Overlaps(focus) {
+			sorted = append(sorted, t)
+		}
+	}
+	for _, t := range tiles {
+		if !t.Overlaps(focus) {
+			sorted = append(sorted, t)
+		}
+	}
+	return sorted
+}
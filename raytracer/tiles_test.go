@@ -0,0 +1,127 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestMakeTiles(t *testing.T) {
+	r := image.Rect(0, 0, 10, 7)
+	for _, td := range []struct {
+		nstripes, size int
+		exp            []image.Rectangle
+	}{
+		{3, 0, []image.Rectangle{
+			image.Rect(0, 0, 10, 2), image.Rect(0, 2, 10, 4), image.Rect(0, 4, 10, 7)}},
+		{1, 6, []image.Rectangle{
+			image.Rect(0, 0, 6, 6), image.Rect(6, 0, 10, 6),
+			image.Rect(0, 6, 6, 7), image.Rect(6, 6, 10, 7)}},
+	} {
+		act := makeTiles(r, td.nstripes, td.size)
+		if len(act) != len(td.exp) {
+			t.Fatalf("exp: %v act: %v", td.exp, act)
+		}
+		for i := range act {
+			if act[i] != td.exp[i] {
+				t.Fatalf("exp: %v act: %v", td.exp, act)
+			}
+		}
+	}
+}
+
+func TestFocusRenderedFirst(t *testing.T) {
+	s := testScene()
+	ref, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	focus := image.Rect(12, 12, 16, 16)
+	var done []image.Rectangle
+	o := Options{
+		Stripes:  1,
+		TileSize: 4,
+		Focus:    focus,
+		TileDone: func(img *image.RGBA, r image.Rectangle) { done = append(done, r) },
+	}
+	img, err := s.RenderWithOptions(&o)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !bytes.Equal(img.Pix, ref.Pix) {
+		t.Fatalf("tiled image differs from reference")
+	}
+	if len(done) != 25 {
+		t.Fatalf("bad tile count: exp: %v act: %v", 25, len(done))
+	}
+	if done[0] != focus {
+		t.Fatalf("focus not rendered first: %v", done[0])
+	}
+}
+
+func TestTileOrders(t *testing.T) {
+	r := image.Rect(0, 0, 16, 16)
+	const size = 4
+	for _, order := range []TileOrder{ScanlineOrder, SpiralOrder, HilbertOrder} {
+		tiles := makeTiles(r, 1, size)
+		orderTiles(tiles, r, size, order)
+		seen := make(map[image.Rectangle]bool)
+		for _, tile := range tiles {
+			seen[tile] = true
+		}
+		if len(seen) != 16 {
+			t.Fatalf("%v: not a permutation: %v", order, tiles)
+		}
+
+		switch order {
+		case SpiralOrder:
+			center := image.Rect(4, 4, 12, 12)
+			for _, tile := range tiles[:4] {
+				if !tile.In(center) {
+					t.Fatalf("%v: center not first: %v", order, tiles)
+				}
+			}
+		case HilbertOrder:
+			for i := 1; i < len(tiles); i++ {
+				d := tiles[i].Min.Sub(tiles[i-1].Min)
+				if d.X*d.X+d.Y*d.Y != size*size {
+					t.Fatalf("%v: %v and %v not adjacent", order, tiles[i-1], tiles[i])
+				}
+			}
+		}
+	}
+}
+
+func TestParseTileOrder(t *testing.T) {
+	for _, order := range []TileOrder{ScanlineOrder, SpiralOrder, HilbertOrder} {
+		if act, err := ParseTileOrder(order.String()); err != nil || act != order {
+			t.Fatalf("exp: %v act: %v (%v)", order, act, err)
+		}
+	}
+	if _, err := ParseTileOrder("zigzag"); err == nil {
+		t.Fatalf("bad order accepted")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// ToonShader is a non-photorealistic shading model for illustrations.
+// Diffuse lighting, summed over lights, is quantized into Bands flat bands of
+// color, zero selecting 3, without highlights, reflections nor refractions.
+// Silhouettes, where surfaces turn away from the eye, are drawn in
+// EdgeColor.  Edge is the cosine of the angle between the normal and the
+// eye direction below which points lie on silhouettes, zero selecting 0.3
+// and negative values disabling silhouettes.
+type ToonShader struct {
+	Bands     int
+	Edge      float64
+	EdgeColor Color
+}
+
+func init() {
+	RegisterShaderType("Toon", func() Shader { return new(ToonShader) })
+}
+
+const (
+	defaultToonBands = 3
+	defaultToonEdge  = 0.3
+)
+
+func (ts *ToonShader) Validate() error {
+	if ts.Bands < 0 {
+		return fmt.Errorf("invalid toon shader: negative bands: %v", ts.Bands)
+	}
+	if ts.Edge >= 1 {
+		return fmt.Errorf("invalid toon shader: edge threshold out-of-range: %v", ts.Edge)
+	}
+	if err := ts.EdgeColor.Validate(); err != nil {
+		return fmt.Errorf("invalid toon shader: edge %v", err)
+	}
+	return nil
+}
+
+func (ts *ToonShader) Shade(h *Hit, s *Scene, r *TracedRay, depth int) Color {
+	normal, _ := facingNormal(h, &r.Ray, r.Time)
+	eye := r.Dir.Neg()
+	eye = eye.UnitVector()
+	edge := ts.Edge
+	if edge == 0 {
+		edge = defaultToonEdge
+	}
+	if geom.DotProduct(&normal, &eye) < edge {
+		return ts.EdgeColor
+	}
+
+	dot := 0.0
+	for i := range s.Lights {
+		l := &s.Lights[i]
+		if s.Lit(h, r, l) {
+			light, _ := l.toward(h.Point)
+			dot += l.emission(h.Point) * math.Max(0, geom.DotProduct(&light, &normal))
+		}
+	}
+	bands := ts.Bands
+	if bands == 0 {
+		bands = defaultToonBands
+	}
+	// Bands span from unlit to fully lit.
+	q := 1.0
+	if bands > 1 {
+		q = math.Min(math.Floor(dot*float64(bands)), float64(bands-1)) / float64(bands-1)
+	}
+
+	ka := s.ambientFactor(h.Object.MaterialAt(h))
+	col := h.Object.ColorAt(h)
+	return Color{
+		(ka.R + (1-ka.R)*q) * col.R,
+		(ka.G + (1-ka.G)*q) * col.G,
+		(ka.B + (1-ka.B)*q) * col.B,
+	}
+}
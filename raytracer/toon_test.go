@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+func TestToonShaderValidate(t *testing.T) {
+	data := []struct {
+		ts ToonShader
+		ok bool
+	}{
+		{ToonShader{}, true},
+		{ToonShader{Bands: 4, Edge: 0.5, EdgeColor: Color{0.1, 0.1, 0.1}}, true},
+		{ToonShader{Edge: -1}, true},
+		{ToonShader{Bands: -1}, false},
+		{ToonShader{Edge: 1}, false},
+		{ToonShader{EdgeColor: Color{2, 0, 0}}, false},
+	}
+	for i, d := range data {
+		if err := d.ts.Validate(); (err == nil) != d.ok {
+			t.Errorf("#%d: exp: %v act: %v", i, d.ok, err)
+		}
+	}
+}
+
+func TestToonShader(t *testing.T) {
+	s := testScene()
+	center := geom.Vector{0, 0, 1}
+	rim := geom.Vector{9.9, 0, 80}
+	data := []struct {
+		ts  ToonShader
+		dir geom.Vector
+		exp Color
+	}{
+		// Light at ~56% of full intensity at the center of the sphere.
+		{ToonShader{}, center, Color{0.55, 0, 0}},
+		{ToonShader{Bands: 1}, center, Color{1, 0, 0}},
+		{ToonShader{Bands: 2}, center, Color{1, 0, 0}},
+		{ToonShader{Bands: 4}, center, Color{0.7, 0, 0}},
+		{ToonShader{EdgeColor: Color{0, 0, 1}}, rim, Color{0, 0, 1}},
+		{ToonShader{Edge: -1}, rim, Color{0.1, 0, 0}},
+	}
+	for i, d := range data {
+		r := &TracedRay{Ray: geom.MakeRay(geom.Point{}, d.dir.UnitVector())}
+		h, ok := s.Objects[0].Intersect(r.Ray, 0)
+		if !ok {
+			t.Fatalf("#%d: missed sphere", i)
+		}
+		if act := d.ts.Shade(&h, s, r, 1); !colorsEqual(act, d.exp) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+
+	// Shadowed points are shaded with the ambient term only.
+	s.Objects = append(s.Objects, &Sphere{Sphere: geom.Sphere{geom.Point{-88, 27, 9}, 8}})
+	r := &TracedRay{Ray: geom.MakeRay(geom.Point{}, center)}
+	h, _ := s.Objects[0].Intersect(r.Ray, 0)
+	ts := ToonShader{}
+	if exp, act := (Color{0.1, 0, 0}), ts.Shade(&h, s, r, 1); !colorsEqual(act, exp) {
+		t.Errorf("shadowed: exp: %v act: %v", exp, act)
+	}
+}
+
+func TestToonShaderJSON(t *testing.T) {
+	var s Scene
+	if err := s.UnmarshalJSON([]byte(`{"Shader": {"Type": "Toon", "Bands": 4, "Edge": 0.5}}`)); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	ts, ok := s.Shader.(*ToonShader)
+	if !ok {
+		t.Fatalf("exp: *ToonShader act: %T", s.Shader)
+	}
+	if exp, act := (ToonShader{Bands: 4, Edge: 0.5}), *ts; act != exp {
+		t.Errorf("exp: %v act: %v", exp, act)
+	}
+	if err := s.UnmarshalJSON([]byte(`{"Shader": {"Type": "Sketch"}}`)); err == nil {
+		t.Errorf("unknown shader type accepted")
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// Torus objects are tori.
+type Torus struct {
+	Torus    geom.Torus
+	Color    Color
+	Material *Material
+	Visibility
+}
+
+func init() {
+	RegisterObjectType("Torus", func() Object { return new(Torus) })
+}
+
+func (to *Torus) Intersect(ray geom.Ray, time float64) (Hit, bool) {
+	p, t, ok := geom.RayTorusIntersection(to.Torus, ray)
+	if !ok {
+		return Hit{}, false
+	}
+	return Hit{T: t, Point: p, Object: to}, true
+}
+
+func (to *Torus) NormalAt(h *Hit, time float64) geom.Vector {
+	return to.Torus.NormalVectorAt(&h.Point)
+}
+
+func (to *Torus) Bounds() geom.AABB {
+	g := &to.Torus
+	a := g.Axis.UnitVector()
+	e := geom.Vector{
+		g.Major*math.Sqrt(math.Max(0, 1-a.X*a.X)) + g.Minor,
+		g.Major*math.Sqrt(math.Max(0, 1-a.Y*a.Y)) + g.Minor,
+		g.Major*math.Sqrt(math.Max(0, 1-a.Z*a.Z)) + g.Minor,
+	}
+	return geom.AABB{g.Center.Translate(e.Neg()), g.Center.Translate(e)}
+}
+
+func (to *Torus) Inside(p geom.Point, time float64) bool {
+	return to.Torus.Contains(&p)
+}
+
+func (to *Torus) ColorAt(h *Hit) Color {
+	return to.Color
+}
+
+func (to *Torus) MaterialAt(h *Hit) *Material {
+	return to.Material
+}
+
+func (to *Torus) Clone() Object {
+	c := *to
+	c.Material = to.Material.clone()
+	return &c
+}
+
+func (to *Torus) Validate() error {
+	if err := to.Torus.Validate(); err != nil {
+		return err
+	}
+	if err := to.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid torus: %v", err)
+	}
+	if to.Material != nil {
+		if err := to.Material.Validate(); err != nil {
+			return fmt.Errorf("invalid torus: %v", err)
+		}
+		if err := to.Material.checkUntextured(); err != nil {
+			return fmt.Errorf("invalid torus: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"github.com/nthery/goraytracer/geom"
+	"testing"
+)
+
+func TestTorusBounds(t *testing.T) {
+	to := &Torus{Torus: geom.Torus{geom.Point{0, 0, 10}, geom.Vector{0, 1, 0}, 3, 1}}
+	exp := geom.AABB{geom.Point{-4, -1, 6}, geom.Point{4, 1, 14}}
+	b := to.Bounds()
+	if !geom.PointsEqual(b.Min, exp.Min, 1e-9) || !geom.PointsEqual(b.Max, exp.Max, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, b)
+	}
+}
+
+func TestTorusHole(t *testing.T) {
+	s := testScene()
+	// Torus facing the eye in front of the sphere.  The sphere is visible
+	// through the hole.
+	s.Objects = append(s.Objects, &Torus{
+		Torus: geom.Torus{Center: geom.Point{0, 0, 60}, Axis: geom.Vector{0, 0, 1}, Major: 6, Minor: 2},
+		Color: Color{0, 1, 0},
+	})
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// Pixel (10, 10) looks along the axis and (14, 10) at the torus.
+	if act := img.RGBAAt(10, 10); act.R == 0 || act.G != act.B {
+		t.Fatalf("sphere not visible through hole: %v", act)
+	}
+	if act := img.RGBAAt(14, 10); act.G == 0 || act.R != act.B {
+		t.Fatalf("torus not rendered: %v", act)
+	}
+}
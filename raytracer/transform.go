@@ -0,0 +1,128 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"fmt"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+)
+
+// A Transform is an affine transform made of a scaling, followed by a rotation
+// and then a translation.
+type Transform struct {
+	Scale     geom.Vector // scaling factors along each axis, null for none
+	Axis      geom.Vector // rotation axis
+	Angle     float64     // counterclockwise rotation angle in degrees
+	Translate geom.Vector
+}
+
+func (tr *Transform) Validate() error {
+	if tr.Scale != (geom.Vector{}) && (tr.Scale.X == 0 || tr.Scale.Y == 0 || tr.Scale.Z == 0) {
+		return fmt.Errorf("invalid transform: null scaling factor: %v", tr.Scale)
+	}
+	if tr.Angle != 0 && tr.Axis.Module() == 0 {
+		return fmt.Errorf("invalid transform: null rotation axis")
+	}
+	return nil
+}
+
+// Matrix returns the matrix of tr.
+func (tr *Transform) Matrix() geom.Mat4 {
+	m := geom.Translate(tr.Translate)
+	if tr.Angle != 0 {
+		r := geom.Rotate(tr.Axis, tr.Angle*math.Pi/180)
+		m = m.Mul(&r)
+	}
+	if tr.Scale != (geom.Vector{}) {
+		s := geom.Scale(tr.Scale.X, tr.Scale.Y, tr.Scale.Z)
+		m = m.Mul(&s)
+	}
+	return m
+}
+
+// rotation returns the rotation of tr as a unit quaternion.
+func (tr *Transform) rotation() geom.Quaternion {
+	if tr.Angle == 0 {
+		return geom.Quaternion{W: 1}
+	}
+	return geom.QuaternionFromAxisAngle(tr.Axis, tr.Angle*math.Pi/180)
+}
+
+// scale returns the scaling factors of tr.
+func (tr *Transform) scale() geom.Vector {
+	if tr.Scale == (geom.Vector{}) {
+		return geom.Vector{1, 1, 1}
+	}
+	return tr.Scale
+}
+
+// lerpTransform returns the matrix of the transform at time t in [0..1]
+// moving from a at t == 0 to b at t == 1.  Scaling factors and translations
+// are interpolated linearly and rotations along the shortest arc, so that
+// objects turn at constant speed.
+func lerpTransform(a, b *Transform, t float64) geom.Mat4 {
+	lerp := func(u, v geom.Vector) geom.Vector {
+		return geom.Vector{u.X + t*(v.X-u.X), u.Y + t*(v.Y-u.Y), u.Z + t*(v.Z-u.Z)}
+	}
+	m := geom.Translate(lerp(a.Translate, b.Translate))
+	q := geom.Slerp(a.rotation(), b.rotation(), t)
+	r := q.Mat4()
+	m = m.Mul(&r)
+	sc := lerp(a.scale(), b.scale())
+	s := geom.Scale(sc.X, sc.Y, sc.Z)
+	return m.Mul(&s)
+}
+
+// transformBounds returns a box enclosing b transformed by m.  Unbounded boxes
+// remain unbounded.
+func transformBounds(m *geom.Mat4, b geom.AABB) geom.AABB {
+	if b.Empty() {
+		return b
+	}
+	if unbounded(b) {
+		inf := math.Inf(1)
+		return geom.AABB{geom.Point{-inf, -inf, -inf}, geom.Point{inf, inf, inf}}
+	}
+	r := geom.EmptyAABB()
+	for i := 0; i < 8; i++ {
+		c := b.Min
+		if i&1 != 0 {
+			c.X = b.Max.X
+		}
+		if i&2 != 0 {
+			c.Y = b.Max.Y
+		}
+		if i&4 != 0 {
+			c.Z = b.Max.Z
+		}
+		r = r.Expand(m.TransformPoint(c))
+	}
+	return r
+}
+
+// unbounded returns whether b extends infinitely along some axis.
+func unbounded(b geom.AABB) bool {
+	return math.IsInf(b.Min.X, 0) || math.IsInf(b.Min.Y, 0) || math.IsInf(b.Min.Z, 0) ||
+		math.IsInf(b.Max.X, 0) || math.IsInf(b.Max.Y, 0) || math.IsInf(b.Max.Z, 0)
+}
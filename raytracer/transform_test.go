@@ -0,0 +1,163 @@
+/*
+Copyright (c) 2013 Nicolas Thery <nthery@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package raytracer
+
+import (
+	"encoding/json"
+	"github.com/nthery/goraytracer/geom"
+	"math"
+	"testing"
+)
+
+func TestTransformMatrix(t *testing.T) {
+	tr := Transform{
+		Scale:     geom.Vector{2, 1, 1},
+		Axis:      geom.Vector{0, 0, 1},
+		Angle:     90,
+		Translate: geom.Vector{0, 0, 5},
+	}
+	m := tr.Matrix()
+	// Scaled to (2, 0, 0), rotated to (0, 2, 0) and translated.
+	if act, exp := m.TransformPoint(geom.Point{1, 0, 0}), (geom.Point{0, 2, 5}); !geom.PointsEqual(act, exp, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+
+	var id Transform
+	if act, exp := id.Matrix(), geom.Identity(); !geom.Mat4sEqual(act, exp, 1e-12) {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+
+	for _, bad := range []Transform{
+		{Scale: geom.Vector{1, 0, 1}},
+		{Angle: 10},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("invalid transform accepted: %v", bad)
+		}
+	}
+}
+
+func TestLerpTransform(t *testing.T) {
+	a := Transform{Translate: geom.Vector{1, 2, 3}}
+	b := Transform{Scale: geom.Vector{3, 3, 3}, Axis: geom.Vector{0, 0, 1}, Angle: 90, Translate: geom.Vector{5, 2, 3}}
+	// Halfway: scaled by 2, turned by 45 degrees.
+	mid := Transform{Scale: geom.Vector{2, 2, 2}, Axis: geom.Vector{0, 0, 1}, Angle: 45, Translate: geom.Vector{3, 2, 3}}
+	for i, d := range []struct {
+		t   float64
+		exp geom.Mat4
+	}{
+		{0, a.Matrix()},
+		{1, b.Matrix()},
+		{0.5, mid.Matrix()},
+	} {
+		if act := lerpTransform(&a, &b, d.t); !geom.Mat4sEqual(act, d.exp, 1e-9) {
+			t.Errorf("#%d: exp: %v act: %v", i, d.exp, act)
+		}
+	}
+}
+
+// ellipsoid returns an ellipsoid of semi-axes 2, 1 and 1 along x, y and z.
+func ellipsoid() *Sphere {
+	return &Sphere{
+		Sphere:    geom.Sphere{geom.Point{0, 0, 0}, 1},
+		Transform: &Transform{Scale: geom.Vector{2, 1, 1}},
+	}
+}
+
+func TestEllipsoidIntersect(t *testing.T) {
+	s := ellipsoid()
+	h, ok := s.Intersect(geom.MakeRay(geom.Point{-10, 0, 0}, geom.Vector{1, 0, 0}), 0)
+	if !ok {
+		t.Fatalf("ray missed ellipsoid")
+	}
+	if exp := (geom.Point{-2, 0, 0}); !geom.PointsEqual(h.Point, exp, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, h.Point)
+	}
+
+	// The normal is the gradient of x^2/4 + y^2 + z^2.
+	x := math.Sqrt2
+	h, ok = s.Intersect(geom.MakeRay(geom.Point{x, 10, 0}, geom.Vector{0, -1, 0}), 0)
+	if !ok {
+		t.Fatalf("ray missed ellipsoid")
+	}
+	if exp := (geom.Point{x, math.Sqrt(0.5), 0}); !geom.PointsEqual(h.Point, exp, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, h.Point)
+	}
+	exp := geom.Vector{x / 2, 2 * math.Sqrt(0.5), 0}
+	exp = exp.UnitVector()
+	if n := s.NormalAt(&h, 0); !geom.VectorsEqual(n, exp, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, n)
+	}
+
+	if _, ok := s.Intersect(geom.MakeRay(geom.Point{0, 1.5, -10}, geom.Vector{0, 0, 1}), 0); ok {
+		t.Fatalf("ray hit ellipsoid")
+	}
+	if !s.Inside(geom.Point{1.9, 0, 0}, 0) || s.Inside(geom.Point{0, 1.1, 0}, 0) {
+		t.Fatalf("bad inside test")
+	}
+}
+
+func TestEllipsoidBounds(t *testing.T) {
+	s := ellipsoid()
+	s.Transform.Axis = geom.Vector{0, 0, 1}
+	s.Transform.Angle = 90
+	s.Motion = geom.Vector{0, 0, 3}
+	exp := geom.AABB{geom.Point{-1, -2, -1}, geom.Point{1, 2, 4}}
+	b := s.Bounds()
+	if !geom.PointsEqual(b.Min, exp.Min, 1e-9) || !geom.PointsEqual(b.Max, exp.Max, 1e-9) {
+		t.Fatalf("exp: %v act: %v", exp, b)
+	}
+}
+
+func TestEllipsoidRender(t *testing.T) {
+	s := testScene()
+	var l ObjectList
+	// Flattened version of the test sphere, too thin to be seen from the
+	// corners of the image.
+	data := `[{ "Sphere": { "Center": {"X":0,"Y":0,"Z":0}, "Radius":1 },
+		"Transform": { "Scale": {"X":20,"Y":1,"Z":20}, "Translate": {"X":0,"Y":0,"Z":80} },
+		"Color": {"R":1, "G":0, "B":0} }]`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	ref, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	s.Objects = l
+	img, err := s.Render(1)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// Pixel (10, 7) sees the sphere but not the ellipsoid and pixel (1, 10)
+	// the ellipsoid but not the sphere.
+	if act, bg := ref.RGBAAt(10, 7), s.Bg.toRGBA(); act == bg {
+		t.Fatalf("sphere not rendered")
+	}
+	if act, exp := img.RGBAAt(10, 7), s.Bg.toRGBA(); act != exp {
+		t.Fatalf("exp: %v act: %v", exp, act)
+	}
+	if act := img.RGBAAt(1, 10); act.R == 0 || act.G != act.B {
+		t.Fatalf("ellipsoid not rendered: %v", act)
+	}
+}